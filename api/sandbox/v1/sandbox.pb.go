@@ -0,0 +1,1283 @@
+// Sandbox execution API for machine-to-machine callers. It serves the same
+// tools as MCP (run_code, upload_file, list_runners) with the same scopes,
+// conversation tenants and limits. Authenticate with an "authorization:
+// Bearer <token>" metadata entry.
+//
+// Regenerate the Go code with:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative api/sandbox/v1/sandbox.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v5.28.3
+// source: api/sandbox/v1/sandbox.proto
+
+package sandboxv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Network int32
+
+const (
+	Network_NETWORK_DISABLED   Network = 0
+	Network_NETWORK_ENABLED    Network = 1
+	Network_NETWORK_RESTRICTED Network = 2 // Allowlisted domains only, through the egress proxy
+)
+
+// Enum value maps for Network.
+var (
+	Network_name = map[int32]string{
+		0: "NETWORK_DISABLED",
+		1: "NETWORK_ENABLED",
+		2: "NETWORK_RESTRICTED",
+	}
+	Network_value = map[string]int32{
+		"NETWORK_DISABLED":   0,
+		"NETWORK_ENABLED":    1,
+		"NETWORK_RESTRICTED": 2,
+	}
+)
+
+func (x Network) Enum() *Network {
+	p := new(Network)
+	*p = x
+	return p
+}
+
+func (x Network) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Network) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_sandbox_v1_sandbox_proto_enumTypes[0].Descriptor()
+}
+
+func (Network) Type() protoreflect.EnumType {
+	return &file_api_sandbox_v1_sandbox_proto_enumTypes[0]
+}
+
+func (x Network) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Network.Descriptor instead.
+func (Network) EnumDescriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{0}
+}
+
+type Output_Stream int32
+
+const (
+	Output_STREAM_STDOUT Output_Stream = 0
+	Output_STREAM_STDERR Output_Stream = 1
+)
+
+// Enum value maps for Output_Stream.
+var (
+	Output_Stream_name = map[int32]string{
+		0: "STREAM_STDOUT",
+		1: "STREAM_STDERR",
+	}
+	Output_Stream_value = map[string]int32{
+		"STREAM_STDOUT": 0,
+		"STREAM_STDERR": 1,
+	}
+)
+
+func (x Output_Stream) Enum() *Output_Stream {
+	p := new(Output_Stream)
+	*p = x
+	return p
+}
+
+func (x Output_Stream) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Output_Stream) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_sandbox_v1_sandbox_proto_enumTypes[1].Descriptor()
+}
+
+func (Output_Stream) Type() protoreflect.EnumType {
+	return &file_api_sandbox_v1_sandbox_proto_enumTypes[1]
+}
+
+func (x Output_Stream) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Output_Stream.Descriptor instead.
+func (Output_Stream) EnumDescriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{2, 0}
+}
+
+type RunCodeRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	Language       string                 `protobuf:"bytes,2,opt,name=language,proto3" json:"language,omitempty"`
+	Version        string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"` // Defaults to the language's default runner
+	Code           string                 `protobuf:"bytes,4,opt,name=code,proto3" json:"code,omitempty"`
+	Packages       []string               `protobuf:"bytes,5,rep,name=packages,proto3" json:"packages,omitempty"` // Installed with network access before the code runs
+	Network        Network                `protobuf:"varint,6,opt,name=network,proto3,enum=sandbox.v1.Network" json:"network,omitempty"`
+	Environment    map[string]string      `protobuf:"bytes,7,rep,name=environment,proto3" json:"environment,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Secrets        []string               `protobuf:"bytes,8,rep,name=secrets,proto3" json:"secrets,omitempty"` // Names of registered secrets to inject
+	Stdin          string                 `protobuf:"bytes,9,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	Files          map[string]string      `protobuf:"bytes,10,rep,name=files,proto3" json:"files,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // A multi-file project written to /data first
+	Entrypoint     string                 `protobuf:"bytes,11,opt,name=entrypoint,proto3" json:"entrypoint,omitempty"`                                                                 // The file to run in place of code
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RunCodeRequest) Reset() {
+	*x = RunCodeRequest{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunCodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunCodeRequest) ProtoMessage() {}
+
+func (x *RunCodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunCodeRequest.ProtoReflect.Descriptor instead.
+func (*RunCodeRequest) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RunCodeRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *RunCodeRequest) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *RunCodeRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *RunCodeRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *RunCodeRequest) GetPackages() []string {
+	if x != nil {
+		return x.Packages
+	}
+	return nil
+}
+
+func (x *RunCodeRequest) GetNetwork() Network {
+	if x != nil {
+		return x.Network
+	}
+	return Network_NETWORK_DISABLED
+}
+
+func (x *RunCodeRequest) GetEnvironment() map[string]string {
+	if x != nil {
+		return x.Environment
+	}
+	return nil
+}
+
+func (x *RunCodeRequest) GetSecrets() []string {
+	if x != nil {
+		return x.Secrets
+	}
+	return nil
+}
+
+func (x *RunCodeRequest) GetStdin() string {
+	if x != nil {
+		return x.Stdin
+	}
+	return ""
+}
+
+func (x *RunCodeRequest) GetFiles() map[string]string {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *RunCodeRequest) GetEntrypoint() string {
+	if x != nil {
+		return x.Entrypoint
+	}
+	return ""
+}
+
+type RunCodeEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*RunCodeEvent_Output
+	//	*RunCodeEvent_Progress
+	//	*RunCodeEvent_Result
+	Event         isRunCodeEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunCodeEvent) Reset() {
+	*x = RunCodeEvent{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunCodeEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunCodeEvent) ProtoMessage() {}
+
+func (x *RunCodeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunCodeEvent.ProtoReflect.Descriptor instead.
+func (*RunCodeEvent) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RunCodeEvent) GetEvent() isRunCodeEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *RunCodeEvent) GetOutput() *Output {
+	if x != nil {
+		if x, ok := x.Event.(*RunCodeEvent_Output); ok {
+			return x.Output
+		}
+	}
+	return nil
+}
+
+func (x *RunCodeEvent) GetProgress() *Progress {
+	if x != nil {
+		if x, ok := x.Event.(*RunCodeEvent_Progress); ok {
+			return x.Progress
+		}
+	}
+	return nil
+}
+
+func (x *RunCodeEvent) GetResult() *RunCodeResult {
+	if x != nil {
+		if x, ok := x.Event.(*RunCodeEvent_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+type isRunCodeEvent_Event interface {
+	isRunCodeEvent_Event()
+}
+
+type RunCodeEvent_Output struct {
+	Output *Output `protobuf:"bytes,1,opt,name=output,proto3,oneof"`
+}
+
+type RunCodeEvent_Progress struct {
+	Progress *Progress `protobuf:"bytes,2,opt,name=progress,proto3,oneof"`
+}
+
+type RunCodeEvent_Result struct {
+	Result *RunCodeResult `protobuf:"bytes,3,opt,name=result,proto3,oneof"`
+}
+
+func (*RunCodeEvent_Output) isRunCodeEvent_Event() {}
+
+func (*RunCodeEvent_Progress) isRunCodeEvent_Event() {}
+
+func (*RunCodeEvent_Result) isRunCodeEvent_Event() {}
+
+// Output is a chunk of the program's output as it is written. Chunks past
+// the output limit aren't sent, and sealed conversations get none.
+type Output struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stream        Output_Stream          `protobuf:"varint,1,opt,name=stream,proto3,enum=sandbox.v1.Output_Stream" json:"stream,omitempty"`
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Output) Reset() {
+	*x = Output{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Output) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Output) ProtoMessage() {}
+
+func (x *Output) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Output.ProtoReflect.Descriptor instead.
+func (*Output) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Output) GetStream() Output_Stream {
+	if x != nil {
+		return x.Stream
+	}
+	return Output_STREAM_STDOUT
+}
+
+func (x *Output) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type Progress struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ElapsedMs       int64                  `protobuf:"varint,1,opt,name=elapsed_ms,json=elapsedMs,proto3" json:"elapsed_ms,omitempty"`
+	StdoutBytes     int64                  `protobuf:"varint,2,opt,name=stdout_bytes,json=stdoutBytes,proto3" json:"stdout_bytes,omitempty"`
+	StderrBytes     int64                  `protobuf:"varint,3,opt,name=stderr_bytes,json=stderrBytes,proto3" json:"stderr_bytes,omitempty"`
+	Queued          bool                   `protobuf:"varint,4,opt,name=queued,proto3" json:"queued,omitempty"` // Waiting for a concurrency slot
+	QueuePosition   int32                  `protobuf:"varint,5,opt,name=queue_position,json=queuePosition,proto3" json:"queue_position,omitempty"`
+	EstimatedWaitMs int64                  `protobuf:"varint,6,opt,name=estimated_wait_ms,json=estimatedWaitMs,proto3" json:"estimated_wait_ms,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Progress) GetElapsedMs() int64 {
+	if x != nil {
+		return x.ElapsedMs
+	}
+	return 0
+}
+
+func (x *Progress) GetStdoutBytes() int64 {
+	if x != nil {
+		return x.StdoutBytes
+	}
+	return 0
+}
+
+func (x *Progress) GetStderrBytes() int64 {
+	if x != nil {
+		return x.StderrBytes
+	}
+	return 0
+}
+
+func (x *Progress) GetQueued() bool {
+	if x != nil {
+		return x.Queued
+	}
+	return false
+}
+
+func (x *Progress) GetQueuePosition() int32 {
+	if x != nil {
+		return x.QueuePosition
+	}
+	return 0
+}
+
+func (x *Progress) GetEstimatedWaitMs() int64 {
+	if x != nil {
+		return x.EstimatedWaitMs
+	}
+	return 0
+}
+
+type RunCodeResult struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Success         bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Stdout          string                 `protobuf:"bytes,2,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr          string                 `protobuf:"bytes,3,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	ExitCode        int32                  `protobuf:"varint,4,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	OomKilled       bool                   `protobuf:"varint,5,opt,name=oom_killed,json=oomKilled,proto3" json:"oom_killed,omitempty"`
+	Files           []*File                `protobuf:"bytes,6,rep,name=files,proto3" json:"files,omitempty"`
+	StdoutBytes     int64                  `protobuf:"varint,7,opt,name=stdout_bytes,json=stdoutBytes,proto3" json:"stdout_bytes,omitempty"`
+	StderrBytes     int64                  `protobuf:"varint,8,opt,name=stderr_bytes,json=stderrBytes,proto3" json:"stderr_bytes,omitempty"`
+	Truncated       bool                   `protobuf:"varint,9,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	StdoutNextToken string                 `protobuf:"bytes,10,opt,name=stdout_next_token,json=stdoutNextToken,proto3" json:"stdout_next_token,omitempty"` // For the read_output MCP tool
+	PreviewUrl      string                 `protobuf:"bytes,11,opt,name=preview_url,json=previewUrl,proto3" json:"preview_url,omitempty"`
+	Error           *ToolError             `protobuf:"bytes,12,opt,name=error,proto3" json:"error,omitempty"`   // Set when the code could not be run at all
+	Sealed          string                 `protobuf:"bytes,13,opt,name=sealed,proto3" json:"sealed,omitempty"` // Base64 sealed output when the conversation has a result key; stdout and stderr are then empty
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RunCodeResult) Reset() {
+	*x = RunCodeResult{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunCodeResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunCodeResult) ProtoMessage() {}
+
+func (x *RunCodeResult) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunCodeResult.ProtoReflect.Descriptor instead.
+func (*RunCodeResult) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RunCodeResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *RunCodeResult) GetStdout() string {
+	if x != nil {
+		return x.Stdout
+	}
+	return ""
+}
+
+func (x *RunCodeResult) GetStderr() string {
+	if x != nil {
+		return x.Stderr
+	}
+	return ""
+}
+
+func (x *RunCodeResult) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *RunCodeResult) GetOomKilled() bool {
+	if x != nil {
+		return x.OomKilled
+	}
+	return false
+}
+
+func (x *RunCodeResult) GetFiles() []*File {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *RunCodeResult) GetStdoutBytes() int64 {
+	if x != nil {
+		return x.StdoutBytes
+	}
+	return 0
+}
+
+func (x *RunCodeResult) GetStderrBytes() int64 {
+	if x != nil {
+		return x.StderrBytes
+	}
+	return 0
+}
+
+func (x *RunCodeResult) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *RunCodeResult) GetStdoutNextToken() string {
+	if x != nil {
+		return x.StdoutNextToken
+	}
+	return ""
+}
+
+func (x *RunCodeResult) GetPreviewUrl() string {
+	if x != nil {
+		return x.PreviewUrl
+	}
+	return ""
+}
+
+func (x *RunCodeResult) GetError() *ToolError {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+func (x *RunCodeResult) GetSealed() string {
+	if x != nil {
+		return x.Sealed
+	}
+	return ""
+}
+
+// ToolError is a tool failure, with the same codes as MCP tool errors.
+type ToolError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolError) Reset() {
+	*x = ToolError{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolError) ProtoMessage() {}
+
+func (x *ToolError) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolError.ProtoReflect.Descriptor instead.
+func (*ToolError) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ToolError) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ToolError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type File struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *File) Reset() {
+	*x = File{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *File) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*File) ProtoMessage() {}
+
+func (x *File) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use File.ProtoReflect.Descriptor instead.
+func (*File) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *File) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *File) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type UploadFileRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Part:
+	//
+	//	*UploadFileRequest_Header
+	//	*UploadFileRequest_Chunk
+	Part          isUploadFileRequest_Part `protobuf_oneof:"part"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadFileRequest) Reset() {
+	*x = UploadFileRequest{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadFileRequest) ProtoMessage() {}
+
+func (x *UploadFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadFileRequest.ProtoReflect.Descriptor instead.
+func (*UploadFileRequest) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UploadFileRequest) GetPart() isUploadFileRequest_Part {
+	if x != nil {
+		return x.Part
+	}
+	return nil
+}
+
+func (x *UploadFileRequest) GetHeader() *UploadFileHeader {
+	if x != nil {
+		if x, ok := x.Part.(*UploadFileRequest_Header); ok {
+			return x.Header
+		}
+	}
+	return nil
+}
+
+func (x *UploadFileRequest) GetChunk() []byte {
+	if x != nil {
+		if x, ok := x.Part.(*UploadFileRequest_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+type isUploadFileRequest_Part interface {
+	isUploadFileRequest_Part()
+}
+
+type UploadFileRequest_Header struct {
+	Header *UploadFileHeader `protobuf:"bytes,1,opt,name=header,proto3,oneof"`
+}
+
+type UploadFileRequest_Chunk struct {
+	Chunk []byte `protobuf:"bytes,2,opt,name=chunk,proto3,oneof"`
+}
+
+func (*UploadFileRequest_Header) isUploadFileRequest_Part() {}
+
+func (*UploadFileRequest_Chunk) isUploadFileRequest_Part() {}
+
+type UploadFileHeader struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	Filename       string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	Extract        bool                   `protobuf:"varint,3,opt,name=extract,proto3" json:"extract,omitempty"` // Unpack a zip/tar(.gz) archive instead of storing it
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *UploadFileHeader) Reset() {
+	*x = UploadFileHeader{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadFileHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadFileHeader) ProtoMessage() {}
+
+func (x *UploadFileHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadFileHeader.ProtoReflect.Descriptor instead.
+func (*UploadFileHeader) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UploadFileHeader) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *UploadFileHeader) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *UploadFileHeader) GetExtract() bool {
+	if x != nil {
+		return x.Extract
+	}
+	return false
+}
+
+type UploadFileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Files         []*File                `protobuf:"bytes,3,rep,name=files,proto3" json:"files,omitempty"` // The stored file, or the extracted ones
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadFileResponse) Reset() {
+	*x = UploadFileResponse{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadFileResponse) ProtoMessage() {}
+
+func (x *UploadFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadFileResponse.ProtoReflect.Descriptor instead.
+func (*UploadFileResponse) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *UploadFileResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *UploadFileResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *UploadFileResponse) GetFiles() []*File {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type ListRunnersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRunnersRequest) Reset() {
+	*x = ListRunnersRequest{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRunnersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRunnersRequest) ProtoMessage() {}
+
+func (x *ListRunnersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRunnersRequest.ProtoReflect.Descriptor instead.
+func (*ListRunnersRequest) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{10}
+}
+
+type ListRunnersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Runners       []*Runner              `protobuf:"bytes,1,rep,name=runners,proto3" json:"runners,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRunnersResponse) Reset() {
+	*x = ListRunnersResponse{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRunnersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRunnersResponse) ProtoMessage() {}
+
+func (x *ListRunnersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRunnersResponse.ProtoReflect.Descriptor instead.
+func (*ListRunnersResponse) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListRunnersResponse) GetRunners() []*Runner {
+	if x != nil {
+		return x.Runners
+	}
+	return nil
+}
+
+type Runner struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Language      string                 `protobuf:"bytes,1,opt,name=language,proto3" json:"language,omitempty"`
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Default       bool                   `protobuf:"varint,3,opt,name=default,proto3" json:"default,omitempty"`
+	Image         string                 `protobuf:"bytes,4,opt,name=image,proto3" json:"image,omitempty"`
+	Description   string                 `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Runner) Reset() {
+	*x = Runner{}
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Runner) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Runner) ProtoMessage() {}
+
+func (x *Runner) ProtoReflect() protoreflect.Message {
+	mi := &file_api_sandbox_v1_sandbox_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Runner.ProtoReflect.Descriptor instead.
+func (*Runner) Descriptor() ([]byte, []int) {
+	return file_api_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Runner) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *Runner) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Runner) GetDefault() bool {
+	if x != nil {
+		return x.Default
+	}
+	return false
+}
+
+func (x *Runner) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *Runner) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+var File_api_sandbox_v1_sandbox_proto protoreflect.FileDescriptor
+
+const file_api_sandbox_v1_sandbox_proto_rawDesc = "" +
+	"\n" +
+	"\x1capi/sandbox/v1/sandbox.proto\x12\n" +
+	"sandbox.v1\"\xa4\x04\n" +
+	"\x0eRunCodeRequest\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\x12\x1a\n" +
+	"\blanguage\x18\x02 \x01(\tR\blanguage\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x12\x12\n" +
+	"\x04code\x18\x04 \x01(\tR\x04code\x12\x1a\n" +
+	"\bpackages\x18\x05 \x03(\tR\bpackages\x12-\n" +
+	"\anetwork\x18\x06 \x01(\x0e2\x13.sandbox.v1.NetworkR\anetwork\x12M\n" +
+	"\venvironment\x18\a \x03(\v2+.sandbox.v1.RunCodeRequest.EnvironmentEntryR\venvironment\x12\x18\n" +
+	"\asecrets\x18\b \x03(\tR\asecrets\x12\x14\n" +
+	"\x05stdin\x18\t \x01(\tR\x05stdin\x12;\n" +
+	"\x05files\x18\n" +
+	" \x03(\v2%.sandbox.v1.RunCodeRequest.FilesEntryR\x05files\x12\x1e\n" +
+	"\n" +
+	"entrypoint\x18\v \x01(\tR\n" +
+	"entrypoint\x1a>\n" +
+	"\x10EnvironmentEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a8\n" +
+	"\n" +
+	"FilesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xae\x01\n" +
+	"\fRunCodeEvent\x12,\n" +
+	"\x06output\x18\x01 \x01(\v2\x12.sandbox.v1.OutputH\x00R\x06output\x122\n" +
+	"\bprogress\x18\x02 \x01(\v2\x14.sandbox.v1.ProgressH\x00R\bprogress\x123\n" +
+	"\x06result\x18\x03 \x01(\v2\x19.sandbox.v1.RunCodeResultH\x00R\x06resultB\a\n" +
+	"\x05event\"\x7f\n" +
+	"\x06Output\x121\n" +
+	"\x06stream\x18\x01 \x01(\x0e2\x19.sandbox.v1.Output.StreamR\x06stream\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\".\n" +
+	"\x06Stream\x12\x11\n" +
+	"\rSTREAM_STDOUT\x10\x00\x12\x11\n" +
+	"\rSTREAM_STDERR\x10\x01\"\xda\x01\n" +
+	"\bProgress\x12\x1d\n" +
+	"\n" +
+	"elapsed_ms\x18\x01 \x01(\x03R\telapsedMs\x12!\n" +
+	"\fstdout_bytes\x18\x02 \x01(\x03R\vstdoutBytes\x12!\n" +
+	"\fstderr_bytes\x18\x03 \x01(\x03R\vstderrBytes\x12\x16\n" +
+	"\x06queued\x18\x04 \x01(\bR\x06queued\x12%\n" +
+	"\x0equeue_position\x18\x05 \x01(\x05R\rqueuePosition\x12*\n" +
+	"\x11estimated_wait_ms\x18\x06 \x01(\x03R\x0festimatedWaitMs\"\xb3\x03\n" +
+	"\rRunCodeResult\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x16\n" +
+	"\x06stdout\x18\x02 \x01(\tR\x06stdout\x12\x16\n" +
+	"\x06stderr\x18\x03 \x01(\tR\x06stderr\x12\x1b\n" +
+	"\texit_code\x18\x04 \x01(\x05R\bexitCode\x12\x1d\n" +
+	"\n" +
+	"oom_killed\x18\x05 \x01(\bR\toomKilled\x12&\n" +
+	"\x05files\x18\x06 \x03(\v2\x10.sandbox.v1.FileR\x05files\x12!\n" +
+	"\fstdout_bytes\x18\a \x01(\x03R\vstdoutBytes\x12!\n" +
+	"\fstderr_bytes\x18\b \x01(\x03R\vstderrBytes\x12\x1c\n" +
+	"\ttruncated\x18\t \x01(\bR\ttruncated\x12*\n" +
+	"\x11stdout_next_token\x18\n" +
+	" \x01(\tR\x0fstdoutNextToken\x12\x1f\n" +
+	"\vpreview_url\x18\v \x01(\tR\n" +
+	"previewUrl\x12+\n" +
+	"\x05error\x18\f \x01(\v2\x15.sandbox.v1.ToolErrorR\x05error\x12\x16\n" +
+	"\x06sealed\x18\r \x01(\tR\x06sealed\"9\n" +
+	"\tToolError\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\",\n" +
+	"\x04File\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\"k\n" +
+	"\x11UploadFileRequest\x126\n" +
+	"\x06header\x18\x01 \x01(\v2\x1c.sandbox.v1.UploadFileHeaderH\x00R\x06header\x12\x16\n" +
+	"\x05chunk\x18\x02 \x01(\fH\x00R\x05chunkB\x06\n" +
+	"\x04part\"q\n" +
+	"\x10UploadFileHeader\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12\x18\n" +
+	"\aextract\x18\x03 \x01(\bR\aextract\"p\n" +
+	"\x12UploadFileResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12&\n" +
+	"\x05files\x18\x03 \x03(\v2\x10.sandbox.v1.FileR\x05files\"\x14\n" +
+	"\x12ListRunnersRequest\"C\n" +
+	"\x13ListRunnersResponse\x12,\n" +
+	"\arunners\x18\x01 \x03(\v2\x12.sandbox.v1.RunnerR\arunners\"\x90\x01\n" +
+	"\x06Runner\x12\x1a\n" +
+	"\blanguage\x18\x01 \x01(\tR\blanguage\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12\x18\n" +
+	"\adefault\x18\x03 \x01(\bR\adefault\x12\x14\n" +
+	"\x05image\x18\x04 \x01(\tR\x05image\x12 \n" +
+	"\vdescription\x18\x05 \x01(\tR\vdescription*L\n" +
+	"\aNetwork\x12\x14\n" +
+	"\x10NETWORK_DISABLED\x10\x00\x12\x13\n" +
+	"\x0fNETWORK_ENABLED\x10\x01\x12\x16\n" +
+	"\x12NETWORK_RESTRICTED\x10\x022\xeb\x01\n" +
+	"\aSandbox\x12A\n" +
+	"\aRunCode\x12\x1a.sandbox.v1.RunCodeRequest\x1a\x18.sandbox.v1.RunCodeEvent0\x01\x12M\n" +
+	"\n" +
+	"UploadFile\x12\x1d.sandbox.v1.UploadFileRequest\x1a\x1e.sandbox.v1.UploadFileResponse(\x01\x12N\n" +
+	"\vListRunners\x12\x1e.sandbox.v1.ListRunnersRequest\x1a\x1f.sandbox.v1.ListRunnersResponseB:Z8github.com/jsc/mcp-code-sandbox/api/sandbox/v1;sandboxv1b\x06proto3"
+
+var (
+	file_api_sandbox_v1_sandbox_proto_rawDescOnce sync.Once
+	file_api_sandbox_v1_sandbox_proto_rawDescData []byte
+)
+
+func file_api_sandbox_v1_sandbox_proto_rawDescGZIP() []byte {
+	file_api_sandbox_v1_sandbox_proto_rawDescOnce.Do(func() {
+		file_api_sandbox_v1_sandbox_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_sandbox_v1_sandbox_proto_rawDesc), len(file_api_sandbox_v1_sandbox_proto_rawDesc)))
+	})
+	return file_api_sandbox_v1_sandbox_proto_rawDescData
+}
+
+var file_api_sandbox_v1_sandbox_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_api_sandbox_v1_sandbox_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_api_sandbox_v1_sandbox_proto_goTypes = []any{
+	(Network)(0),                // 0: sandbox.v1.Network
+	(Output_Stream)(0),          // 1: sandbox.v1.Output.Stream
+	(*RunCodeRequest)(nil),      // 2: sandbox.v1.RunCodeRequest
+	(*RunCodeEvent)(nil),        // 3: sandbox.v1.RunCodeEvent
+	(*Output)(nil),              // 4: sandbox.v1.Output
+	(*Progress)(nil),            // 5: sandbox.v1.Progress
+	(*RunCodeResult)(nil),       // 6: sandbox.v1.RunCodeResult
+	(*ToolError)(nil),           // 7: sandbox.v1.ToolError
+	(*File)(nil),                // 8: sandbox.v1.File
+	(*UploadFileRequest)(nil),   // 9: sandbox.v1.UploadFileRequest
+	(*UploadFileHeader)(nil),    // 10: sandbox.v1.UploadFileHeader
+	(*UploadFileResponse)(nil),  // 11: sandbox.v1.UploadFileResponse
+	(*ListRunnersRequest)(nil),  // 12: sandbox.v1.ListRunnersRequest
+	(*ListRunnersResponse)(nil), // 13: sandbox.v1.ListRunnersResponse
+	(*Runner)(nil),              // 14: sandbox.v1.Runner
+	nil,                         // 15: sandbox.v1.RunCodeRequest.EnvironmentEntry
+	nil,                         // 16: sandbox.v1.RunCodeRequest.FilesEntry
+}
+var file_api_sandbox_v1_sandbox_proto_depIdxs = []int32{
+	0,  // 0: sandbox.v1.RunCodeRequest.network:type_name -> sandbox.v1.Network
+	15, // 1: sandbox.v1.RunCodeRequest.environment:type_name -> sandbox.v1.RunCodeRequest.EnvironmentEntry
+	16, // 2: sandbox.v1.RunCodeRequest.files:type_name -> sandbox.v1.RunCodeRequest.FilesEntry
+	4,  // 3: sandbox.v1.RunCodeEvent.output:type_name -> sandbox.v1.Output
+	5,  // 4: sandbox.v1.RunCodeEvent.progress:type_name -> sandbox.v1.Progress
+	6,  // 5: sandbox.v1.RunCodeEvent.result:type_name -> sandbox.v1.RunCodeResult
+	1,  // 6: sandbox.v1.Output.stream:type_name -> sandbox.v1.Output.Stream
+	8,  // 7: sandbox.v1.RunCodeResult.files:type_name -> sandbox.v1.File
+	7,  // 8: sandbox.v1.RunCodeResult.error:type_name -> sandbox.v1.ToolError
+	10, // 9: sandbox.v1.UploadFileRequest.header:type_name -> sandbox.v1.UploadFileHeader
+	8,  // 10: sandbox.v1.UploadFileResponse.files:type_name -> sandbox.v1.File
+	14, // 11: sandbox.v1.ListRunnersResponse.runners:type_name -> sandbox.v1.Runner
+	2,  // 12: sandbox.v1.Sandbox.RunCode:input_type -> sandbox.v1.RunCodeRequest
+	9,  // 13: sandbox.v1.Sandbox.UploadFile:input_type -> sandbox.v1.UploadFileRequest
+	12, // 14: sandbox.v1.Sandbox.ListRunners:input_type -> sandbox.v1.ListRunnersRequest
+	3,  // 15: sandbox.v1.Sandbox.RunCode:output_type -> sandbox.v1.RunCodeEvent
+	11, // 16: sandbox.v1.Sandbox.UploadFile:output_type -> sandbox.v1.UploadFileResponse
+	13, // 17: sandbox.v1.Sandbox.ListRunners:output_type -> sandbox.v1.ListRunnersResponse
+	15, // [15:18] is the sub-list for method output_type
+	12, // [12:15] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_api_sandbox_v1_sandbox_proto_init() }
+func file_api_sandbox_v1_sandbox_proto_init() {
+	if File_api_sandbox_v1_sandbox_proto != nil {
+		return
+	}
+	file_api_sandbox_v1_sandbox_proto_msgTypes[1].OneofWrappers = []any{
+		(*RunCodeEvent_Output)(nil),
+		(*RunCodeEvent_Progress)(nil),
+		(*RunCodeEvent_Result)(nil),
+	}
+	file_api_sandbox_v1_sandbox_proto_msgTypes[7].OneofWrappers = []any{
+		(*UploadFileRequest_Header)(nil),
+		(*UploadFileRequest_Chunk)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_sandbox_v1_sandbox_proto_rawDesc), len(file_api_sandbox_v1_sandbox_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_sandbox_v1_sandbox_proto_goTypes,
+		DependencyIndexes: file_api_sandbox_v1_sandbox_proto_depIdxs,
+		EnumInfos:         file_api_sandbox_v1_sandbox_proto_enumTypes,
+		MessageInfos:      file_api_sandbox_v1_sandbox_proto_msgTypes,
+	}.Build()
+	File_api_sandbox_v1_sandbox_proto = out.File
+	file_api_sandbox_v1_sandbox_proto_goTypes = nil
+	file_api_sandbox_v1_sandbox_proto_depIdxs = nil
+}
@@ -12,6 +12,10 @@ echo ""
 echo "Building TypeScript runner image (Bun)..."
 docker build -f Dockerfile-typescript -t runner-typescript .
 
+echo ""
+echo "Building browser runner image (Playwright/Chromium)..."
+docker build -f Dockerfile-browser -t runner-browser .
+
 echo ""
 echo "Building server Docker image..."
 docker build -f Dockerfile -t mcp-sandbox-server .
@@ -21,6 +25,7 @@ echo ""
 echo "Building Go server binary..."
 mkdir -p bin
 go build -o bin/mcp-sandbox-server ./cmd/server
+go build -o bin/sandboxctl ./cmd/sandboxctl
 
 echo ""
 echo "Build complete!"
@@ -28,9 +33,11 @@ echo ""
 echo "Docker images:"
 echo "  - runner-python (Python 3.11 + numpy, pandas, requests)"
 echo "  - runner-typescript (Bun runtime - fast TypeScript/JavaScript)"
+echo "  - runner-browser (Playwright + Chromium for render_page)"
 echo "  - mcp-sandbox-server"
 echo ""
 echo "Server binary: bin/mcp-sandbox-server"
+echo "CLI client: bin/sandboxctl"
 echo ""
 echo "To run with Docker Compose:"
 echo "  docker-compose up -d                    # Local development"
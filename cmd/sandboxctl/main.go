@@ -0,0 +1,431 @@
+// sandboxctl is a command-line client for the sandbox's gRPC API: it runs
+// local files, uploads and downloads files and lists runners, which is handy
+// for testing runner images and for demos
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	sandboxv1 "github.com/jsc/mcp-code-sandbox/api/sandbox/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// uploadChunkSize is the content size of each UploadFile message
+const uploadChunkSize = 256 << 10
+
+// exitError makes main exit with a code without printing anything more
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := rootCommand().ExecuteContext(ctx); err != nil {
+		var code exitError
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// connection holds the global flags for reaching the server
+type connection struct {
+	addr  string
+	token string
+	tls   bool
+}
+
+// client dials the server and returns a context carrying the token
+func (c *connection) client(ctx context.Context) (sandboxv1.SandboxClient, context.Context, func(), error) {
+	creds := insecure.NewCredentials()
+	if c.tls {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	conn, err := grpc.NewClient(c.addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to connect to %s: %w", c.addr, err)
+	}
+	if c.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+	}
+	return sandboxv1.NewSandboxClient(conn), ctx, func() { conn.Close() }, nil
+}
+
+func rootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "sandboxctl",
+		Short:         "Command-line client for the code sandbox's gRPC API",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	conn := &connection{}
+	flags := root.PersistentFlags()
+	flags.StringVar(&conn.addr, "addr", envOr("SANDBOXCTL_ADDR", "localhost:9090"), "gRPC API address (GRPC_ADDR on the server)")
+	flags.StringVar(&conn.token, "token", envOr("SANDBOXCTL_TOKEN", os.Getenv("MCP_API_TOKEN")), "API token (default $SANDBOXCTL_TOKEN, then $MCP_API_TOKEN)")
+	flags.BoolVar(&conn.tls, "tls", os.Getenv("SANDBOXCTL_TLS") == "true", "connect with TLS")
+
+	root.AddCommand(runCommand(conn), uploadCommand(conn), downloadCommand(), runnersCommand(conn))
+	return root
+}
+
+func runCommand(conn *connection) *cobra.Command {
+	var (
+		req       sandboxv1.RunCodeRequest
+		network   string
+		env       []string
+		stdinFile string
+		jsonOut   bool
+		quiet     bool
+	)
+	cmd := &cobra.Command{
+		Use:   "run --lang LANGUAGE FILE",
+		Short: "Run a local file in the sandbox, streaming its output",
+		Long: `Run a local file in the sandbox, streaming its output as it is written.
+FILE "-" reads the code from standard input. sandboxctl exits with the
+program's exit code.`,
+		Example: "  sandboxctl run --lang python script.py\n  echo 'print(1)' | sandboxctl run --lang python -",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			code, err := readInput(args[0])
+			if err != nil {
+				return err
+			}
+			req.Code = string(code)
+			switch network {
+			case "", "false":
+			case "true":
+				req.Network = sandboxv1.Network_NETWORK_ENABLED
+			case "restricted":
+				req.Network = sandboxv1.Network_NETWORK_RESTRICTED
+			default:
+				return fmt.Errorf("--network must be true, false or restricted")
+			}
+			if len(env) > 0 {
+				req.Environment = make(map[string]string, len(env))
+				for _, kv := range env {
+					key, value, ok := strings.Cut(kv, "=")
+					if !ok {
+						return fmt.Errorf("--env %q is not KEY=VALUE", kv)
+					}
+					req.Environment[key] = value
+				}
+			}
+			if stdinFile != "" {
+				stdin, err := readInput(stdinFile)
+				if err != nil {
+					return err
+				}
+				req.Stdin = string(stdin)
+			}
+
+			client, ctx, closeConn, err := conn.client(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer closeConn()
+			stream, err := client.RunCode(ctx, &req)
+			if err != nil {
+				return rpcError(err)
+			}
+
+			for {
+				event, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					return errors.New("the server ended the stream without a result")
+				}
+				if err != nil {
+					return rpcError(err)
+				}
+				switch e := event.Event.(type) {
+				case *sandboxv1.RunCodeEvent_Output:
+					if jsonOut {
+						continue
+					}
+					out := os.Stdout
+					if e.Output.Stream == sandboxv1.Output_STREAM_STDERR {
+						out = os.Stderr
+					}
+					out.Write(e.Output.Data)
+				case *sandboxv1.RunCodeEvent_Progress:
+					if e.Progress.Queued && !quiet {
+						fmt.Fprintf(os.Stderr, "sandboxctl: queued at position %d, about %s\n",
+							e.Progress.QueuePosition, time.Duration(e.Progress.EstimatedWaitMs)*time.Millisecond)
+					}
+				case *sandboxv1.RunCodeEvent_Result:
+					return finishRun(e.Result, jsonOut, quiet)
+				}
+			}
+		},
+	}
+	f := cmd.Flags()
+	f.StringVarP(&req.Language, "lang", "l", "", "runner language, e.g. python (required)")
+	f.StringVar(&req.Version, "version", "", "runner version (default: the language's default)")
+	f.StringVarP(&req.ConversationId, "conversation", "c", envOr("SANDBOXCTL_CONVERSATION", "sandboxctl"), "conversation whose sandbox to use")
+	f.StringArrayVarP(&req.Packages, "package", "p", nil, "package to install first (repeatable)")
+	f.StringVar(&network, "network", "false", "network access: true, false or restricted")
+	f.StringArrayVarP(&env, "env", "e", nil, "environment variable KEY=VALUE (repeatable)")
+	f.StringArrayVar(&req.Secrets, "secret", nil, "registered secret to inject (repeatable)")
+	f.StringVar(&stdinFile, "stdin", "", `file piped to the program's standard input ("-" for sandboxctl's own)`)
+	f.BoolVar(&jsonOut, "json", false, "print the result as JSON instead of streaming the output")
+	f.BoolVarP(&quiet, "quiet", "q", false, "don't report queueing or list the sandbox files")
+	cmd.MarkFlagRequired("lang")
+	return cmd
+}
+
+// finishRun reports a run's result and exits with the program's exit code
+func finishRun(result *sandboxv1.RunCodeResult, jsonOut, quiet bool) error {
+	if jsonOut {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "sandboxctl: %s (%s)\n", result.Error.Message, result.Error.Code)
+		} else if !result.Success && result.ExitCode == 0 && result.Stderr != "" {
+			// The code never ran (e.g. an unknown language); nothing was streamed
+			fmt.Fprintln(os.Stderr, result.Stderr)
+		}
+		if result.Truncated {
+			fmt.Fprintln(os.Stderr, "sandboxctl: output truncated at the server's output limit")
+		}
+		if result.OomKilled {
+			fmt.Fprintln(os.Stderr, "sandboxctl: killed for exceeding the memory limit")
+		}
+		if !quiet && len(result.Files) > 0 {
+			fmt.Fprintln(os.Stderr, "sandboxctl: files:")
+			for _, f := range result.Files {
+				fmt.Fprintf(os.Stderr, "  %s\t%s\n", f.Name, f.Url)
+			}
+		}
+	}
+
+	switch {
+	case result.ExitCode != 0:
+		return exitError(result.ExitCode)
+	case !result.Success:
+		return exitError(1)
+	}
+	return nil
+}
+
+func uploadCommand(conn *connection) *cobra.Command {
+	var (
+		conversation string
+		name         string
+		extract      bool
+	)
+	cmd := &cobra.Command{
+		Use:   "upload FILE...",
+		Short: "Upload files to a conversation's sandbox",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if name != "" && len(args) > 1 {
+				return errors.New("--name needs a single file")
+			}
+			client, ctx, closeConn, err := conn.client(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer closeConn()
+
+			for _, file := range args {
+				filename := name
+				if filename == "" {
+					filename = filepath.Base(file)
+				}
+				resp, err := upload(ctx, client, file, &sandboxv1.UploadFileHeader{
+					ConversationId: conversation,
+					Filename:       filename,
+					Extract:        extract,
+				})
+				if err != nil {
+					return err
+				}
+				if !resp.Success {
+					return fmt.Errorf("upload of %s failed: %s", file, resp.Message)
+				}
+				for _, f := range resp.Files {
+					fmt.Printf("%s\t%s\n", f.Name, f.Url)
+				}
+			}
+			return nil
+		},
+	}
+	f := cmd.Flags()
+	f.StringVarP(&conversation, "conversation", "c", envOr("SANDBOXCTL_CONVERSATION", "sandboxctl"), "conversation whose sandbox to upload to")
+	f.StringVar(&name, "name", "", "file name in the sandbox (default: the local file's name)")
+	f.BoolVar(&extract, "extract", false, "unpack a zip/tar(.gz) archive instead of storing it")
+	return cmd
+}
+
+// upload streams one file to UploadFile
+func upload(ctx context.Context, client sandboxv1.SandboxClient, file string, header *sandboxv1.UploadFileHeader) (*sandboxv1.UploadFileResponse, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	stream, err := client.UploadFile(ctx)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	if err := stream.Send(&sandboxv1.UploadFileRequest{Part: &sandboxv1.UploadFileRequest_Header{Header: header}}); err != nil {
+		return nil, rpcError(err)
+	}
+	buf := make([]byte, uploadChunkSize)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			chunk := &sandboxv1.UploadFileRequest{Part: &sandboxv1.UploadFileRequest_Chunk{Chunk: buf[:n]}}
+			if err := stream.Send(chunk); err != nil {
+				// The server gave up; CloseAndRecv returns why
+				break
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return resp, nil
+}
+
+func downloadCommand() *cobra.Command {
+	var outDir string
+	cmd := &cobra.Command{
+		Use:   "download URL...",
+		Short: "Download sandbox files by the URLs run and upload print",
+		Long: `Download sandbox files by the URLs run and upload print. File URLs
+need no token; the hashed directory in them grants access.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, url := range args {
+				target := filepath.Join(outDir, path.Base(url))
+				if err := download(cmd.Context(), url, target); err != nil {
+					return err
+				}
+				fmt.Println(target)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&outDir, "output-dir", "o", ".", "directory to save the files in")
+	return cmd
+}
+
+// download saves one file URL to target
+func download(ctx context.Context, url, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return out.Close()
+}
+
+func runnersCommand(conn *connection) *cobra.Command {
+	var jsonOut bool
+	cmd := &cobra.Command{
+		Use:   "runners",
+		Short: "List the available language runners",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, ctx, closeConn, err := conn.client(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer closeConn()
+			resp, err := client.ListRunners(ctx, &sandboxv1.ListRunnersRequest{})
+			if err != nil {
+				return rpcError(err)
+			}
+			if jsonOut {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(resp.Runners)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "LANGUAGE\tVERSION\tDEFAULT\tIMAGE")
+			for _, r := range resp.Runners {
+				fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", r.Language, r.Version, r.Default, r.Image)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "print JSON instead of a table")
+	return cmd
+}
+
+// readInput reads a file, or standard input for "-"
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// rpcError makes a gRPC status readable
+func rpcError(err error) error {
+	if s, ok := status.FromError(err); ok {
+		return fmt.Errorf("%s: %s", strings.ToLower(strings.ReplaceAll(s.Code().String(), "_", " ")), s.Message())
+	}
+	return err
+}
+
+// envOr returns an environment variable, or fallback when unset
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/audit"
+	"github.com/jsc/mcp-code-sandbox/internal/config"
+	"github.com/jsc/mcp-code-sandbox/internal/gc"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+	"github.com/jsc/mcp-code-sandbox/internal/services"
+	"github.com/spf13/cobra"
+)
+
+// settingFlags are the command-line flags mirroring every setting, e.g.
+// --max-concurrent-executions for MAX_CONCURRENT_EXECUTIONS
+type settingFlags struct {
+	configFile string
+	values     map[string]*string // Variable name -> flag value
+	names      map[string]string  // Variable name -> flag name
+}
+
+// addSettingFlags registers --config and a flag per setting on cmd and its
+// subcommands
+func addSettingFlags(cmd *cobra.Command) *settingFlags {
+	f := &settingFlags{values: make(map[string]*string), names: make(map[string]string)}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&f.configFile, "config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables and flags override it")
+	for _, key := range config.Keys() {
+		name := strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+		f.names[key] = name
+		f.values[key] = flags.String(name, "", "sets "+key)
+	}
+	return f
+}
+
+// overrides returns the settings given on the command line
+func (f *settingFlags) overrides(cmd *cobra.Command) map[string]string {
+	overrides := make(map[string]string)
+	for key, name := range f.names {
+		if cmd.Flags().Changed(name) {
+			overrides[key] = *f.values[key]
+		}
+	}
+	return overrides
+}
+
+// load reads the full server configuration
+func (f *settingFlags) load(cmd *cobra.Command) (*config.Config, error) {
+	cfg, err := config.Load(f.configFile, f.overrides(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// rootCommand builds the command tree. Without a subcommand the server runs,
+// as it did before subcommands existed
+func rootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "mcp-sandbox-server",
+		Short:        "MCP server that runs code in sandboxed containers",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+	}
+	settings := addSettingFlags(root)
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the MCP server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := settings.load(cmd)
+			if err != nil {
+				return err
+			}
+			serve(cfg, func() (*config.Config, error) { return settings.load(cmd) })
+			return nil
+		},
+	}
+	root.RunE = serveCmd.RunE
+
+	root.AddCommand(
+		serveCmd,
+		checkCommand(settings),
+		gcCommand(settings),
+		runnersCommand(settings),
+		passthroughCommand("run-once", "Execute one code payload in a runner container and exit", runOnce),
+		passthroughCommand("egress-proxy", "Run the allowlisting egress proxy (sidecar mode)", egressProxy),
+		passthroughCommand("new-token", "Generate a named API token for API_TOKENS_FILE", newToken),
+	)
+	return root
+}
+
+// passthroughCommand wraps a subcommand that parses its own flags and
+// returns an exit code
+func passthroughCommand(name, short string, run func(args []string) int) *cobra.Command {
+	return &cobra.Command{
+		Use:                name,
+		Short:              short,
+		DisableFlagParsing: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(run(args))
+		},
+	}
+}
+
+// checkCommand validates the configuration, connects to Docker and
+// discovers runners, without starting the server
+func checkCommand(settings *settingFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "check",
+		Short: "Validate the configuration, Docker connectivity and runner discovery",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			cfg, err := settings.load(cmd)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "Configuration: OK")
+
+			ctx := cmd.Context()
+			backend := runner.Backend(cfg.ContainerBackend)
+			dockerClient, err := connectDocker(ctx, backend, cfg.DockerHost)
+			if err != nil {
+				return err
+			}
+			defer dockerClient.Close()
+			fmt.Fprintf(out, "Docker: connected to %s daemon at %s\n", backend, dockerClient.DaemonHost())
+
+			runners, err := discoverRunners(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			if len(runners) == 0 {
+				return fmt.Errorf("no runner images found (build them with the sandbox.runner=true and sandbox.language labels)")
+			}
+			fmt.Fprintf(out, "Runners: %d found\n", len(runners))
+			for _, name := range cfg.RunnerImages {
+				if !hasRunnerImage(runners, name) {
+					fmt.Fprintf(out, "WARNING: %s from RUNNER_IMAGES is missing or not a runner image\n", name)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// gcCommand deletes inactive sandboxes once, like POST /admin/gc
+func gcCommand(settings *settingFlags) *cobra.Command {
+	var maxAge time.Duration
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete sandboxes inactive for longer than --max-age (default SANDBOX_RETENTION)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := settings.load(cmd)
+			if err != nil {
+				return err
+			}
+			if maxAge == 0 {
+				maxAge = cfg.Retention
+			}
+			if maxAge <= 0 {
+				return fmt.Errorf("--max-age or SANDBOX_RETENTION is required")
+			}
+
+			ctx := cmd.Context()
+			dockerClient, err := connectDocker(ctx, runner.Backend(cfg.ContainerBackend), cfg.DockerHost)
+			if err != nil {
+				return err
+			}
+			defer dockerClient.Close()
+			serviceMgr, err := services.NewManager(dockerClient, cfg.Services)
+			if err != nil {
+				return fmt.Errorf("invalid SANDBOX_SERVICES: %w", err)
+			}
+			auditLog, err := audit.Open(cfg.AuditLog)
+			if err != nil {
+				return fmt.Errorf("failed to open audit log: %w", err)
+			}
+			defer auditLog.Close()
+
+			// Background processes of deleted sandboxes are removed by the
+			// running server's process loop
+			sandboxMgr, err := newSandboxManager(cfg)
+			if err != nil {
+				return err
+			}
+			report, err := gc.New(sandboxMgr, auditLog, serviceMgr, nil).Run(maxAge, dryRun, "cli")
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		},
+	}
+	cmd.Flags().DurationVar(&maxAge, "max-age", 0, "delete sandboxes not modified for this long, e.g. 720h")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only report what would be deleted")
+	return cmd
+}
+
+// runnersCommand groups runner management subcommands
+func runnersCommand(settings *settingFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "runners",
+		Short: "Inspect runner images",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the runners the server would discover",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Only Docker and runner settings matter here
+			cfg, err := config.LoadRunOnce(settings.configFile, settings.overrides(cmd))
+			if err != nil {
+				return fmt.Errorf("invalid configuration: %w", err)
+			}
+			runners, err := discoverRunners(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "LANGUAGE\tVERSION\tDEFAULT\tIMAGE")
+			for _, r := range runners {
+				version := r.Version
+				if version == "" {
+					version = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", r.Language, version, r.Default, r.Image)
+			}
+			return w.Flush()
+		},
+	})
+	return cmd
+}
+
+// discoverRunners finds the runners the server would offer: labelled images
+// and those in RUNNERS_CONFIG
+func discoverRunners(ctx context.Context, cfg *config.Config) ([]runner.RunnerInfo, error) {
+	dockerClient, err := connectDocker(ctx, runner.Backend(cfg.ContainerBackend), cfg.DockerHost)
+	if err != nil {
+		return nil, err
+	}
+	defer dockerClient.Close()
+
+	var staticRunners []runner.RunnerInfo
+	if cfg.RunnersConfig != "" {
+		if staticRunners, err = runner.LoadConfig(cfg.RunnersConfig); err != nil {
+			return nil, fmt.Errorf("failed to load runners config: %w", err)
+		}
+	}
+	verifier, err := runner.NewVerifier(cfg.CosignKey, cfg.CosignIdentity, cfg.CosignIssuer)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := runner.NewRegistry(ctx, dockerClient, staticRunners, verifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover runners: %w", err)
+	}
+	return registry.ListRunners(), nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/egress"
+)
+
+// egressProxy runs the allowlisting HTTP(S) proxy. The server starts it as a
+// sidecar container for runners that request a restricted network
+func egressProxy(args []string) int {
+	fs := flag.NewFlagSet("egress-proxy", flag.ContinueOnError)
+	listen := fs.String("listen", ":3128", "address to listen on")
+	allow := fs.String("allow", "", "comma-separated domains to allow (*.example.com for subdomains)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *allow == "" {
+		fmt.Fprintln(os.Stderr, "egress-proxy: -allow is required")
+		return 2
+	}
+
+	domains := strings.Split(*allow, ",")
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           egress.NewProxy(domains),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Egress proxy listening on %s, allowing: %s", *listen, *allow)
+	if err := srv.ListenAndServe(); err != nil {
+		log.Printf("egress-proxy: %v", err)
+		return 1
+	}
+	return 0
+}
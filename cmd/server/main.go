@@ -2,55 +2,185 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/docker/docker/client"
+	"github.com/jsc/mcp-code-sandbox/internal/audit"
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
+	"github.com/jsc/mcp-code-sandbox/internal/bundle"
 	"github.com/jsc/mcp-code-sandbox/internal/config"
+	"github.com/jsc/mcp-code-sandbox/internal/egress"
+	"github.com/jsc/mcp-code-sandbox/internal/envstore"
+	"github.com/jsc/mcp-code-sandbox/internal/events"
 	"github.com/jsc/mcp-code-sandbox/internal/filesign"
+	"github.com/jsc/mcp-code-sandbox/internal/gc"
+	"github.com/jsc/mcp-code-sandbox/internal/gitclone"
 	"github.com/jsc/mcp-code-sandbox/internal/handler"
+	"github.com/jsc/mcp-code-sandbox/internal/history"
+	"github.com/jsc/mcp-code-sandbox/internal/kernels"
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/metrics"
+	"github.com/jsc/mcp-code-sandbox/internal/pager"
+	"github.com/jsc/mcp-code-sandbox/internal/preview"
+	"github.com/jsc/mcp-code-sandbox/internal/processes"
 	"github.com/jsc/mcp-code-sandbox/internal/runner"
 	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+	"github.com/jsc/mcp-code-sandbox/internal/secrets"
+	"github.com/jsc/mcp-code-sandbox/internal/security"
+	"github.com/jsc/mcp-code-sandbox/internal/services"
+	"github.com/jsc/mcp-code-sandbox/internal/session"
+	"github.com/jsc/mcp-code-sandbox/internal/storage"
+	"github.com/jsc/mcp-code-sandbox/internal/templates"
+	"github.com/jsc/mcp-code-sandbox/internal/tlsconfig"
+	"github.com/jsc/mcp-code-sandbox/internal/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("Starting MCP Code Sandbox Server...")
 
-	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+	if err := rootCommand().Execute(); err != nil {
+		os.Exit(1)
 	}
+}
+
+// serve runs the server with cfg until it receives SIGINT or SIGTERM. On
+// SIGHUP it calls load and applies the settings that can change at runtime
+func serve(cfg *config.Config, load func() (*config.Config, error)) {
+	log.Println("Starting MCP Code Sandbox Server...")
 
 	log.Printf("Configuration loaded:")
 	log.Printf("  HTTP Address: %s", cfg.HTTPAddr)
 	log.Printf("  Public Base URL: %s", cfg.PublicBaseURL)
+	if cfg.BasePath != "" {
+		log.Printf("  Base Path: %s", cfg.BasePath)
+	}
 	log.Printf("  Sandbox Root: %s", cfg.SandboxRoot)
 	if cfg.SandboxHostPath != cfg.SandboxRoot {
 		log.Printf("  Sandbox Host Path: %s (for Docker bind mounts)", cfg.SandboxHostPath)
 	}
 
 	// Create Docker client
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	ctx := context.Background()
+
+	// Export traces when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Setup(ctx)
 	if err != nil {
-		log.Fatalf("Failed to create Docker client: %v", err)
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	if tracing.Enabled() {
+		log.Println("  Tracing: OTLP export enabled")
+	}
+
+	backend := runner.Backend(cfg.ContainerBackend)
+	dockerClient, err := connectDocker(ctx, backend, cfg.DockerHost)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 	defer dockerClient.Close()
+	log.Printf("Connected to %s daemon at %s", backend, dockerClient.DaemonHost())
 
-	// Ping Docker to ensure connection
-	ctx := context.Background()
-	if _, err := dockerClient.Ping(ctx); err != nil {
-		log.Fatalf("Failed to connect to Docker: %v", err)
+	// Load statically configured runners, if any
+	var staticRunners []runner.RunnerInfo
+	if cfg.RunnersConfig != "" {
+		staticRunners, err = runner.LoadConfig(cfg.RunnersConfig)
+		if err != nil {
+			log.Fatalf("Failed to load runners config: %v", err)
+		}
+		log.Printf("Loaded %d runner(s) from %s", len(staticRunners), cfg.RunnersConfig)
+	}
+
+	catalog, err := messages.Load(cfg.MessagesFile)
+	if err != nil {
+		log.Fatalf("Failed to load messages: %v", err)
+	}
+
+	// Ensure sandbox root directory exists
+	if err := os.MkdirAll(cfg.SandboxRoot, 0o755); err != nil {
+		log.Fatalf("Failed to create sandbox root directory: %v", err)
+	}
+	sandboxMgr, err := newSandboxManager(cfg)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if sandboxMgr.RemoteEnabled() {
+		log.Printf("Sandbox files stored in s3://%s/%s (%s)", cfg.S3Bucket, cfg.S3Prefix, cfg.S3Endpoint)
 	}
-	log.Println("Connected to Docker daemon")
+
+	// Share downloaded package archives between each tenant's installs if enabled
+	if cfg.DownloadCache {
+		sandboxMgr.SetDownloadCache(true)
+		log.Printf("Package download caches: %s/.cache (one per tenant)", cfg.SandboxRoot)
+	}
+
+	// Code files are mounted into runners from here, leaving stdin free
+	stagingDir, stagingHostDir, err := sandboxMgr.EnsureStaging()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	staging := runner.Staging{Dir: stagingDir, HostDir: stagingHostDir}
+
+	// Start the proxy sidecar that restricted-network runners egress through
+	var egressCfg *runner.Egress
+	if len(cfg.EgressAllowedDomains) > 0 {
+		if err := egress.EnsureSidecar(ctx, dockerClient, cfg.EgressProxyImage, cfg.EgressAllowedDomains); err != nil {
+			log.Fatalf("Failed to start egress proxy: %v", err)
+		}
+		egressCfg = &runner.Egress{Network: egress.NetworkName, ProxyURL: egress.ProxyURL, Domains: cfg.EgressAllowedDomains}
+	}
+
+	// Spread executions over additional Docker hosts sharing the sandbox root
+	var pool *runner.Pool
+	if len(cfg.DockerHosts) > 0 {
+		if pool, err = runner.NewPool(ctx, dockerClient, cfg.DockerHosts); err != nil {
+			log.Fatalf("Failed to set up Docker hosts: %v", err)
+		}
+		defer pool.Close()
+		go pool.Monitor(ctx, 30*time.Second)
+	}
+
+	collector := metrics.New()
+	limiter := runner.NewLimiter(cfg.MaxConcurrent, cfg.MaxConcurrentPerConversation, cfg.ExecutionQueueSize)
+	hardening, err := runnerHardening(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load runner security profiles: %v", err)
+	}
+	if len(hardening.SeccompProfiles) > 0 {
+		log.Printf("Loaded %d seccomp profile(s) from %s", len(hardening.SeccompProfiles), cfg.SeccompDir)
+	}
+	gpus := runner.GPUPolicy{Count: cfg.GPUCount, Devices: cfg.GPUDevices}
+	if gpus.Enabled() {
+		log.Printf("GPU access enabled for sandbox.gpu runners (count %d, devices %v)", gpus.Count, gpus.Devices)
+	}
+	verifier, err := runner.NewVerifier(cfg.CosignKey, cfg.CosignIdentity, cfg.CosignIssuer)
+	if err != nil {
+		log.Fatalf("Failed to set up runner image verification: %v", err)
+	}
+	if verifier.Enabled() {
+		log.Printf("Runner images must have a valid cosign signature")
+	}
+	executor := runner.NewExecutor(dockerClient, 30*time.Second, cfg.AllowedRunnerCaps, collector, catalog, egressCfg, backend, pool, limiter, hardening, cfg.MaxOutputBytes, staging, gpus, verifier)
+
+	// Pull configured runner images (and those referenced by the runners
+	// config) that are missing locally, so discovery can find them
+	pullImages := append([]string{}, cfg.RunnerImages...)
+	for _, r := range staticRunners {
+		pullImages = append(pullImages, r.Image)
+	}
+	pullRunnerImages(ctx, executor, pullImages)
 
 	// Discover runner images
-	registry, err := runner.NewRegistry(ctx, dockerClient)
+	registry, err := runner.NewRegistry(ctx, dockerClient, staticRunners, verifier)
 	if err != nil {
 		log.Fatalf("Failed to create runner registry: %v", err)
 	}
@@ -58,7 +188,18 @@ func main() {
 	runners := registry.ListRunners()
 	log.Printf("Discovered %d runner(s):", len(runners))
 	for _, r := range runners {
-		log.Printf("  - %s: %s", r.Language, r.Image)
+		if r.Version != "" {
+			log.Printf("  - %s %s: %s (default: %v)", r.Language, r.Version, r.Image, r.Default)
+		} else {
+			log.Printf("  - %s: %s", r.Language, r.Image)
+		}
+	}
+
+	// Pulled images only become runners if they carry the discovery labels
+	for _, name := range cfg.RunnerImages {
+		if !hasRunnerImage(runners, name) {
+			log.Printf("WARNING: %s is not a runner image (missing sandbox.* labels); register it in RUNNERS_CONFIG instead", name)
+		}
 	}
 
 	if len(runners) == 0 {
@@ -67,19 +208,191 @@ func main() {
 		log.Println("  sandbox.language=<language>")
 	}
 
-	// Ensure sandbox root directory exists
-	if err := os.MkdirAll(cfg.SandboxRoot, 0o755); err != nil {
-		log.Fatalf("Failed to create sandbox root directory: %v", err)
+	// Create components
+	signer := filesign.NewSigner(cfg.FileSecret, cfg.PublicBaseURL, cfg.BasePath)
+
+	// Containers of a crashed previous run would otherwise linger until
+	// their deadline
+	executor.RemoveOrphans(ctx)
+
+	// Probe runner images for installed packages so tool descriptions are accurate
+	registry.ProbePackages(ctx, executor)
+
+	// Remove containers that outlive their timeout (e.g. after Docker API stalls)
+	go executor.Watchdog(ctx, 10*time.Second, cfg.WatchdogGrace)
+
+	// Pick up runner images built, pulled or removed after startup
+	go registry.Watch(ctx, time.Minute, func() {
+		registry.ProbePackages(ctx, executor)
+	})
+
+	// Reconnect after Docker daemon restarts, failing executions fast in
+	// the meantime. The restart stops the egress sidecar and may have
+	// changed the images
+	go executor.Supervise(ctx, 10*time.Second, func(ctx context.Context) {
+		if egressCfg != nil {
+			if err := egress.EnsureSidecar(ctx, dockerClient, cfg.EgressProxyImage, cfg.EgressAllowedDomains); err != nil {
+				log.Printf("Failed to restart egress proxy: %v", err)
+			}
+		}
+		changed, err := registry.Refresh(ctx)
+		if err != nil {
+			log.Printf("Failed to rediscover runners: %v", err)
+			return
+		}
+		log.Printf("Rediscovered %d runner(s) after reconnecting", len(registry.ListRunners()))
+		if changed {
+			registry.ProbePackages(ctx, executor)
+		}
+	})
+
+	bundles := bundle.NewStore(100)
+	sessions := session.NewStore(cfg.SessionIdleTimeout, cfg.MaxSessions)
+	outputs := pager.NewStore(64*1024, time.Hour, 200)
+	envs, err := envstore.NewStore(sandboxMgr, cfg.FileSecret)
+	if err != nil {
+		log.Fatalf("Failed to create environment store: %v", err)
 	}
+	executions, err := history.Open(cfg.HistoryDB)
+	if err != nil {
+		log.Fatalf("Failed to open execution history: %v", err)
+	}
+	defer executions.Close()
 
-	// Create components
-	sandboxMgr := sandbox.NewManager(cfg.SandboxRoot, cfg.SandboxHostPath, cfg.FileSecret)
-	signer := filesign.NewSigner(cfg.FileSecret, cfg.PublicBaseURL)
-	executor := runner.NewExecutor(dockerClient, 30*time.Second)
+	auditLog, err := audit.Open(cfg.AuditLog)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
+
+	// Helper services (databases etc.) conversations can start
+	serviceMgr, err := services.NewManager(dockerClient, cfg.Services)
+	if err != nil {
+		log.Fatalf("Invalid SANDBOX_SERVICES: %v", err)
+	}
+	if serviceMgr.Enabled() {
+		log.Printf("Sandbox services: %s", strings.Join(serviceMgr.Available(), ", "))
+		serviceMgr.RemoveOrphans(ctx, sandboxMgr.HashedDirExists)
+	}
+
+	var previews *preview.Registry
+	if cfg.PreviewEnabled {
+		previews = preview.New(cfg.PreviewBindAddress, cfg.PreviewUpstreamHost)
+		log.Printf("Web app previews enabled (ports published on %s)", cfg.PreviewBindAddress)
+	}
+
+	// Long-lived background processes; the loop also removes those left
+	// behind by a previous run whose sandbox is gone
+	processMgr := processes.NewManager(dockerClient, executor, previews, cfg.ProcessMaxLifetime, cfg.ProcessIdleTimeout, cfg.MaxProcessesPerConversation)
+	if processMgr.Enabled() {
+		log.Printf("Background processes: up to %d per conversation (lifetime %s, idle timeout %s)", cfg.MaxProcessesPerConversation, cfg.ProcessMaxLifetime, cfg.ProcessIdleTimeout)
+		go processMgr.Loop(ctx, time.Minute, sandboxMgr.HashedDirExists)
+	}
+
+	// Jupyter kernels for run_code's kernel mode, reaped when idle
+	kernelMgr := kernels.NewManager(dockerClient, executor, cfg.KernelIdleTimeout)
+	if kernelMgr.Enabled() {
+		log.Printf("Jupyter kernels: idle timeout %s", cfg.KernelIdleTimeout)
+		go kernelMgr.Loop(ctx, time.Minute, sandboxMgr.HashedDirExists)
+	}
+
+	sandboxGC := gc.New(sandboxMgr, auditLog, serviceMgr, processMgr)
+	if cfg.Retention > 0 {
+		log.Printf("Sandbox retention: %s (checked every %s, dry run: %v)", cfg.Retention, cfg.GCInterval, cfg.GCDryRun)
+		go sandboxGC.Loop(ctx, cfg.GCInterval, cfg.Retention, cfg.GCDryRun)
+	}
 
 	// Create handlers
-	mcpHandler := handler.NewMCPHandler(registry, executor, sandboxMgr, signer)
-	httpServer := handler.NewServer(mcpHandler, signer, sandboxMgr, cfg.APIToken)
+	installs := runner.NewInstallPolicy(cfg.InstallAllowedPackages, cfg.InstallDeniedPackages, cfg.PackageCacheMaxBytes)
+	if installs.Enabled() {
+		log.Printf("Package installs allowed for: %s", strings.Join(cfg.InstallAllowedPackages, ", "))
+		if len(cfg.InstallDeniedPackages) > 0 {
+			log.Printf("Package installs denied for: %s", strings.Join(cfg.InstallDeniedPackages, ", "))
+		}
+	}
+
+	sandboxTemplates, err := templates.Load(cfg.TemplatesDir)
+	if err != nil {
+		log.Fatalf("Failed to load sandbox templates: %v", err)
+	}
+	log.Printf("Loaded %d sandbox template(s)", len(sandboxTemplates.List()))
+
+	secretStore, err := secrets.Load(cfg.SecretsFile, cfg.VaultAddr, cfg.VaultToken)
+	if err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+	if cfg.SecretsFile != "" {
+		log.Printf("Loaded %d secret(s) from %s", secretStore.Len(), cfg.SecretsFile)
+	}
+
+	fetcher := egress.NewFetcher(cfg.FetchAllowedDomains, cfg.FetchContentTypes, cfg.FetchMaxBytes)
+	if fetcher.Enabled() {
+		log.Printf("fetch_file enabled for: %s", strings.Join(cfg.FetchAllowedDomains, ", "))
+	}
+	cloner, err := gitclone.New(cfg.CloneAllowedHosts, cfg.CloneMaxBytes, cfg.CloneMaxDepth)
+	if err != nil {
+		log.Fatalf("Failed to set up clone_repo: %v", err)
+	}
+	if cloner.Enabled() {
+		log.Printf("clone_repo enabled for: %s", strings.Join(cfg.CloneAllowedHosts, ", "))
+	}
+
+	tokens, err := auth.LoadTokens(cfg.APITokensFile, cfg.APIToken, cfg.APITokenSHA256)
+	if err != nil {
+		log.Fatalf("Failed to load API tokens: %v", err)
+	}
+	if cfg.APITokensFile != "" {
+		log.Printf("Loaded %d API token(s) from %s", tokens.Len(), cfg.APITokensFile)
+		go tokens.Watch(ctx, 10*time.Second)
+	}
+
+	var jwtVerifier *auth.JWTVerifier
+	if cfg.JWKSURL != "" {
+		jwtVerifier = auth.NewJWTVerifier(ctx, cfg.JWKSURL, cfg.JWTIssuer, cfg.JWTAudience)
+		log.Printf("JWT authentication enabled (issuer %s, audience %s)", cfg.JWTIssuer, cfg.JWTAudience)
+	}
+
+	rateLimiter := auth.NewRateLimiter(cfg.RateLimitPerToken, cfg.RateLimitPerIP, cfg.RateLimitGlobal, cfg.RateLimitIPHeader)
+	for _, limit := range []struct {
+		name string
+		rate auth.Rate
+	}{{"token", cfg.RateLimitPerToken}, {"IP", cfg.RateLimitPerIP}, {"server", cfg.RateLimitGlobal}} {
+		if limit.rate.Enabled() {
+			log.Printf("Rate limit per %s: %s", limit.name, limit.rate)
+		}
+	}
+
+	lockout := auth.NewLockout(cfg.AuthMaxFailures, cfg.AuthLockout, cfg.AuthFailureDelay, cfg.RateLimitIPHeader)
+	if cfg.AuthMaxFailures > 0 {
+		log.Printf("Authentication lockout: %d failures lock an IP out for %s", cfg.AuthMaxFailures, cfg.AuthLockout)
+	}
+
+	// Tokens, rate limits, runners and resource limits reload on SIGHUP and
+	// POST /admin/reload
+	reloads := &reloader{
+		load:        load,
+		tokens:      tokens,
+		rateLimiter: rateLimiter,
+		limiter:     limiter,
+		executor:    executor,
+		registry:    registry,
+		current:     cfg,
+	}
+	reload := func() error { return reloads.reload(ctx) }
+
+	mcpHandler := handler.NewMCPHandler(registry, executor, sandboxMgr, signer, bundles, outputs, envs, executions, installs, sandboxTemplates, serviceMgr, catalog, previews, processMgr, kernelMgr, secretStore, fetcher, cloner)
+	// Keep SSE events so clients can resume after a dropped connection
+	var eventStore events.Store
+	if cfg.SSEEventRetention > 0 {
+		eventStore = events.NewMemoryStore(cfg.SSEEventRetention)
+	}
+	// Let HTML reports run their scripts, in an opaque origin
+	var reports *security.Policy
+	if cfg.HTMLReports {
+		policy := security.ReportPolicy(cfg.HTMLReportSources)
+		reports = &policy
+	}
+	httpServer := handler.NewServer(mcpHandler, signer, sandboxMgr, bundles, sessions, collector, executions, sandboxGC, cfg.Retention, tokens, cfg.BasePath, cfg.IngestMaxBytes, cfg.RequestMaxBytes, cfg.UploadMaxFileBytes, jwtVerifier, rateLimiter, lockout, append([]string{cfg.PublicBaseURL}, cfg.AllowedOrigins...), reload, cfg.SSEKeepAlive, eventStore, reports)
 
 	// Setup HTTP routes
 	mux := http.NewServeMux()
@@ -94,18 +407,66 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	}
 
+	// Serve HTTPS directly when a certificate is configured; otherwise plain
+	// HTTP, e.g. behind a TLS-terminating proxy
+	redirect, err := configureTLS(cfg, srv)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+	if redirect != nil {
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS", cfg.TLSRedirectAddr)
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("HTTP redirect server failed: %v", err)
+			}
+		}()
+	}
+
+	// gRPC API for machine-to-machine callers, over the same TLS config
+	var grpcServer *grpc.Server
+	if cfg.GRPCAddr != "" {
+		listener, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen on GRPC_ADDR: %v", err)
+		}
+		var creds credentials.TransportCredentials
+		if srv.TLSConfig != nil {
+			creds = credentials.NewTLS(srv.TLSConfig.Clone())
+		}
+		grpcServer = handler.NewGRPCServer(mcpHandler, tokens, jwtVerifier, rateLimiter, lockout, cfg.IngestMaxBytes, creds)
+		go func() {
+			log.Printf("gRPC API listening on %s", cfg.GRPCAddr)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
+	}
+
 	// Start server in goroutine
 	go func() {
-		log.Printf("Server listening on %s", cfg.HTTPAddr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			log.Printf("Server listening on %s (HTTPS)", cfg.HTTPAddr)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Server listening on %s", cfg.HTTPAddr)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed: %v", err)
 		}
 	}()
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, reloading on SIGHUP
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		log.Println("Received SIGHUP, reloading configuration...")
+		reload() // Failures are logged and keep the current settings
+	}
 
 	log.Println("Shutting down server...")
 
@@ -116,6 +477,170 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("Server shutdown error: %v", err)
 	}
+	if redirect != nil {
+		redirect.Shutdown(shutdownCtx)
+	}
+	if grpcServer != nil {
+		// Running executions get until the shutdown deadline to finish
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Tracing shutdown error: %v", err)
+	}
 
 	log.Println("Server stopped")
 }
+
+// configureTLS sets srv's TLS config from TLS_CERT_FILE/TLS_KEY_FILE or
+// TLS_AUTOCERT, and returns the plain HTTP server for TLS_REDIRECT_ADDR, if
+// any. With autocert it also answers HTTP-01 challenges
+func configureTLS(cfg *config.Config, srv *http.Server) (*http.Server, error) {
+	redirect := tlsconfig.Redirect(cfg.HTTPAddr)
+	switch {
+	case cfg.TLSCertFile != "":
+		tlsConfig, err := tlsconfig.FromFiles(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = tlsConfig
+		log.Printf("  TLS: certificate from %s", cfg.TLSCertFile)
+	case cfg.TLSAutocert:
+		u, _ := url.Parse(cfg.PublicBaseURL) // Validated by config.Load
+		tlsConfig, manager, err := tlsconfig.Autocert(u.Hostname(), cfg.TLSAutocertEmail, cfg.TLSAutocertCache)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = tlsConfig
+		redirect = manager.HTTPHandler(redirect)
+		log.Printf("  TLS: Let's Encrypt certificate for %s (cached in %s)", u.Hostname(), cfg.TLSAutocertCache)
+	default:
+		return nil, nil
+	}
+
+	if cfg.TLSRedirectAddr == "" {
+		return nil, nil
+	}
+	return &http.Server{
+		Addr:         cfg.TLSRedirectAddr,
+		Handler:      redirect,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}, nil
+}
+
+// newSandboxManager creates the sandbox manager, keeping files in object
+// storage when STORAGE_BACKEND is s3
+func newSandboxManager(cfg *config.Config) (*sandbox.Manager, error) {
+	sandboxMgr := sandbox.NewManager(cfg.SandboxRoot, cfg.SandboxHostPath, cfg.FileSecret, cfg.IsolateUIDs, cfg.MinFreeBytes, cfg.ExtractMaxBytes)
+	sandboxMgr.SetLimits(sandbox.Limits{
+		MaxFileBytes:  cfg.UploadMaxFileBytes,
+		MaxTotalBytes: cfg.SandboxMaxBytes,
+		MaxFiles:      cfg.SandboxMaxFiles,
+	})
+	if cfg.StorageBackend != "s3" {
+		return sandboxMgr, nil
+	}
+	backend, err := storage.NewS3(storage.S3Config{
+		Endpoint:        cfg.S3Endpoint,
+		Bucket:          cfg.S3Bucket,
+		Region:          cfg.S3Region,
+		AccessKeyID:     cfg.S3AccessKeyID,
+		SecretAccessKey: cfg.S3SecretAccessKey,
+		SessionToken:    cfg.S3SessionToken,
+		Prefix:          cfg.S3Prefix,
+		PathStyle:       cfg.S3PathStyle,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure S3 storage: %w", err)
+	}
+	sandboxMgr.SetStorage(backend)
+	return sandboxMgr, nil
+}
+
+// pullRunnerImages pulls each image that is not present locally
+// Failures are logged so one unreachable registry doesn't block startup
+func pullRunnerImages(ctx context.Context, executor *runner.Executor, images []string) {
+	seen := make(map[string]bool)
+	for _, name := range images {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		pulled, err := executor.EnsureImage(ctx, name)
+		if err != nil {
+			log.Printf("WARNING: Runner image %s unavailable: %v", name, err)
+			continue
+		}
+		if pulled {
+			log.Printf("Pulled runner image %s", name)
+		}
+	}
+}
+
+// hasRunnerImage reports whether any runner uses the named image
+func hasRunnerImage(runners []runner.RunnerInfo, name string) bool {
+	for _, r := range runners {
+		if r.Image == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runnerHardening is the configured security baseline for runner
+// containers, with seccomp profiles loaded from disk
+func runnerHardening(cfg *config.Config) (runner.Hardening, error) {
+	hardening := runner.Hardening{
+		ReadonlyRootfs:  cfg.ReadonlyRootfs,
+		CapDrop:         cfg.CapDrop,
+		NoNewPrivileges: cfg.NoNewPrivileges,
+		PidsLimit:       int64(cfg.PidsLimit),
+		TmpfsBytes:      cfg.TmpfsBytes,
+		AppArmorProfile: cfg.AppArmorProfile,
+	}
+	var err error
+	if cfg.SeccompProfile != "" {
+		if hardening.SeccompProfile, err = runner.LoadSeccompProfile(cfg.SeccompProfile); err != nil {
+			return hardening, err
+		}
+	}
+	if cfg.SeccompDir != "" {
+		if hardening.SeccompProfiles, err = runner.LoadSeccompProfiles(cfg.SeccompDir); err != nil {
+			return hardening, err
+		}
+	}
+	return hardening, nil
+}
+
+// connectDocker creates a Docker client from the environment and pings the
+// daemon at host (DOCKER_HOST, possibly from the config file). Without one,
+// Podman is reached through its API socket
+func connectDocker(ctx context.Context, backend runner.Backend, host string) (*client.Client, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	switch {
+	case host != "":
+		opts = append(opts, client.WithHost(host))
+	case backend == runner.BackendPodman:
+		opts = append(opts, client.WithHost(runner.PodmanSocket()))
+	}
+	dockerClient, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+
+	if _, err := dockerClient.Ping(ctx); err != nil {
+		dockerClient.Close()
+		return nil, fmt.Errorf("failed to connect to Docker: %w", err)
+	}
+	return dockerClient, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
+	"github.com/jsc/mcp-code-sandbox/internal/config"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+)
+
+// reloadable are the Config fields reloader applies to the running server
+var reloadable = map[string]bool{
+	// Tokens
+	"APIToken":       true,
+	"APITokenSHA256": true,
+	// Rate limits
+	"RateLimitPerToken": true,
+	"RateLimitPerIP":    true,
+	"RateLimitGlobal":   true,
+	// Runners
+	"RunnersConfig": true,
+	"RunnerImages":  true,
+	// Resource limits
+	"MaxConcurrent":                true,
+	"MaxConcurrentPerConversation": true,
+	"ExecutionQueueSize":           true,
+	"MaxOutputBytes":               true,
+	"ReadonlyRootfs":               true,
+	"CapDrop":                      true,
+	"NoNewPrivileges":              true,
+	"PidsLimit":                    true,
+	"TmpfsBytes":                   true,
+	"SeccompProfile":               true,
+	"SeccompDir":                   true,
+	"AppArmorProfile":              true,
+}
+
+// reloader rereads the configuration on SIGHUP or POST /admin/reload and
+// applies tokens, rate limits, runners and resource limits without a
+// restart. Running executions keep the limits they started with
+type reloader struct {
+	load func() (*config.Config, error) // Reads the config file, environment and flags again
+
+	tokens      *auth.TokenStore
+	rateLimiter *auth.RateLimiter
+	limiter     *runner.Limiter
+	executor    *runner.Executor
+	registry    *runner.Registry
+
+	mu      sync.Mutex // Serializes reloads
+	current *config.Config
+}
+
+// reload reads and applies the configuration. Nothing is applied unless it
+// all loads, so a typo doesn't leave the server half reconfigured
+func (r *reloader) reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.load()
+	if err != nil {
+		log.Printf("Configuration reload failed, keeping the current settings: %v", err)
+		return err
+	}
+	hardening, err := runnerHardening(cfg)
+	if err != nil {
+		log.Printf("Configuration reload failed, keeping the current settings: %v", err)
+		return fmt.Errorf("failed to load runner security profiles: %w", err)
+	}
+	var staticRunners []runner.RunnerInfo
+	if cfg.RunnersConfig != "" {
+		if staticRunners, err = runner.LoadConfig(cfg.RunnersConfig); err != nil {
+			log.Printf("Configuration reload failed, keeping the current settings: %v", err)
+			return fmt.Errorf("failed to load runners config: %w", err)
+		}
+	}
+	// Checked last, since it replaces the tokens when it succeeds
+	if err := r.tokens.Reload(cfg.APIToken, cfg.APITokenSHA256); err != nil {
+		log.Printf("Configuration reload failed, keeping the current settings: %v", err)
+		return fmt.Errorf("failed to load API tokens: %w", err)
+	}
+
+	// New rates start every client with a full bucket, so only when changed
+	if cfg.RateLimitPerToken != r.current.RateLimitPerToken || cfg.RateLimitPerIP != r.current.RateLimitPerIP || cfg.RateLimitGlobal != r.current.RateLimitGlobal {
+		r.rateLimiter.SetRates(cfg.RateLimitPerToken, cfg.RateLimitPerIP, cfg.RateLimitGlobal)
+	}
+	r.limiter.SetLimits(cfg.MaxConcurrent, cfg.MaxConcurrentPerConversation, cfg.ExecutionQueueSize)
+	r.executor.SetLimits(hardening, cfg.MaxOutputBytes)
+	log.Printf("Reloaded %d API token(s), rate limits and resource limits (max %d concurrent executions)", r.tokens.Len(), cfg.MaxConcurrent)
+
+	r.registry.SetStatic(staticRunners)
+	pullImages := append([]string{}, cfg.RunnerImages...)
+	for _, info := range staticRunners {
+		pullImages = append(pullImages, info.Image)
+	}
+	pullRunnerImages(ctx, r.executor, pullImages)
+	changed, err := r.registry.Refresh(ctx)
+	if err != nil {
+		// The previous runners stay; the registry's watch retries
+		log.Printf("Failed to refresh runners after reload: %v", err)
+	} else if changed {
+		log.Printf("Runners changed, now serving: %v", r.registry.ListLanguages())
+		r.registry.ProbePackages(ctx, r.executor)
+	}
+
+	if restart := restartRequired(r.current, cfg); len(restart) > 0 {
+		log.Printf("WARNING: Changed settings take effect after a restart: %v", restart)
+	}
+	r.current = cfg
+	return nil
+}
+
+// restartRequired lists the Config fields that differ between old and next
+// but aren't reloadable
+func restartRequired(old, next *config.Config) []string {
+	var fields []string
+	oldValue, newValue := reflect.ValueOf(*old), reflect.ValueOf(*next)
+	for i := 0; i < oldValue.NumField(); i++ {
+		name := oldValue.Type().Field(i).Name
+		if !reloadable[name] && !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
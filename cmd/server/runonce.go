@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/config"
+	"github.com/jsc/mcp-code-sandbox/internal/filesign"
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+)
+
+// runOnce executes a single code payload in a runner container and returns
+// the process exit code. Stdout and stderr of the code are passed through.
+func runOnce(args []string) int {
+	fs := flag.NewFlagSet("run-once", flag.ContinueOnError)
+	language := fs.String("language", "", "runner language (resolved via image labels)")
+	version := fs.String("version", "", "runner version (default: the language's default)")
+	imageName := fs.String("image", "", "runner image to use directly, skipping discovery")
+	file := fs.String("file", "-", "path to the code to execute, or - for stdin")
+	stdinFile := fs.String("stdin", "", "file piped to the program's stdin (runners with sandbox.code-file)")
+	conversationID := fs.String("conversation", "", "sandbox conversation ID (default: random, deleted on exit)")
+	network := fs.Bool("network", false, "enable network access for the container")
+	timeout := fs.Duration("timeout", 30*time.Second, "execution timeout")
+	keep := fs.Bool("keep", false, "keep the sandbox directory after execution")
+	verbose := fs.Bool("v", false, "log progress to stderr")
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables override it")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s run-once (-language <lang> | -image <image>) [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if (*language == "") == (*imageName == "") {
+		fmt.Fprintln(os.Stderr, "run-once: exactly one of -language or -image is required")
+		fs.Usage()
+		return 2
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	cfg, err := config.LoadRunOnce(*configFile, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+
+	code, err := readPayload(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+	var stdin string
+	if *stdinFile != "" {
+		data, err := os.ReadFile(*stdinFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "run-once: failed to read stdin data: %v\n", err)
+			return 1
+		}
+		stdin = string(data)
+	}
+
+	ctx := context.Background()
+	backend := runner.Backend(cfg.ContainerBackend)
+	dockerClient, err := connectDocker(ctx, backend, cfg.DockerHost)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+	defer dockerClient.Close()
+	verifier, err := runner.NewVerifier(cfg.CosignKey, cfg.CosignIdentity, cfg.CosignIssuer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+
+	// Resolve the runner image; -image skips discovery for the fastest cold start
+	runnerInfo := runner.RunnerInfo{Image: *imageName}
+	if *imageName == "" {
+		var staticRunners []runner.RunnerInfo
+		if cfg.RunnersConfig != "" {
+			if staticRunners, err = runner.LoadConfig(cfg.RunnersConfig); err != nil {
+				fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+				return 1
+			}
+		}
+		registry, err := runner.NewRegistry(ctx, dockerClient, staticRunners, verifier)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+			return 1
+		}
+		info, ok := registry.GetRunner(*language, *version)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "run-once: no runner for language %s (version %q)\n", *language, *version)
+			return 1
+		}
+		runnerInfo = info
+	}
+	log.Printf("Using runner image %s", runnerInfo.Image)
+
+	ephemeral := *conversationID == ""
+	if ephemeral {
+		*conversationID = randomID()
+	}
+
+	sandboxMgr, err := newSandboxManager(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+	hashedDir, err := sandboxMgr.EnsureSandboxDir(*conversationID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+	if ephemeral && !*keep {
+		defer sandboxMgr.DeleteSandbox(*conversationID)
+	} else if err := sandboxMgr.Stage(ctx, *conversationID); err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+	log.Printf("Sandbox directory: %s", sandboxMgr.GetSandboxDir(*conversationID))
+
+	env := make(map[string]string)
+	if cfg.PublicBaseURL != "" {
+		signer := filesign.NewSigner(cfg.FileSecret, cfg.PublicBaseURL, cfg.BasePath)
+		env["FILE_BASE_URL"] = signer.FileBaseURL(hashedDir)
+	}
+
+	catalog, err := messages.Load(cfg.MessagesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+
+	hardening, err := runnerHardening(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+	stagingDir, stagingHostDir, err := sandboxMgr.EnsureStaging()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+		return 1
+	}
+
+	executor := runner.NewExecutor(dockerClient, *timeout, cfg.AllowedRunnerCaps, nil, catalog, nil, backend, nil, nil, hardening, cfg.MaxOutputBytes, runner.Staging{Dir: stagingDir, HostDir: stagingHostDir}, runner.GPUPolicy{}, verifier)
+	if stdin != "" {
+		if !executor.AcceptsStdin(runnerInfo) {
+			fmt.Fprintf(os.Stderr, "run-once: %s reads its code from stdin, so -stdin needs a runner labelled sandbox.code-file=true\n", runnerInfo.Image)
+			return 2
+		}
+		ctx = runner.WithStdin(ctx, stdin)
+	}
+	result := executor.Execute(ctx, runnerInfo, sandboxMgr.GetSandboxHostPath(*conversationID), sandboxMgr.User(*conversationID), code, *network, env)
+
+	fmt.Fprint(os.Stdout, result.Stdout)
+	fmt.Fprint(os.Stderr, result.Stderr)
+
+	if !ephemeral || *keep {
+		if err := sandboxMgr.Sync(ctx, *conversationID); err != nil {
+			fmt.Fprintf(os.Stderr, "run-once: %v\n", err)
+			return 1
+		}
+	}
+
+	if result.Error != nil || result.TimedOut {
+		return 1
+	}
+	return result.ExitCode
+}
+
+// readPayload reads code from a file, or from stdin when path is "-"
+func readPayload(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read code: %w", err)
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("no code provided")
+	}
+	return string(data), nil
+}
+
+// randomID returns a random identifier for an ephemeral sandbox
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "run-once-" + hex.EncodeToString(b)
+}
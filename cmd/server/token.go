@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
+)
+
+// newToken generates an API token and prints an API_TOKENS_FILE entry for
+// it, so the token itself never has to be stored on the server
+func newToken(args []string) int {
+	fs := flag.NewFlagSet("new-token", flag.ContinueOnError)
+	name := fs.String("name", "", "token name, shown in logs")
+	scopes := fs.String("scopes", "execute,upload,read-files", "comma-separated scopes (execute, upload, read-files, admin)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *name == "" {
+		fmt.Fprintln(os.Stderr, "new-token: -name is required")
+		return 2
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		fmt.Fprintf(os.Stderr, "new-token: %v\n", err)
+		return 1
+	}
+	token := hex.EncodeToString(secret)
+
+	fmt.Fprintf(os.Stderr, "Token (give it to the client; it is not stored): %s\n\n", token)
+	fmt.Printf("  - name: %s\n    sha256: %s\n    scopes: [%s]\n", *name, auth.HashToken(token), strings.Join(strings.Split(*scopes, ","), ", "))
+	return 0
+}
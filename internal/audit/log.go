@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Event is one entry in the audit log
+type Event struct {
+	Time    time.Time              `json:"time"`
+	Action  string                 `json:"action"`
+	Actor   string                 `json:"actor"` // "system" or the requesting client
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Log is an append-only JSON Lines file of administrative actions
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens (creating if needed) the audit log at path
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Log{file: file}, nil
+}
+
+// Record appends an event, stamping it with the current time
+func (l *Log) Record(action, actor string, details map[string]interface{}) error {
+	line, err := json.Marshal(Event{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Actor:   actor,
+		Details: details,
+	})
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	return l.file.Close()
+}
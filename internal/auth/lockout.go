@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Lockout slows down and then blocks clients that keep presenting invalid
+// credentials. A nil Lockout does neither
+type Lockout struct {
+	maxFailures int           // Failures before a client is locked out
+	duration    time.Duration // How long a lockout lasts, and how long failures are remembered
+	delay       time.Duration // Added to every rejected attempt's response
+	ipHeader    string        // As for RateLimiter
+
+	mu      sync.Mutex
+	clients map[string]*failures
+	swept   time.Time
+}
+
+type failures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout locks a client IP out for duration after maxFailures failed
+// attempts within duration, and delays each failed attempt's response.
+// It returns nil when maxFailures is 0 and there is no delay
+func NewLockout(maxFailures int, duration, delay time.Duration, ipHeader string) *Lockout {
+	if maxFailures == 0 && delay == 0 {
+		return nil
+	}
+	return &Lockout{
+		maxFailures: maxFailures,
+		duration:    duration,
+		delay:       delay,
+		ipHeader:    ipHeader,
+		clients:     make(map[string]*failures),
+		swept:       time.Now(),
+	}
+}
+
+// locked answers 429 when the request's client is locked out
+func (l *Lockout) locked(w http.ResponseWriter, r *http.Request) bool {
+	if l == nil {
+		return false
+	}
+	remaining := l.LockedFor(clientIP(r, l.ipHeader))
+	if remaining <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+	writeJSONError(w, "Too many failed authentication attempts", http.StatusTooManyRequests)
+	return true
+}
+
+// LockedFor returns how much longer a client IP is locked out, if at all
+func (l *Lockout) LockedFor(ip string) time.Duration {
+	if l == nil || l.maxFailures == 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if f, ok := l.clients[ip]; ok {
+		return time.Until(f.lockedUntil)
+	}
+	return 0
+}
+
+// fail records a rejected attempt and waits out the failure delay, so
+// guessing tokens is slow even below the lockout threshold
+func (l *Lockout) fail(r *http.Request) {
+	if l == nil {
+		return
+	}
+	l.Fail(r.Context(), clientIP(r, l.ipHeader))
+}
+
+// Fail records a rejected attempt from a client IP, as fail does for HTTP
+// requests
+func (l *Lockout) Fail(ctx context.Context, ip string) {
+	if l == nil {
+		return
+	}
+	if l.maxFailures > 0 {
+		l.mu.Lock()
+		now := time.Now()
+		l.sweepLocked(now)
+		f, ok := l.clients[ip]
+		if !ok || now.Sub(f.last) > l.duration {
+			f = &failures{}
+			l.clients[ip] = f
+		}
+		f.count++
+		f.last = now
+		if f.count >= l.maxFailures {
+			f.lockedUntil = now.Add(l.duration)
+			f.count = 0
+			log.Printf("[HTTP] Locked out %s for %s after %d failed authentication attempts", ip, l.duration, l.maxFailures)
+		}
+		l.mu.Unlock()
+	}
+
+	if l.delay > 0 {
+		timer := time.NewTimer(l.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// succeed forgets a client's failed attempts
+func (l *Lockout) succeed(r *http.Request) {
+	if l == nil {
+		return
+	}
+	l.Succeed(clientIP(r, l.ipHeader))
+}
+
+// Succeed forgets a client IP's failed attempts
+func (l *Lockout) Succeed(ip string) {
+	if l == nil || l.maxFailures == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if f, ok := l.clients[ip]; ok && time.Now().After(f.lockedUntil) {
+		delete(l.clients, ip)
+	}
+}
+
+// sweepLocked drops clients whose failures and lockout have expired; caller
+// must hold l.mu
+func (l *Lockout) sweepLocked(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	for ip, f := range l.clients {
+		if now.Sub(f.last) > l.duration && now.After(f.lockedUntil) {
+			delete(l.clients, ip)
+		}
+	}
+	l.swept = now
+}
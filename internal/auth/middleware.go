@@ -1,7 +1,11 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
 )
@@ -18,10 +22,16 @@ func writeJSONError(w http.ResponseWriter, message string, statusCode int) {
 	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
 }
 
-// Middleware creates an authentication middleware
-func Middleware(apiToken string) func(http.Handler) http.Handler {
+// Middleware creates an authentication middleware accepting the tokens in
+// the store and, when jwt is non-nil, JWTs it validates. The identity must
+// have the required scope; "" accepts any. Clients presenting invalid
+// credentials are slowed down and locked out by lockout, if non-nil
+func Middleware(tokens *TokenStore, jwt *JWTVerifier, lockout *Lockout, required Scope) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if lockout.locked(w, r) {
+				return
+			}
 			authHeader := r.Header.Get("Authorization")
 
 			if authHeader == "" {
@@ -32,17 +42,43 @@ func Middleware(apiToken string) func(http.Handler) http.Handler {
 			// Check for Bearer token
 			const bearerPrefix = "Bearer "
 			if !strings.HasPrefix(authHeader, bearerPrefix) {
+				lockout.fail(r)
 				writeJSONError(w, "Invalid Authorization header format", http.StatusUnauthorized)
 				return
 			}
 
-			token := strings.TrimPrefix(authHeader, bearerPrefix)
-			if token != apiToken {
-				writeJSONError(w, "Invalid API token", http.StatusUnauthorized)
+			identity, err := Authenticate(r.Context(), tokens, jwt, strings.TrimPrefix(authHeader, bearerPrefix))
+			if err != nil {
+				log.Printf("[HTTP] Rejected token from %s: %v", r.RemoteAddr, err)
+				lockout.fail(r)
+				writeJSONError(w, err.Error(), http.StatusUnauthorized)
 				return
 			}
+			lockout.succeed(r)
 
-			next.ServeHTTP(w, r)
+			if required != "" && !identity.Has(required) {
+				log.Printf("[HTTP] Token %q lacks the %s scope for %s", identity.Name, required, r.URL.Path)
+				writeJSONError(w, "Token lacks the "+string(required)+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
 		})
 	}
 }
+
+// Authenticate returns the identity of a bearer token: a named token, or a
+// JWT when a verifier is configured
+func Authenticate(ctx context.Context, tokens *TokenStore, jwt *JWTVerifier, token string) (Identity, error) {
+	if identity, ok := tokens.Lookup(token); ok {
+		return identity, nil
+	}
+	if jwt == nil || strings.Count(token, ".") != 2 {
+		return Identity{}, errors.New("Invalid API token")
+	}
+	claims, err := jwt.Verify(ctx, token)
+	if err != nil {
+		return Identity{}, fmt.Errorf("Invalid token: %w", err)
+	}
+	// Prefixed so a subject can't pose as a named token
+	return Identity{Name: "jwt:" + claims.Subject, Scopes: claims.Scopes()}, nil
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// sweepInterval is how often buckets that have refilled are dropped
+const sweepInterval = time.Minute
+
+// Rate is a request budget: up to Requests at once, refilled over Per
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseRate parses "N/s", "N/m" or "N/h". "0" or "" is no limit
+func ParseRate(value string) (Rate, error) {
+	if value == "" || value == "0" {
+		return Rate{}, nil
+	}
+	count, unit, ok := strings.Cut(value, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q (want e.g. 300/m)", value)
+	}
+	per := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}[unit]
+	if per == 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q (unit must be s, m or h)", value)
+	}
+	return Rate{Requests: n, Per: per}, nil
+}
+
+// Enabled reports whether the rate limits anything
+func (r Rate) Enabled() bool {
+	return r.Requests > 0
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d per %s", r.Requests, r.Per)
+}
+
+func (r Rate) limiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(float64(r.Requests)/r.Per.Seconds()), r.Requests)
+}
+
+// RateLimiter keeps token buckets per API token, per client IP and for the
+// whole server. Disabled rates never reject
+type RateLimiter struct {
+	ipHeader string // Trusted header carrying the client IP, e.g. CF-Connecting-IP
+
+	mu       sync.Mutex
+	perToken Rate
+	perIP    Rate
+	global   *rate.Limiter      // nil without a global limit
+	buckets  map[string]*bucket // "token:" or "ip:" + key
+	swept    time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a rate limiter. ipHeader names a header set by a
+// trusted reverse proxy to the client's address; empty uses the peer address
+func NewRateLimiter(perToken, perIP, global Rate, ipHeader string) *RateLimiter {
+	l := &RateLimiter{ipHeader: ipHeader}
+	l.SetRates(perToken, perIP, global)
+	return l
+}
+
+// SetRates replaces the limits. Every client starts again with a full bucket
+func (l *RateLimiter) SetRates(perToken, perIP, global Rate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.perToken = perToken
+	l.perIP = perIP
+	l.global = nil
+	if global.Enabled() {
+		l.global = global.limiter()
+	}
+	l.buckets = make(map[string]*bucket)
+	l.swept = time.Now()
+}
+
+// rates returns the current limits
+func (l *RateLimiter) rates() (perToken, perIP Rate, global *rate.Limiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.perToken, l.perIP, l.global
+}
+
+// ByIP limits requests per client IP and overall. Put it in front of
+// authentication so failed attempts count too. The client's bucket is
+// checked first, so one client over its limit can't drain the global
+// bucket and lock everyone else out
+func (l *RateLimiter) ByIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, perIP, global := l.rates()
+		if perIP.Enabled() {
+			ip := clientIP(r, l.ipHeader)
+			if !allow(w, l.limiter("ip:"+ip, perIP)) {
+				log.Printf("[HTTP] Rate limit reached for %s on %s", ip, r.URL.Path)
+				return
+			}
+		}
+		if global != nil && !allow(w, global) {
+			log.Printf("[HTTP] Global rate limit reached, rejecting %s %s", r.Method, r.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ByToken limits requests per authenticated token. It goes after the
+// authentication middleware
+func (l *RateLimiter) ByToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		perToken, _, _ := l.rates()
+		if identity, ok := FromContext(r.Context()); ok && perToken.Enabled() {
+			if !allow(w, l.limiter("token:"+identity.Name, perToken)) {
+				log.Printf("[HTTP] Rate limit reached for token %q", identity.Name)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Allow takes a request from the client IP, (if not empty) token and
+// global buckets, for callers that aren't HTTP handlers. Like ByIP, the
+// global bucket is only charged for requests the client's buckets allow
+func (l *RateLimiter) Allow(ip, tokenName string) bool {
+	perToken, perIP, global := l.rates()
+	if perIP.Enabled() && !l.limiter("ip:"+ip, perIP).Allow() {
+		return false
+	}
+	if tokenName != "" && perToken.Enabled() && !l.limiter("token:"+tokenName, perToken).Allow() {
+		return false
+	}
+	return global == nil || global.Allow()
+}
+
+// limiter returns the bucket for key, creating it full
+func (l *RateLimiter) limiter(key string, r Rate) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) > sweepInterval {
+		// A full bucket is the same as a new one
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > sweepInterval && b.limiter.TokensAt(now) >= float64(b.limiter.Burst()) {
+				delete(l.buckets, k)
+			}
+		}
+		l.swept = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: r.limiter()}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	return b.limiter
+}
+
+// clientIP returns the request's client address, taken from ipHeader when
+// a trusted proxy sets one
+func clientIP(r *http.Request, ipHeader string) string {
+	if ipHeader != "" {
+		// X-Forwarded-For style lists: the proxy appends the address it saw last
+		if value := r.Header.Get(ipHeader); value != "" {
+			parts := strings.Split(value, ",")
+			return strings.TrimSpace(parts[len(parts)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// allow takes a token from the bucket, or answers 429 with the seconds
+// until one is available
+func allow(w http.ResponseWriter, limiter *rate.Limiter) bool {
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return true
+	}
+	reservation.Cancel()
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	writeJSONError(w, "Rate limit exceeded", http.StatusTooManyRequests)
+	return false
+}
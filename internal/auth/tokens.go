@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scope is a permission a token grants
+type Scope string
+
+const (
+	ScopeExecute   Scope = "execute"    // Run code, install packages, start services and processes
+	ScopeUpload    Scope = "upload"     // Upload files and create ingest links
+	ScopeReadFiles Scope = "read-files" // Read sandbox files, output, history and share links
+	ScopeAdmin     Scope = "admin"      // /admin endpoints, /metrics and /api/executions
+)
+
+// AllScopes are granted to MCP_API_TOKEN
+var AllScopes = []Scope{ScopeExecute, ScopeUpload, ScopeReadFiles, ScopeAdmin}
+
+// Identity is who a request was authenticated as
+type Identity struct {
+	Name   string // Token name, or "jwt:" and the JWT subject
+	Scopes []Scope
+}
+
+// Has reports whether the identity was granted scope
+func (i Identity) Has(scope Scope) bool {
+	return slices.Contains(i.Scopes, scope)
+}
+
+type identityKey struct{}
+
+// WithIdentity attaches an authenticated identity to a context
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the identity a request was authenticated as
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+// Allowed reports whether a request may use scope. Requests that didn't go
+// through the middleware (e.g. run-once) are not restricted
+func Allowed(ctx context.Context, scope Scope) bool {
+	identity, ok := FromContext(ctx)
+	return !ok || identity.Has(scope)
+}
+
+// tokenFile is the YAML format of API_TOKENS_FILE
+type tokenFile struct {
+	Tokens []struct {
+		Name   string  `yaml:"name"`
+		SHA256 string  `yaml:"sha256"` // Hex digest of the token (preferred)
+		Token  string  `yaml:"token"`  // The token itself
+		Scopes []Scope `yaml:"scopes"`
+	} `yaml:"tokens"`
+}
+
+// TokenStore holds the API tokens the server accepts as digests, so the
+// tokens themselves needn't be kept
+type TokenStore struct {
+	path string // API_TOKENS_FILE; empty for MCP_API_TOKEN only
+
+	reloadMu  sync.Mutex         // Serializes loads
+	apiDigest *[sha256.Size]byte // MCP_API_TOKEN; nil when unset
+
+	mu      sync.RWMutex
+	tokens  []storedToken
+	modTime time.Time
+}
+
+// storedToken is a token's digest and who it authenticates
+type storedToken struct {
+	digest   [sha256.Size]byte
+	identity Identity
+}
+
+// LoadTokens creates a token store from MCP_API_TOKEN, which gets every
+// scope, and the tokens in path, if set. The API token may instead be given
+// as the hex SHA-256 digest apiTokenSHA256 (MCP_API_TOKEN_SHA256)
+func LoadTokens(path, apiToken, apiTokenSHA256 string) (*TokenStore, error) {
+	apiDigest, err := apiTokenDigest(apiToken, apiTokenSHA256)
+	if err != nil {
+		return nil, err
+	}
+	s := &TokenStore{path: path, apiDigest: apiDigest}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload replaces MCP_API_TOKEN and rereads the token file. On error the
+// previous tokens stay in effect
+func (s *TokenStore) Reload(apiToken, apiTokenSHA256 string) error {
+	apiDigest, err := apiTokenDigest(apiToken, apiTokenSHA256)
+	if err != nil {
+		return err
+	}
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	previous := s.apiDigest
+	s.apiDigest = apiDigest
+	if err := s.load(); err != nil {
+		s.apiDigest = previous
+		return err
+	}
+	return nil
+}
+
+// apiTokenDigest returns the digest of MCP_API_TOKEN, given as the token or
+// its hex SHA-256, or nil when neither is set
+func apiTokenDigest(apiToken, apiTokenSHA256 string) (*[sha256.Size]byte, error) {
+	switch {
+	case apiToken != "" && apiTokenSHA256 != "":
+		return nil, fmt.Errorf("set MCP_API_TOKEN or MCP_API_TOKEN_SHA256, not both")
+	case apiToken != "":
+		digest := sha256.Sum256([]byte(apiToken))
+		return &digest, nil
+	case apiTokenSHA256 != "":
+		digest, err := parseDigest(apiTokenSHA256)
+		if err != nil {
+			return nil, fmt.Errorf("MCP_API_TOKEN_SHA256: %w", err)
+		}
+		return &digest, nil
+	}
+	return nil, nil
+}
+
+// Len returns the number of tokens
+func (s *TokenStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.tokens)
+}
+
+// Lookup returns the identity a token belongs to. Every stored digest is
+// compared in constant time, so timing reveals neither which token nor how
+// much of one matched
+func (s *TokenStore) Lookup(token string) (Identity, bool) {
+	if token == "" {
+		return Identity{}, false
+	}
+	digest := sha256.Sum256([]byte(token))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found Identity
+	ok := 0
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(digest[:], t.digest[:]) == 1 {
+			found = t.identity
+			ok = 1
+		}
+	}
+	return found, ok == 1
+}
+
+// Watch reloads the token file every interval when it has changed, until
+// ctx is done. A file that fails to load leaves the previous tokens in place
+func (s *TokenStore) Watch(ctx context.Context, interval time.Duration) {
+	if s.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			if err != nil {
+				log.Printf("Failed to check API tokens file: %v", err)
+				continue
+			}
+			s.mu.RLock()
+			changed := !info.ModTime().Equal(s.modTime)
+			s.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			s.reloadMu.Lock()
+			err = s.load()
+			s.reloadMu.Unlock()
+			if err != nil {
+				log.Printf("Failed to reload API tokens, keeping the previous ones: %v", err)
+				continue
+			}
+			log.Printf("Reloaded %d API token(s) from %s", s.Len(), s.path)
+		}
+	}
+}
+
+// load reads the token file and replaces the store's tokens
+func (s *TokenStore) load() error {
+	var tokens []storedToken
+	seen := make(map[[sha256.Size]byte]bool)
+	if s.apiDigest != nil {
+		tokens = append(tokens, storedToken{digest: *s.apiDigest, identity: Identity{Name: "MCP_API_TOKEN", Scopes: AllScopes}})
+		seen[*s.apiDigest] = true
+	}
+
+	var modTime time.Time
+	if s.path != "" {
+		info, err := os.Stat(s.path)
+		if err != nil {
+			return fmt.Errorf("failed to read API tokens file: %w", err)
+		}
+		modTime = info.ModTime()
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			return fmt.Errorf("failed to read API tokens file: %w", err)
+		}
+		var file tokenFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to parse API tokens file: %w", err)
+		}
+
+		names := make(map[string]bool)
+		for i, t := range file.Tokens {
+			if t.Name == "" {
+				return fmt.Errorf("token %d has no name", i+1)
+			}
+			if names[t.Name] {
+				return fmt.Errorf("duplicate token name %q", t.Name)
+			}
+			names[t.Name] = true
+
+			var digest [sha256.Size]byte
+			switch {
+			case t.SHA256 != "" && t.Token != "":
+				return fmt.Errorf("token %q: set sha256 or token, not both", t.Name)
+			case t.SHA256 != "":
+				var err error
+				if digest, err = parseDigest(t.SHA256); err != nil {
+					return fmt.Errorf("token %q: %w", t.Name, err)
+				}
+			case len(t.Token) >= 16:
+				digest = sha256.Sum256([]byte(t.Token))
+			default:
+				return fmt.Errorf("token %q: sha256 or a token of at least 16 characters is required", t.Name)
+			}
+			for _, scope := range t.Scopes {
+				if !slices.Contains(AllScopes, scope) {
+					return fmt.Errorf("token %q: unknown scope %q (known: %s)", t.Name, scope, scopeList(AllScopes))
+				}
+			}
+			if seen[digest] {
+				return fmt.Errorf("token %q is already in use", t.Name)
+			}
+			seen[digest] = true
+			tokens = append(tokens, storedToken{digest: digest, identity: Identity{Name: t.Name, Scopes: t.Scopes}})
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = tokens
+	s.modTime = modTime
+	return nil
+}
+
+// HashToken returns the sha256 digest to put in the tokens file for token
+func HashToken(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+// parseDigest decodes a hex SHA-256 digest
+func parseDigest(value string) ([sha256.Size]byte, error) {
+	var digest [sha256.Size]byte
+	decoded, err := hex.DecodeString(value)
+	if err != nil || len(decoded) != sha256.Size {
+		return digest, fmt.Errorf("sha256 must be 64 hex characters")
+	}
+	copy(digest[:], decoded)
+	return digest, nil
+}
+
+func scopeList(scopes []Scope) string {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,178 @@
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+)
+
+// redactedValue replaces secret values in a bundle
+const redactedValue = "[REDACTED]"
+
+// InputFile describes a sandbox file present before the execution ran
+type InputFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Limits describes the resource limits the execution ran under
+type Limits struct {
+	TimeoutSeconds float64 `json:"timeoutSeconds"`
+	MemoryBytes    int64   `json:"memoryBytes"`
+	NanoCPUs       int64   `json:"nanoCpus"`
+	Network        bool    `json:"network"`
+	Restricted     bool    `json:"restricted,omitempty"` // Egress only to allowlisted domains
+}
+
+// Result describes the outcome of the execution
+type Result struct {
+	ExitCode int    `json:"exitCode"`
+	TimedOut bool   `json:"timedOut"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+
+	// Host state when the container itself could not be run
+	Diagnostics *runner.Diagnostics `json:"diagnostics,omitempty"`
+}
+
+// Bundle packages everything needed to reproduce an execution offline.
+// Environment values are redacted; only their names and a fingerprint remain.
+type Bundle struct {
+	CreatedAt        time.Time         `json:"createdAt"`
+	ConversationName string            `json:"conversationName,omitempty"`
+	Language         string            `json:"language"`
+	Image            string            `json:"image"`
+	ImageID          string            `json:"imageId"`
+	Code             string            `json:"code"`
+	Shell            bool              `json:"shell,omitempty"` // Code is a /bin/sh -c command (run_shell)
+	Stdin            string            `json:"stdin,omitempty"` // Piped to the program
+	Entrypoint       string            `json:"entrypoint,omitempty"`
+	Inputs           []InputFile       `json:"inputs"`
+	Environment      map[string]string `json:"environment"`
+	EnvFingerprint   string            `json:"envFingerprint"`
+	Limits           Limits            `json:"limits"`
+	Result           Result            `json:"result"`
+}
+
+// Manifest hashes the regular files in dir so inputs can be verified later
+func Manifest(dir string) ([]InputFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []InputFile{}, nil
+		}
+		return nil, fmt.Errorf("failed to read sandbox directory: %w", err)
+	}
+
+	inputs := make([]InputFile, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		input, err := hashFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		input.Name = entry.Name()
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+func hashFile(path string) (InputFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return InputFile{}, fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return InputFile{}, fmt.Errorf("failed to hash %s: %w", filepath.Base(path), err)
+	}
+	return InputFile{Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Redact replaces environment values with a placeholder, computes a
+// fingerprint over the original values, and scrubs any of those values
+// from the captured output
+func (b *Bundle) Redact(environment map[string]string) {
+	keys := make([]string, 0, len(environment))
+	for key := range environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	redacted := make(map[string]string, len(environment))
+	for _, key := range keys {
+		value := environment[key]
+		fmt.Fprintf(h, "%s=%s\n", key, value)
+		redacted[key] = redactedValue
+
+		// Skip very short values to avoid mangling unrelated output
+		if len(value) >= 4 {
+			b.Result.Stdout = strings.ReplaceAll(b.Result.Stdout, value, redactedValue)
+			b.Result.Stderr = strings.ReplaceAll(b.Result.Stderr, value, redactedValue)
+		}
+	}
+
+	b.Environment = redacted
+	b.EnvFingerprint = hex.EncodeToString(h.Sum(nil))
+}
+
+// Store keeps the most recent failed execution bundle per conversation
+type Store struct {
+	mu      sync.Mutex
+	bundles map[string]*Bundle
+	order   []string
+	max     int
+}
+
+// NewStore creates a bundle store holding at most max conversations
+func NewStore(max int) *Store {
+	if max <= 0 {
+		max = 100
+	}
+	return &Store{
+		bundles: make(map[string]*Bundle),
+		max:     max,
+	}
+}
+
+// Put records a bundle for a conversation, evicting the oldest when full
+func (s *Store) Put(conversationID string, b *Bundle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.bundles[conversationID]; !exists {
+		s.order = append(s.order, conversationID)
+	}
+	s.bundles[conversationID] = b
+
+	for len(s.order) > s.max {
+		oldest := s.order[0]
+		s.order = s.order[1:]
+		delete(s.bundles, oldest)
+	}
+}
+
+// Get returns the last failed execution bundle for a conversation
+func (s *Store) Get(conversationID string) (*Bundle, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.bundles[conversationID]
+	return b, ok
+}
@@ -2,54 +2,688 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
 )
 
 // Config holds all configuration for the MCP sandbox server
 type Config struct {
-	HTTPAddr        string
+	HTTPAddr        string // Loopback only unless MCP_HTTP_ADDR names another interface
+	GRPCAddr        string // GRPC_ADDR; empty disables the gRPC API
 	APIToken        string
+	APITokenSHA256  string // MCP_API_TOKEN_SHA256, the API token's digest in place of MCP_API_TOKEN
 	SandboxRoot     string // Path where server reads/writes files (filesystem operations)
 	SandboxHostPath string // Path on Docker host for bind mounts (Docker operations) - may be same as SandboxRoot
 	FileSecret      string
 	PublicBaseURL   string
+	BasePath        string // Route prefix when mounted under a sub-path (e.g. "/sandbox"), empty for root
 	DockerHost      string
-}
 
-// Load reads configuration from environment variables
-func Load() (*Config, error) {
-	sandboxRoot := os.Getenv("SANDBOX_ROOT")
+	// Browser origins allowed to call /mcp and the admin API besides
+	// PUBLIC_BASE_URL's, "*" for any (ALLOWED_ORIGINS)
+	AllowedOrigins []string
 
-	cfg := &Config{
-		HTTPAddr:        getEnvOrDefault("MCP_HTTP_ADDR", ":8080"),
-		APIToken:        os.Getenv("MCP_API_TOKEN"),
-		SandboxRoot:     sandboxRoot,
-		SandboxHostPath: getEnvOrDefault("SANDBOX_HOST_PATH", sandboxRoot), // Default to SandboxRoot if not set
-		FileSecret:      os.Getenv("FILE_SECRET"),
-		PublicBaseURL:   os.Getenv("PUBLIC_BASE_URL"),
-		DockerHost:      os.Getenv("DOCKER_HOST"),
+	// HTTPS: a certificate and key (TLS_CERT_FILE, TLS_KEY_FILE), or
+	// Let's Encrypt certificates for PUBLIC_BASE_URL's host (TLS_AUTOCERT)
+	TLSCertFile      string
+	TLSKeyFile       string
+	TLSAutocert      bool
+	TLSAutocertEmail string // TLS_AUTOCERT_EMAIL, for expiry notices
+	TLSAutocertCache string // TLS_AUTOCERT_CACHE
+	TLSRedirectAddr  string // TLS_REDIRECT_ADDR, plain HTTP listener redirecting to HTTPS
+
+	// Container engine behind the Docker API: docker or podman (CONTAINER_BACKEND)
+	ContainerBackend string
+
+	// Additional Docker hosts to schedule executions on (DOCKER_HOSTS)
+	DockerHosts []string
+
+	// Run each conversation's containers under its own high UID (SANDBOX_ISOLATE_UIDS)
+	IsolateUIDs bool
+
+	// Free space required on the sandbox filesystem before running code (SANDBOX_MIN_FREE)
+	MinFreeBytes int64
+
+	// Extra time after an execution's deadline before the watchdog force-removes its container (WATCHDOG_GRACE)
+	WatchdogGrace time.Duration
+
+	// Linux capabilities runner images may request via the sandbox.cap-add label
+	AllowedRunnerCaps []string
+
+	// Optional YAML/JSON file registering runners in addition to label discovery
+	RunnersConfig string
+
+	// Runner images pulled at startup when missing locally
+	RunnerImages []string
+
+	// SQLite database recording run_code executions (HISTORY_DB)
+	HistoryDB string
+
+	// Body size limit for signed /ingest uploads (INGEST_MAX_SIZE)
+	IngestMaxBytes int64
+
+	// Body size limit for /mcp and the REST API's JSON endpoints (MAX_REQUEST_SIZE)
+	RequestMaxBytes int64
+
+	// Most bytes an archive uploaded with extract may expand to (UPLOAD_EXTRACT_MAX_SIZE)
+	ExtractMaxBytes int64
+
+	// Bounds on files put in a sandbox by uploads, downloads and clones: the
+	// largest file (UPLOAD_MAX_FILE_SIZE), the sandbox's total size
+	// (SANDBOX_MAX_SIZE) and file count (SANDBOX_MAX_FILES); 0 for no limit
+	UploadMaxFileBytes int64
+	SandboxMaxBytes    int64
+	SandboxMaxFiles    int
+
+	// Delete sandboxes inactive for longer than this (SANDBOX_RETENTION, 0 = keep forever)
+	Retention  time.Duration
+	GCInterval time.Duration // SANDBOX_GC_INTERVAL
+	GCDryRun   bool          // Only log what automatic GC would delete (SANDBOX_GC_DRY_RUN)
+
+	// Append-only log of administrative actions such as purges (AUDIT_LOG)
+	AuditLog string
+
+	// YAML file overriding user-facing server messages, e.g. to translate them (MESSAGES_FILE)
+	MessagesFile string
+
+	// Directory of sandbox templates for create_from_template (TEMPLATES_DIR)
+	TemplatesDir string
+
+	// Package name globs run_code and install_package may install with network access (INSTALL_ALLOWED_PACKAGES)
+	InstallAllowedPackages []string
+
+	// Package name globs that may never be installed (INSTALL_DENIED_PACKAGES)
+	InstallDeniedPackages []string
+
+	// Largest a conversation's package cache may grow (PACKAGE_CACHE_MAX_SIZE, 0 for no limit)
+	PackageCacheMaxBytes int64
+
+	// Share downloaded package archives between each tenant's installs (PACKAGE_DOWNLOAD_CACHE)
+	DownloadCache bool
+
+	// Domains network: "restricted" executions may reach (EGRESS_ALLOWED_DOMAINS)
+	EgressAllowedDomains []string
+
+	// Image the egress proxy sidecar runs from; must contain this server (EGRESS_PROXY_IMAGE)
+	EgressProxyImage string
+
+	// Domains fetch_file may download from (FETCH_ALLOWED_DOMAINS; empty
+	// disables the tool), the largest download (FETCH_MAX_SIZE) and the media
+	// types accepted (FETCH_CONTENT_TYPES)
+	FetchAllowedDomains []string
+	FetchMaxBytes       int64
+	FetchContentTypes   []string
+
+	// Hosts clone_repo may clone from (CLONE_ALLOWED_HOSTS; empty disables
+	// the tool), the largest clone including history (CLONE_MAX_SIZE) and the
+	// most commits of history a clone may fetch (CLONE_MAX_DEPTH)
+	CloneAllowedHosts []string
+	CloneMaxBytes     int64
+	CloneMaxDepth     int
+
+	// Let scripts in downloaded HTML files run, in an opaque origin, so
+	// interactive reports work (HTML_REPORTS), and the origins they may load
+	// scripts, styles, fonts and images from (HTML_REPORT_SOURCES)
+	HTMLReports       bool
+	HTMLReportSources []string
+
+	// Helper services conversations may start, e.g. postgres,redis (SANDBOX_SERVICES)
+	Services []string
+
+	// Containers that may run at once, overall and per conversation (0 for
+	// no limit), and how many more may wait for a slot before being rejected
+	MaxConcurrent                int // MAX_CONCURRENT_EXECUTIONS
+	MaxConcurrentPerConversation int // MAX_CONCURRENT_PER_CONVERSATION
+	ExecutionQueueSize           int // EXECUTION_QUEUE_SIZE
+
+	// Security baseline for runner containers
+	ReadonlyRootfs  bool     // RUNNER_READONLY_ROOTFS
+	CapDrop         []string // RUNNER_CAP_DROP, "none" to keep Docker's defaults
+	NoNewPrivileges bool     // RUNNER_NO_NEW_PRIVILEGES
+	PidsLimit       int      // RUNNER_PIDS_LIMIT (0 for no limit)
+	TmpfsBytes      int64    // RUNNER_TMPFS_SIZE (0 for no tmpfs at /tmp)
+
+	// Bytes of stdout and of stderr kept per execution (RUNNER_MAX_OUTPUT)
+	MaxOutputBytes int64
+
+	// Seccomp profile file (or "unconfined") for all runners (RUNNER_SECCOMP_PROFILE)
+	SeccompProfile string
+
+	// Directory of named seccomp profiles runners may select (RUNNER_SECCOMP_DIR)
+	SeccompDir string
+
+	// AppArmor profile for all runners, loaded on the host (RUNNER_APPARMOR_PROFILE)
+	AppArmorProfile string
+
+	// Host GPUs sandbox.gpu runners get when run_code asks: a count per
+	// execution, -1 for all, 0 to disable (RUNNER_GPUS), or specific devices
+	// (RUNNER_GPU_DEVICES)
+	GPUCount   int
+	GPUDevices []string
+
+	// Cosign verification of runner images: signed with a public key file or
+	// KMS URI (RUNNER_COSIGN_KEY), or keyless by an identity as issued by an
+	// OIDC issuer (RUNNER_COSIGN_IDENTITY, RUNNER_COSIGN_ISSUER)
+	CosignKey      string
+	CosignIdentity string
+	CosignIssuer   string
+
+	// Web app previews: executions may publish a port on PreviewBindAddress,
+	// proxied under /preview/ (PREVIEW_ENABLED)
+	PreviewEnabled      bool
+	PreviewBindAddress  string // PREVIEW_BIND_ADDRESS
+	PreviewUpstreamHost string // PREVIEW_UPSTREAM_HOST, where the server reaches published ports
+
+	// Background processes (start_process); MaxProcessesPerConversation 0 disables them
+	MaxProcessesPerConversation int           // MAX_PROCESSES_PER_CONVERSATION
+	ProcessMaxLifetime          time.Duration // PROCESS_MAX_LIFETIME
+	ProcessIdleTimeout          time.Duration // PROCESS_IDLE_TIMEOUT
+
+	// Jupyter kernels for run_code's kernel mode are removed after this long
+	// without an execution; 0 disables them (KERNEL_IDLE_TIMEOUT)
+	KernelIdleTimeout time.Duration
+
+	// Named tokens with scopes, accepted alongside APIToken (API_TOKENS_FILE)
+	APITokensFile string
+
+	// Interval of SSE keepalive comments on idle streams, so proxies don't
+	// drop them during long executions (SSE_KEEPALIVE; 0 disables)
+	SSEKeepAlive time.Duration
+
+	// How long events sent on SSE streams are kept so clients can resume
+	// with Last-Event-ID (SSE_EVENT_RETENTION; 0 disables resumption)
+	SSEEventRetention time.Duration
+
+	// MCP sessions expire after SESSION_IDLE_TIMEOUT without requests; at
+	// most MAX_SESSIONS are kept, evicting the one idle longest
+	SessionIdleTimeout time.Duration
+	MaxSessions        int
+
+	// Where sandbox files are kept: local (the sandbox root only) or s3,
+	// which syncs them to an S3-compatible bucket so instances can share
+	// conversations (STORAGE_BACKEND)
+	StorageBackend    string
+	S3Endpoint        string // S3_ENDPOINT, empty for AWS in S3Region
+	S3Bucket          string // S3_BUCKET
+	S3Region          string // S3_REGION
+	S3Prefix          string // S3_PREFIX
+	S3AccessKeyID     string // S3_ACCESS_KEY_ID
+	S3SecretAccessKey string // S3_SECRET_ACCESS_KEY
+	S3SessionToken    string // S3_SESSION_TOKEN
+	S3PathStyle       bool   // S3_PATH_STYLE, default true with S3_ENDPOINT
+
+	// Failed authentication attempts from one IP before it is locked out
+	// (0 disables lockout), and the delay added to every failed attempt
+	AuthMaxFailures  int           // AUTH_MAX_FAILURES
+	AuthLockout      time.Duration // AUTH_LOCKOUT, also how long failures are remembered
+	AuthFailureDelay time.Duration // AUTH_FAILURE_DELAY
+
+	// Named secrets run_code may inject (SECRETS_FILE), and the Vault server
+	// vault secrets are read from
+	SecretsFile string
+	VaultAddr   string // VAULT_ADDR
+	VaultToken  string // VAULT_TOKEN
+
+	// Rate limits on /mcp and /files (auth.Rate; zero disables)
+	RateLimitPerToken auth.Rate // RATE_LIMIT_PER_TOKEN
+	RateLimitPerIP    auth.Rate // RATE_LIMIT_PER_IP
+	RateLimitGlobal   auth.Rate // RATE_LIMIT_GLOBAL
+	RateLimitIPHeader string    // RATE_LIMIT_IP_HEADER, set by a trusted proxy
+
+	// JWT bearer authentication, accepted alongside APIToken when JWKSURL is set
+	JWKSURL     string // JWT_JWKS_URL
+	JWTIssuer   string // JWT_ISSUER
+	JWTAudience string // JWT_AUDIENCE
+}
+
+// Load reads configuration from environment variables and, when path is
+// set, a YAML config file they override. flags, keyed by variable name,
+// override both. The error lists every problem
+func Load(path string, flags map[string]string) (*Config, error) {
+	vars, err := loadSettings(path, flags)
+	if err != nil {
+		return nil, err
 	}
+	cfg, errs := fromEnv(vars)
 
 	// Validate required fields
-	if cfg.APIToken == "" {
-		return nil, fmt.Errorf("MCP_API_TOKEN is required")
+	if cfg.APIToken == "" && cfg.APITokenSHA256 == "" && cfg.APITokensFile == "" && cfg.JWKSURL == "" {
+		errs = append(errs, fmt.Errorf("MCP_API_TOKEN is required (or MCP_API_TOKEN_SHA256, API_TOKENS_FILE or JWT_JWKS_URL)"))
+	}
+	if cfg.JWKSURL != "" && (cfg.JWTIssuer == "" || cfg.JWTAudience == "") {
+		errs = append(errs, fmt.Errorf("JWT_ISSUER and JWT_AUDIENCE are required with JWT_JWKS_URL"))
 	}
 	if cfg.SandboxRoot == "" {
-		return nil, fmt.Errorf("SANDBOX_ROOT is required")
+		errs = append(errs, fmt.Errorf("SANDBOX_ROOT is required"))
 	}
 	if cfg.FileSecret == "" {
-		return nil, fmt.Errorf("FILE_SECRET is required")
+		errs = append(errs, fmt.Errorf("FILE_SECRET is required"))
 	}
 	if cfg.PublicBaseURL == "" {
-		return nil, fmt.Errorf("PUBLIC_BASE_URL is required")
+		errs = append(errs, fmt.Errorf("PUBLIC_BASE_URL is required"))
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if cfg.TLSAutocert {
+		if cfg.TLSCertFile != "" {
+			errs = append(errs, fmt.Errorf("TLS_AUTOCERT can't be combined with TLS_CERT_FILE"))
+		}
+		u, err := url.Parse(cfg.PublicBaseURL)
+		if err != nil || u.Scheme != "https" || u.Hostname() == "" || net.ParseIP(u.Hostname()) != nil {
+			errs = append(errs, fmt.Errorf("TLS_AUTOCERT needs an https PUBLIC_BASE_URL with a domain name"))
+		}
+	}
+	if cfg.TLSRedirectAddr != "" && cfg.TLSCertFile == "" && !cfg.TLSAutocert {
+		errs = append(errs, fmt.Errorf("TLS_REDIRECT_ADDR needs TLS_CERT_FILE or TLS_AUTOCERT"))
+	}
+	errs = append(errs, vars.fileErrors()...)
+
+	if err := errs.orNil(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadRunOnce reads configuration for single-shot mode, which has no HTTP
+// server and therefore only needs the sandbox settings
+func LoadRunOnce(path string, flags map[string]string) (*Config, error) {
+	vars, err := loadSettings(path, flags)
+	if err != nil {
+		return nil, err
+	}
+	cfg, errs := fromEnv(vars)
+	if err := errs.orNil(); err != nil {
+		return nil, err
+	}
+
+	if cfg.SandboxRoot == "" {
+		cfg.SandboxRoot = os.TempDir()
+		cfg.SandboxHostPath = vars.getOr("SANDBOX_HOST_PATH", cfg.SandboxRoot)
 	}
 
 	return cfg, nil
 }
 
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// fromEnv builds a Config from settings, validating only formats. The
+// config is returned even when some settings are invalid
+func fromEnv(vars settings) (*Config, Errors) {
+	var errs Errors
+	sandboxRoot := vars.get("SANDBOX_ROOT")
+
+	minFree, err := units.RAMInBytes(vars.getOr("SANDBOX_MIN_FREE", "100m"))
+	if err != nil || minFree < 0 {
+		errs = append(errs, fmt.Errorf("invalid SANDBOX_MIN_FREE: %q", vars.get("SANDBOX_MIN_FREE")))
+	}
+	ingestMax, err := units.RAMInBytes(vars.getOr("INGEST_MAX_SIZE", "50m"))
+	if err != nil || ingestMax <= 0 {
+		errs = append(errs, fmt.Errorf("invalid INGEST_MAX_SIZE: %q", vars.get("INGEST_MAX_SIZE")))
+	}
+	requestMax, err := units.RAMInBytes(vars.getOr("MAX_REQUEST_SIZE", "150m"))
+	if err != nil || requestMax <= 0 {
+		errs = append(errs, fmt.Errorf("invalid MAX_REQUEST_SIZE: %q", vars.get("MAX_REQUEST_SIZE")))
+	}
+
+	extractMax, err := units.RAMInBytes(vars.getOr("UPLOAD_EXTRACT_MAX_SIZE", "200m"))
+	if err != nil || extractMax <= 0 {
+		errs = append(errs, fmt.Errorf("invalid UPLOAD_EXTRACT_MAX_SIZE: %q", vars.get("UPLOAD_EXTRACT_MAX_SIZE")))
+	}
+
+	uploadMaxFile, err := units.RAMInBytes(vars.getOr("UPLOAD_MAX_FILE_SIZE", "100m"))
+	if err != nil || uploadMaxFile < 0 {
+		errs = append(errs, fmt.Errorf("invalid UPLOAD_MAX_FILE_SIZE: %q", vars.get("UPLOAD_MAX_FILE_SIZE")))
+	}
+	sandboxMax, err := units.RAMInBytes(vars.getOr("SANDBOX_MAX_SIZE", "1g"))
+	if err != nil || sandboxMax < 0 {
+		errs = append(errs, fmt.Errorf("invalid SANDBOX_MAX_SIZE: %q", vars.get("SANDBOX_MAX_SIZE")))
+	}
+	sandboxMaxFiles, err := vars.getInt("SANDBOX_MAX_FILES", 10000)
+	if err != nil {
+		errs = append(errs, err)
+	} else if sandboxMaxFiles < 0 {
+		errs = append(errs, fmt.Errorf("SANDBOX_MAX_FILES must not be negative"))
+	}
+
+	fetchMax, err := units.RAMInBytes(vars.getOr("FETCH_MAX_SIZE", "100m"))
+	if err != nil || fetchMax <= 0 {
+		errs = append(errs, fmt.Errorf("invalid FETCH_MAX_SIZE: %q", vars.get("FETCH_MAX_SIZE")))
+	}
+	// Text, data, document, image and archive formats by default
+	fetchContentTypes := splitList(strings.ToLower(vars.getOr("FETCH_CONTENT_TYPES",
+		"text/*,application/json,application/xml,application/pdf,application/vnd.*,image/*,"+
+			"application/zip,application/gzip,application/x-gzip,application/x-tar,application/octet-stream")))
+
+	reportSources := splitList(vars.getOr("HTML_REPORT_SOURCES",
+		"https://cdn.plot.ly,https://cdn.jsdelivr.net,https://cdnjs.cloudflare.com,https://unpkg.com,"+
+			"https://code.jquery.com,https://netdna.bootstrapcdn.com,https://tile.openstreetmap.org,https://*.tile.openstreetmap.org"))
+	for _, source := range reportSources {
+		// Sources go into the Content-Security-Policy verbatim
+		if !strings.HasPrefix(source, "https://") || strings.ContainsAny(source, "; '\"\t") {
+			errs = append(errs, fmt.Errorf("invalid HTML_REPORT_SOURCES entry: %q (want https://host)", source))
+		}
+	}
+
+	cloneMax, err := units.RAMInBytes(vars.getOr("CLONE_MAX_SIZE", "200m"))
+	if err != nil || cloneMax <= 0 {
+		errs = append(errs, fmt.Errorf("invalid CLONE_MAX_SIZE: %q", vars.get("CLONE_MAX_SIZE")))
+	}
+	cloneMaxDepth, err := vars.getInt("CLONE_MAX_DEPTH", 50)
+	if err != nil {
+		errs = append(errs, err)
+	} else if cloneMaxDepth < 1 {
+		errs = append(errs, fmt.Errorf("CLONE_MAX_DEPTH must be at least 1"))
+	}
+
+	packageCacheMax, err := units.RAMInBytes(vars.getOr("PACKAGE_CACHE_MAX_SIZE", "1g"))
+	if err != nil || packageCacheMax < 0 {
+		errs = append(errs, fmt.Errorf("invalid PACKAGE_CACHE_MAX_SIZE: %q", vars.get("PACKAGE_CACHE_MAX_SIZE")))
+	}
+
+	var retention time.Duration
+	if v := vars.get("SANDBOX_RETENTION"); v != "" {
+		if retention, err = time.ParseDuration(v); err != nil || retention < 0 {
+			errs = append(errs, fmt.Errorf("invalid SANDBOX_RETENTION: %q", v))
+		}
+	}
+	gcInterval, err := time.ParseDuration(vars.getOr("SANDBOX_GC_INTERVAL", "1h"))
+	if err != nil || gcInterval <= 0 {
+		errs = append(errs, fmt.Errorf("invalid SANDBOX_GC_INTERVAL: %q", vars.get("SANDBOX_GC_INTERVAL")))
+	}
+
+	watchdogGrace, err := time.ParseDuration(vars.getOr("WATCHDOG_GRACE", "30s"))
+	if err != nil || watchdogGrace < 0 {
+		errs = append(errs, fmt.Errorf("invalid WATCHDOG_GRACE: %q", vars.get("WATCHDOG_GRACE")))
+	}
+
+	backend := strings.ToLower(vars.getOr("CONTAINER_BACKEND", "docker"))
+	if backend != "docker" && backend != "podman" {
+		errs = append(errs, fmt.Errorf("invalid CONTAINER_BACKEND: %q (want docker or podman)", vars.get("CONTAINER_BACKEND")))
+	}
+	if backend == "podman" && vars.get("SANDBOX_ISOLATE_UIDS") == "true" {
+		// Rootless Podman can only map the server's own user into containers
+		errs = append(errs, fmt.Errorf("SANDBOX_ISOLATE_UIDS is not supported with CONTAINER_BACKEND=podman"))
+	}
+
+	storageBackend := strings.ToLower(vars.getOr("STORAGE_BACKEND", "local"))
+	switch storageBackend {
+	case "local":
+	case "s3":
+		if vars.get("S3_BUCKET") == "" {
+			errs = append(errs, fmt.Errorf("S3_BUCKET is required with STORAGE_BACKEND=s3"))
+		}
+		if vars.get("S3_ACCESS_KEY_ID") == "" || vars.get("S3_SECRET_ACCESS_KEY") == "" {
+			errs = append(errs, fmt.Errorf("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required with STORAGE_BACKEND=s3"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid STORAGE_BACKEND: %q (want local or s3)", vars.get("STORAGE_BACKEND")))
+	}
+	s3Region := vars.getOr("S3_REGION", "us-east-1")
+	s3Endpoint := vars.get("S3_ENDPOINT")
+	s3PathStyle := vars.getOr("S3_PATH_STYLE", fmt.Sprint(s3Endpoint != "")) == "true"
+	if s3Endpoint == "" {
+		s3Endpoint = "https://s3." + s3Region + ".amazonaws.com"
+	}
+
+	maxConcurrent, err := vars.getInt("MAX_CONCURRENT_EXECUTIONS", 8)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	maxPerConversation, err := vars.getInt("MAX_CONCURRENT_PER_CONVERSATION", 2)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	queueSize, err := vars.getInt("EXECUTION_QUEUE_SIZE", 32)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	pidsLimit, err := vars.getInt("RUNNER_PIDS_LIMIT", 256)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	tmpfsSize, err := units.RAMInBytes(vars.getOr("RUNNER_TMPFS_SIZE", "64m"))
+	if err != nil || tmpfsSize < 0 {
+		errs = append(errs, fmt.Errorf("invalid RUNNER_TMPFS_SIZE: %q", vars.get("RUNNER_TMPFS_SIZE")))
+	}
+	gpuCount := -1 // "all"
+	if vars.get("RUNNER_GPUS") != "all" {
+		if gpuCount, err = vars.getInt("RUNNER_GPUS", 0); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if (vars.get("RUNNER_COSIGN_IDENTITY") == "") != (vars.get("RUNNER_COSIGN_ISSUER") == "") {
+		errs = append(errs, fmt.Errorf("RUNNER_COSIGN_IDENTITY and RUNNER_COSIGN_ISSUER must be set together"))
+	} else if vars.get("RUNNER_COSIGN_KEY") != "" && vars.get("RUNNER_COSIGN_IDENTITY") != "" {
+		errs = append(errs, fmt.Errorf("RUNNER_COSIGN_KEY can't be combined with RUNNER_COSIGN_IDENTITY"))
+	}
+	maxOutput, err := units.RAMInBytes(vars.getOr("RUNNER_MAX_OUTPUT", "10m"))
+	if err != nil || maxOutput <= 0 {
+		errs = append(errs, fmt.Errorf("invalid RUNNER_MAX_OUTPUT: %q", vars.get("RUNNER_MAX_OUTPUT")))
+	}
+	maxProcesses, err := vars.getInt("MAX_PROCESSES_PER_CONVERSATION", 2)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	processLifetime, err := time.ParseDuration(vars.getOr("PROCESS_MAX_LIFETIME", "1h"))
+	if err != nil || processLifetime <= 0 {
+		errs = append(errs, fmt.Errorf("invalid PROCESS_MAX_LIFETIME: %q", vars.get("PROCESS_MAX_LIFETIME")))
+	}
+	processIdle, err := time.ParseDuration(vars.getOr("PROCESS_IDLE_TIMEOUT", "15m"))
+	if err != nil || processIdle <= 0 {
+		errs = append(errs, fmt.Errorf("invalid PROCESS_IDLE_TIMEOUT: %q", vars.get("PROCESS_IDLE_TIMEOUT")))
+	}
+
+	kernelIdle, err := time.ParseDuration(vars.getOr("KERNEL_IDLE_TIMEOUT", "30m"))
+	if err != nil || kernelIdle < 0 {
+		errs = append(errs, fmt.Errorf("invalid KERNEL_IDLE_TIMEOUT: %q", vars.get("KERNEL_IDLE_TIMEOUT")))
+	}
+
+	sseKeepAlive, err := time.ParseDuration(vars.getOr("SSE_KEEPALIVE", "15s"))
+	if err != nil || sseKeepAlive < 0 {
+		errs = append(errs, fmt.Errorf("invalid SSE_KEEPALIVE: %q", vars.get("SSE_KEEPALIVE")))
+	}
+	sseEventRetention, err := time.ParseDuration(vars.getOr("SSE_EVENT_RETENTION", "5m"))
+	if err != nil || sseEventRetention < 0 {
+		errs = append(errs, fmt.Errorf("invalid SSE_EVENT_RETENTION: %q", vars.get("SSE_EVENT_RETENTION")))
+	}
+	sessionIdle, err := time.ParseDuration(vars.getOr("SESSION_IDLE_TIMEOUT", "24h"))
+	if err != nil || sessionIdle <= 0 {
+		errs = append(errs, fmt.Errorf("invalid SESSION_IDLE_TIMEOUT: %q", vars.get("SESSION_IDLE_TIMEOUT")))
+	}
+	maxSessions, err := vars.getInt("MAX_SESSIONS", 10000)
+	if err != nil {
+		errs = append(errs, err)
+	} else if maxSessions < 1 {
+		errs = append(errs, fmt.Errorf("MAX_SESSIONS must be at least 1"))
+	}
+
+	authMaxFailures, err := vars.getInt("AUTH_MAX_FAILURES", 10)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	authLockout, err := time.ParseDuration(vars.getOr("AUTH_LOCKOUT", "15m"))
+	if err != nil || authLockout <= 0 {
+		errs = append(errs, fmt.Errorf("invalid AUTH_LOCKOUT: %q", vars.get("AUTH_LOCKOUT")))
+	}
+	authFailureDelay, err := time.ParseDuration(vars.getOr("AUTH_FAILURE_DELAY", "1s"))
+	if err != nil || authFailureDelay < 0 {
+		errs = append(errs, fmt.Errorf("invalid AUTH_FAILURE_DELAY: %q", vars.get("AUTH_FAILURE_DELAY")))
+	}
+
+	var rateLimits [3]auth.Rate
+	for i, limit := range []struct{ key, defaultValue string }{
+		{"RATE_LIMIT_PER_TOKEN", "300/m"},
+		{"RATE_LIMIT_PER_IP", "600/m"},
+		{"RATE_LIMIT_GLOBAL", ""},
+	} {
+		if rateLimits[i], err = auth.ParseRate(vars.getOr(limit.key, limit.defaultValue)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", limit.key, err))
+		}
+	}
+
+	capDrop := splitList(strings.ToUpper(vars.getOr("RUNNER_CAP_DROP", "ALL")))
+	if len(capDrop) == 1 && capDrop[0] == "NONE" {
+		capDrop = nil
+	}
+
+	cfg := &Config{
+		HTTPAddr:        vars.getOr("MCP_HTTP_ADDR", "127.0.0.1:8080"),
+		GRPCAddr:        vars.get("GRPC_ADDR"),
+		APIToken:        vars.get("MCP_API_TOKEN"),
+		APITokenSHA256:  vars.get("MCP_API_TOKEN_SHA256"),
+		SandboxRoot:     sandboxRoot,
+		SandboxHostPath: vars.getOr("SANDBOX_HOST_PATH", sandboxRoot), // Default to SandboxRoot if not set
+		FileSecret:      vars.get("FILE_SECRET"),
+		PublicBaseURL:   vars.get("PUBLIC_BASE_URL"),
+		BasePath:        normalizeBasePath(vars.get("BASE_PATH")),
+		AllowedOrigins:  splitList(vars.get("ALLOWED_ORIGINS")),
+		DockerHost:      vars.get("DOCKER_HOST"),
+		IsolateUIDs:     vars.get("SANDBOX_ISOLATE_UIDS") == "true",
+		MinFreeBytes:    minFree,
+		WatchdogGrace:   watchdogGrace,
+
+		AllowedRunnerCaps: splitList(strings.ToUpper(vars.get("RUNNER_ALLOWED_CAPS"))),
+		RunnersConfig:     vars.get("RUNNERS_CONFIG"),
+		RunnerImages:      splitList(vars.get("RUNNER_IMAGES")),
+		HistoryDB:         vars.get("HISTORY_DB"),
+		IngestMaxBytes:    ingestMax,
+		RequestMaxBytes:   requestMax,
+		ExtractMaxBytes:   extractMax,
+
+		UploadMaxFileBytes: uploadMaxFile,
+		SandboxMaxBytes:    sandboxMax,
+		SandboxMaxFiles:    sandboxMaxFiles,
+
+		InstallAllowedPackages: splitList(vars.get("INSTALL_ALLOWED_PACKAGES")),
+		InstallDeniedPackages:  splitList(vars.get("INSTALL_DENIED_PACKAGES")),
+		PackageCacheMaxBytes:   packageCacheMax,
+		DownloadCache:          vars.get("PACKAGE_DOWNLOAD_CACHE") == "true",
+		EgressAllowedDomains:   splitList(vars.get("EGRESS_ALLOWED_DOMAINS")),
+		EgressProxyImage:       vars.getOr("EGRESS_PROXY_IMAGE", "mcp-sandbox-server"),
+		FetchAllowedDomains:    splitList(vars.get("FETCH_ALLOWED_DOMAINS")),
+		FetchMaxBytes:          fetchMax,
+		FetchContentTypes:      fetchContentTypes,
+		CloneAllowedHosts:      splitList(strings.ToLower(vars.get("CLONE_ALLOWED_HOSTS"))),
+		CloneMaxBytes:          cloneMax,
+		CloneMaxDepth:          cloneMaxDepth,
+		HTMLReports:            vars.get("HTML_REPORTS") != "false",
+		HTMLReportSources:      reportSources,
+		Services:               splitList(strings.ToLower(vars.get("SANDBOX_SERVICES"))),
+		ContainerBackend:       backend,
+		TLSCertFile:            vars.get("TLS_CERT_FILE"),
+		TLSKeyFile:             vars.get("TLS_KEY_FILE"),
+		TLSAutocert:            vars.get("TLS_AUTOCERT") == "true",
+		TLSAutocertEmail:       vars.get("TLS_AUTOCERT_EMAIL"),
+		TLSAutocertCache:       vars.get("TLS_AUTOCERT_CACHE"),
+		TLSRedirectAddr:        vars.get("TLS_REDIRECT_ADDR"),
+		DockerHosts:            splitList(vars.get("DOCKER_HOSTS")),
+		TemplatesDir:           vars.get("TEMPLATES_DIR"),
+		MessagesFile:           vars.get("MESSAGES_FILE"),
+		Retention:              retention,
+		GCInterval:             gcInterval,
+		GCDryRun:               vars.get("SANDBOX_GC_DRY_RUN") == "true",
+		AuditLog:               vars.get("AUDIT_LOG"),
+
+		MaxConcurrent:                maxConcurrent,
+		MaxConcurrentPerConversation: maxPerConversation,
+		ExecutionQueueSize:           queueSize,
+
+		ReadonlyRootfs:  vars.get("RUNNER_READONLY_ROOTFS") != "false",
+		CapDrop:         capDrop,
+		NoNewPrivileges: vars.get("RUNNER_NO_NEW_PRIVILEGES") != "false",
+		PidsLimit:       pidsLimit,
+		TmpfsBytes:      tmpfsSize,
+		MaxOutputBytes:  maxOutput,
+		SeccompProfile:  vars.get("RUNNER_SECCOMP_PROFILE"),
+		SeccompDir:      vars.get("RUNNER_SECCOMP_DIR"),
+		AppArmorProfile: vars.get("RUNNER_APPARMOR_PROFILE"),
+		GPUCount:        gpuCount,
+		GPUDevices:      splitList(vars.get("RUNNER_GPU_DEVICES")),
+		CosignKey:       vars.get("RUNNER_COSIGN_KEY"),
+		CosignIdentity:  vars.get("RUNNER_COSIGN_IDENTITY"),
+		CosignIssuer:    vars.get("RUNNER_COSIGN_ISSUER"),
+
+		PreviewEnabled:      vars.get("PREVIEW_ENABLED") == "true",
+		PreviewBindAddress:  vars.getOr("PREVIEW_BIND_ADDRESS", "127.0.0.1"),
+		PreviewUpstreamHost: vars.get("PREVIEW_UPSTREAM_HOST"),
+
+		MaxProcessesPerConversation: maxProcesses,
+		ProcessMaxLifetime:          processLifetime,
+		ProcessIdleTimeout:          processIdle,
+
+		KernelIdleTimeout: kernelIdle,
+
+		APITokensFile: vars.get("API_TOKENS_FILE"),
+
+		SSEKeepAlive:       sseKeepAlive,
+		SSEEventRetention:  sseEventRetention,
+		SessionIdleTimeout: sessionIdle,
+		MaxSessions:        maxSessions,
+
+		StorageBackend:    storageBackend,
+		S3Endpoint:        s3Endpoint,
+		S3Bucket:          vars.get("S3_BUCKET"),
+		S3Region:          s3Region,
+		S3Prefix:          vars.get("S3_PREFIX"),
+		S3AccessKeyID:     vars.get("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey: vars.get("S3_SECRET_ACCESS_KEY"),
+		S3SessionToken:    vars.get("S3_SESSION_TOKEN"),
+		S3PathStyle:       s3PathStyle,
+
+		AuthMaxFailures:  authMaxFailures,
+		AuthLockout:      authLockout,
+		AuthFailureDelay: authFailureDelay,
+
+		SecretsFile: vars.get("SECRETS_FILE"),
+		VaultAddr:   vars.get("VAULT_ADDR"),
+		VaultToken:  vars.get("VAULT_TOKEN"),
+
+		RateLimitPerToken: rateLimits[0],
+		RateLimitPerIP:    rateLimits[1],
+		RateLimitGlobal:   rateLimits[2],
+		RateLimitIPHeader: vars.get("RATE_LIMIT_IP_HEADER"),
+
+		JWKSURL:     vars.get("JWT_JWKS_URL"),
+		JWTIssuer:   vars.get("JWT_ISSUER"),
+		JWTAudience: vars.get("JWT_AUDIENCE"),
+	}
+	if cfg.HistoryDB == "" && sandboxRoot != "" {
+		// Alongside other server metadata, outside the runner mounts
+		cfg.HistoryDB = filepath.Join(sandboxRoot, ".metadata", "history.db")
+	}
+	if cfg.AuditLog == "" && sandboxRoot != "" {
+		cfg.AuditLog = filepath.Join(sandboxRoot, ".metadata", "audit.log")
+	}
+	if cfg.TLSAutocertCache == "" && sandboxRoot != "" {
+		cfg.TLSAutocertCache = filepath.Join(sandboxRoot, ".metadata", "autocert")
+	}
+	return cfg, errs
+}
+
+// normalizeBasePath ensures a leading slash and strips trailing slashes,
+// so "sandbox/", "/sandbox" and "/sandbox/" all become "/sandbox"
+func normalizeBasePath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// splitList splits a comma-separated value, trimming blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	return defaultValue
+	return items
 }
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// settings looks up configuration values by environment variable name.
+// Command-line flags override environment variables, which override the
+// config file
+type settings struct {
+	flags   map[string]string // Command-line overrides, keyed by name
+	file    map[string]string // Config file values, keyed by upper-case name
+	invalid Errors            // Config file values that couldn't be read
+	used    map[string]bool   // Names looked up, to spot unknown file keys
+}
+
+// loadSettings reads the YAML config file at path, if any. Its keys are the
+// environment variable names, in any case, e.g.
+//
+//	public_base_url: https://sandbox.example.com
+//	max_concurrent_executions: 16
+//	runner_images: [mcp-runner-python, mcp-runner-typescript]
+//
+// Lists become comma-separated values
+func loadSettings(path string, flags map[string]string) (settings, error) {
+	s := settings{flags: flags, file: make(map[string]string), used: make(map[string]bool)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return s, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	for key, value := range raw {
+		text, err := settingValue(value)
+		if err != nil {
+			s.invalid = append(s.invalid, fmt.Errorf("%s in config file: %w", key, err))
+			s.used[strings.ToUpper(key)] = true
+			continue
+		}
+		s.file[strings.ToUpper(key)] = text
+	}
+	return s, nil
+}
+
+// settingValue converts a YAML value to the environment variable form
+func settingValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			text, err := settingValue(item)
+			if err != nil || strings.Contains(text, ",") {
+				return "", fmt.Errorf("list items must be plain values without commas")
+			}
+			items[i] = text
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("must be a value or a list")
+	}
+}
+
+// get returns the flag or environment variable key, or else its config
+// file value
+func (s settings) get(key string) string {
+	s.used[key] = true
+	if value, ok := s.flags[key]; ok {
+		return value
+	}
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return s.file[key]
+}
+
+// getOr returns the value of key, or defaultValue when it is unset
+func (s settings) getOr(key, defaultValue string) string {
+	if value := s.get(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getInt reads a non-negative integer, or defaultValue when unset
+func (s settings) getInt(key string, defaultValue int) (int, error) {
+	v := s.get(key)
+	if v == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, v)
+	}
+	return n, nil
+}
+
+// Keys returns the names of all settings, sorted
+func Keys() []string {
+	vars := settings{file: map[string]string{}, used: make(map[string]bool)}
+	fromEnv(vars)
+	keys := make([]string, 0, len(vars.used))
+	for key := range vars.used {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fileErrors reports config file values that couldn't be read, and keys
+// that no setting looked up, which are most likely typos
+func (s settings) fileErrors() Errors {
+	errs := append(Errors(nil), s.invalid...)
+	var keys []string
+	for key := range s.file {
+		if !s.used[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		errs = append(errs, fmt.Errorf("unknown setting %s in config file", strings.ToLower(key)))
+	}
+	return errs
+}
+
+// Errors lists every problem found in the configuration, so they can all
+// be fixed in one go
+type Errors []error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "\n  - " + err.Error()
+	}
+	return fmt.Sprintf("%d problems:%s", len(e), strings.Join(lines, ""))
+}
+
+// orNil returns nil for an empty list, so callers can compare with nil
+func (e Errors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,692 @@
+package docrender
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Options controls how documents link to other files
+type Options struct {
+	FileBaseURL string // Download URL prefix of the sandbox (FILE_BASE_URL)
+	Dir         string // Slash-separated directory of the document in the sandbox, "" for the top
+
+	attachments map[string]string // Notebook cell attachments: name -> data URI
+}
+
+// Markdown converts CommonMark-style Markdown (headings, paragraphs,
+// emphasis, code, links, images, lists, block quotes, rules and GitHub
+// tables) to HTML. All text is escaped and raw HTML is shown as text, so the
+// result is safe to embed. Relative links and images point into the sandbox
+// under FileBaseURL; links with other schemes than http, https and mailto are
+// dropped
+func Markdown(src []byte, opts Options) string {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\t", "    ")
+	r := &mdRenderer{opts: opts, ids: map[string]int{}}
+	r.blocks(strings.Split(text, "\n"), false)
+	return r.out.String()
+}
+
+type mdRenderer struct {
+	opts Options
+	out  strings.Builder
+	ids  map[string]int // Heading IDs used so far, to keep them unique
+}
+
+var (
+	headingRe   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	ruleRe      = regexp.MustCompile(`^ {0,3}(?:(?:\*\s*){3,}|(?:-\s*){3,}|(?:_\s*){3,})$`)
+	fenceRe     = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})\\s*([^`\\s]*)")
+	listItemRe  = regexp.MustCompile(`^( {0,3})([-*+]|(\d{1,9})[.)])( +|$)`)
+	tableRuleRe = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	setextRe    = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	taskRe      = regexp.MustCompile(`^\[([ xX])\]\s+`)
+)
+
+// blocks renders a sequence of block-level lines. In a tight list item
+// paragraphs are written without <p>
+func (r *mdRenderer) blocks(lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fenceRe.MatchString(line):
+			i = r.fencedCode(lines, i)
+
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			r.heading(len(m[1]), m[2])
+			i++
+
+		case ruleRe.MatchString(line):
+			r.out.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(strings.TrimLeft(line, " "), ">") && indent(line) < 4:
+			var quoted []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				l := strings.TrimLeft(lines[i], " ")
+				if rest, ok := strings.CutPrefix(l, ">"); ok {
+					l = strings.TrimPrefix(rest, " ")
+				}
+				quoted = append(quoted, l)
+			}
+			r.out.WriteString("<blockquote>\n")
+			r.blocks(quoted, false)
+			r.out.WriteString("</blockquote>\n")
+
+		case listItemRe.MatchString(line):
+			i = r.list(lines, i)
+
+		case indent(line) >= 4:
+			var code []string
+			for ; i < len(lines) && (indent(lines[i]) >= 4 || strings.TrimSpace(lines[i]) == ""); i++ {
+				code = append(code, strings.TrimPrefix(lines[i], "    "))
+			}
+			for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+				code = code[:len(code)-1]
+			}
+			r.out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "\n</code></pre>\n")
+
+		case strings.Contains(line, "|") && i+1 < len(lines) && tableRuleRe.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			i = r.table(lines, i)
+
+		default:
+			i = r.paragraph(lines, i, tight)
+		}
+	}
+}
+
+// fencedCode renders a ``` or ~~~ block starting at lines[i] and returns the
+// index after it
+func (r *mdRenderer) fencedCode(lines []string, i int) int {
+	m := fenceRe.FindStringSubmatch(lines[i])
+	pad, fence, lang := len(m[1]), m[2], m[3]
+	var code []string
+	for i++; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		line := lines[i]
+		for n := 0; n < pad && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+
+	r.out.WriteString("<pre><code")
+	if lang != "" {
+		r.out.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	r.out.WriteString(">")
+	if len(code) > 0 {
+		r.out.WriteString(html.EscapeString(strings.Join(code, "\n")) + "\n")
+	}
+	r.out.WriteString("</code></pre>\n")
+	return i
+}
+
+// heading writes an <hN> with an ID derived from its text, for anchors
+func (r *mdRenderer) heading(level int, text string) {
+	id := slug(text)
+	if n := r.ids[id]; n > 0 {
+		r.ids[id] = n + 1
+		id = fmt.Sprintf("%s-%d", id, n)
+	} else {
+		r.ids[id] = 1
+	}
+	fmt.Fprintf(&r.out, "<h%d id=\"%s\">%s</h%d>\n", level, html.EscapeString(id), r.inline(text), level)
+}
+
+// paragraph renders lines up to the next blank line or block start, or a
+// setext heading
+func (r *mdRenderer) paragraph(lines []string, i int, tight bool) int {
+	var para []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if len(para) > 0 {
+			if m := setextRe.FindStringSubmatch(line); m != nil {
+				level := 2
+				if m[1][0] == '=' {
+					level = 1
+				}
+				r.heading(level, strings.Join(para, " "))
+				return i + 1
+			}
+			if startsBlock(line) {
+				break
+			}
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		para = append(para, strings.TrimLeft(line, " "))
+	}
+
+	text := r.inline(strings.Join(para, "\n"))
+	if tight {
+		r.out.WriteString(text + "\n")
+	} else {
+		r.out.WriteString("<p>" + text + "</p>\n")
+	}
+	return i
+}
+
+// startsBlock reports whether line interrupts a paragraph
+func startsBlock(line string) bool {
+	if headingRe.MatchString(line) || ruleRe.MatchString(line) || fenceRe.MatchString(line) {
+		return true
+	}
+	if strings.HasPrefix(strings.TrimLeft(line, " "), ">") && indent(line) < 4 {
+		return true
+	}
+	// Only bullets and lists starting at 1 interrupt, as in CommonMark
+	if m := listItemRe.FindStringSubmatch(line); m != nil && m[4] != "" {
+		return m[3] == "" || m[3] == "1"
+	}
+	return false
+}
+
+// list renders a bullet or ordered list starting at lines[i] and returns the
+// index after it. Items hold the lines indented past their marker
+func (r *mdRenderer) list(lines []string, i int) int {
+	first := listItemRe.FindStringSubmatch(lines[i])
+	ordered := first[3] != ""
+	marker := first[2][len(first[2])-1:]
+
+	// An item of the same list: same kind and marker
+	sameList := func(line string) []string {
+		m := listItemRe.FindStringSubmatch(line)
+		if m == nil || (m[3] != "") != ordered || m[2][len(m[2])-1:] != marker {
+			return nil
+		}
+		return m
+	}
+
+	type item struct{ lines []string }
+	var items []item
+	tight := true
+	for i < len(lines) {
+		m := sameList(lines[i])
+		if m == nil {
+			break
+		}
+		width := len(m[0])
+		if m[4] == "" || len(m[4]) > 4 {
+			width = len(m[1]) + len(m[2]) + 1
+		}
+		it := item{lines: []string{lines[i][min(width, len(lines[i])):]}}
+
+		blank := false
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				blank = true
+				it.lines = append(it.lines, "")
+				continue
+			}
+			if indent(line) >= width {
+				if blank {
+					tight = false
+				}
+				blank = false
+				it.lines = append(it.lines, line[width:])
+				continue
+			}
+			// A lazy continuation of the item's paragraph
+			if !blank && !startsBlock(line) && !listItemRe.MatchString(line) {
+				it.lines = append(it.lines, strings.TrimLeft(line, " "))
+				continue
+			}
+			break
+		}
+		if blank && i < len(lines) && sameList(lines[i]) != nil {
+			tight = false
+		}
+		items = append(items, it)
+	}
+
+	switch {
+	case !ordered:
+		r.out.WriteString("<ul>\n")
+	case first[3] != "1":
+		n, _ := strconv.Atoi(first[3])
+		fmt.Fprintf(&r.out, "<ol start=\"%d\">\n", n)
+	default:
+		r.out.WriteString("<ol>\n")
+	}
+	for _, it := range items {
+		r.out.WriteString("<li>")
+		if m := taskRe.FindStringSubmatch(it.lines[0]); m != nil && !ordered {
+			if m[1] == " " {
+				r.out.WriteString(`<input type="checkbox" disabled> `)
+			} else {
+				r.out.WriteString(`<input type="checkbox" disabled checked> `)
+			}
+			it.lines[0] = it.lines[0][len(m[0]):]
+		}
+		r.blocks(it.lines, tight)
+		r.out.WriteString("</li>\n")
+	}
+	if ordered {
+		r.out.WriteString("</ol>\n")
+	} else {
+		r.out.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// table renders a GitHub table whose header is lines[i] and returns the
+// index after it
+func (r *mdRenderer) table(lines []string, i int) int {
+	header := tableCells(lines[i])
+	var aligns []string
+	for _, cell := range tableCells(lines[i+1]) {
+		cell = strings.TrimSpace(cell)
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "right")
+		case strings.HasPrefix(cell, ":"):
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	row := func(cells []string, tag string) {
+		r.out.WriteString("<tr>")
+		for n := range header {
+			text := ""
+			if n < len(cells) {
+				text = cells[n]
+			}
+			r.out.WriteString("<" + tag)
+			if n < len(aligns) && aligns[n] != "" {
+				r.out.WriteString(` style="text-align: ` + aligns[n] + `"`)
+			}
+			r.out.WriteString(">" + r.inline(strings.TrimSpace(text)) + "</" + tag + ">")
+		}
+		r.out.WriteString("</tr>\n")
+	}
+
+	r.out.WriteString("<table>\n<thead>\n")
+	row(header, "th")
+	r.out.WriteString("</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		row(tableCells(lines[i]), "td")
+	}
+	r.out.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+// tableCells splits a table row on unescaped pipes outside code spans
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	inCode := false
+	for n := 0; n < len(line); n++ {
+		c := line[n]
+		switch {
+		case c == '\\' && n+1 < len(line) && line[n+1] == '|':
+			cell.WriteByte('|')
+			n++
+		case c == '`':
+			inCode = !inCode
+			cell.WriteByte(c)
+		case c == '|' && !inCode:
+			cells = append(cells, cell.String())
+			cell.Reset()
+		default:
+			cell.WriteByte(c)
+		}
+	}
+	return append(cells, cell.String())
+}
+
+// inline renders emphasis, code spans, links, images, autolinks and line
+// breaks, escaping everything else
+func (r *mdRenderer) inline(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+
+		case c == '\\' && i+1 < len(text) && strings.IndexByte(asciiPunct, text[i+1]) >= 0:
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+
+		case c == '`':
+			run := countRun(text[i:], '`')
+			end := strings.Index(text[i+run:], strings.Repeat("`", run))
+			for end >= 0 && i+run+end+run < len(text) && text[i+run+end+run] == '`' {
+				next := strings.Index(text[i+run+end+run:], strings.Repeat("`", run))
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += run + next
+			}
+			if end < 0 {
+				b.WriteString(strings.Repeat("`", run))
+				i += run
+				continue
+			}
+			code := strings.ReplaceAll(text[i+run:i+run+end], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+				code = code[1 : len(code)-1]
+			}
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			i += run + end + run
+
+		case c == '!' && i+1 < len(text) && text[i+1] == '[':
+			label, target, n, ok := parseLink(text[i+1:])
+			if !ok {
+				b.WriteString("!")
+				i++
+				continue
+			}
+			alt := html.EscapeString(plainText(label))
+			if src := r.resolve(target, true); src != "" {
+				b.WriteString(`<img src="` + html.EscapeString(src) + `" alt="` + alt + `">`)
+			} else {
+				b.WriteString(alt)
+			}
+			i += 1 + n
+
+		case c == '[':
+			label, target, n, ok := parseLink(text[i:])
+			if !ok {
+				b.WriteString("[")
+				i++
+				continue
+			}
+			if href := r.resolve(target, false); href != "" {
+				b.WriteString(`<a href="` + html.EscapeString(href) + `">` + r.inline(label) + "</a>")
+			} else {
+				b.WriteString(r.inline(label))
+			}
+			i += n
+
+		case c == '<':
+			end := strings.IndexByte(text[i:], '>')
+			if end > 0 {
+				target := text[i+1 : i+end]
+				if !strings.ContainsAny(target, " \n<") && (strings.Contains(target, "://") || strings.Contains(target, "@")) {
+					href := target
+					if !strings.Contains(target, "://") {
+						href = "mailto:" + target
+					}
+					if href = r.resolve(href, false); href != "" {
+						b.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(target) + "</a>")
+						i += end + 1
+						continue
+					}
+				}
+			}
+			b.WriteString("&lt;")
+			i++
+
+		case (c == 'h' && (strings.HasPrefix(text[i:], "https://") || strings.HasPrefix(text[i:], "http://"))) &&
+			(i == 0 || !isWordByte(text[i-1])):
+			end := strings.IndexFunc(text[i:], func(r rune) bool { return unicode.IsSpace(r) || r == '<' })
+			if end < 0 {
+				end = len(text) - i
+			}
+			link := strings.TrimRight(text[i:i+end], ".,:;!?'\")*_")
+			b.WriteString(`<a href="` + html.EscapeString(link) + `">` + html.EscapeString(link) + "</a>")
+			i += len(link)
+
+		case c == '*' || c == '_' || c == '~':
+			n, inner, ok := emphasis(text, i)
+			if !ok {
+				b.WriteString(html.EscapeString(text[i : i+countRun(text[i:], c)]))
+				i += countRun(text[i:], c)
+				continue
+			}
+			tag := "em"
+			switch {
+			case c == '~':
+				tag = "del"
+			case n-len(inner) >= 4:
+				tag = "strong"
+			}
+			b.WriteString("<" + tag + ">" + r.inline(inner) + "</" + tag + ">")
+			i += n
+
+		case c == '\n':
+			if i >= 2 && text[i-2:i] == "  " {
+				b.WriteString("<br>")
+			}
+			b.WriteByte('\n')
+			i++
+
+		default:
+			b.WriteString(html.EscapeString(text[i : i+1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+// emphasis matches *em*, **strong**, _em_, __strong__ or ~~del~~ at text[i]
+// and returns the length of the whole span and its content
+func emphasis(text string, i int) (int, string, bool) {
+	c := text[i]
+	run := countRun(text[i:], c)
+	switch {
+	case c == '~' && run != 2:
+		return 0, "", false
+	case run > 2:
+		run = 2
+	}
+	start := i + run
+	// Openers are followed by text; _ doesn't work inside words
+	if start >= len(text) || unicode.IsSpace(rune(text[start])) {
+		return 0, "", false
+	}
+	if c == '_' && i > 0 && isWordByte(text[i-1]) {
+		return 0, "", false
+	}
+	for j := start + 1; j < len(text); j++ {
+		if text[j] != c {
+			continue
+		}
+		closing := countRun(text[j:], c)
+		// A single delimiter skips the ** of a nested strong; a double one
+		// may close right after a nested em, as in **a *b***
+		if closing < run || run == 1 && closing > 1 || unicode.IsSpace(rune(text[j-1])) {
+			j += closing - 1
+			continue
+		}
+		j += closing - run
+		if c == '_' && j+run < len(text) && isWordByte(text[j+run]) {
+			continue
+		}
+		return j + run - i, text[start:j], true
+	}
+	return 0, "", false
+}
+
+// parseLink parses [label](target "title") at the start of text and returns
+// the label, the target and the length consumed
+func parseLink(text string) (string, string, int, bool) {
+	depth := 0
+	closing := -1
+	for n := 0; n < len(text) && closing < 0; n++ {
+		switch text[n] {
+		case '\\':
+			n++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = n
+			}
+		case '`':
+			if end := strings.IndexByte(text[n+1:], '`'); end >= 0 {
+				n += end + 1
+			}
+		}
+	}
+	if closing < 0 || closing+1 >= len(text) || text[closing+1] != '(' {
+		return "", "", 0, false
+	}
+	depth = 0
+	end := -1
+	for n := closing + 1; n < len(text) && end < 0; n++ {
+		switch text[n] {
+		case '\\':
+			n++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				end = n
+			}
+		case '\n':
+			if strings.TrimSpace(text[closing+2:n]) == "" {
+				continue
+			}
+		}
+	}
+	if end < 0 {
+		return "", "", 0, false
+	}
+	target := strings.TrimSpace(text[closing+2 : end])
+	if strings.HasPrefix(target, "<") {
+		if gt := strings.IndexByte(target, '>'); gt > 0 {
+			target = target[1:gt]
+		}
+	} else if sp := strings.IndexAny(target, " \n"); sp >= 0 {
+		target = target[:sp] // Drop the title
+	}
+	return text[1:closing], target, end + 1, true
+}
+
+// resolve turns a link or image target into a URL that is safe to emit, or ""
+// if it must be dropped. Relative paths are resolved against the document's
+// directory and served from FileBaseURL
+func (r *mdRenderer) resolve(target string, image bool) string {
+	if target == "" {
+		return ""
+	}
+	if strings.HasPrefix(target, "#") {
+		return target
+	}
+	if name, ok := strings.CutPrefix(target, "attachment:"); ok && image {
+		return r.opts.attachments[name]
+	}
+	lower := strings.ToLower(target)
+	if image && strings.HasPrefix(lower, "data:image/") {
+		return target
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.String()
+	case "mailto":
+		if image {
+			return ""
+		}
+		return u.String()
+	case "":
+	default:
+		return ""
+	}
+	if u.Host != "" || strings.HasPrefix(u.Path, "/") || r.opts.FileBaseURL == "" {
+		return ""
+	}
+
+	rel := path.Join(r.opts.Dir, u.Path)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return ""
+	}
+	segments := strings.Split(rel, "/")
+	for n, segment := range segments {
+		segments[n] = url.PathEscape(segment)
+	}
+	resolved := r.opts.FileBaseURL + "/" + strings.Join(segments, "/")
+	if u.RawQuery != "" {
+		resolved += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		resolved += "#" + url.PathEscape(u.Fragment)
+	}
+	return resolved
+}
+
+// plainText strips the markup from a link label, for alt text
+func plainText(label string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune("*_`[]~", r) {
+			return -1
+		}
+		return r
+	}, label)
+}
+
+// slug derives a heading ID the way GitHub does: lower-case, punctuation
+// dropped, spaces as dashes
+func slug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(plainText(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteByte('-')
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
+
+// indent counts a line's leading spaces
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// countRun counts how often text starts with c
+func countRun(text string, c byte) int {
+	n := 0
+	for n < len(text) && text[n] == c {
+		n++
+	}
+	return n
+}
+
+// asciiPunct are the characters a backslash escapes
+const asciiPunct = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// isWordByte reports whether c is part of a word, for intraword _
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
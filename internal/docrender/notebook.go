@@ -0,0 +1,164 @@
+package docrender
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// multiline is notebook text, stored as a string or a list of lines
+type multiline string
+
+func (m *multiline) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*m = multiline(strings.Join(lines, ""))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*m = multiline(text)
+	return nil
+}
+
+type notebook struct {
+	NBFormat int `json:"nbformat"`
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType       string                          `json:"cell_type"`
+		Source         multiline                       `json:"source"`
+		ExecutionCount *int                            `json:"execution_count"`
+		Outputs        []notebookOutput                `json:"outputs"`
+		Attachments    map[string]map[string]multiline `json:"attachments"`
+	} `json:"cells"`
+}
+
+type notebookOutput struct {
+	OutputType string               `json:"output_type"`
+	Name       string               `json:"name"` // stdout or stderr
+	Text       multiline            `json:"text"`
+	Data       map[string]multiline `json:"data"`
+	EName      string               `json:"ename"`
+	EValue     string               `json:"evalue"`
+	Traceback  []string             `json:"traceback"`
+}
+
+// ErrNotNotebook is returned for files that aren't Jupyter notebooks in
+// format 4, the one Jupyter has written since 2015
+var ErrNotNotebook = errors.New("not a Jupyter notebook (nbformat 4)")
+
+// ansiRe matches the terminal color codes in tracebacks
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// imageTypes are the output images shown, most preferred first
+var imageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/svg+xml"}
+
+// Notebook converts a Jupyter notebook to HTML: Markdown cells as Markdown,
+// code cells with their saved outputs. Images are inlined as data URIs. HTML
+// and JavaScript outputs can't be sanitized, so their plain text version is
+// shown instead
+func Notebook(src []byte, opts Options) (string, error) {
+	var nb notebook
+	if err := json.Unmarshal(src, &nb); err != nil || nb.NBFormat != 4 {
+		return "", ErrNotNotebook
+	}
+	lang := nb.Metadata.LanguageInfo.Name
+
+	var b strings.Builder
+	for _, cell := range nb.Cells {
+		switch cell.CellType {
+		case "markdown":
+			cellOpts := opts
+			cellOpts.attachments = map[string]string{}
+			for name, data := range cell.Attachments {
+				if uri := imageURI(data); uri != "" {
+					cellOpts.attachments[name] = uri
+				}
+			}
+			b.WriteString(`<div class="cell markdown">` + "\n" + Markdown([]byte(cell.Source), cellOpts) + "</div>\n")
+
+		case "code":
+			prompt := " "
+			if cell.ExecutionCount != nil {
+				prompt = fmt.Sprint(*cell.ExecutionCount)
+			}
+			b.WriteString(`<div class="cell code">` + "\n")
+			fmt.Fprintf(&b, `<div class="prompt">In [%s]:</div>`+"\n", prompt)
+			b.WriteString("<pre><code")
+			if lang != "" {
+				b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+			}
+			b.WriteString(">" + html.EscapeString(string(cell.Source)) + "</code></pre>\n")
+			for _, output := range cell.Outputs {
+				b.WriteString(renderOutput(output, opts))
+			}
+			b.WriteString("</div>\n")
+
+		default: // raw
+			b.WriteString(`<div class="cell raw"><pre>` + html.EscapeString(string(cell.Source)) + "</pre></div>\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// renderOutput renders one output of a code cell
+func renderOutput(output notebookOutput, opts Options) string {
+	switch output.OutputType {
+	case "stream":
+		return `<pre class="output ` + html.EscapeString(output.Name) + `">` + html.EscapeString(ansiRe.ReplaceAllString(string(output.Text), "")) + "</pre>\n"
+
+	case "error":
+		traceback := ansiRe.ReplaceAllString(strings.Join(output.Traceback, "\n"), "")
+		if traceback == "" {
+			traceback = output.EName + ": " + output.EValue
+		}
+		return `<pre class="output error">` + html.EscapeString(traceback) + "</pre>\n"
+
+	case "execute_result", "display_data":
+		if uri := imageURI(output.Data); uri != "" {
+			return `<div class="output"><img src="` + html.EscapeString(uri) + `" alt=""></div>` + "\n"
+		}
+		if text, ok := output.Data["text/markdown"]; ok {
+			return `<div class="output">` + Markdown([]byte(text), opts) + "</div>\n"
+		}
+		for _, mimeType := range []string{"text/plain", "text/latex", "application/json"} {
+			if text, ok := output.Data[mimeType]; ok {
+				return `<pre class="output">` + html.EscapeString(string(text)) + "</pre>\n"
+			}
+		}
+		if len(output.Data) > 0 {
+			return `<p class="output omitted">Interactive output not shown</p>` + "\n"
+		}
+	}
+	return ""
+}
+
+// imageURI returns the preferred image in a MIME bundle as a data URI, or ""
+func imageURI(data map[string]multiline) string {
+	for _, mimeType := range imageTypes {
+		value, ok := data[mimeType]
+		if !ok {
+			continue
+		}
+		if mimeType == "image/svg+xml" {
+			// SVG is stored as text; scripts in it don't run from an <img>
+			return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(value))
+		}
+		encoded := strings.Join(strings.Fields(string(value)), "")
+		if _, err := base64.StdEncoding.DecodeString(encoded); err != nil {
+			continue
+		}
+		return "data:" + mimeType + ";base64," + encoded
+	}
+	return ""
+}
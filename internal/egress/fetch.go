@@ -0,0 +1,169 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// fetchTimeout bounds one download, including redirects
+const fetchTimeout = 5 * time.Minute
+
+// maxRedirects is how many redirects a download may follow
+const maxRedirects = 5
+
+// ErrFetchTooLarge is returned for downloads over the size limit
+var ErrFetchTooLarge = errors.New("download exceeds the size limit")
+
+// Fetcher downloads files from allowed domains on behalf of conversations.
+// Like the proxy it refuses names that resolve to internal addresses, and it
+// checks every redirect against the allowlist
+type Fetcher struct {
+	allowed      []string
+	contentTypes []string
+	maxBytes     int64
+	client       *http.Client
+}
+
+// Download is a fetched file
+type Download struct {
+	Data        []byte
+	ContentType string
+	URL         string // After redirects
+	Filename    string // From Content-Disposition or the URL path, may be empty
+}
+
+// NewFetcher creates a fetcher for the given domains (matched as by
+// NewProxy), allowing responses up to maxBytes with one of contentTypes
+// ("text/*" matches any text type). No domains disables it
+func NewFetcher(allowed, contentTypes []string, maxBytes int64) *Fetcher {
+	f := &Fetcher{allowed: normalize(allowed), contentTypes: normalize(contentTypes), maxBytes: maxBytes}
+	dialer := &net.Dialer{Timeout: dialTimeout, Control: refusePrivate}
+	f.client = &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ResponseHeaderTimeout: time.Minute,
+			IdleConnTimeout:       time.Minute,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+// Enabled reports whether any domain may be fetched from
+func (f *Fetcher) Enabled() bool {
+	return len(f.allowed) > 0
+}
+
+// Domains returns the allowed domains
+func (f *Fetcher) Domains() []string {
+	return f.allowed
+}
+
+// MaxBytes returns the largest download accepted
+func (f *Fetcher) MaxBytes() int64 {
+	return f.maxBytes
+}
+
+// checkURL rejects URLs that aren't HTTP(S) on an allowed domain
+func (f *Fetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("only http and https URLs can be fetched")
+	}
+	if u.User != nil {
+		return fmt.Errorf("URLs with credentials can't be fetched")
+	}
+	if !allows(f.allowed, u.Hostname()) {
+		return fmt.Errorf("domain %s is not allowed", u.Hostname())
+	}
+	return nil
+}
+
+// allowsContentType reports whether a response's media type is accepted
+func (f *Fetcher) allowsContentType(mediaType string) bool {
+	for _, allowed := range f.contentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(mediaType, prefix) {
+				return true
+			}
+		} else if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch downloads rawURL, enforcing the allowlist, size limit and content
+// types
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Download, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("User-Agent", "mcp-code-sandbox")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	// A missing Content-Type means arbitrary bytes
+	mediaType := "application/octet-stream"
+	if header := resp.Header.Get("Content-Type"); header != "" {
+		if mediaType, _, err = mime.ParseMediaType(header); err != nil {
+			return nil, fmt.Errorf("invalid Content-Type %q", header)
+		}
+	}
+	if !f.allowsContentType(mediaType) {
+		return nil, fmt.Errorf("content type %s is not allowed", mediaType)
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, ErrFetchTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	if int64(len(data)) > f.maxBytes {
+		return nil, ErrFetchTooLarge
+	}
+
+	download := &Download{Data: data, ContentType: mediaType, URL: resp.Request.URL.String()}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		download.Filename = path.Base(params["filename"])
+	}
+	if download.Filename == "" || download.Filename == "." || download.Filename == "/" {
+		download.Filename = path.Base(resp.Request.URL.Path)
+	}
+	if download.Filename == "." || download.Filename == "/" {
+		download.Filename = ""
+	}
+	return download, nil
+}
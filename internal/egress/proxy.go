@@ -0,0 +1,182 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// dialTimeout bounds connecting to an upstream host
+const dialTimeout = 10 * time.Second
+
+// hopHeaders are connection-specific headers a proxy must not forward
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Proxy is an HTTP forward proxy that only connects to allowed domains.
+// HTTPS goes through CONNECT tunnels, so only the host name is checked
+type Proxy struct {
+	allowed   []string
+	dialer    *net.Dialer
+	transport *http.Transport
+}
+
+// NewProxy creates a proxy allowing the given domains. "example.com" allows
+// only that host; "*.example.com" allows its subdomains but not itself
+func NewProxy(allowed []string) *Proxy {
+	p := &Proxy{allowed: normalize(allowed)}
+	p.dialer = &net.Dialer{Timeout: dialTimeout, Control: refusePrivate}
+	p.transport = &http.Transport{
+		Proxy:                 nil,
+		DialContext:           p.dialer.DialContext,
+		ResponseHeaderTimeout: time.Minute,
+		IdleConnTimeout:       time.Minute,
+	}
+	return p
+}
+
+// normalize lowercases domains and drops trailing dots and blanks
+func normalize(domains []string) []string {
+	var out []string
+	for _, d := range domains {
+		d = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+		if d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Allows reports whether host (without port) may be reached
+func (p *Proxy) Allows(host string) bool {
+	return allows(p.allowed, host)
+}
+
+// allows reports whether host matches one of the normalized domains
+func allows(allowed []string, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, domain := range allowed {
+		if suffix, ok := strings.CutPrefix(domain, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+		} else if host == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// refusePrivate stops allowed names that resolve to internal addresses (e.g.
+// cloud metadata endpoints) from being used to reach the host's networks
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("refusing to connect to internal address %s", host)
+	}
+	return nil
+}
+
+// ServeHTTP handles CONNECT tunnels and plain HTTP requests
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+	if r.URL.Host == "" {
+		http.Error(w, "This is a forward proxy", http.StatusBadRequest)
+		return
+	}
+	if !p.Allows(r.URL.Hostname()) {
+		p.deny(w, r, r.URL.Hostname())
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		log.Printf("[EGRESS] %s %s failed: %v", r.Method, r.URL.Host, err)
+		http.Error(w, "Upstream request failed", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for key, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// serveConnect opens a tunnel to an allowed host
+func (p *Proxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		http.Error(w, "Invalid CONNECT target", http.StatusBadRequest)
+		return
+	}
+	if !p.Allows(host) {
+		p.deny(w, r, host)
+		return
+	}
+
+	upstream, err := p.dialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		log.Printf("[EGRESS] CONNECT %s failed: %v", r.Host, err)
+		http.Error(w, "Upstream connection failed", http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	// Copy until either side closes, then tear down both
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		io.Copy(upstream, buffered)
+		cancel()
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		cancel()
+	}()
+	<-ctx.Done()
+}
+
+// deny rejects a request to a host outside the allowlist
+func (p *Proxy) deny(w http.ResponseWriter, r *http.Request, host string) {
+	log.Printf("[EGRESS] Blocked %s %s from %s", r.Method, host, r.RemoteAddr)
+	http.Error(w, fmt.Sprintf("Egress to %s is not allowed by the sandbox network policy", host), http.StatusForbidden)
+}
@@ -0,0 +1,105 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+const (
+	// NetworkName is the internal Docker network restricted runners join.
+	// It has no route out; only the proxy sidecar is also on a normal network
+	NetworkName = "sandbox-egress"
+
+	// ContainerName is the proxy sidecar's container name
+	ContainerName = "sandbox-egress-proxy"
+
+	// ProxyURL is the proxy's address as seen from the egress network
+	ProxyURL = "http://egress-proxy:3128"
+
+	proxyAlias  = "egress-proxy"
+	proxyListen = ":3128"
+
+	// allowLabel records the sidecar's allowlist so a changed list recreates it
+	allowLabel = "sandbox.egress.allow"
+)
+
+// EnsureSidecar creates the internal egress network and (re)starts the proxy
+// sidecar from image, which must run this server's binary (it is started
+// with the egress-proxy subcommand). An existing sidecar is reused if it is
+// running with the same image and allowlist
+func EnsureSidecar(ctx context.Context, cli *client.Client, image string, allowed []string) error {
+	if err := ensureNetwork(ctx, cli); err != nil {
+		return err
+	}
+
+	domains := normalize(allowed)
+	sort.Strings(domains)
+	allowList := strings.Join(domains, ",")
+
+	existing, err := cli.ContainerInspect(ctx, ContainerName)
+	switch {
+	case err == nil:
+		if existing.State != nil && existing.State.Running &&
+			existing.Config.Image == image && existing.Config.Labels[allowLabel] == allowList {
+			log.Printf("Egress proxy already running for: %s", allowList)
+			return nil
+		}
+		if err := cli.ContainerRemove(ctx, existing.ID, container.RemoveOptions{Force: true}); err != nil {
+			return fmt.Errorf("failed to remove stale egress proxy: %w", err)
+		}
+	case !errdefs.IsNotFound(err):
+		return fmt.Errorf("failed to inspect egress proxy: %w", err)
+	}
+
+	resp, err := cli.ContainerCreate(ctx,
+		&container.Config{
+			Image:  image,
+			Cmd:    []string{"egress-proxy", "-listen", proxyListen, "-allow", allowList},
+			Labels: map[string]string{allowLabel: allowList},
+		},
+		&container.HostConfig{
+			NetworkMode:    "bridge", // Outbound access; runners reach it via NetworkName
+			RestartPolicy:  container.RestartPolicy{Name: container.RestartPolicyUnlessStopped},
+			CapDrop:        []string{"ALL"},
+			ReadonlyRootfs: true,
+		},
+		nil, nil, ContainerName)
+	if err != nil {
+		return fmt.Errorf("failed to create egress proxy from %s: %w", image, err)
+	}
+	if err := cli.NetworkConnect(ctx, NetworkName, resp.ID, &network.EndpointSettings{Aliases: []string{proxyAlias}}); err != nil {
+		cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+		return fmt.Errorf("failed to attach egress proxy to %s: %w", NetworkName, err)
+	}
+	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start egress proxy: %w", err)
+	}
+	log.Printf("Started egress proxy for: %s", allowList)
+	return nil
+}
+
+// ensureNetwork creates the internal egress network if it doesn't exist
+func ensureNetwork(ctx context.Context, cli *client.Client) error {
+	if _, err := cli.NetworkInspect(ctx, NetworkName, network.InspectOptions{}); err == nil {
+		return nil
+	} else if !errdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to inspect network %s: %w", NetworkName, err)
+	}
+	_, err := cli.NetworkCreate(ctx, NetworkName, network.CreateOptions{
+		Driver:   "bridge",
+		Internal: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create network %s: %w", NetworkName, err)
+	}
+	log.Printf("Created internal network %s", NetworkName)
+	return nil
+}
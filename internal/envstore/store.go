@@ -0,0 +1,162 @@
+package envstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// fileName is the metadata file holding a conversation's environment
+const fileName = "environment.json"
+
+// Store persists per-conversation environment variables with values
+// encrypted at rest (AES-256-GCM, key derived from the file secret)
+type Store struct {
+	mu      sync.Mutex
+	sandbox *sandbox.Manager
+	aead    cipher.AEAD
+}
+
+// NewStore creates an environment store
+func NewStore(sandboxMgr *sandbox.Manager, secret string) (*Store, error) {
+	key := sha256.Sum256([]byte("envstore:" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return &Store{
+		sandbox: sandboxMgr,
+		aead:    aead,
+	}, nil
+}
+
+// Get returns the decrypted environment for a conversation
+func (s *Store) Get(conversationID string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(conversationID)
+}
+
+// Update applies changes to a conversation's environment and returns the
+// resulting variable names. A nil value removes the variable.
+func (s *Store) Update(conversationID string, changes map[string]*string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	env, err := s.loadLocked(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(env, key)
+		} else {
+			env[key] = *value
+		}
+	}
+	if err := s.saveLocked(conversationID, env); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// loadLocked reads and decrypts the environment file; caller must hold s.mu
+func (s *Store) loadLocked(conversationID string) (map[string]string, error) {
+	path := filepath.Join(s.sandbox.GetMetadataDir(conversationID), fileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read environment: %w", err)
+	}
+
+	var encrypted map[string]string
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		return nil, fmt.Errorf("failed to parse environment: %w", err)
+	}
+
+	env := make(map[string]string, len(encrypted))
+	for key, value := range encrypted {
+		plain, err := s.decrypt(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+		}
+		env[key] = plain
+	}
+	return env, nil
+}
+
+// saveLocked encrypts and writes the environment file; caller must hold s.mu
+func (s *Store) saveLocked(conversationID string, env map[string]string) error {
+	encrypted := make(map[string]string, len(env))
+	for key, value := range env {
+		sealed, err := s.encrypt(key, value)
+		if err != nil {
+			return err
+		}
+		encrypted[key] = sealed
+	}
+
+	data, err := json.MarshalIndent(encrypted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode environment: %w", err)
+	}
+
+	dir := s.sandbox.GetMetadataDir(conversationID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	// Write atomically so a crash never leaves a truncated file
+	tmp := filepath.Join(dir, fileName+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write environment: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(dir, fileName))
+}
+
+// encrypt seals value, binding it to its key name as additional data
+func (s *Store) encrypt(key, value string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(value), []byte(key))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value sealed by encrypt
+func (s *Store) decrypt(key, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	nonceSize := s.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	plain, err := s.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(key))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownEvent is returned when resuming from an event ID that belongs to
+// no retained stream of the session
+var ErrUnknownEvent = errors.New("unknown event ID")
+
+// Event is one SSE event sent on a stream
+type Event struct {
+	ID   string
+	Data []byte
+}
+
+// Batch is what a resuming client has missed on a stream
+type Batch struct {
+	Events  []Event
+	Closed  bool            // The stream ended; no more events will follow
+	Changed <-chan struct{} // Closed when the stream gets new events or ends
+}
+
+// Store keeps the events sent on SSE streams so a client that loses its
+// connection can resume with Last-Event-ID (MCP Streamable HTTP).
+// Implementations must be safe for concurrent use
+type Store interface {
+	// Open starts a stream belonging to a session and returns its ID
+	Open(sessionID string) string
+	// Append records an event on a stream and returns the event's ID
+	Append(streamID string, data []byte) string
+	// Close marks a stream as ended
+	Close(streamID string)
+	// After returns the session's events following lastEventID on the
+	// stream that event was sent on
+	After(sessionID, lastEventID string) (Batch, error)
+}
+
+// maxStreamEvents bounds the events kept per stream; a client further
+// behind than that misses the oldest
+const maxStreamEvents = 1000
+
+// MemoryStore is a Store kept in process memory, so streams don't survive a
+// restart
+type MemoryStore struct {
+	mu        sync.Mutex
+	streams   map[string]*stream
+	retention time.Duration
+}
+
+type stream struct {
+	sessionID string
+	events    []Event
+	first     int // Sequence number of events[0]
+	closed    bool
+	updated   time.Time
+	changed   chan struct{}
+}
+
+// NewMemoryStore creates an in-memory store; streams untouched for longer
+// than retention are dropped
+func NewMemoryStore(retention time.Duration) *MemoryStore {
+	return &MemoryStore{
+		streams:   make(map[string]*stream),
+		retention: retention,
+	}
+}
+
+// Open starts a stream belonging to a session and returns its ID
+func (m *MemoryStore) Open(sessionID string) string {
+	id := newStreamID()
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweepLocked(now)
+	m.streams[id] = &stream{
+		sessionID: sessionID,
+		updated:   now,
+		changed:   make(chan struct{}),
+	}
+	return id
+}
+
+// Append records an event on a stream and returns the event's ID. Appending
+// to a dropped stream still returns an ID, which can't be resumed from
+func (m *MemoryStore) Append(streamID string, data []byte) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.streams[streamID]
+	if !ok {
+		return streamID + "-0"
+	}
+	id := streamID + "-" + strconv.Itoa(s.first+len(s.events)+1)
+	s.events = append(s.events, Event{ID: id, Data: data})
+	if len(s.events) > maxStreamEvents {
+		s.events = s.events[1:]
+		s.first++
+	}
+	s.notifyLocked()
+	return id
+}
+
+// Close marks a stream as ended
+func (m *MemoryStore) Close(streamID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.streams[streamID]; ok {
+		s.closed = true
+		s.notifyLocked()
+	}
+}
+
+// After returns the session's events following lastEventID on the stream
+// that event was sent on
+func (m *MemoryStore) After(sessionID, lastEventID string) (Batch, error) {
+	streamID, seq, ok := parseEventID(lastEventID)
+	if !ok {
+		return Batch{}, ErrUnknownEvent
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.streams[streamID]
+	if !ok || s.sessionID != sessionID {
+		return Batch{}, ErrUnknownEvent
+	}
+	skip := seq - s.first
+	if skip < 0 {
+		skip = 0
+	}
+	if skip > len(s.events) {
+		skip = len(s.events)
+	}
+	return Batch{
+		Events:  append([]Event(nil), s.events[skip:]...),
+		Closed:  s.closed,
+		Changed: s.changed,
+	}, nil
+}
+
+// notifyLocked wakes clients waiting on the stream; caller must hold m.mu
+func (s *stream) notifyLocked() {
+	s.updated = time.Now()
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// sweepLocked drops streams untouched for longer than the retention; caller
+// must hold m.mu
+func (m *MemoryStore) sweepLocked(now time.Time) {
+	for id, s := range m.streams {
+		if now.Sub(s.updated) > m.retention {
+			delete(m.streams, id)
+		}
+	}
+}
+
+// parseEventID splits an event ID into its stream ID and sequence number
+func parseEventID(id string) (string, int, bool) {
+	i := strings.LastIndexByte(id, '-')
+	if i <= 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.Atoi(id[i+1:])
+	if err != nil || seq < 0 {
+		return "", 0, false
+	}
+	return id[:i], seq, true
+}
+
+// newStreamID returns a random stream ID
+func newStreamID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,64 @@
+package filesign
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DownloadLink is what a download link token grants
+type DownloadLink struct {
+	HashedDir string
+	Filename  string    // Slash-separated path within the sandbox
+	Epoch     int64     // The sandbox's link epoch when the link was made
+	ID        string    // Random ID of a single-use link; empty otherwise
+	Expires   time.Time // When the link stops working
+}
+
+// SingleUse reports whether the link may be downloaded only once
+func (l *DownloadLink) SingleUse() bool {
+	return l.ID != ""
+}
+
+// MakeDownloadURL creates a link to one file that stops working at expires
+// or, for single-use links, after its first download. Like share links the
+// token is encrypted, so it reveals neither the hashed directory nor the
+// filename. epoch is the sandbox's current link epoch; links from older
+// epochs are refused
+func (s *Signer) MakeDownloadURL(hashedDir, filename string, epoch int64, singleUse bool, expires time.Time) (string, error) {
+	id := ""
+	if singleUse {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("failed to generate link ID: %w", err)
+		}
+		id = hex.EncodeToString(b)
+	}
+	payload := strings.Join([]string{hashedDir, strconv.FormatInt(epoch, 10), id, filename}, ":")
+	token, err := s.sealToken(purposeDownload, payload, expires)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/download/%s", s.GetBaseURL(), token), nil
+}
+
+// ParseDownloadToken verifies a download token and returns what it grants.
+// The caller checks the epoch and, for single-use links, that the ID is unused
+func (s *Signer) ParseDownloadToken(token string) (*DownloadLink, error) {
+	payload, expires, err := s.openToken(purposeDownload, token)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.SplitN(payload, ":", 4)
+	if len(fields) != 4 {
+		return nil, ErrInvalidShareToken
+	}
+	epoch, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidShareToken
+	}
+	return &DownloadLink{HashedDir: fields[0], Filename: fields[3], Epoch: epoch, ID: fields[2], Expires: expires}, nil
+}
@@ -0,0 +1,151 @@
+package filesign
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Token purposes, bound into each token so one kind can't be used as another
+const (
+	purposeShare    = "share"
+	purposeIngest   = "ingest"
+	purposeDownload = "download"
+)
+
+// ErrInvalidShareToken is returned for share, download or ingest tokens that
+// are malformed, tampered with, or expired
+var ErrInvalidShareToken = errors.New("invalid or expired share link")
+
+// MakeShareURL creates a read-only link to a sandbox's file browser that
+// stops working at expires. The token is encrypted so it doesn't reveal the
+// hashed directory, whose /files/ URLs never expire. epoch is the sandbox's
+// current link epoch; links from older epochs are refused
+func (s *Signer) MakeShareURL(hashedDir string, epoch int64, expires time.Time) (string, error) {
+	token, err := s.sealToken(purposeShare, hashedDir+":"+strconv.FormatInt(epoch, 10), expires)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/share/%s/", s.GetBaseURL(), token), nil
+}
+
+// ParseShareToken verifies a share token and returns the hashed directory it
+// grants and the link epoch it was made in
+func (s *Signer) ParseShareToken(token string) (string, int64, time.Time, error) {
+	payload, expires, err := s.openToken(purposeShare, token)
+	if err != nil {
+		return "", 0, time.Time{}, err
+	}
+	// Links made before revocation existed carry no epoch
+	hashedDir, epochText, ok := strings.Cut(payload, ":")
+	if !ok {
+		return hashedDir, 0, expires, nil
+	}
+	epoch, err := strconv.ParseInt(epochText, 10, 64)
+	if err != nil {
+		return "", 0, time.Time{}, ErrInvalidShareToken
+	}
+	return hashedDir, epoch, expires, nil
+}
+
+// MakeIngestURL creates an upload endpoint for a conversation that stops
+// working at expires, along with the secret callers must sign bodies with
+func (s *Signer) MakeIngestURL(conversationID string, expires time.Time) (string, string, error) {
+	token, err := s.sealToken(purposeIngest, conversationID, expires)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%s/ingest/%s", s.GetBaseURL(), token), s.ingestSecret(token), nil
+}
+
+// ParseIngestToken verifies an ingest token and returns its conversation ID
+func (s *Signer) ParseIngestToken(token string) (string, time.Time, error) {
+	return s.openToken(purposeIngest, token)
+}
+
+// VerifyIngestSignature checks a "sha256=<hex>" HMAC of body made with the
+// token's signing secret
+func (s *Signer) VerifyIngestSignature(token string, body []byte, signature string) bool {
+	provided, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(s.ingestSecret(token)))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(provided)) == 1
+}
+
+// ingestSecret derives the per-token body signing secret
+func (s *Signer) ingestSecret(token string) string {
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte("ingest-signing:" + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sealToken encrypts an expiring payload into a URL-safe token
+func (s *Signer) sealToken(purpose, payload string, expires time.Time) (string, error) {
+	aead, err := s.tokenCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	plaintext := strconv.FormatInt(expires.Unix(), 10) + ":" + payload
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(purpose))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openToken decrypts a token sealed for purpose, rejecting expired ones
+func (s *Signer) openToken(purpose, token string) (string, time.Time, error) {
+	aead, err := s.tokenCipher()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", time.Time{}, ErrInvalidShareToken
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(purpose))
+	if err != nil {
+		return "", time.Time{}, ErrInvalidShareToken
+	}
+
+	expiry, payload, ok := strings.Cut(string(plaintext), ":")
+	if !ok {
+		return "", time.Time{}, ErrInvalidShareToken
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidShareToken
+	}
+	expires := time.Unix(unix, 0)
+	if time.Now().After(expires) {
+		return "", time.Time{}, ErrInvalidShareToken
+	}
+	return payload, expires, nil
+}
+
+// tokenCipher derives the token cipher from the secret
+func (s *Signer) tokenCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("share:" + s.secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
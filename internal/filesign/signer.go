@@ -14,13 +14,16 @@ import (
 type Signer struct {
 	secret        string
 	publicBaseURL string
+	basePath      string
 }
 
 // NewSigner creates a new file signer
-func NewSigner(secret, publicBaseURL string) *Signer {
+// basePath is the route prefix the server is mounted under (empty for root)
+func NewSigner(secret, publicBaseURL, basePath string) *Signer {
 	return &Signer{
 		secret:        secret,
 		publicBaseURL: publicBaseURL,
+		basePath:      basePath,
 	}
 }
 
@@ -35,7 +38,7 @@ func (s *Signer) SignPath(conversationID, filename string) string {
 func (s *Signer) MakeFileURL(conversationID, filename string) string {
 	sig := s.SignPath(conversationID, filename)
 	return fmt.Sprintf("%s/files/%s/%s?sig=%s",
-		strings.TrimRight(s.publicBaseURL, "/"),
+		s.GetBaseURL(),
 		url.PathEscape(conversationID),
 		url.PathEscape(filename),
 		sig,
@@ -49,7 +52,17 @@ func (s *Signer) VerifySignature(conversationID, filename, providedSig string) b
 	return subtle.ConstantTimeCompare([]byte(expectedSig), []byte(providedSig)) == 1
 }
 
-// GetBaseURL returns the public base URL
+// GetBaseURL returns the public base URL including the route prefix
 func (s *Signer) GetBaseURL() string {
-	return strings.TrimRight(s.publicBaseURL, "/")
+	return strings.TrimRight(s.publicBaseURL, "/") + s.basePath
+}
+
+// PreviewURL returns the URL a hashed sandbox directory's web app preview is proxied at
+func (s *Signer) PreviewURL(hashedDir string) string {
+	return fmt.Sprintf("%s/preview/%s/", s.GetBaseURL(), hashedDir)
+}
+
+// FileBaseURL returns the download URL prefix for a hashed sandbox directory
+func (s *Signer) FileBaseURL(hashedDir string) string {
+	return fmt.Sprintf("%s/files/%s", s.GetBaseURL(), hashedDir)
 }
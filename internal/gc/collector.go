@@ -0,0 +1,179 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/audit"
+	"github.com/jsc/mcp-code-sandbox/internal/processes"
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+	"github.com/jsc/mcp-code-sandbox/internal/services"
+)
+
+// Candidate is a sandbox that has been inactive for longer than the retention
+type Candidate struct {
+	sandbox.Usage
+	AgeHours float64 `json:"ageHours"`
+	Deleted  bool    `json:"deleted"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Report summarizes one collection run
+type Report struct {
+	DryRun     bool        `json:"dryRun"`
+	MaxAge     string      `json:"maxAge"`
+	Scanned    int         `json:"scanned"`
+	Candidates []Candidate `json:"candidates"` // Oldest first
+	TotalBytes int64       `json:"totalBytes"` // Size of all candidates
+	Deleted    int         `json:"deleted"`
+	FreedBytes int64       `json:"freedBytes"`
+}
+
+// Collector deletes sandboxes that have been inactive for too long
+type Collector struct {
+	sandbox  *sandbox.Manager
+	audit    *audit.Log
+	services *services.Manager // Torn down with their sandbox; may be nil
+	mu       sync.Mutex        // One run at a time
+
+	processes *processes.Manager // Torn down with their sandbox; may be nil
+}
+
+// New creates a garbage collector
+func New(sandboxMgr *sandbox.Manager, auditLog *audit.Log, serviceMgr *services.Manager, processMgr *processes.Manager) *Collector {
+	return &Collector{sandbox: sandboxMgr, audit: auditLog, services: serviceMgr, processes: processMgr}
+}
+
+// Run finds sandboxes not modified within maxAge and, unless dryRun, deletes
+// them. Every deletion is recorded in the audit log under actor. With object
+// storage only local copies are deleted: another instance may be using the
+// sandbox, so stored copies expire by the bucket's lifecycle rules instead
+func (c *Collector) Run(maxAge time.Duration, dryRun bool, actor string) (Report, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sandboxes, err := c.sandbox.ListSandboxes()
+	if err != nil {
+		return Report{}, err
+	}
+
+	now := time.Now()
+	report := Report{
+		DryRun:     dryRun,
+		MaxAge:     maxAge.String(),
+		Scanned:    len(sandboxes),
+		Candidates: []Candidate{},
+	}
+	for _, usage := range sandboxes {
+		age := now.Sub(usage.LastModified)
+		if age < maxAge {
+			continue
+		}
+		report.Candidates = append(report.Candidates, Candidate{
+			Usage:    usage,
+			AgeHours: float64(age.Round(time.Minute)) / float64(time.Hour),
+		})
+		report.TotalBytes += usage.SizeBytes
+	}
+	sort.Slice(report.Candidates, func(i, j int) bool {
+		return report.Candidates[i].LastModified.Before(report.Candidates[j].LastModified)
+	})
+
+	if dryRun {
+		return report, nil
+	}
+
+	for i := range report.Candidates {
+		candidate := &report.Candidates[i]
+		if err := c.purge(candidate.HashedDir, c.sandbox.RemoteEnabled()); err != nil {
+			log.Printf("GC: %v", err)
+			candidate.Error = err.Error()
+			continue
+		}
+		candidate.Deleted = true
+		report.Deleted++
+		report.FreedBytes += candidate.SizeBytes
+
+		if err := c.audit.Record("sandbox.purge", actor, map[string]interface{}{
+			"hashedDir":    candidate.HashedDir,
+			"sizeBytes":    candidate.SizeBytes,
+			"lastModified": candidate.LastModified.UTC(),
+			"maxAge":       report.MaxAge,
+			"localOnly":    c.sandbox.RemoteEnabled(),
+		}); err != nil {
+			log.Printf("GC: %v", err)
+		}
+	}
+	return report, nil
+}
+
+// Delete removes one sandbox regardless of its age, with its processes and
+// services, recording it in the audit log under actor. It returns false if
+// there is no such sandbox
+func (c *Collector) Delete(hashedDir, actor string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.sandbox.HashedDirExists(hashedDir) {
+		return false, nil
+	}
+	if err := c.purge(hashedDir, false); err != nil {
+		return true, err
+	}
+	if err := c.audit.Record("sandbox.delete", actor, map[string]interface{}{
+		"hashedDir": hashedDir,
+	}); err != nil {
+		log.Printf("GC: %v", err)
+	}
+	return true, nil
+}
+
+// purge tears down a sandbox's processes and services, then deletes it, or
+// with localOnly just its local copy
+func (c *Collector) purge(hashedDir string, localOnly bool) error {
+	if err := c.processes.Teardown(context.Background(), hashedDir); err != nil {
+		return fmt.Errorf("failed to remove processes of %s: %w", hashedDir, err)
+	}
+	if err := c.services.Teardown(context.Background(), hashedDir); err != nil {
+		return fmt.Errorf("failed to remove services of %s: %w", hashedDir, err)
+	}
+	remove := c.sandbox.DeleteHashedDir
+	if localOnly {
+		remove = c.sandbox.EvictHashedDir
+	}
+	if err := remove(hashedDir); err != nil {
+		return fmt.Errorf("failed to delete sandbox %s: %w", hashedDir, err)
+	}
+	return nil
+}
+
+// Loop runs a collection every interval until ctx is done. With dryRun it
+// only logs what would be deleted, to check a retention before enforcing it
+func (c *Collector) Loop(ctx context.Context, interval, maxAge time.Duration, dryRun bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := c.Run(maxAge, dryRun, "system")
+		switch {
+		case err != nil:
+			log.Printf("GC: %v", err)
+		case dryRun:
+			log.Printf("GC (dry run): %d of %d sandbox(es) inactive for over %s would be deleted (%d bytes)",
+				len(report.Candidates), report.Scanned, report.MaxAge, report.TotalBytes)
+		case len(report.Candidates) > 0:
+			log.Printf("GC: deleted %d of %d sandbox(es) inactive for over %s, freeing %d bytes",
+				report.Deleted, report.Scanned, report.MaxAge, report.FreedBytes)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Package gitclone shallow-clones git repositories for sandboxes. Clones
+// run the system git with a scrubbed configuration, and all of git's traffic
+// goes through a loopback egress proxy that only reaches allowlisted hosts
+package gitclone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/egress"
+)
+
+// cloneTimeout bounds one clone
+const cloneTimeout = 5 * time.Minute
+
+// sizeCheckInterval is how often a running clone's size is measured
+const sizeCheckInterval = 500 * time.Millisecond
+
+// ErrTooLarge is returned for clones over the size limit
+var ErrTooLarge = errors.New("repository exceeds the size limit")
+
+// Cloner clones repositories from allowed hosts
+type Cloner struct {
+	proxy    *egress.Proxy
+	hosts    []string
+	maxBytes int64
+	maxDepth int
+	git      string // Path of the git binary
+	proxyURL string // Loopback proxy git connects through
+}
+
+// Result describes a finished clone
+type Result struct {
+	Commit string // HEAD commit ID
+	Files  int    // Files in the working tree, excluding .git
+}
+
+// New creates a cloner for hosts (matched like EGRESS_ALLOWED_DOMAINS:
+// "*.example.com" for subdomains) and starts its loopback proxy. No hosts
+// returns a disabled cloner. Enabled cloners need git on the PATH
+func New(hosts []string, maxBytes int64, maxDepth int) (*Cloner, error) {
+	c := &Cloner{hosts: hosts, maxBytes: maxBytes, maxDepth: maxDepth}
+	if len(hosts) == 0 {
+		return c, nil
+	}
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("git is required for clone_repo: %w", err)
+	}
+	c.git = git
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start clone proxy: %w", err)
+	}
+	c.proxy = egress.NewProxy(hosts)
+	c.proxyURL = "http://" + listener.Addr().String()
+	srv := &http.Server{Handler: c.proxy, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(listener); err != nil {
+			log.Printf("Clone proxy stopped: %v", err)
+		}
+	}()
+	return c, nil
+}
+
+// Enabled reports whether any host may be cloned from
+func (c *Cloner) Enabled() bool {
+	return len(c.hosts) > 0
+}
+
+// Hosts returns the allowed hosts
+func (c *Cloner) Hosts() []string {
+	return c.hosts
+}
+
+// MaxBytes returns the largest clone accepted, including .git
+func (c *Cloner) MaxBytes() int64 {
+	return c.maxBytes
+}
+
+// MaxDepth returns the deepest history a clone may fetch
+func (c *Cloner) MaxDepth() int {
+	return c.maxDepth
+}
+
+// CheckURL rejects repository URLs that aren't HTTP(S) on an allowed host
+func (c *Cloner) CheckURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("only http and https repository URLs are supported")
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("URLs with credentials can't be cloned")
+	}
+	if !c.Enabled() || !c.proxy.Allows(u.Hostname()) {
+		return nil, fmt.Errorf("host %s is not allowed", u.Hostname())
+	}
+	return u, nil
+}
+
+// DefaultDirectory derives a directory name from a repository URL, e.g.
+// "project" for https://example.com/org/project.git
+func DefaultDirectory(u *url.URL) string {
+	name := strings.TrimSuffix(path.Base(strings.TrimSuffix(u.Path, "/")), ".git")
+	if name == "" || name == "." || name == "/" {
+		return "repo"
+	}
+	return name
+}
+
+// Clone shallow-clones rawURL at ref (a branch or tag; empty for the default
+// branch) with depth commits of history into dest, which must not exist. If
+// the clone fails or grows past the size limit, dest is removed
+func (c *Cloner) Clone(ctx context.Context, rawURL, ref string, depth int, dest string) (*Result, error) {
+	u, err := c.CheckURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if depth < 1 || depth > c.maxDepth {
+		return nil, fmt.Errorf("depth must be between 1 and %d", c.maxDepth)
+	}
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid ref: %q", ref)
+	}
+
+	// An empty HOME keeps the server user's git configuration out
+	home, err := os.MkdirTemp("", "clone-home-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare clone: %w", err)
+	}
+	defer os.RemoveAll(home)
+
+	ctx, cancel := context.WithTimeout(ctx, cloneTimeout)
+	defer cancel()
+
+	args := []string{
+		"-c", "http.proxy=" + c.proxyURL,
+		"-c", "core.symlinks=false", // Links become plain files naming their target
+		"-c", "core.hooksPath=/dev/null",
+		"clone", "--quiet", "--depth", strconv.Itoa(depth), "--single-branch", "--no-tags",
+		"--no-recurse-submodules",
+	}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, "--", u.String(), dest)
+	cmd := exec.CommandContext(ctx, c.git, args...)
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + home,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ALLOW_PROTOCOL=http:https",
+		"GIT_LFS_SKIP_SMUDGE=1",
+	}
+
+	// Watch the clone's size while it runs; git has no limit of its own
+	tooLarge := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(sizeCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if size, _ := dirSize(dest); size > c.maxBytes {
+					close(tooLarge)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	output, err := cmd.CombinedOutput()
+	close(done)
+
+	select {
+	case <-tooLarge:
+		err = ErrTooLarge
+	default:
+		if err == nil {
+			if size, _ := dirSize(dest); size > c.maxBytes {
+				err = ErrTooLarge
+			}
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dest)
+		if errors.Is(err, ErrTooLarge) {
+			return nil, err
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("clone timed out after %s", cloneTimeout)
+		}
+		return nil, fmt.Errorf("git clone failed: %s", strings.TrimSpace(string(output)))
+	}
+
+	result := &Result{}
+	rev := exec.CommandContext(ctx, c.git, "-C", dest, "rev-parse", "HEAD")
+	rev.Env = cmd.Env
+	if out, err := rev.Output(); err == nil {
+		result.Commit = strings.TrimSpace(string(out))
+	}
+	filepath.WalkDir(dest, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			result.Files++
+		}
+		return nil
+	})
+	return result, nil
+}
+
+// dirSize totals the sizes of the regular files under dir
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Created or removed while walking
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// AdminSandbox is a sandbox as listed by GET /admin/sandboxes
+type AdminSandbox struct {
+	sandbox.Usage
+	AgeHours float64 `json:"ageHours"` // Since the last modification
+}
+
+// AdminRunner is a registry entry as listed by GET /admin/runners
+type AdminRunner struct {
+	Language    string           `json:"language"`
+	Version     string           `json:"version,omitempty"`
+	Default     bool             `json:"default"`
+	Image       string           `json:"image"`
+	ImageID     string           `json:"imageId,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Static      bool             `json:"static"` // From RUNNERS_CONFIG rather than image labels
+	Timeout     string           `json:"timeout"`
+	MemoryBytes int64            `json:"memoryBytes"`
+	NanoCPUs    int64            `json:"nanoCpus"`
+	Packages    []runner.Package `json:"packages,omitempty"` // As probed from the image
+}
+
+// handleAdminSandboxes lists sandboxes, most recently active first:
+// GET /admin/sandboxes. DELETE /admin/sandboxes/{hashedDir} removes one with
+// its processes and services
+func (s *Server) handleAdminSandboxes(w http.ResponseWriter, r *http.Request) {
+	hashedDir := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/sandboxes"), "/")
+	switch {
+	case r.Method == http.MethodGet && hashedDir == "":
+		usages, err := s.sandbox.ListSandboxes()
+		if err != nil {
+			log.Printf("[HTTP] Failed to list sandboxes: %v", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		sandboxes := make([]AdminSandbox, 0, len(usages))
+		var totalBytes int64
+		for _, usage := range usages {
+			sandboxes = append(sandboxes, AdminSandbox{
+				Usage:    usage,
+				AgeHours: float64(now.Sub(usage.LastModified).Round(time.Minute)) / float64(time.Hour),
+			})
+			totalBytes += usage.SizeBytes
+		}
+		sort.Slice(sandboxes, func(i, j int) bool {
+			return sandboxes[i].LastModified.After(sandboxes[j].LastModified)
+		})
+		writeAdminJSON(w, map[string]interface{}{"sandboxes": sandboxes, "totalBytes": totalBytes})
+
+	case r.Method == http.MethodDelete && hashedDir != "":
+		found, err := s.gc.Delete(hashedDir, fmt.Sprintf("admin api (%s)", r.RemoteAddr))
+		if err != nil {
+			log.Printf("[HTTP] Failed to delete sandbox: %v", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Sandbox not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("[HTTP] Deleted sandbox %s", hashedDir)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminExecutions lists running executions: GET /admin/executions.
+// DELETE /admin/executions/{id} kills one; its caller gets the output so far
+func (s *Server) handleAdminExecutions(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/executions"), "/")
+	executor := s.mcpHandler.executor
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		executions, err := executor.Executions(r.Context())
+		if err != nil {
+			log.Printf("[HTTP] Failed to list executions: %v", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		writeAdminJSON(w, map[string]interface{}{"executions": executions, "queue": executor.Queue()})
+
+	case r.Method == http.MethodDelete && id != "":
+		err := executor.KillExecution(r.Context(), id)
+		if errors.Is(err, runner.ErrExecutionNotFound) {
+			http.Error(w, "Execution not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[HTTP] Failed to kill execution: %v", err)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("[HTTP] Execution %s killed by %s", id, r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminRunners shows the runner registry: GET /admin/runners.
+// POST /admin/runners/refresh rediscovers runner images right away
+func (s *Server) handleAdminRunners(w http.ResponseWriter, r *http.Request) {
+	registry := s.mcpHandler.registry
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/admin/runners":
+		writeAdminJSON(w, map[string]interface{}{"runners": adminRunners(registry, s.mcpHandler.executor)})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/admin/runners/refresh":
+		changed, err := registry.Refresh(r.Context())
+		if err != nil {
+			log.Printf("[HTTP] Failed to refresh runners: %v", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if changed {
+			log.Printf("[HTTP] Runners changed, now serving: %v", registry.ListLanguages())
+			registry.ProbePackages(r.Context(), s.mcpHandler.executor)
+		}
+		writeAdminJSON(w, map[string]interface{}{"changed": changed, "runners": adminRunners(registry, s.mcpHandler.executor)})
+
+	case r.URL.Path == "/admin/runners" || r.URL.Path == "/admin/runners/refresh":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// adminRunners describes every registered runner with its effective limits
+func adminRunners(registry *runner.Registry, executor *runner.Executor) []AdminRunner {
+	runners := registry.ListRunners()
+	result := make([]AdminRunner, 0, len(runners))
+	for _, info := range runners {
+		limits := executor.Limits(info)
+		entry := AdminRunner{
+			Language:    info.Language,
+			Version:     info.Version,
+			Default:     info.Default,
+			Image:       info.Image,
+			ImageID:     info.ImageID,
+			Description: info.Description,
+			Static:      info.Static,
+			Timeout:     limits.Timeout.String(),
+			MemoryBytes: limits.MemoryBytes,
+			NanoCPUs:    limits.NanoCPUs,
+		}
+		entry.Packages, _ = registry.Packages(info)
+		result = append(result, entry)
+	}
+	return result
+}
+
+// writeAdminJSON writes an admin API response
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[HTTP] Failed to write admin response: %v", err)
+	}
+}
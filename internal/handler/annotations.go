@@ -0,0 +1,53 @@
+package handler
+
+// ToolAnnotations are hints about a tool's behavior (MCP 2025-03-26) that
+// clients use to decide when to ask the user for confirmation. They're
+// hints, not guarantees
+type ToolAnnotations struct {
+	ReadOnlyHint    bool `json:"readOnlyHint"`    // Doesn't modify the sandbox
+	DestructiveHint bool `json:"destructiveHint"` // May delete or overwrite data
+	IdempotentHint  bool `json:"idempotentHint"`  // Repeating a call has no further effect
+	OpenWorldHint   bool `json:"openWorldHint"`   // May reach beyond the sandbox (network, external sites)
+}
+
+// readOnly annotates tools that only look at the sandbox
+var readOnly = ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true}
+
+// toolAnnotations are listed with each tool in tools/list
+var toolAnnotations = map[string]ToolAnnotations{
+	// Code can do anything to /data and, with network enabled, the internet
+	"run_code":             {DestructiveHint: true, OpenWorldHint: true},
+	"run_shell":            {DestructiveHint: true, OpenWorldHint: true},
+	"run_pipeline":         {DestructiveHint: true, OpenWorldHint: true},
+	"run_tests":            {DestructiveHint: true, OpenWorldHint: true},
+	"install_package":      {IdempotentHint: true, OpenWorldHint: true},
+	"start_service":        {OpenWorldHint: true},
+	"start_process":        {OpenWorldHint: true},
+	"render_page":          {IdempotentHint: true, OpenWorldHint: true},
+	"upload_file":          {DestructiveHint: true, IdempotentHint: true},
+	"upload_files":         {DestructiveHint: true, IdempotentHint: true},
+	"fetch_file":           {DestructiveHint: true, IdempotentHint: true, OpenWorldHint: true},
+	"clone_repo":           {OpenWorldHint: true},
+	"create_from_template": {DestructiveHint: true, IdempotentHint: true},
+	"restore_sandbox":      {DestructiveHint: true, IdempotentHint: true},
+	"revoke_links":         {DestructiveHint: true, IdempotentHint: true},
+
+	"stop_service":          {DestructiveHint: true, IdempotentHint: true},
+	"stop_process":          {DestructiveHint: true, IdempotentHint: true},
+	"set_environment":       {IdempotentHint: true},
+	"set_conversation_name": {IdempotentHint: true},
+	"set_result_key":        {IdempotentHint: true},
+	"share_conversation":    {},
+	"create_download_link":  {},
+	"create_ingest_link":    {},
+	"snapshot_sandbox":      {},
+
+	"list_runners":          readOnly,
+	"describe_runner":       readOnly,
+	"lint_and_format":       readOnly,
+	"list_services":         readOnly,
+	"list_processes":        readOnly,
+	"read_output":           readOnly,
+	"get_environment":       readOnly,
+	"get_execution_history": readOnly,
+}
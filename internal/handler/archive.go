@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// extractUpload implements upload_file with extract: the archive is unpacked
+// into the sandbox instead of being stored
+func (h *MCPHandler) extractUpload(id interface{}, args UploadFileArguments, content []byte) JSONRPCResponse {
+	if !sandbox.IsArchive(args.Filename) {
+		return h.wrapToolResult(id, map[string]interface{}{
+			"success": false,
+			"message": sandbox.ErrUnsupportedArchive.Error(),
+		})
+	}
+
+	files, err := h.sandbox.ExtractArchive(args.ConversationID, args.Filename, content)
+	if err != nil {
+		log.Printf("[MCP] Failed to extract %s: %v", args.Filename, err)
+		return h.uploadFailure(id, "Failed to extract archive", err)
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to get hashed directory: %v", err)
+		return h.wrapToolResult(id, map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Failed to get directory: %v", err),
+		})
+	}
+
+	baseURL := h.signer.FileBaseURL(hashedDir)
+	descriptors := make([]FileDescriptor, 0, len(files))
+	for _, name := range files {
+		descriptors = append(descriptors, FileDescriptor{
+			Name: name,
+			URL:  fmt.Sprintf("%s/%s", baseURL, escapePath(name)),
+		})
+	}
+
+	log.Printf("[MCP] upload_file extracted %d file(s) from %s", len(files), args.Filename)
+	return h.wrapToolResult(id, map[string]interface{}{
+		"success": true,
+		"message": h.messages.Format(messages.ArchiveExtracted, messages.Args{"Files": len(files), "Filename": args.Filename}),
+		"files":   descriptors,
+	})
+}
+
+// escapePath escapes each segment of a slash-separated relative path
+func escapePath(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/jsc/mcp-code-sandbox/internal/gitclone"
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// CloneRepoArguments represents arguments for clone_repo
+type CloneRepoArguments struct {
+	ConversationID string `json:"conversationId"`
+	URL            string `json:"url"`
+	Ref            string `json:"ref,omitempty"`       // Branch or tag; defaults to the default branch
+	Depth          int    `json:"depth,omitempty"`     // Commits of history; defaults to 1
+	Directory      string `json:"directory,omitempty"` // Defaults to the repository name
+}
+
+// cloneDescription builds the clone_repo tool description
+func (h *MCPHandler) cloneDescription() string {
+	return fmt.Sprintf("Shallow-clone a git repository into a directory of the sandbox (/data/<directory>), server-side, so the code can be read, run or tested. Only HTTP(S) URLs on these hosts are allowed: %s. Clones may be up to %s including history, with at most %d commits of history. Submodules and Git LFS files are not fetched.",
+		strings.Join(h.cloner.Hosts(), ", "), units.HumanSize(float64(h.cloner.MaxBytes())), h.cloner.MaxDepth())
+}
+
+// handleCloneRepo implements the clone_repo tool
+func (h *MCPHandler) handleCloneRepo(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args CloneRepoArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] clone_repo: conversationId=%s, url=%s, ref=%s, depth=%d, directory=%s",
+		args.ConversationID, args.URL, args.Ref, args.Depth, args.Directory)
+
+	if !h.cloner.Enabled() {
+		return NewErrorResponse(id, InvalidParams, "clone_repo is not available: the server has no CLONE_ALLOWED_HOSTS", nil)
+	}
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if args.URL == "" {
+		return NewErrorResponse(id, InvalidParams, "url is required", nil)
+	}
+	u, err := h.cloner.CheckURL(args.URL)
+	if err != nil {
+		return NewErrorResponse(id, InvalidParams, err.Error(), nil)
+	}
+	if args.Depth == 0 {
+		args.Depth = 1
+	}
+	if args.Depth < 1 || args.Depth > h.cloner.MaxDepth() {
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("depth must be between 1 and %d", h.cloner.MaxDepth()), nil)
+	}
+	if args.Directory == "" {
+		args.Directory = gitclone.DefaultDirectory(u)
+	}
+	if err := sandbox.ValidateDirectoryName(args.Directory); err != nil {
+		return NewErrorResponse(id, InvalidParams, err.Error(), nil)
+	}
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return h.wrapToolResult(id, map[string]interface{}{
+			"success": false,
+			"message": toolErr.Message,
+			"error":   toolErr,
+		})
+	}
+
+	// Fail before the download if the directory is taken
+	if _, err := os.Lstat(filepath.Join(h.sandbox.GetSandboxDir(args.ConversationID), args.Directory)); err == nil {
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("'%s' already exists in the sandbox; choose another directory", args.Directory), nil)
+	}
+	staging, err := h.sandbox.CloneStagingDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to prepare clone: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to prepare clone", err.Error())
+	}
+	defer os.RemoveAll(staging)
+
+	dest := filepath.Join(staging, "repo")
+	result, err := h.cloner.Clone(ctx, args.URL, args.Ref, args.Depth, dest)
+	if err != nil {
+		if errors.Is(err, gitclone.ErrTooLarge) {
+			err = fmt.Errorf("%w (%s)", err, units.HumanSize(float64(h.cloner.MaxBytes())))
+		}
+		log.Printf("[MCP] Failed to clone %s: %v", args.URL, err)
+		return h.wrapToolResult(id, map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Failed to clone %s: %v", args.URL, err),
+		})
+	}
+	if err := h.sandbox.AdoptDirectory(args.ConversationID, dest, args.Directory); err != nil {
+		if errors.Is(err, sandbox.ErrDirectoryExists) {
+			return NewErrorResponse(id, InvalidParams, fmt.Sprintf("'%s' already exists in the sandbox; choose another directory", args.Directory), nil)
+		}
+		log.Printf("[MCP] Failed to move clone into sandbox: %v", err)
+		if h.limitError(err) != nil {
+			return h.uploadFailure(id, "Failed to clone "+args.URL, err)
+		}
+		return NewErrorResponse(id, InternalError, "Failed to move clone into sandbox", err.Error())
+	}
+
+	log.Printf("[MCP] clone_repo completed: %s -> %s (commit %s, %d files)", args.URL, args.Directory, result.Commit, result.Files)
+	return h.wrapToolResult(id, map[string]interface{}{
+		"success": true,
+		"message": h.messages.Format(messages.RepoCloned, messages.Args{
+			"URL": args.URL, "Directory": args.Directory, "Files": result.Files, "Commit": result.Commit,
+		}),
+		"directory": args.Directory,
+		"commit":    result.Commit,
+		"files":     result.Files,
+	})
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/docrender"
+)
+
+// maxRenderBytes is the largest Markdown file or notebook /render/ converts
+const maxRenderBytes = 20 << 20
+
+// renderedPage wraps a converted document. The body was built by docrender,
+// which escapes all text and drops unsafe URLs
+var renderedPage = template.Must(template.New("rendered").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #222; line-height: 1.5; }
+header { display: flex; justify-content: space-between; align-items: baseline; border-bottom: 1px solid #eee; margin-bottom: 1em; }
+header span { color: #777; }
+pre { background: #f6f8fa; padding: 0.8em; overflow-x: auto; border-radius: 4px; }
+code { font-family: ui-monospace, monospace; font-size: 0.9em; }
+:not(pre) > code { background: #f6f8fa; padding: 0.1em 0.3em; border-radius: 3px; }
+img { max-width: 100%; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.6em; }
+blockquote { margin-left: 0; padding-left: 1em; border-left: 4px solid #ddd; color: #555; }
+.cell { margin: 1em 0; }
+.prompt { color: #777; font-family: ui-monospace, monospace; font-size: 0.8em; }
+pre.output { background: none; border-left: 3px solid #ddd; }
+pre.stderr, pre.error { background: #fdf0f0; }
+.omitted { color: #777; font-style: italic; }
+</style>
+</head>
+<body>
+<header><span>{{.Name}}</span><a href="{{.Download}}">Download</a></header>
+{{.Body}}
+</body>
+</html>
+`))
+
+// handleRender shows a Markdown file or Jupyter notebook as HTML:
+// GET /render/{hashedDir}/{file}. Like /files/, the hashed directory is the
+// capability. Relative links in the document point to the sandbox's files
+func (s *Server) handleRender(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hashedDir, filename, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/render/"), "/")
+	if len(hashedDir) != 16 {
+		http.Error(w, "Invalid directory hash", http.StatusBadRequest)
+		return
+	}
+	ext := strings.ToLower(path.Ext(filename))
+	if ext != ".md" && ext != ".markdown" && ext != ".ipynb" {
+		http.Error(w, "Only Markdown (.md) files and notebooks (.ipynb) can be rendered", http.StatusBadRequest)
+		return
+	}
+	if s.sandbox.ResultKey(hashedDir) != nil {
+		http.Error(w, "Files in this sandbox are sealed; download and decrypt them instead", http.StatusForbidden)
+		return
+	}
+
+	if err := s.sandbox.FetchFile(r.Context(), hashedDir, filename); err != nil && !os.IsNotExist(err) {
+		log.Printf("[HTTP] Failed to fetch file from storage: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	filePath := s.sandbox.GetFilePath(hashedDir, filename)
+	sandboxDir := filepath.Join(s.sandbox.GetSandboxRoot(), hashedDir)
+	if !strings.HasPrefix(filepath.Clean(filePath), filepath.Clean(sandboxDir)+string(filepath.Separator)) {
+		log.Printf("Path traversal attempt: %s", filePath)
+		http.Error(w, "Invalid file path", http.StatusForbidden)
+		return
+	}
+	f, info, err := s.sandbox.OpenFile(hashedDir, filename)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	if info.Size() > maxRenderBytes {
+		http.Error(w, "File is too large to render; download it instead", http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Header().Set("ETag", fileETag(info))
+	w.Header().Set("Cache-Control", "no-cache")
+	if match := r.Header.Get("If-None-Match"); match != "" && match == fileETag(info) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, info.Size()))
+	if err != nil {
+		log.Printf("[HTTP] Failed to read file: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	baseURL := s.signer.FileBaseURL(hashedDir)
+	opts := docrender.Options{FileBaseURL: baseURL, Dir: path.Dir(filename)}
+	if opts.Dir == "." {
+		opts.Dir = ""
+	}
+	var body string
+	if ext == ".ipynb" {
+		body, err = docrender.Notebook(data, opts)
+		if errors.Is(err, docrender.ErrNotNotebook) {
+			http.Error(w, "File is not a Jupyter notebook (nbformat 4)", http.StatusUnprocessableEntity)
+			return
+		}
+	} else {
+		body = docrender.Markdown(data, opts)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	err = renderedPage.Execute(w, map[string]interface{}{
+		"Name":     path.Base(filename),
+		"Download": baseURL + "/" + escapePath(filename),
+		"Body":     template.HTML(body),
+	})
+	if err != nil {
+		log.Printf("[HTTP] Failed to render document: %v", err)
+	}
+}
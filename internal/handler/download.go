@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// fileTypes are media types for data formats that Go's built-in table and a
+// minimal system's mime.types lack or get wrong; they take precedence
+var fileTypes = map[string]string{
+	".csv":     "text/csv; charset=utf-8",
+	".tsv":     "text/tab-separated-values; charset=utf-8",
+	".md":      "text/markdown; charset=utf-8",
+	".txt":     "text/plain; charset=utf-8",
+	".log":     "text/plain; charset=utf-8",
+	".py":      "text/x-python; charset=utf-8",
+	".ts":      "text/plain; charset=utf-8", // TypeScript, not MPEG transport streams
+	".json":    "application/json",
+	".jsonl":   "application/x-ndjson",
+	".ndjson":  "application/x-ndjson",
+	".geojson": "application/geo+json",
+	".yaml":    "application/yaml",
+	".yml":     "application/yaml",
+	".ipynb":   "application/x-ipynb+json",
+	".parquet": "application/vnd.apache.parquet",
+	".arrow":   "application/vnd.apache.arrow.file",
+	".feather": "application/vnd.apache.arrow.file",
+	".avro":    "application/avro",
+	".orc":     "application/vnd.apache.orc",
+	".h5":      "application/x-hdf5",
+	".hdf5":    "application/x-hdf5",
+	".sqlite":  "application/vnd.sqlite3",
+	".db":      "application/vnd.sqlite3",
+	".xlsx":    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".docx":    "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".pptx":    "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".svg":     "image/svg+xml",
+	".webp":    "image/webp",
+}
+
+// typeByExtension returns the media type for a file name's extension, or ""
+// if it is unknown
+func typeByExtension(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if contentType, ok := fileTypes[ext]; ok {
+		return contentType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// detectContentType returns a file's media type from its extension, or by
+// sniffing head, its first bytes
+func detectContentType(name string, head []byte) string {
+	if contentType := typeByExtension(name); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(head)
+}
+
+// contentDisposition builds a download's Content-Disposition: inline for
+// images and HTML, which browsers should show (the file CSP keeps HTML
+// inert), and attachment for everything else or when download is set
+func contentDisposition(name, contentType string, download bool) string {
+	disposition := "attachment"
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if !download && (strings.HasPrefix(mediaType, "image/") || mediaType == "text/html") {
+		disposition = "inline"
+	}
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": filepath.Base(name)}); header != "" {
+		return header
+	}
+	return disposition
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/lintreport"
+)
+
+// pythonSyntaxCheck compiles each file named in its arguments without running
+// it, printing syntax errors in tsc's format so one parser reads both
+const pythonSyntaxCheck = `import sys
+failed = False
+for path in sys.argv[1:]:
+    try:
+        with open(path, "rb") as f:
+            compile(f.read(), path, "exec", dont_inherit=True)
+    except (SyntaxError, ValueError) as e:
+        failed = True
+        line, col = getattr(e, "lineno", None) or 0, getattr(e, "offset", None) or 0
+        print(f"{path}({line},{col}): error {type(e).__name__}: {getattr(e, 'msg', e)}")
+sys.exit(1 if failed else 0)
+`
+
+// checkers are the dry-run checks for each language, as commands taking the
+// files to check as arguments
+var checkers = map[string]struct {
+	Command    string
+	Extensions []string
+}{
+	"python": {
+		Command:    "python -c " + shellQuote(pythonSyntaxCheck),
+		Extensions: []string{".py"},
+	},
+	"typescript": {
+		// Files given on the command line make tsc ignore tsconfig.json, so
+		// the options approximate what bun accepts
+		Command: "bun /opt/lint/node_modules/typescript/bin/tsc --noEmit --pretty false" +
+			" --target es2022 --module esnext --moduleResolution bundler --lib es2022,dom" +
+			" --allowJs --allowImportingTsExtensions --esModuleInterop --skipLibCheck" +
+			" --typeRoots /opt/lint/node_modules/@types --types bun",
+		Extensions: []string{".ts", ".tsx", ".js", ".mjs"},
+	},
+}
+
+// checkCode carries out run_code with dryRun: it checks the code, the
+// entrypoint and the project files in the runner without running anything.
+// Packages aren't installed and the container has no network
+func (h *MCPHandler) checkCode(ctx context.Context, id interface{}, args RunCodeArguments) JSONRPCResponse {
+	checker, ok := checkers[args.Language]
+	if !ok {
+		return NewErrorResponse(id, InvalidParams, "dryRun is only supported for python and typescript", nil)
+	}
+	// The report is read back from the sandbox, which sealing keeps from the server
+	if h.sandbox.ConversationResultKey(args.ConversationID) != nil {
+		return NewErrorResponse(id, InvalidParams, "dryRun is not available for conversations with a result key", nil)
+	}
+
+	scratch, err := scratchName(".check-")
+	if err != nil {
+		return NewErrorResponse(id, InternalError, "Failed to check code", err.Error())
+	}
+	files := map[string]string{}
+	for name, content := range args.Files {
+		files[name] = content
+	}
+	var targets []string
+	if args.Code != "" {
+		files[scratch+checker.Extensions[0]] = args.Code
+		targets = append(targets, scratch+checker.Extensions[0])
+	} else {
+		targets = append(targets, filepath.ToSlash(filepath.Clean(args.Entrypoint)))
+	}
+	for name := range args.Files {
+		name = path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "/"))
+		if name != targets[0] && slices.Contains(checker.Extensions, path.Ext(name)) {
+			targets = append(targets, name)
+		}
+	}
+	sort.Strings(targets[1:])
+
+	report := scratch + ".out"
+	command := checker.Command
+	for _, target := range targets {
+		command += " " + shellQuote("/data/"+target)
+	}
+	command += " > " + shellQuote("/data/"+report)
+
+	log.Printf("[MCP] Dry run: %d file(s) for conversation %s", len(targets), args.ConversationID)
+	resp := h.runInSandbox(ctx, id, RunCodeArguments{
+		ConversationID: args.ConversationID,
+		Language:       args.Language,
+		Version:        args.Version,
+		Code:           command,
+		Files:          files,
+		Template:       args.Template,
+	}, true)
+	sandboxDir := h.sandbox.GetSandboxDir(args.ConversationID)
+	defer func() {
+		os.Remove(filepath.Join(sandboxDir, report))
+		if args.Code != "" {
+			os.Remove(filepath.Join(sandboxDir, targets[0]))
+		}
+	}()
+	if resp.Error != nil {
+		return resp
+	}
+	toolResult, _ := resp.Result.(ToolResult)
+	ran, ok := toolResult.StructuredContent.(RunCodeResult)
+	if !ok || ran.Error != nil {
+		return resp
+	}
+
+	output, _ := h.sandbox.ReadPath(args.ConversationID, report)
+	result := RunCodeResult{
+		DryRun:   true,
+		Stderr:   tail(ran.Stderr, maxLintStderr),
+		ExitCode: ran.ExitCode,
+		Usage:    ran.Usage,
+	}
+	errorCount := 0
+	for i, d := range lintreport.ParseTSC(output) {
+		if d.Severity == "error" {
+			errorCount++
+		}
+		if i >= maxLintDiagnostics {
+			continue
+		}
+		if args.Code != "" && d.File == targets[0] {
+			d.File = ""
+		}
+		result.Diagnostics = append(result.Diagnostics, LintDiagnostic(d))
+	}
+	// A checker that failed without diagnostics didn't run, e.g. isn't installed
+	result.Success = errorCount == 0 && ran.ExitCode == 0
+	if !result.Success && len(result.Diagnostics) == 0 && result.Stderr == "" {
+		result.Stderr = fmt.Sprintf("The %s check failed with exit code %d", args.Language, ran.ExitCode)
+	}
+
+	log.Printf("[MCP] Dry run completed: success=%v, diagnostics=%d", result.Success, len(result.Diagnostics))
+	return h.wrapToolResult(id, result)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/jsc/mcp-code-sandbox/internal/egress"
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+)
+
+// FetchFileArguments represents arguments for fetch_file
+type FetchFileArguments struct {
+	ConversationID string `json:"conversationId"`
+	URL            string `json:"url"`
+	Filename       string `json:"filename,omitempty"` // Defaults to the name from the response or URL
+	Extract        bool   `json:"extract,omitempty"`  // Unpack a zip/tar(.gz) archive instead of storing it
+}
+
+// fetchDescription builds the fetch_file tool description
+func (h *MCPHandler) fetchDescription() string {
+	return fmt.Sprintf("Download a file from a URL into the sandbox (/data), server-side, so public datasets don't have to be base64-uploaded with upload_file. Only these domains are allowed: %s. Files may be up to %s. Returns the file's download URL, as upload_file does.",
+		strings.Join(h.fetcher.Domains(), ", "), units.HumanSize(float64(h.fetcher.MaxBytes())))
+}
+
+// handleFetchFile implements the fetch_file tool
+func (h *MCPHandler) handleFetchFile(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args FetchFileArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] fetch_file: conversationId=%s, url=%s, filename=%s, extract=%v",
+		args.ConversationID, args.URL, args.Filename, args.Extract)
+
+	if !h.fetcher.Enabled() {
+		return NewErrorResponse(id, InvalidParams, "fetch_file is not available: the server has no FETCH_ALLOWED_DOMAINS", nil)
+	}
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if args.URL == "" {
+		return NewErrorResponse(id, InvalidParams, "url is required", nil)
+	}
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return h.wrapToolResult(id, map[string]interface{}{
+			"success": false,
+			"message": toolErr.Message,
+			"error":   toolErr,
+		})
+	}
+
+	download, err := h.fetcher.Fetch(ctx, args.URL)
+	if err != nil {
+		if errors.Is(err, egress.ErrFetchTooLarge) {
+			err = fmt.Errorf("%w (%s)", err, units.HumanSize(float64(h.fetcher.MaxBytes())))
+		}
+		log.Printf("[MCP] Failed to fetch %s: %v", args.URL, err)
+		return h.wrapToolResult(id, map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Failed to fetch %s: %v", args.URL, err),
+		})
+	}
+	if args.Filename == "" {
+		args.Filename = download.Filename
+	}
+	if args.Filename == "" {
+		return NewErrorResponse(id, InvalidParams, "filename is required: the URL doesn't name a file", nil)
+	}
+
+	upload := UploadFileArguments{ConversationID: args.ConversationID, Filename: args.Filename, Extract: args.Extract}
+	if args.Extract {
+		return h.extractUpload(id, upload, download.Data)
+	}
+
+	if err := h.sandbox.WriteFile(args.ConversationID, args.Filename, download.Data); err != nil {
+		log.Printf("[MCP] Failed to write file: %v", err)
+		return h.uploadFailure(id, "Failed to write file", err)
+	}
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to get hashed directory: %v", err)
+		return h.wrapToolResult(id, map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Failed to get directory: %v", err),
+		})
+	}
+
+	fileURL := fmt.Sprintf("%s/%s", h.signer.FileBaseURL(hashedDir), escapePath(args.Filename))
+	log.Printf("[MCP] fetch_file completed: %s -> %s (%d bytes, %s)", download.URL, fileURL, len(download.Data), download.ContentType)
+	return h.wrapToolResult(id, map[string]interface{}{
+		"success": true,
+		"message": h.messages.Format(messages.FileFetched, messages.Args{"Filename": args.Filename, "URL": download.URL, "Bytes": len(download.Data)}),
+		"file": FileDescriptor{
+			Name: args.Filename,
+			URL:  fileURL,
+		},
+		"contentType": download.ContentType,
+	})
+}
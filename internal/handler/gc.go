@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// handleGC reports or purges inactive sandboxes:
+// GET /admin/gc?maxAge=720h always dry-runs; POST deletes unless dryRun=true.
+// maxAge defaults to the configured retention
+func (s *Server) handleGC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	maxAge := s.retention
+	if v := query.Get("maxAge"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid maxAge", http.StatusBadRequest)
+			return
+		}
+		maxAge = d
+	}
+	if maxAge <= 0 {
+		http.Error(w, "maxAge is required when SANDBOX_RETENTION is not set", http.StatusBadRequest)
+		return
+	}
+
+	dryRun := r.Method == http.MethodGet
+	if v := query.Get("dryRun"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid dryRun", http.StatusBadRequest)
+			return
+		}
+		dryRun = dryRun || b
+	}
+
+	report, err := s.gc.Run(maxAge, dryRun, fmt.Sprintf("admin api (%s)", r.RemoteAddr))
+	if err != nil {
+		log.Printf("[HTTP] GC failed: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[HTTP] GC (dryRun=%v, maxAge=%s): %d candidate(s), %d deleted", dryRun, maxAge, len(report.Candidates), report.Deleted)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("[HTTP] Failed to write GC report: %v", err)
+	}
+}
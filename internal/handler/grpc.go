@@ -0,0 +1,329 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	sandboxv1 "github.com/jsc/mcp-code-sandbox/api/sandbox/v1"
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+)
+
+// GRPCServer implements the Sandbox gRPC service (api/sandbox/v1) over the
+// same tool handlers as MCP, so scopes, tenants and limits apply alike
+type GRPCServer struct {
+	sandboxv1.UnimplementedSandboxServer
+	mcpHandler     *MCPHandler
+	uploadMaxBytes int64
+}
+
+// NewGRPCServer creates a gRPC server for the Sandbox service. Calls
+// authenticate with "authorization: Bearer <token>" metadata, checked like
+// the HTTP API's header. creds is nil for plaintext
+func NewGRPCServer(
+	mcpHandler *MCPHandler,
+	tokens *auth.TokenStore,
+	jwt *auth.JWTVerifier,
+	limiter *auth.RateLimiter,
+	lockout *auth.Lockout,
+	uploadMaxBytes int64,
+	creds credentials.TransportCredentials,
+) *grpc.Server {
+	a := grpcAuth{tokens: tokens, jwt: jwt, limiter: limiter, lockout: lockout}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(a.unary),
+		grpc.StreamInterceptor(a.stream),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	srv := grpc.NewServer(opts...)
+	sandboxv1.RegisterSandboxServer(srv, &GRPCServer{mcpHandler: mcpHandler, uploadMaxBytes: uploadMaxBytes})
+	return srv
+}
+
+// RunCode runs code, streaming its output and progress, then the result
+func (g *GRPCServer) RunCode(req *sandboxv1.RunCodeRequest, stream sandboxv1.Sandbox_RunCodeServer) error {
+	args := RunCodeArguments{
+		ConversationID: req.ConversationId,
+		Language:       req.Language,
+		Version:        req.Version,
+		Code:           req.Code,
+		Packages:       req.Packages,
+		Network: NetworkSetting{
+			Enabled:    req.Network == sandboxv1.Network_NETWORK_ENABLED,
+			Restricted: req.Network == sandboxv1.Network_NETWORK_RESTRICTED,
+		},
+		Environment: req.Environment,
+		Secrets:     req.Secrets,
+		Stdin:       req.Stdin,
+		Files:       req.Files,
+		Entrypoint:  req.Entrypoint,
+	}
+
+	// Output and progress arrive on different goroutines; a stream allows
+	// one sender at a time
+	var mu sync.Mutex
+	send := func(event *sandboxv1.RunCodeEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := stream.Send(event); err != nil {
+			log.Printf("[gRPC] Failed to send RunCode event: %v", err)
+		}
+	}
+	ctx := stream.Context()
+	// Sealed conversations only get their output encrypted, in the result
+	if g.mcpHandler.sandbox.ConversationResultKey(req.ConversationId) == nil {
+		ctx = runner.WithOutput(ctx, func(name string, data []byte) {
+			output := &sandboxv1.Output{Data: append([]byte(nil), data...)}
+			if name == "stderr" {
+				output.Stream = sandboxv1.Output_STREAM_STDERR
+			}
+			send(&sandboxv1.RunCodeEvent{Event: &sandboxv1.RunCodeEvent_Output{Output: output}})
+		})
+	}
+	ctx = runner.WithProgress(ctx, func(p runner.Progress) {
+		send(&sandboxv1.RunCodeEvent{Event: &sandboxv1.RunCodeEvent_Progress{Progress: &sandboxv1.Progress{
+			ElapsedMs:       p.Elapsed.Milliseconds(),
+			StdoutBytes:     p.StdoutBytes,
+			StderrBytes:     p.StderrBytes,
+			Queued:          p.Queued,
+			QueuePosition:   int32(p.QueuePosition),
+			EstimatedWaitMs: p.EstimatedWait.Milliseconds(),
+		}}})
+	})
+
+	log.Printf("[gRPC] RunCode: conversationId=%s, language=%s", req.ConversationId, req.Language)
+	var result RunCodeResult
+	if err := grpcToolResult(g.mcpHandler.callTool(ctx, "run_code", args), &result); err != nil {
+		return err
+	}
+	send(&sandboxv1.RunCodeEvent{Event: &sandboxv1.RunCodeEvent_Result{Result: &sandboxv1.RunCodeResult{
+		Success:         result.Success,
+		Stdout:          result.Stdout,
+		Stderr:          result.Stderr,
+		ExitCode:        int32(result.ExitCode),
+		OomKilled:       result.OOMKilled,
+		Files:           grpcFiles(result.Files),
+		StdoutBytes:     int64(result.StdoutBytes),
+		StderrBytes:     int64(result.StderrBytes),
+		Truncated:       result.Truncated,
+		StdoutNextToken: result.StdoutNextToken,
+		PreviewUrl:      result.PreviewURL,
+		Error:           grpcToolError(result.Error),
+		Sealed:          result.Sealed,
+	}}})
+	return nil
+}
+
+// UploadFile stores a file sent as a header followed by content chunks
+func (g *GRPCServer) UploadFile(stream sandboxv1.Sandbox_UploadFileServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	header := first.GetHeader()
+	if header == nil {
+		return status.Error(codes.InvalidArgument, "the first message must carry the header")
+	}
+	if !filepath.IsLocal(header.Filename) {
+		return status.Error(codes.InvalidArgument, "filename must be a relative path inside the sandbox")
+	}
+
+	var content []byte
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		chunk, ok := msg.Part.(*sandboxv1.UploadFileRequest_Chunk)
+		if !ok {
+			return status.Error(codes.InvalidArgument, "only the first message may carry the header")
+		}
+		if int64(len(content)+len(chunk.Chunk)) > g.uploadMaxBytes {
+			return status.Errorf(codes.ResourceExhausted, "file larger than %d bytes", g.uploadMaxBytes)
+		}
+		content = append(content, chunk.Chunk...)
+	}
+
+	log.Printf("[gRPC] UploadFile: conversationId=%s, filename=%s, %d bytes", header.ConversationId, header.Filename, len(content))
+	args := UploadFileArguments{
+		ConversationID: header.ConversationId,
+		Filename:       header.Filename,
+		Content:        base64.StdEncoding.EncodeToString(content),
+		Extract:        header.Extract,
+	}
+	var result struct {
+		Success bool             `json:"success"`
+		Message string           `json:"message"`
+		File    *FileDescriptor  `json:"file"`
+		Files   []FileDescriptor `json:"files"`
+	}
+	if err := grpcToolResult(g.mcpHandler.callTool(stream.Context(), "upload_file", args), &result); err != nil {
+		return err
+	}
+	if result.File != nil {
+		result.Files = append(result.Files, *result.File)
+	}
+	return stream.SendAndClose(&sandboxv1.UploadFileResponse{
+		Success: result.Success,
+		Message: result.Message,
+		Files:   grpcFiles(result.Files),
+	})
+}
+
+// ListRunners lists the available language runners
+func (g *GRPCServer) ListRunners(ctx context.Context, _ *sandboxv1.ListRunnersRequest) (*sandboxv1.ListRunnersResponse, error) {
+	var result ListRunnersResult
+	if err := grpcToolResult(g.mcpHandler.callTool(ctx, "list_runners", struct{}{}), &result); err != nil {
+		return nil, err
+	}
+	resp := &sandboxv1.ListRunnersResponse{}
+	for _, r := range result.Languages {
+		resp.Runners = append(resp.Runners, &sandboxv1.Runner{
+			Language:    r.Language,
+			Version:     r.Version,
+			Default:     r.Default,
+			Image:       r.Image,
+			Description: r.Description,
+		})
+	}
+	return resp, nil
+}
+
+// grpcToolResult decodes a tool's structured result into v, or returns its
+// JSON-RPC error as a gRPC status
+func grpcToolResult(resp JSONRPCResponse, v interface{}) error {
+	if resp.Error != nil {
+		code := codes.Internal
+		switch resp.Error.Code {
+		case InvalidParams, InvalidRequest:
+			code = codes.InvalidArgument
+		case Forbidden:
+			code = codes.PermissionDenied
+		case MethodNotFound, ResourceNotFound:
+			code = codes.NotFound
+		}
+		return status.Error(code, resp.Error.Message)
+	}
+	result, ok := resp.Result.(ToolResult)
+	if !ok {
+		return status.Error(codes.Internal, "unexpected tool result")
+	}
+	// The structured content is a typed result or a map; JSON handles both
+	data, err := json.Marshal(result.StructuredContent)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to decode tool result: %v", err)
+	}
+	return nil
+}
+
+func grpcFiles(files []FileDescriptor) []*sandboxv1.File {
+	result := make([]*sandboxv1.File, 0, len(files))
+	for _, f := range files {
+		result = append(result, &sandboxv1.File{Name: f.Name, Url: f.URL})
+	}
+	return result
+}
+
+func grpcToolError(toolErr *ToolError) *sandboxv1.ToolError {
+	if toolErr == nil {
+		return nil
+	}
+	return &sandboxv1.ToolError{Code: toolErr.Code, Message: toolErr.Message}
+}
+
+// grpcAuth authenticates calls by their bearer token and applies the rate
+// limits and lockout of the HTTP API
+type grpcAuth struct {
+	tokens  *auth.TokenStore
+	jwt     *auth.JWTVerifier
+	limiter *auth.RateLimiter
+	lockout *auth.Lockout
+}
+
+func (a grpcAuth) unary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a grpcAuth) stream(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate returns ctx carrying the caller's identity
+func (a grpcAuth) authenticate(ctx context.Context) (context.Context, error) {
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	if a.lockout.LockedFor(ip) > 0 {
+		return nil, status.Error(codes.ResourceExhausted, "Too many failed authentication attempts")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Missing authorization metadata")
+	}
+	const bearerPrefix = "Bearer "
+	if !strings.HasPrefix(values[0], bearerPrefix) {
+		a.lockout.Fail(ctx, ip)
+		return nil, status.Error(codes.Unauthenticated, "Invalid authorization metadata format")
+	}
+	identity, err := auth.Authenticate(ctx, a.tokens, a.jwt, strings.TrimPrefix(values[0], bearerPrefix))
+	if err != nil {
+		log.Printf("[gRPC] Rejected token from %s: %v", ip, err)
+		a.lockout.Fail(ctx, ip)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	a.lockout.Succeed(ip)
+
+	if !a.limiter.Allow(ip, identity.Name) {
+		log.Printf("[gRPC] Rate limit reached for %s (token %q)", ip, identity.Name)
+		return nil, status.Error(codes.ResourceExhausted, "Rate limit exceeded")
+	}
+	return auth.WithIdentity(ctx, identity), nil
+}
+
+// authenticatedStream is a server stream whose context carries the caller's
+// identity
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/history"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// GetExecutionHistoryArguments represents arguments for get_execution_history
+type GetExecutionHistoryArguments struct {
+	ConversationID string `json:"conversationId"`
+	Language       string `json:"language,omitempty"`
+	Limit          int    `json:"limit,omitempty"`
+	Before         int64  `json:"before,omitempty"`
+}
+
+// ExecutionHistoryResult is a page of execution records
+// NextBefore is set when older records may exist
+type ExecutionHistoryResult struct {
+	Executions []history.Record `json:"executions"`
+	NextBefore int64            `json:"nextBefore,omitempty"`
+}
+
+// recordHistory stores a finished run_code execution; failures are logged
+func (h *MCPHandler) recordHistory(ctx context.Context, args RunCodeArguments, runnerInfo runner.RunnerInfo, started time.Time, duration time.Duration, execResult runner.ExecutionResult, files []FileDescriptor) {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+
+	// Record even if the client has gone away
+	err := h.history.Add(context.WithoutCancel(ctx), history.Record{
+		ConversationID: args.ConversationID,
+		Language:       runnerInfo.Language,
+		Version:        runnerInfo.Version,
+		Image:          runnerInfo.Image,
+		StartedAt:      started,
+		DurationMs:     duration.Milliseconds(),
+		ExitCode:       execResult.ExitCode,
+		Success:        execResult.Success,
+		TimedOut:       execResult.TimedOut,
+		Stdout:         execResult.Stdout,
+		Stderr:         execResult.Stderr,
+		Files:          names,
+	})
+	if err != nil {
+		log.Printf("[MCP] %v", err)
+	}
+}
+
+// handleGetExecutionHistory implements the get_execution_history tool
+func (h *MCPHandler) handleGetExecutionHistory(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args GetExecutionHistoryArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+
+	result, err := queryHistory(ctx, h.history, h.sandbox, history.Filter{
+		ConversationID: args.ConversationID,
+		Language:       args.Language,
+		Before:         args.Before,
+		Limit:          args.Limit,
+	})
+	if err != nil {
+		log.Printf("[MCP] %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to read execution history", err.Error())
+	}
+	return h.wrapToolResult(id, result)
+}
+
+// handleExecutions serves the execution history across conversations:
+// GET /api/executions?conversationId=&language=&limit=&before=
+func (s *Server) handleExecutions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := history.Filter{
+		ConversationID: query.Get("conversationId"),
+		Language:       query.Get("language"),
+	}
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("before"); v != "" {
+		if filter.Before, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "Invalid before", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := queryHistory(r.Context(), s.history, s.sandbox, filter)
+	if err != nil {
+		log.Printf("[HTTP] %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("[HTTP] Failed to write executions: %v", err)
+	}
+}
+
+// queryHistory fetches one page of records, labels them with their
+// conversation's display name and computes the next cursor
+func queryHistory(ctx context.Context, store *history.Store, sandboxMgr *sandbox.Manager, filter history.Filter) (ExecutionHistoryResult, error) {
+	records, err := store.Query(ctx, filter)
+	if err != nil {
+		return ExecutionHistoryResult{}, err
+	}
+	names := make(map[string]string)
+	for i := range records {
+		id := records[i].ConversationID
+		name, ok := names[id]
+		if !ok {
+			name = sandboxMgr.ConversationDisplayName(id)
+			names[id] = name
+		}
+		records[i].ConversationName = name
+	}
+
+	result := ExecutionHistoryResult{Executions: records}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = history.DefaultLimit
+	}
+	if len(records) > 0 && len(records) >= min(limit, history.MaxLimit) {
+		result.NextBefore = records[len(records)-1].ID
+	}
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// Ingest link lifetimes
+const (
+	defaultIngestHours = 7 * 24
+	maxIngestHours     = 30 * 24
+)
+
+// ingestSignatureHeader carries the HMAC of the request body
+const ingestSignatureHeader = "X-Signature-256"
+
+// CreateIngestLinkArguments represents arguments for create_ingest_link
+type CreateIngestLinkArguments struct {
+	ConversationID string `json:"conversationId"`
+	ExpiresInHours int    `json:"expiresInHours,omitempty"` // Default 168, at most 720
+}
+
+// CreateIngestLinkResult represents the result of create_ingest_link
+type CreateIngestLinkResult struct {
+	URL           string    `json:"url"`
+	SigningSecret string    `json:"signingSecret"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+}
+
+// handleCreateIngestLink implements the create_ingest_link tool
+func (h *MCPHandler) handleCreateIngestLink(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args CreateIngestLinkArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if args.ExpiresInHours == 0 {
+		args.ExpiresInHours = defaultIngestHours
+	}
+	if args.ExpiresInHours < 0 || args.ExpiresInHours > maxIngestHours {
+		return NewErrorResponse(id, InvalidParams, "expiresInHours must be between 1 and 720", nil)
+	}
+
+	expires := time.Now().Add(time.Duration(args.ExpiresInHours) * time.Hour).Truncate(time.Second)
+	ingestURL, secret, err := h.signer.MakeIngestURL(args.ConversationID, expires)
+	if err != nil {
+		log.Printf("[MCP] Failed to create ingest link: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create ingest link", err.Error())
+	}
+
+	log.Printf("[MCP] create_ingest_link: conversationId=%s, expires=%s", args.ConversationID, expires.UTC().Format(time.RFC3339))
+	return h.wrapToolResult(id, CreateIngestLinkResult{
+		URL:           ingestURL,
+		SigningSecret: secret,
+		ExpiresAt:     expires.UTC(),
+	})
+}
+
+// handleIngest accepts a file from an external system:
+// POST /ingest/{token}?filename=data.csv with the body signed in X-Signature-256
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, "/ingest/")
+	conversationID, _, err := s.signer.ParseIngestToken(token)
+	if err != nil {
+		http.Error(w, "Ingest link is invalid or has expired", http.StatusNotFound)
+		return
+	}
+
+	filename := r.URL.Query().Get("filename")
+	if !isPlainFilename(filename) {
+		http.Error(w, "filename query parameter must be a plain file name", http.StatusBadRequest)
+		return
+	}
+
+	// The body is size-limited before it is buffered for signature checking
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.ingestMaxBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Body exceeds %d bytes", s.ingestMaxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	if !s.signer.VerifyIngestSignature(token, body, r.Header.Get(ingestSignatureHeader)) {
+		log.Printf("[HTTP] Rejected ingest with bad signature from %s", r.RemoteAddr)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.sandbox.CheckFreeSpace(); err != nil {
+		var spaceErr *sandbox.InsufficientSpaceError
+		if errors.As(err, &spaceErr) {
+			log.Printf("[HTTP] Refusing ingest: %v", spaceErr)
+			http.Error(w, "Insufficient storage", http.StatusInsufficientStorage)
+			return
+		}
+		log.Printf("[HTTP] %v", err)
+	}
+
+	if err := s.sandbox.WriteFile(conversationID, filename, body); err != nil {
+		var limitErr *sandbox.LimitError
+		if errors.As(err, &limitErr) {
+			log.Printf("[HTTP] Refusing ingest: %v", limitErr)
+			http.Error(w, limitErr.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		log.Printf("[HTTP] Failed to write ingested file: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.sandbox.Sync(r.Context(), conversationID); err != nil {
+		log.Printf("[HTTP] Failed to store ingested file: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	hashedDir, err := s.sandbox.EnsureSandboxDir(conversationID)
+	if err != nil {
+		log.Printf("[HTTP] Failed to get hashed directory: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[HTTP] Ingested %s (%d bytes) for conversation %s", filename, len(body), conversationID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"file": FileDescriptor{
+			Name: filename,
+			URL:  fmt.Sprintf("%s/%s", s.signer.FileBaseURL(hashedDir), filename),
+		},
+	})
+}
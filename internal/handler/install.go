@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+)
+
+// InstallPackageArguments represents arguments for install_package
+type InstallPackageArguments struct {
+	ConversationID string   `json:"conversationId"`
+	Language       string   `json:"language"`
+	Version        string   `json:"version,omitempty"`
+	Packages       []string `json:"packages"`
+	Reset          bool     `json:"reset,omitempty"` // Clear the cache before installing
+}
+
+// InstallPackageResult represents the result of install_package
+type InstallPackageResult struct {
+	Success    bool       `json:"success"`
+	Output     string     `json:"output,omitempty"` // Package manager output
+	CacheBytes int64      `json:"cacheBytes"`       // Size of the package cache afterwards
+	Error      *ToolError `json:"error,omitempty"`
+}
+
+// handleInstallPackage implements the install_package tool
+func (h *MCPHandler) handleInstallPackage(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args InstallPackageArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if args.Language == "" {
+		return NewErrorResponse(id, InvalidParams, "language is required", nil)
+	}
+	if len(args.Packages) == 0 && !args.Reset {
+		return NewErrorResponse(id, InvalidParams, "packages is required", nil)
+	}
+	if len(args.Packages) > 0 {
+		if err := h.installs.Check(args.Packages); err != nil {
+			log.Printf("[MCP] Rejected packages: %v", err)
+			return NewErrorResponse(id, InvalidParams, err.Error(), nil)
+		}
+	}
+
+	runnerInfo, ok := h.registry.GetRunner(args.Language, args.Version)
+	if !ok {
+		msg := h.messages.Format(messages.UnsupportedLanguage, messages.Args{"Language": args.Language})
+		if args.Version != "" {
+			msg = h.messages.Format(messages.UnsupportedVersion, messages.Args{"Language": args.Language, "Version": args.Version})
+		}
+		return NewErrorResponse(id, InvalidParams, msg, nil)
+	}
+
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return h.wrapToolResult(id, InstallPackageResult{Error: toolErr})
+	}
+	if _, err := h.sandbox.EnsureSandboxDir(args.ConversationID); err != nil {
+		log.Printf("[MCP] Failed to ensure sandbox directory: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create sandbox directory", err.Error())
+	}
+
+	if args.Reset {
+		log.Printf("[MCP] Clearing package cache for conversation %s", args.ConversationID)
+		if err := h.sandbox.ClearPackages(args.ConversationID); err != nil {
+			log.Printf("[MCP] Failed to clear package cache: %v", err)
+			return NewErrorResponse(id, InternalError, "Failed to clear package cache", err.Error())
+		}
+	}
+
+	result := InstallPackageResult{Success: true}
+	if len(args.Packages) > 0 {
+		sandboxHostPath := h.sandbox.GetSandboxHostPath(args.ConversationID)
+		execResult, toolErr := h.installPackages(ctx, args.ConversationID, args.Packages, runnerInfo, sandboxHostPath, messages.PackagesNotInstalled)
+		result.Success = toolErr == nil
+		result.Output = strings.TrimSpace(execResult.Stdout + "\n" + execResult.Stderr)
+		result.Error = toolErr
+	}
+	if size, err := h.sandbox.PackagesSize(args.ConversationID); err == nil {
+		result.CacheBytes = size
+	}
+
+	log.Printf("[MCP] install_package: conversationId=%s, packages=%d, success=%v, cacheBytes=%d", args.ConversationID, len(args.Packages), result.Success, result.CacheBytes)
+	return h.wrapToolResult(id, result)
+}
+
+// installPackages installs packages into a conversation's package cache in a
+// network-enabled container running only the package manager. failedKey is
+// the message shown when the package manager fails. A cache that grows past
+// the policy's size limit is cleared, since packages can always be reinstalled
+func (h *MCPHandler) installPackages(ctx context.Context, conversationID string, packages []string, runnerInfo runner.RunnerInfo, sandboxHostPath, failedKey string) (runner.ExecutionResult, *ToolError) {
+	packageList := strings.Join(packages, ", ")
+	if err := h.sandbox.EnsurePackagesDir(conversationID); err != nil {
+		log.Printf("[MCP] %v", err)
+		return runner.ExecutionResult{}, &ToolError{
+			Code:    ErrPackageInstallFailed,
+			Message: h.messages.Format(failedKey, messages.Args{"Packages": packageList}),
+			Data:    map[string]interface{}{"output": err.Error()},
+		}
+	}
+
+	log.Printf("[MCP] Installing %d package(s) for conversation %s: %s", len(packages), conversationID, strings.Join(packages, " "))
+	packagesHostPath := h.sandbox.GetPackagesHostPath(conversationID)
+	downloadCache, err := h.sandbox.EnsureDownloadCache(conversationID)
+	if err != nil {
+		// Installs work without the cache, only slower
+		log.Printf("[MCP] %v", err)
+	}
+	result := h.executor.Install(ctx, runnerInfo, sandboxHostPath, packagesHostPath, downloadCache, h.sandbox.User(conversationID), packages)
+	if !result.Success {
+		log.Printf("[MCP] Package install failed: exitCode=%d", result.ExitCode)
+		if toolErr := h.executionError(result); toolErr != nil {
+			return result, toolErr
+		}
+		return result, &ToolError{
+			Code:    ErrPackageInstallFailed,
+			Message: h.messages.Format(failedKey, messages.Args{"Packages": packageList}),
+			Data: map[string]interface{}{
+				"exitCode": result.ExitCode,
+				"output":   strings.TrimSpace(result.Stdout + "\n" + result.Stderr),
+			},
+		}
+	}
+
+	limit := h.installs.MaxCacheBytes
+	if limit <= 0 {
+		return result, nil
+	}
+	size, err := h.sandbox.PackagesSize(conversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to measure package cache: %v", err)
+		return result, nil
+	}
+	if size <= limit {
+		return result, nil
+	}
+
+	log.Printf("[MCP] Package cache for conversation %s is %d bytes (limit %d); clearing it", conversationID, size, limit)
+	if err := h.sandbox.ClearPackages(conversationID); err != nil {
+		log.Printf("[MCP] Failed to clear package cache: %v", err)
+	}
+	return result, &ToolError{
+		Code: ErrPackageCacheFull,
+		Message: h.messages.Format(messages.PackageCacheFull, messages.Args{
+			"Limit":     units.HumanSize(float64(limit)),
+			"SizeBytes": size,
+		}),
+		Data: map[string]int64{
+			"sizeBytes":  size,
+			"limitBytes": limit,
+		},
+	}
+}
+
+// withRequirements returns the packages to install before a run: the call's
+// packages plus, with installRequirements, those in the sandbox's dependency
+// file unless they were installed already. The digest to record once they
+// are installed is "" when the file adds nothing
+func (h *MCPHandler) withRequirements(id interface{}, args RunCodeArguments, language string) ([]string, string, *JSONRPCResponse) {
+	if !args.InstallRequirements {
+		return args.Packages, "", nil
+	}
+	specs, digest, err := runner.ReadRequirements(h.sandbox.GetSandboxDir(args.ConversationID), language)
+	if err != nil {
+		resp := NewErrorResponse(id, InvalidParams, "Invalid requirements: "+err.Error(), nil)
+		return nil, "", &resp
+	}
+	if len(specs) == 0 || h.sandbox.RequirementsInstalled(args.ConversationID, language, digest) {
+		return args.Packages, "", nil
+	}
+	if err := h.installs.Check(specs); err != nil {
+		log.Printf("[MCP] Rejected requirements: %v", err)
+		resp := NewErrorResponse(id, InvalidParams, "Invalid requirements: "+err.Error(), nil)
+		return nil, "", &resp
+	}
+	log.Printf("[MCP] Installing %d requirement(s) for conversation %s", len(specs), args.ConversationID)
+	return append(specs, args.Packages...), digest, nil
+}
+
+// withPackages mounts a conversation's package cache, if it has one, and
+// makes its packages importable unless the caller set the variable themselves
+func (h *MCPHandler) withPackages(ctx context.Context, conversationID, language string, env map[string]string) context.Context {
+	if !h.sandbox.HasPackages(conversationID) {
+		return ctx
+	}
+	if key, value, ok := runner.PackagesEnv(language); ok {
+		if _, set := env[key]; !set {
+			env[key] = value
+		}
+	}
+	return runner.WithPackages(ctx, h.sandbox.GetPackagesHostPath(conversationID))
+}
+
+// packagesDescription summarizes the install policy for tool descriptions
+func (h *MCPHandler) packagesDescription() string {
+	if h.installs.MaxCacheBytes <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" The cache holds at most %s.", units.HumanSize(float64(h.installs.MaxCacheBytes)))
+}
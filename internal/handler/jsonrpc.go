@@ -2,6 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
 )
 
 // JSONRPCRequest represents a JSON-RPC 2.0 request
@@ -12,6 +16,12 @@ type JSONRPCRequest struct {
 	Params  json.RawMessage `json:"params"`
 }
 
+// IsNotification reports whether the request has no ID, so no response may
+// be sent. MCP doesn't allow null IDs, so they count as absent
+func (r JSONRPCRequest) IsNotification() bool {
+	return r.ID == nil
+}
+
 // JSONRPCResponse represents a JSON-RPC 2.0 response
 type JSONRPCResponse struct {
 	JSONRPC string      `json:"jsonrpc"`
@@ -20,6 +30,13 @@ type JSONRPCResponse struct {
 	Error   *RPCError   `json:"error,omitempty"`
 }
 
+// JSONRPCNotification represents a JSON-RPC 2.0 notification (no ID)
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 // RPCError represents a JSON-RPC error
 type RPCError struct {
 	Code    int         `json:"code"`
@@ -36,15 +53,38 @@ const (
 	InternalError  = -32603
 )
 
+// MCP-specific error codes
+const (
+	ResourceNotFound = -32002
+	Forbidden        = -32003 // The token lacks a scope
+)
+
 // ToolCallParams represents the params for a tools/call method
 type ToolCallParams struct {
 	Name      string          `json:"name"`
 	Arguments json.RawMessage `json:"arguments"`
+	Meta      *RequestMeta    `json:"_meta,omitempty"`
+}
+
+// RequestMeta carries MCP request metadata
+type RequestMeta struct {
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+}
+
+// ProgressParams represents the params of a notifications/progress message
+type ProgressParams struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Total         float64     `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
 }
 
 // ToolResult represents the result wrapper for MCP tools
+// StructuredContent carries the same data as the text block in machine-parseable
+// form (MCP 2025-06-18); the text block is kept for older clients
 type ToolResult struct {
-	Content []ContentBlock `json:"content"`
+	Content           []ContentBlock `json:"content"`
+	StructuredContent interface{}    `json:"structuredContent,omitempty"`
 }
 
 // ContentBlock represents a content block in the tool result
@@ -54,13 +94,119 @@ type ContentBlock struct {
 	Text string `json:"text,omitempty"`
 }
 
+// NetworkRestricted is the network argument value allowing only allowlisted
+// domains, reached through the egress proxy
+const NetworkRestricted = "restricted"
+
+// NetworkSetting is the network argument of run_code and run_shell: a
+// boolean, or "restricted"
+type NetworkSetting struct {
+	Enabled    bool
+	Restricted bool
+}
+
+// UnmarshalJSON accepts true, false or "restricted"
+func (n *NetworkSetting) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		*n = NetworkSetting{Enabled: enabled}
+		return nil
+	}
+	var mode string
+	if err := json.Unmarshal(data, &mode); err != nil || mode != NetworkRestricted {
+		return fmt.Errorf("network must be true, false or %q", NetworkRestricted)
+	}
+	*n = NetworkSetting{Restricted: true}
+	return nil
+}
+
+// MarshalJSON writes the argument as UnmarshalJSON reads it
+func (n NetworkSetting) MarshalJSON() ([]byte, error) {
+	if n.Restricted {
+		return json.Marshal(NetworkRestricted)
+	}
+	return json.Marshal(n.Enabled)
+}
+
+func (n NetworkSetting) String() string {
+	if n.Restricted {
+		return NetworkRestricted
+	}
+	return strconv.FormatBool(n.Enabled)
+}
+
+// ToolError is a machine-readable reason a tool call could not be carried out
+type ToolError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Tool error codes
+const (
+	ErrInsufficientDiskSpace = "insufficient_disk_space"
+	ErrPackageInstallFailed  = "package_install_failed"
+	ErrPackageCacheFull      = "package_cache_full"
+	ErrSandboxUnavailable    = "sandbox_unavailable"
+	ErrExecutionQueueFull    = "execution_queue_full"
+	ErrBackendUnavailable    = "backend_unavailable"
+	ErrUploadLimitExceeded   = "upload_limit_exceeded"
+	ErrStepRejected          = "step_rejected"
+)
+
 // RunCodeArguments represents arguments for sandbox.run_code
 type RunCodeArguments struct {
 	ConversationID string            `json:"conversationId"`
 	Language       string            `json:"language"`
+	Version        string            `json:"version,omitempty"` // Optional: runner version, defaults to the language's default
 	Code           string            `json:"code"`
-	Network        *bool             `json:"network,omitempty"`     // Optional: defaults to false (network disabled)
+	Packages       []string          `json:"packages,omitempty"`    // Optional: installed with network access before the code runs
+	Network        NetworkSetting    `json:"network,omitempty"`     // Optional: defaults to false (network disabled)
 	Environment    map[string]string `json:"environment,omitempty"` // Optional: environment variables to pass to container
+	Secrets        []string          `json:"secrets,omitempty"`     // Optional: names of registered secrets to inject
+	CombinedLog    bool              `json:"combinedLog,omitempty"` // Optional: also return interleaved, timestamped output
+	Stdin          string            `json:"stdin,omitempty"`       // Optional: piped to the program's standard input
+
+	// Optional: a multi-file project, written to /data before execution;
+	// entrypoint names the file to run in place of code
+	Files      map[string]string `json:"files,omitempty"`
+	Entrypoint string            `json:"entrypoint,omitempty"`
+
+	// Optional: container port to publish under /preview/ while running
+	PreviewPort int `json:"previewPort,omitempty"`
+
+	// Optional: template seeding the sandbox if it has no files yet
+	Template string `json:"template,omitempty"`
+
+	// Optional: only check the code's syntax (and types, for typescript)
+	// without running it
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Optional: also install requirements.txt or package.json from /data
+	// when it changed since the last install
+	InstallRequirements bool `json:"installRequirements,omitempty"`
+
+	// Optional: run in the conversation's Jupyter kernel, which keeps
+	// variables between calls; RestartKernel starts a fresh one first
+	Kernel        bool `json:"kernel,omitempty"`
+	RestartKernel bool `json:"restartKernel,omitempty"`
+
+	// Optional: give the code the host GPUs (sandbox.gpu runners only)
+	GPU bool `json:"gpu,omitempty"`
+}
+
+// RunShellArguments represents arguments for run_shell
+type RunShellArguments struct {
+	ConversationID string            `json:"conversationId"`
+	Language       string            `json:"language"` // Selects the runner image
+	Version        string            `json:"version,omitempty"`
+	Command        string            `json:"command"`
+	Network        NetworkSetting    `json:"network,omitempty"`
+	Environment    map[string]string `json:"environment,omitempty"`
+	Secrets        []string          `json:"secrets,omitempty"`
+	Stdin          string            `json:"stdin,omitempty"`
+	PreviewPort    int               `json:"previewPort,omitempty"`
+	Template       string            `json:"template,omitempty"`
 }
 
 // FileDescriptor describes a file with its download URL
@@ -70,16 +216,117 @@ type FileDescriptor struct {
 }
 
 // RunCodeResult represents the result of code execution
+// When stdout exceeds the inline cap only the first page is returned;
+// StdoutNextToken can be passed to read_output to fetch the rest
 type RunCodeResult struct {
-	Success bool   `json:"success"`
-	Stdout  string `json:"stdout"`
-	Stderr  string `json:"stderr,omitempty"`
+	Success         bool              `json:"success"`
+	Stdout          string            `json:"stdout"`
+	Stderr          string            `json:"stderr,omitempty"`
+	ExitCode        int               `json:"exitCode,omitempty"`
+	OOMKilled       bool              `json:"oomKilled,omitempty"`
+	Files           []FileDescriptor  `json:"files,omitempty"`
+	StdoutBytes     int               `json:"stdoutBytes,omitempty"`
+	StdoutNextToken string            `json:"stdoutNextToken,omitempty"`
+	StderrBytes     int               `json:"stderrBytes,omitempty"`
+	Truncated       bool              `json:"truncated,omitempty"`
+	Log             []runner.LogEntry `json:"log,omitempty"` // Set when combinedLog was requested
+	LogTruncated    bool              `json:"logTruncated,omitempty"`
+	Usage           *ResourceUsage    `json:"usage,omitempty"`
+	PreviewURL      string            `json:"previewUrl,omitempty"`
+	Sealed          string            `json:"sealed,omitempty"` // Base64 sealed SealedOutput when the conversation has a result key
+	DryRun          bool              `json:"dryRun,omitempty"`
+	Diagnostics     []LintDiagnostic  `json:"diagnostics,omitempty"`   // Set by dry runs
+	CompileOutput   string            `json:"compileOutput,omitempty"` // Compiler output, for compiled runners
+	CompileFailed   bool              `json:"compileFailed,omitempty"` // The code didn't compile, so it never ran
+	Display         []runner.Display  `json:"display,omitempty"`       // Rich output of kernel runs
+	ExecutionCount  int               `json:"executionCount,omitempty"`
+	KernelStarted   bool              `json:"kernelStarted,omitempty"` // The kernel is new, so earlier state is gone
+	Error           *ToolError        `json:"error,omitempty"`         // Set when the code could not be run at all
+}
+
+// ResourceUsage reports what an execution consumed. The network counters
+// stay zero without network access
+type ResourceUsage struct {
+	CPUMillis       int64  `json:"cpuMs"`
+	PeakMemoryBytes uint64 `json:"peakMemoryBytes"`
+	NetworkRxBytes  uint64 `json:"networkRxBytes,omitempty"`
+	NetworkTxBytes  uint64 `json:"networkTxBytes,omitempty"`
+}
+
+// resourceUsage converts sampled usage, if any, for a tool result
+func resourceUsage(usage *runner.ResourceUsage) *ResourceUsage {
+	if usage == nil {
+		return nil
+	}
+	return &ResourceUsage{
+		CPUMillis:       usage.CPUTime.Milliseconds(),
+		PeakMemoryBytes: usage.PeakMemoryBytes,
+		NetworkRxBytes:  usage.NetworkRxBytes,
+		NetworkTxBytes:  usage.NetworkTxBytes,
+	}
+}
+
+// SetEnvironmentArguments represents arguments for set_environment
+// A null value removes the variable
+type SetEnvironmentArguments struct {
+	ConversationID string             `json:"conversationId"`
+	Environment    map[string]*string `json:"environment"`
+}
+
+// SetEnvironmentResult lists the persisted variable names (never values)
+type SetEnvironmentResult struct {
+	Success   bool     `json:"success"`
+	Variables []string `json:"variables"`
+}
+
+// GetEnvironmentArguments represents arguments for get_environment
+type GetEnvironmentArguments struct {
+	ConversationID string `json:"conversationId"`
+}
+
+// GetEnvironmentResult lists the persisted variable names (never values)
+type GetEnvironmentResult struct {
+	Variables []string `json:"variables"`
+}
+
+// ReadOutputArguments represents arguments for read_output
+type ReadOutputArguments struct {
+	Token string `json:"token"`
+}
+
+// ReadOutputResult represents one page of paginated output
+type ReadOutputResult struct {
+	Output     string `json:"output"`
+	Offset     int    `json:"offset"`
+	TotalBytes int    `json:"totalBytes"`
+	NextToken  string `json:"nextToken,omitempty"`
 }
 
 // RunnerDescriptor describes an available runner
 type RunnerDescriptor struct {
+	Language    string `json:"language"`
+	Version     string `json:"version,omitempty"`
+	Default     bool   `json:"default"`
+	Image       string `json:"image"`
+	Description string `json:"description,omitempty"`
+	// Architectures with an image of the runner, e.g. ["amd64", "arm64"]
+	Architectures []string `json:"architectures,omitempty"`
+}
+
+// DescribeRunnerArguments represents arguments for describe_runner
+type DescribeRunnerArguments struct {
 	Language string `json:"language"`
-	Image    string `json:"image"`
+	Version  string `json:"version,omitempty"`
+}
+
+// DescribeRunnerResult describes a runner's limits and installed packages
+type DescribeRunnerResult struct {
+	RunnerDescriptor
+	TimeoutSeconds float64          `json:"timeoutSeconds"`
+	MemoryBytes    int64            `json:"memoryBytes"`
+	CPUs           float64          `json:"cpus"`
+	Packages       []runner.Package `json:"packages"`
+	PackagesProbed bool             `json:"packagesProbed"` // False if the probe failed or none is configured
 }
 
 // ListRunnersResult represents the result of listing runners
@@ -91,7 +338,54 @@ type ListRunnersResult struct {
 type UploadFileArguments struct {
 	ConversationID string `json:"conversationId"`
 	Filename       string `json:"filename"`
-	Content        string `json:"content"` // Base64 encoded file content
+	Content        string `json:"content"`            // Base64 encoded file content
+	Extract        bool   `json:"extract,omitempty"`  // Unpack a zip/tar(.gz) archive instead of storing it
+	Template       string `json:"template,omitempty"` // Seed the sandbox if it has no files yet
+}
+
+// ResourcesListParams represents the params for resources/list
+// ConversationID is an extension: sandbox files are scoped per conversation
+// (defaults to the session's conversation)
+type ResourcesListParams struct {
+	ConversationID string `json:"conversationId"`
+	Cursor         string `json:"cursor,omitempty"`
+}
+
+// ResourcesReadParams represents the params for resources/read
+type ResourcesReadParams struct {
+	URI string `json:"uri"`
+}
+
+// Resource describes a sandbox file exposed as an MCP resource
+type Resource struct {
+	URI      string `json:"uri"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate describes a parameterized resource URI
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// ResourceContents holds the contents of a read resource
+// Exactly one of Text or Blob (base64) is set
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// NewNotification creates a JSON-RPC notification
+func NewNotification(method string, params interface{}) JSONRPCNotification {
+	return JSONRPCNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	}
 }
 
 // NewSuccessResponse creates a successful JSON-RPC response
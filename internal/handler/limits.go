@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/go-units"
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// limitError converts a *sandbox.LimitError into a structured tool error,
+// or returns nil for other errors
+func (h *MCPHandler) limitError(err error) *ToolError {
+	var limitErr *sandbox.LimitError
+	if !errors.As(err, &limitErr) {
+		return nil
+	}
+
+	var message string
+	switch limitErr.Limit {
+	case sandbox.LimitFileSize:
+		message = h.messages.Format(messages.FileTooLarge, messages.Args{
+			"Filename": limitErr.Filename,
+			"Limit":    units.HumanSize(float64(limitErr.Max)),
+		})
+	case sandbox.LimitSandboxSize:
+		message = h.messages.Format(messages.SandboxFull, messages.Args{
+			"Limit": units.HumanSize(float64(limitErr.Max)),
+		})
+	default:
+		message = h.messages.Format(messages.TooManyFiles, messages.Args{"Limit": limitErr.Max})
+	}
+	return &ToolError{
+		Code:    ErrUploadLimitExceeded,
+		Message: message,
+		Data: map[string]interface{}{
+			"limit":    limitErr.Limit,
+			"max":      limitErr.Max,
+			"actual":   limitErr.Actual,
+			"filename": limitErr.Filename,
+		},
+	}
+}
+
+// uploadFailure is the result of files that couldn't be written, with a
+// structured error if they would have broken the sandbox's limits
+func (h *MCPHandler) uploadFailure(id interface{}, message string, err error) JSONRPCResponse {
+	result := map[string]interface{}{
+		"success": false,
+		"message": fmt.Sprintf("%s: %v", message, err),
+	}
+	if toolErr := h.limitError(err); toolErr != nil {
+		result["message"] = toolErr.Message
+		result["error"] = toolErr
+	}
+	return h.wrapToolResult(id, result)
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Download link lifetimes
+const (
+	defaultDownloadLinkHours = 24
+	maxDownloadLinkHours     = 30 * 24
+)
+
+// CreateDownloadLinkArguments represents arguments for create_download_link
+type CreateDownloadLinkArguments struct {
+	ConversationID string `json:"conversationId"`
+	Filename       string `json:"filename"`
+	ExpiresInHours int    `json:"expiresInHours,omitempty"` // Default 24, at most 720
+	SingleUse      bool   `json:"singleUse,omitempty"`
+}
+
+// CreateDownloadLinkResult represents the result of create_download_link
+type CreateDownloadLinkResult struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	SingleUse bool      `json:"singleUse"`
+}
+
+// RevokeLinksArguments represents arguments for revoke_links
+type RevokeLinksArguments struct {
+	ConversationID string `json:"conversationId"`
+}
+
+// RevokeLinksResult represents the result of revoke_links
+type RevokeLinksResult struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// handleCreateDownloadLink implements the create_download_link tool
+func (h *MCPHandler) handleCreateDownloadLink(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args CreateDownloadLinkArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	args.Filename = strings.TrimPrefix(args.Filename, "/")
+	if args.Filename == "" || !filepath.IsLocal(filepath.FromSlash(args.Filename)) {
+		return NewErrorResponse(id, InvalidParams, "filename must be a path within the sandbox", nil)
+	}
+	if args.ExpiresInHours == 0 {
+		args.ExpiresInHours = defaultDownloadLinkHours
+	}
+	if args.ExpiresInHours < 0 || args.ExpiresInHours > maxDownloadLinkHours {
+		return NewErrorResponse(id, InvalidParams, "expiresInHours must be between 1 and 720", nil)
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to ensure sandbox directory: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create sandbox directory", err.Error())
+	}
+	if err := h.sandbox.FetchFile(ctx, hashedDir, args.Filename); err != nil && !os.IsNotExist(err) {
+		log.Printf("[MCP] Failed to fetch file from storage: %v", err)
+	}
+	f, _, err := h.sandbox.OpenFile(hashedDir, args.Filename)
+	if err != nil {
+		return NewErrorResponse(id, InvalidParams, "File not found: "+args.Filename, nil)
+	}
+	f.Close()
+
+	expires := time.Now().Add(time.Duration(args.ExpiresInHours) * time.Hour).Truncate(time.Second)
+	linkURL, err := h.signer.MakeDownloadURL(hashedDir, args.Filename, h.sandbox.LinkEpoch(hashedDir), args.SingleUse, expires)
+	if err != nil {
+		log.Printf("[MCP] Failed to create download link: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create download link", err.Error())
+	}
+
+	log.Printf("[MCP] create_download_link: conversationId=%s, filename=%s, singleUse=%v, expires=%s",
+		args.ConversationID, args.Filename, args.SingleUse, expires.UTC().Format(time.RFC3339))
+	return h.wrapToolResult(id, CreateDownloadLinkResult{
+		URL:       linkURL,
+		ExpiresAt: expires.UTC(),
+		SingleUse: args.SingleUse,
+	})
+}
+
+// handleRevokeLinks implements the revoke_links tool
+func (h *MCPHandler) handleRevokeLinks(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args RevokeLinksArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+
+	epoch, err := h.sandbox.RevokeLinks(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to revoke links: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to revoke links", err.Error())
+	}
+
+	log.Printf("[MCP] revoke_links: conversationId=%s, epoch=%d", args.ConversationID, epoch)
+	return h.wrapToolResult(id, RevokeLinksResult{
+		Success: true,
+		Message: "All share and download links for this conversation have been revoked",
+	})
+}
+
+// handleDownloadLink serves a download link: GET (or HEAD) /download/{token}.
+// A single-use link is spent by the first GET of an existing file; HEAD
+// requests, such as link previews, don't spend it
+func (s *Server) handleDownloadLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	link, err := s.signer.ParseDownloadToken(strings.TrimPrefix(r.URL.Path, "/download/"))
+	if err != nil || link.Epoch != s.sandbox.LinkEpoch(link.HashedDir) {
+		http.Error(w, "Download link is invalid, expired or revoked", http.StatusNotFound)
+		return
+	}
+
+	if err := s.sandbox.FetchFile(r.Context(), link.HashedDir, link.Filename); err != nil && !os.IsNotExist(err) {
+		log.Printf("[HTTP] Failed to fetch linked file from storage: %v", err)
+	}
+	// Check before spending a single-use link
+	f, _, err := s.sandbox.OpenFile(link.HashedDir, link.Filename)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	f.Close()
+
+	if link.SingleUse() && r.Method == http.MethodGet {
+		fresh, err := s.sandbox.ClaimLink(link.HashedDir, link.ID, link.Expires)
+		if err != nil {
+			log.Printf("[HTTP] Failed to record download link use: %v", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		if !fresh {
+			http.Error(w, "Download link has already been used", http.StatusGone)
+			return
+		}
+	}
+	// Caches would keep serving spent and revoked links
+	w.Header().Set("Cache-Control", "no-store")
+
+	log.Printf("[HTTP] Serving linked file: %s (single use: %v)", s.sandbox.GetFilePath(link.HashedDir, link.Filename), link.SingleUse())
+	s.serveSandboxFile(w, r, link.HashedDir, link.Filename)
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/lintreport"
+)
+
+// Limits on what lint_and_format returns
+const (
+	maxLintDiagnostics = 100
+	maxLintStderr      = 4000
+)
+
+// linters are the linter and formatter lint_and_format runs for each
+// language. The runner images install them
+var linters = map[string]struct {
+	Linter    string
+	Formatter string
+	Extension string // For code passed inline
+}{
+	"python":     {Linter: "ruff", Formatter: "ruff format", Extension: ".py"},
+	"typescript": {Linter: "eslint", Formatter: "prettier", Extension: ".ts"},
+}
+
+// LintArguments represents arguments for lint_and_format
+type LintArguments struct {
+	ConversationID string `json:"conversationId"`
+	Language       string `json:"language"`
+	Version        string `json:"version,omitempty"`
+	Code           string `json:"code,omitempty"`     // Code to check, or
+	Filename       string `json:"filename,omitempty"` // a file or directory in the sandbox
+	Format         bool   `json:"format,omitempty"`   // Also return the formatted code
+}
+
+// LintResult represents the result of lint_and_format
+type LintResult struct {
+	Success              bool             `json:"success"` // The linter ran and reported nothing
+	Linter               string           `json:"linter"`
+	Errors               int              `json:"errors"`
+	Warnings             int              `json:"warnings"`
+	Diagnostics          []LintDiagnostic `json:"diagnostics,omitempty"`
+	DiagnosticsTruncated bool             `json:"diagnosticsTruncated,omitempty"`
+	Formatter            string           `json:"formatter,omitempty"`
+	Formatted            *string          `json:"formatted,omitempty"` // Set when format was requested and succeeded
+	Changed              bool             `json:"changed,omitempty"`   // Formatting changed the code
+	Message              string           `json:"message,omitempty"`
+	Stderr               string           `json:"stderr,omitempty"`
+	Error                *ToolError       `json:"error,omitempty"` // Set when the linter could not be run at all
+}
+
+// LintDiagnostic describes one problem. File is omitted for inline code
+type LintDiagnostic struct {
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Code     string `json:"code,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Fixable  bool   `json:"fixable,omitempty"`
+}
+
+// handleLintAndFormat implements the lint_and_format tool: it runs the
+// language's linter, and optionally its formatter, in the runner container
+func (h *MCPHandler) handleLintAndFormat(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args LintArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] lint_and_format: conversationId=%s, language=%s, codeLen=%d, filename=%s, format=%v",
+		args.ConversationID, args.Language, len(args.Code), args.Filename, args.Format)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	tools, ok := linters[args.Language]
+	if !ok {
+		return NewErrorResponse(id, InvalidParams, "language must be python or typescript", nil)
+	}
+	if (args.Code == "") == (args.Filename == "") {
+		return NewErrorResponse(id, InvalidParams, "Give exactly one of code and filename", nil)
+	}
+	args.Filename = strings.TrimPrefix(args.Filename, "/data/")
+	if args.Filename != "" && !filepath.IsLocal(filepath.FromSlash(args.Filename)) {
+		return NewErrorResponse(id, InvalidParams, "filename must be a path within the sandbox", nil)
+	}
+	// The report is read back from the sandbox, which sealing keeps from the server
+	if h.sandbox.ConversationResultKey(args.ConversationID) != nil {
+		return NewErrorResponse(id, InvalidParams, "lint_and_format is not available for conversations with a result key; use run_shell", nil)
+	}
+
+	scratch, err := scratchName(".lint-")
+	if err != nil {
+		return NewErrorResponse(id, InternalError, "Failed to run linter", err.Error())
+	}
+	run := RunCodeArguments{
+		ConversationID: args.ConversationID,
+		Language:       args.Language,
+		Version:        args.Version,
+	}
+	target := args.Filename
+	if args.Code != "" {
+		target = scratch + tools.Extension
+		run.Files = map[string]string{target: args.Code}
+	}
+	if args.Format {
+		info, err := os.Stat(filepath.Join(h.sandbox.GetSandboxDir(args.ConversationID), filepath.FromSlash(target)))
+		if args.Code == "" && (err != nil || !info.Mode().IsRegular()) {
+			return NewErrorResponse(id, InvalidParams, "format needs code or a single file", nil)
+		}
+	}
+	reportName, formattedName := scratch+".json", scratch+".out"
+	run.Code = lintCommand(args.Language, "/data/"+target, "/data/"+reportName, "/data/"+formattedName, args.Format)
+
+	resp := h.runInSandbox(ctx, id, run, true)
+	sandboxDir := h.sandbox.GetSandboxDir(args.ConversationID)
+	defer func() {
+		for _, name := range []string{reportName, formattedName} {
+			os.Remove(filepath.Join(sandboxDir, name))
+		}
+		if args.Code != "" {
+			os.Remove(filepath.Join(sandboxDir, target))
+		}
+	}()
+	if resp.Error != nil {
+		return resp
+	}
+	toolResult, _ := resp.Result.(ToolResult)
+	ran, ok := toolResult.StructuredContent.(RunCodeResult)
+	if !ok {
+		return resp
+	}
+
+	result := LintResult{
+		Linter: tools.Linter,
+		Stderr: tail(ran.Stderr, maxLintStderr),
+		Error:  ran.Error,
+	}
+	if ran.Error != nil {
+		result.Message = ran.Error.Message
+		return h.wrapToolResult(id, result)
+	}
+
+	data, err := h.sandbox.ReadPath(args.ConversationID, reportName)
+	if err != nil {
+		// Not installed in this runner, or it failed to start, e.g. on a bad config
+		result.Message = fmt.Sprintf("%s wrote no report; see stderr", tools.Linter)
+		return h.wrapToolResult(id, result)
+	}
+	var diagnostics []lintreport.Diagnostic
+	if tools.Linter == "ruff" {
+		diagnostics, err = lintreport.ParseRuff(data)
+	} else {
+		diagnostics, err = lintreport.ParseESLint(data)
+	}
+	if err != nil {
+		log.Printf("[MCP] Failed to parse %s report: %v", tools.Linter, err)
+		result.Message = err.Error()
+		return h.wrapToolResult(id, result)
+	}
+
+	for i, d := range diagnostics {
+		if d.Severity == "error" {
+			result.Errors++
+		} else {
+			result.Warnings++
+		}
+		if i >= maxLintDiagnostics {
+			result.DiagnosticsTruncated = true
+			continue
+		}
+		if args.Code != "" {
+			d.File = ""
+		}
+		result.Diagnostics = append(result.Diagnostics, LintDiagnostic(d))
+	}
+	result.Success = len(diagnostics) == 0
+	result.Message = fmt.Sprintf("%d errors, %d warnings", result.Errors, result.Warnings)
+
+	if args.Format {
+		result.Formatter = tools.Formatter
+		if formatted, err := h.sandbox.ReadPath(args.ConversationID, formattedName); err == nil {
+			code := string(formatted)
+			result.Formatted = &code
+			if args.Code != "" {
+				result.Changed = code != args.Code
+			} else if original, err := h.sandbox.ReadPath(args.ConversationID, target); err == nil {
+				result.Changed = code != string(original)
+			}
+		} else {
+			result.Message += fmt.Sprintf("; %s failed, see stderr", tools.Formatter)
+		}
+	}
+
+	log.Printf("[MCP] lint_and_format completed: errors=%d, warnings=%d", result.Errors, result.Warnings)
+	return h.wrapToolResult(id, result)
+}
+
+// lintCommand builds the shell command that lints target into report and,
+// with format, writes the formatted file to formatted. The formatter's output
+// is removed if it fails, so a partial file is never returned
+func lintCommand(language, target, report, formatted string, format bool) string {
+	q := shellQuote
+	var lint, fmtCmd string
+	switch language {
+	case "python":
+		lint = "ruff check --no-cache --exit-zero --output-format=json -o " + q(report) + " " + q(target)
+		fmtCmd = "ruff format --stdin-filename " + q(target) + " - < " + q(target)
+	case "typescript":
+		// Projects without an ESLint config get the image's default one
+		lint = "config=; ls /data/eslint.config.* >/dev/null 2>&1 || config='-c /opt/lint/eslint.config.mjs'; " +
+			"bun /opt/lint/node_modules/eslint/bin/eslint.js $config --format json -o " + q(report) + " " + q(target)
+		fmtCmd = "bun /opt/lint/node_modules/prettier/bin/prettier.cjs --stdin-filepath " + q(target) + " < " + q(target)
+	}
+	if !format {
+		return lint
+	}
+	return lint + "; " + fmtCmd + " > " + q(formatted) + " || rm -f " + q(formatted) + "; true"
+}
+
+// scratchName returns a random hidden file name in the sandbox for a tool's
+// intermediate files
+func scratchName(prefix string) (string, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(nonce), nil
+}
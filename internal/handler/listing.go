@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// ListingEntry is one file in a /files/{hashedDir}/ listing
+type ListingEntry struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Type     string    `json:"type"`
+	Modified time.Time `json:"modified"`
+	URL      string    `json:"url"`
+}
+
+// fileListing renders a sandbox's files for browsers. Links are relative to
+// the listing URL, which ends in "/"
+var fileListing = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{if .Name}}{{.Name}} - {{end}}Sandbox files</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 960px; margin: 2em auto; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #eee; }
+td.num { text-align: right; white-space: nowrap; }
+.muted { color: #777; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{if .Name}}{{.Name}}{{else}}Sandbox files{{end}}</h1>
+{{if .Files}}<table>
+<tr><th>Name</th><th>Size</th><th>Type</th><th>Modified</th></tr>
+{{range .Files}}<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td class="num">{{.Size}}</td><td class="muted">{{.Type}}</td><td class="muted">{{.Modified}}</td></tr>
+{{end}}</table>{{else}}<p>No files yet.</p>{{end}}
+{{if .Truncated}}<p class="muted">Only the first {{.Max}} files are listed.</p>{{end}}
+</body>
+</html>
+`))
+
+// serveFileListing lists a sandbox's files: GET /files/{hashedDir}/. Browsers
+// (Accept: text/html) get a page, everything else JSON; "?format=json" or
+// "?format=html" overrides
+func (s *Server) serveFileListing(w http.ResponseWriter, r *http.Request, hashedDir string) {
+	if err := s.sandbox.StageHashedDir(r.Context(), hashedDir); err != nil {
+		log.Printf("[HTTP] Failed to stage sandbox files: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if !s.sandbox.HashedDirExists(hashedDir) {
+		http.Error(w, "Sandbox not found", http.StatusNotFound)
+		return
+	}
+	files, truncated, err := s.sandbox.ListTree(hashedDir)
+	if err != nil {
+		log.Printf("[HTTP] Failed to list sandbox files: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		format = "html"
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if format != "html" {
+		baseURL := s.signer.FileBaseURL(hashedDir)
+		entries := make([]ListingEntry, 0, len(files))
+		for _, f := range files {
+			entries = append(entries, ListingEntry{
+				Name:     f.Name,
+				Size:     f.Size,
+				Type:     typeByExtension(f.Name),
+				Modified: f.Modified.UTC(),
+				URL:      fmt.Sprintf("%s/%s", baseURL, escapePath(f.Name)),
+			})
+		}
+		writeAPIJSON(w, http.StatusOK, map[string]interface{}{"files": entries, "truncated": truncated})
+		return
+	}
+
+	type row struct{ Name, Href, Size, Type, Modified string }
+	data := struct {
+		Name      string
+		Files     []row
+		Truncated bool
+		Max       int
+	}{Name: s.sandbox.DisplayName(hashedDir), Truncated: truncated, Max: sandbox.MaxListing}
+	for _, f := range files {
+		data.Files = append(data.Files, row{
+			Name:     f.Name,
+			Href:     escapePath(f.Name),
+			Size:     units.HumanSize(float64(f.Size)),
+			Type:     typeByExtension(f.Name),
+			Modified: f.Modified.UTC().Format("2006-01-02 15:04 MST"),
+		})
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := fileListing.Execute(w, data); err != nil {
+		log.Printf("[HTTP] Failed to render file listing: %v", err)
+	}
+}
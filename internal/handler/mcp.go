@@ -4,20 +4,59 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
+	"github.com/jsc/mcp-code-sandbox/internal/bundle"
+	"github.com/jsc/mcp-code-sandbox/internal/egress"
+	"github.com/jsc/mcp-code-sandbox/internal/envstore"
 	"github.com/jsc/mcp-code-sandbox/internal/filesign"
+	"github.com/jsc/mcp-code-sandbox/internal/gitclone"
+	"github.com/jsc/mcp-code-sandbox/internal/history"
+	"github.com/jsc/mcp-code-sandbox/internal/kernels"
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/pager"
+	"github.com/jsc/mcp-code-sandbox/internal/preview"
+	"github.com/jsc/mcp-code-sandbox/internal/processes"
 	"github.com/jsc/mcp-code-sandbox/internal/runner"
 	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+	"github.com/jsc/mcp-code-sandbox/internal/secrets"
+	"github.com/jsc/mcp-code-sandbox/internal/services"
+	"github.com/jsc/mcp-code-sandbox/internal/session"
+	"github.com/jsc/mcp-code-sandbox/internal/templates"
+	"github.com/jsc/mcp-code-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // MCPHandler handles MCP JSON-RPC requests
 type MCPHandler struct {
-	registry *runner.Registry
-	executor *runner.Executor
-	sandbox  *sandbox.Manager
-	signer   *filesign.Signer
+	registry  *runner.Registry
+	executor  *runner.Executor
+	sandbox   *sandbox.Manager
+	signer    *filesign.Signer
+	bundles   *bundle.Store
+	outputs   *pager.Store
+	envs      *envstore.Store
+	history   *history.Store
+	installs  *runner.InstallPolicy
+	templates *templates.Store
+	services  *services.Manager // nil when no services are enabled
+	messages  *messages.Catalog
+	previews  *preview.Registry // nil when previews are disabled
+	processes *processes.Manager
+	kernels   *kernels.Manager // nil when kernel mode is disabled
+	secrets   *secrets.Store
+	fetcher   *egress.Fetcher
+	cloner    *gitclone.Cloner
 }
 
 // NewMCPHandler creates a new MCP handler
@@ -26,19 +65,56 @@ func NewMCPHandler(
 	executor *runner.Executor,
 	sandbox *sandbox.Manager,
 	signer *filesign.Signer,
+	bundles *bundle.Store,
+	outputs *pager.Store,
+	envs *envstore.Store,
+	history *history.Store,
+	installs *runner.InstallPolicy,
+	templates *templates.Store,
+	services *services.Manager,
+	catalog *messages.Catalog,
+	previews *preview.Registry,
+	processes *processes.Manager,
+	kernels *kernels.Manager,
+	secrets *secrets.Store,
+	fetcher *egress.Fetcher,
+	cloner *gitclone.Cloner,
 ) *MCPHandler {
 	return &MCPHandler{
-		registry: registry,
-		executor: executor,
-		sandbox:  sandbox,
-		signer:   signer,
+		registry:  registry,
+		executor:  executor,
+		sandbox:   sandbox,
+		signer:    signer,
+		bundles:   bundles,
+		outputs:   outputs,
+		envs:      envs,
+		history:   history,
+		installs:  installs,
+		templates: templates,
+		services:  services,
+		messages:  catalog,
+		previews:  previews,
+		processes: processes,
+		kernels:   kernels,
+		secrets:   secrets,
+		fetcher:   fetcher,
+		cloner:    cloner,
 	}
 }
 
 // Handle processes a JSON-RPC request
-func (h *MCPHandler) Handle(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+func (h *MCPHandler) Handle(ctx context.Context, req JSONRPCRequest) (resp JSONRPCResponse) {
 	log.Printf("[MCP] Incoming request - Method: %s, ID: %v", req.Method, req.ID)
 
+	ctx, span := tracing.Start(ctx, "mcp "+req.Method, trace.WithSpanKind(trace.SpanKindServer))
+	defer func() {
+		var err error
+		if resp.Error != nil {
+			err = errors.New(resp.Error.Message)
+		}
+		tracing.End(span, err)
+	}()
+
 	// Validate JSON-RPC version
 	if req.JSONRPC != "2.0" {
 		log.Printf("[MCP] Invalid JSON-RPC version: %s", req.JSONRPC)
@@ -49,31 +125,82 @@ func (h *MCPHandler) Handle(ctx context.Context, req JSONRPCRequest) JSONRPCResp
 	switch req.Method {
 	case "initialize":
 		log.Printf("[MCP] Handling initialize request")
-		return h.handleInitialize(req)
+		return h.handleInitialize(ctx, req)
+	case "ping":
+		return NewSuccessResponse(req.ID, struct{}{})
 	case "tools/list":
 		log.Printf("[MCP] Handling tools/list request")
-		return h.handleToolsList(req)
+		return h.handleToolsList(ctx, req)
 	case "tools/call":
 		log.Printf("[MCP] Handling tools/call request")
-		return h.handleToolCall(ctx, req)
+		return adaptToolResult(ctx, h.handleToolCall(ctx, req))
+	case "resources/list":
+		log.Printf("[MCP] Handling resources/list request")
+		if !auth.Allowed(ctx, auth.ScopeReadFiles) {
+			return scopeError(ctx, req.ID, req.Method, auth.ScopeReadFiles)
+		}
+		return h.handleResourcesList(ctx, req)
+	case "resources/templates/list":
+		log.Printf("[MCP] Handling resources/templates/list request")
+		return h.handleResourceTemplatesList(req)
+	case "resources/read":
+		log.Printf("[MCP] Handling resources/read request")
+		if !auth.Allowed(ctx, auth.ScopeReadFiles) {
+			return scopeError(ctx, req.ID, req.Method, auth.ScopeReadFiles)
+		}
+		return h.handleResourcesRead(ctx, req)
 	default:
 		log.Printf("[MCP] Method not found: %s", req.Method)
 		return NewErrorResponse(req.ID, MethodNotFound, fmt.Sprintf("Method not found: %s", req.Method), nil)
 	}
 }
 
-// handleInitialize handles the MCP initialize method
-func (h *MCPHandler) handleInitialize(req JSONRPCRequest) JSONRPCResponse {
+// HandleNotification processes a JSON-RPC notification. Notifications are
+// never answered, so unknown ones are only logged
+func (h *MCPHandler) HandleNotification(ctx context.Context, req JSONRPCRequest) {
+	log.Printf("[MCP] Incoming notification - Method: %s", req.Method)
+
+	switch req.Method {
+	case "notifications/initialized":
+		if sess, ok := session.FromContext(ctx); ok && sess != nil {
+			sess.MarkInitialized()
+			log.Printf("[MCP] Session %s initialized", sess.ID)
+		}
+	case "notifications/cancelled":
+		// The server cancels the request's context before this runs
+		log.Printf("[MCP] Client cancelled a request: %s", string(req.Params))
+	default:
+		log.Printf("[MCP] Ignoring notification: %s", req.Method)
+	}
+}
+
+// handleInitialize handles the MCP initialize method, negotiating the
+// protocol version for the session
+func (h *MCPHandler) handleInitialize(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
 	log.Printf("[MCP] Processing initialize request")
 
+	var params InitializeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return NewErrorResponse(req.ID, InvalidParams, "Invalid params", err.Error())
+		}
+	}
+	version := negotiateProtocolVersion(params.ProtocolVersion)
+	if sess, ok := session.FromContext(ctx); ok && sess != nil {
+		sess.SetProtocolVersion(version)
+	}
+	log.Printf("[MCP] Client %s %s requested protocol %q, using %s",
+		params.ClientInfo.Name, params.ClientInfo.Version, params.ProtocolVersion, version)
+
 	result := map[string]interface{}{
-		"protocolVersion": "2024-11-05",
+		"protocolVersion": version,
 		"serverInfo": map[string]interface{}{
 			"name":    "mcp-code-sandbox",
 			"version": "1.0.0",
 		},
 		"capabilities": map[string]interface{}{
-			"tools": map[string]interface{}{},
+			"tools":     map[string]interface{}{},
+			"resources": map[string]interface{}{},
 		},
 	}
 
@@ -82,32 +209,65 @@ func (h *MCPHandler) handleInitialize(req JSONRPCRequest) JSONRPCResponse {
 }
 
 // handleToolsList handles the MCP tools/list method
-func (h *MCPHandler) handleToolsList(req JSONRPCRequest) JSONRPCResponse {
+func (h *MCPHandler) handleToolsList(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
 	log.Printf("[MCP] Building tools list")
 
+	tools := h.toolDefinitions(ctx)
+	adaptToolsList(ctx, tools)
+
+	result := map[string]interface{}{
+		"tools": tools,
+	}
+
+	log.Printf("[MCP] Returning %d tools", len(tools))
+	return NewSuccessResponse(req.ID, result)
+}
+
+// toolDefinitions describes the tools the request's token may call, with
+// schemas built from the live runner registry
+func (h *MCPHandler) toolDefinitions(ctx context.Context) []map[string]interface{} {
+
 	// Get available runners
 	runners := h.registry.ListRunners()
 	log.Printf("[MCP] Found %d runners", len(runners))
 
-	// Build language list for tool description
-	languages := make([]string, 0, len(runners))
-	for _, r := range runners {
-		languages = append(languages, r.Language)
+	// Derive the language and version schemas from the same runner list so
+	// they stay consistent with each other after a hot refresh
+	caps := runner.Capabilities(runners)
+	languages := make([]string, 0, len(caps))
+	for _, c := range caps {
+		languages = append(languages, c.Language)
+	}
+	installs := h.installs.Enabled()
+	supported := strings.Join(languages, ", ")
+	if supported == "" {
+		supported = "none (no runners are currently available)"
 	}
 
-	// Build library information per language
-	libraryInfo := map[string][]string{
-		"python":     {"requests", "numpy", "pandas", "matplotlib", "psycopg2"},
-		"typescript": {"postgres", "pg", "csv-parser", "papaparse"},
+	templateList := h.templates.List()
+	templateNames := make([]string, 0, len(templateList))
+	for _, t := range templateList {
+		templateNames = append(templateNames, t.Name)
 	}
 
 	// Create comprehensive description with examples
-	description := fmt.Sprintf(`Execute code in a sandboxed Docker container. Supports: %v. Files in /data persist across executions and are accessible via download URLs.
+	description := fmt.Sprintf(`Execute code in a sandboxed Docker container. Supports: %s. Files in /data persist across executions and are accessible via download URLs.
 
-Available libraries:
-`, languages)
-	for lang, libs := range libraryInfo {
-		description += fmt.Sprintf("- %s: %v\n", lang, libs)
+Available libraries (probed from the default runner images; use describe_runner for versions):
+`, supported)
+	for _, r := range runners {
+		if !r.Default {
+			continue
+		}
+		packages, ok := h.registry.Packages(r)
+		if !ok {
+			continue
+		}
+		names := make([]string, 0, len(packages))
+		for _, p := range packages {
+			names = append(names, p.Name)
+		}
+		description += fmt.Sprintf("- %s: %s\n", r.Language, strings.Join(names, ", "))
 	}
 
 	description += `
@@ -163,7 +323,7 @@ REMEMBER: ALL markdown links MUST use FILE_BASE_URL for proper rendering!`
 				"properties": map[string]interface{}{
 					"conversationId": map[string]interface{}{
 						"type":        "string",
-						"description": "Unique identifier for the conversation/session",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
 					},
 					"filename": map[string]interface{}{
 						"type":        "string",
@@ -173,8 +333,104 @@ REMEMBER: ALL markdown links MUST use FILE_BASE_URL for proper rendering!`
 						"type":        "string",
 						"description": "Base64 encoded file content",
 					},
+					"extract": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Unpack a .zip, .tar, .tar.gz or .tgz archive into /data (keeping its directories) instead of storing the archive itself, to provide multi-file datasets or projects in one call",
+					},
+				},
+				"required": []string{"filename", "content"},
+			},
+		},
+		{
+			"name":        "upload_files",
+			"description": fmt.Sprintf("Upload several files to the sandbox in one call, e.g. the source files of a small project. Each filename may include directories (e.g. 'src/main.py'), which are created as needed. Either every file is written or none is. At most %d files per call.", sandbox.MaxBatchFiles),
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"files": map[string]interface{}{
+						"type":        "array",
+						"minItems":    1,
+						"maxItems":    sandbox.MaxBatchFiles,
+						"description": "Files to write into /data",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"filename": map[string]interface{}{
+									"type":        "string",
+									"description": "Path of the file to create, relative to /data",
+								},
+								"content": map[string]interface{}{
+									"type":        "string",
+									"description": "Base64 encoded file content",
+								},
+							},
+							"required": []string{"filename", "content"},
+						},
+					},
+				},
+				"required": []string{"files"},
+			},
+		},
+		{
+			"name":        "fetch_file",
+			"description": h.fetchDescription(),
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"url": map[string]interface{}{
+						"type":        "string",
+						"description": "HTTP(S) URL to download",
+					},
+					"filename": map[string]interface{}{
+						"type":        "string",
+						"description": "Name to save the file as in /data (defaults to the name the server gives or the last segment of the URL path)",
+					},
+					"extract": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Unpack a .zip, .tar, .tar.gz or .tgz archive into /data instead of storing it, as upload_file does",
+					},
 				},
-				"required": []string{"conversationId", "filename", "content"},
+				"required": []string{"url"},
+			},
+		},
+		{
+			"name":        "clone_repo",
+			"description": h.cloneDescription(),
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"url": map[string]interface{}{
+						"type":        "string",
+						"description": "HTTP(S) URL of the repository, e.g. https://github.com/org/project.git",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Branch or tag to check out (defaults to the repository's default branch)",
+					},
+					"depth": map[string]interface{}{
+						"type":        "integer",
+						"description": "Commits of history to fetch (default 1)",
+						"minimum":     1,
+						"maximum":     h.cloner.MaxDepth(),
+					},
+					"directory": map[string]interface{}{
+						"type":        "string",
+						"description": "Directory in /data to clone into; must not exist (defaults to the repository name)",
+					},
+				},
+				"required": []string{"url"},
 			},
 		},
 		{
@@ -185,49 +441,863 @@ REMEMBER: ALL markdown links MUST use FILE_BASE_URL for proper rendering!`
 				"properties": map[string]interface{}{
 					"conversationId": map[string]interface{}{
 						"type":        "string",
-						"description": "Unique identifier for the conversation/session to isolate sandbox environments",
+						"description": "Unique identifier for the conversation/session to isolate sandbox environments (defaults to the MCP session)",
+					},
+					"language": languageProperty(caps, "Programming language to execute", installs),
+					"version":  versionProperty(caps),
+					"code": map[string]interface{}{
+						"type":        "string",
+						"description": "The code to execute. Any files written to /data will be persisted and returned as downloadable URLs.",
+					},
+					"files": map[string]interface{}{
+						"type":        "object",
+						"description": "Project files to write to /data before running, as {path: content}, e.g. {\"main.py\": \"...\", \"utils.py\": \"...\"}. Paths are relative to /data",
+						"additionalProperties": map[string]interface{}{
+							"type": "string",
+						},
+					},
+					"entrypoint": map[string]interface{}{
+						"type":        "string",
+						"description": "File in /data to run instead of code, e.g. \"main.py\", with /data as the working directory so the project's local imports work. Give either code or entrypoint",
+					},
+					"kernel": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Run the code in the conversation's persistent Jupyter kernel (python): variables, imports and loaded data stay in memory for the next kernel call, like notebook cells. display() output and the value of the last expression come back in display, with images saved to display/ (default: false)",
+					},
+					"restartKernel": map[string]interface{}{
+						"type":        "boolean",
+						"description": "With kernel, start a fresh kernel first, discarding all state (default: false)",
+					},
+					"installRequirements": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also install the dependencies in /data/requirements.txt (python) or /data/package.json (typescript) before running, when the file changed since the last install. Set it on every call to keep a project's dependencies in step (default: false)",
+					},
+					"packages": map[string]interface{}{
+						"type":        "array",
+						"description": "Packages to install before running, e.g. [\"requests\", \"numpy==1.26.4\"]. They are installed in a separate phase with network access, so the code can stay offline, and remain available for later runs in the conversation. Subject to the server's install policy",
+						"items":       map[string]interface{}{"type": "string"},
+					},
+					"network": h.networkProperty("Enable network access for the container (default: false for security). Not needed for installing packages; use packages instead"),
+					"environment": map[string]interface{}{
+						"type":        "object",
+						"description": "Environment variables to pass to the container (e.g., API keys, configuration)",
+						"additionalProperties": map[string]interface{}{
+							"type": "string",
+						},
+					},
+					"combinedLog": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also return stdout and stderr as one interleaved log with millisecond timestamps, for debugging the order of prints and warnings (default: false)",
+					},
+					"stdin": map[string]interface{}{
+						"type":        "string",
+						"description": "Data piped to the program's standard input, e.g. for input() or reading sys.stdin",
+					},
+					"dryRun": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Only check the code without running it: a syntax check for python, tsc --noEmit for typescript. Returns diagnostics in a fraction of the time of a run (default: false)",
+					},
+				},
+				"required": []string{"language"},
+			},
+			"outputSchema": runOutputSchema(),
+		},
+		{
+			"name":        "run_shell",
+			"description": "Run a shell command (/bin/sh -c) in a runner image with the same /data sandbox, limits and network controls as run_code. Useful for inspecting and preparing files: ls -la, pip show pandas, unzip archive.zip, head data.csv. Returns stdout, stderr, the exit code and the sandbox files.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"language": languageProperty(caps, "Runner whose image the command runs in", false),
+					"version":  versionProperty(caps),
+					"command": map[string]interface{}{
+						"type":        "string",
+						"description": "Shell command to run in /data",
+					},
+					"network": h.networkProperty("Enable network access for the container (default: false)"),
+					"environment": map[string]interface{}{
+						"type":        "object",
+						"description": "Environment variables to pass to the container",
+						"additionalProperties": map[string]interface{}{
+							"type": "string",
+						},
+					},
+					"stdin": map[string]interface{}{
+						"type":        "string",
+						"description": "Data piped to the command's standard input",
+					},
+				},
+				"required": []string{"language", "command"},
+			},
+			"outputSchema": runOutputSchema(),
+		},
+		{
+			"name":        "run_pipeline",
+			"description": "Run several code steps in order in the same sandbox, e.g. fetch, then clean, then plot, in one call instead of one run_code call per step. Files a step writes to /data are there for the next. Stops at the first failing step unless it has continueOnError. Returns each step's run_code result, and the sandbox files once at the end.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"steps": map[string]interface{}{
+						"type":        "array",
+						"description": "Steps to run in order (at most 20)",
+						"maxItems":    maxPipelineSteps,
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"name": map[string]interface{}{
+									"type":        "string",
+									"description": "Label for the step in the results (default: its number)",
+								},
+								"language": languageProperty(caps, "Programming language of the step", false),
+								"version":  versionProperty(caps),
+								"code": map[string]interface{}{
+									"type":        "string",
+									"description": "The step's code",
+								},
+								"packages": map[string]interface{}{
+									"type":        "array",
+									"description": "Packages to install before the step runs, as for run_code",
+									"items":       map[string]interface{}{"type": "string"},
+								},
+								"continueOnError": map[string]interface{}{
+									"type":        "boolean",
+									"description": "Run the next step even if this one fails (default: false)",
+								},
+							},
+							"required": []string{"language", "code"},
+						},
+					},
+					"network": h.networkProperty("Enable network access for every step (default: false)"),
+					"environment": map[string]interface{}{
+						"type":        "object",
+						"description": "Environment variables for every step",
+						"additionalProperties": map[string]interface{}{
+							"type": "string",
+						},
+					},
+				},
+				"required": []string{"steps"},
+			},
+		},
+		{
+			"name":        "run_tests",
+			"description": "Run a test suite in the sandbox and return structured results: passed/failed/error/skipped counts and, for each failing test, its name, message and the end of its traceback. Supports pytest (python), jest and bun test (typescript); the framework defaults from the language. Use this instead of parsing test output from run_shell.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"framework": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"pytest", "jest", "bun"},
+						"description": "Test framework (default: pytest for python, bun for typescript)",
+					},
+					"language": languageProperty(caps, "Runner to test in (default: the framework's language)", false),
+					"version":  versionProperty(caps),
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Test file or directory relative to /data (default: the framework's discovery from /data)",
+					},
+					"args": map[string]interface{}{
+						"type":        "array",
+						"description": "Extra framework arguments, e.g. [\"-k\", \"parse\"]",
+						"items":       map[string]interface{}{"type": "string"},
+					},
+					"network": h.networkProperty("Enable network access for the container (default: false)"),
+					"environment": map[string]interface{}{
+						"type":        "object",
+						"description": "Environment variables to pass to the container",
+						"additionalProperties": map[string]interface{}{
+							"type": "string",
+						},
+					},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			"name":        "lint_and_format",
+			"description": "Lint code, and optionally format it, before running it: ruff for python, ESLint and Prettier for typescript, using the project's own configuration when the sandbox has one. Pass code inline or name a file or directory in the sandbox. Returns each diagnostic's line, column, rule and message, and with format the formatted code. Files in the sandbox are not changed.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
 					},
 					"language": map[string]interface{}{
 						"type":        "string",
-						"description": fmt.Sprintf("Programming language to execute. Available: %v", languages),
-						"enum":        languages,
+						"enum":        []string{"python", "typescript"},
+						"description": "Language of the code",
 					},
+					"version": versionProperty(caps),
 					"code": map[string]interface{}{
 						"type":        "string",
-						"description": "The code to execute. Any files written to /data will be persisted and returned as downloadable URLs.",
+						"description": "Code to check (give this or filename)",
+					},
+					"filename": map[string]interface{}{
+						"type":        "string",
+						"description": "File or directory in the sandbox to check, relative to /data (give this or code)",
+					},
+					"format": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also return the formatted code; needs code or a single file (default: false)",
+					},
+				},
+				"required": []string{"language"},
+			},
+		},
+		{
+			"name":        "set_environment",
+			"description": "Persist environment variables for a conversation. They are stored encrypted on the server and merged into every subsequent run_code call (per-call environment overrides them), so secrets like connection strings only need to be sent once. Set a variable to null to remove it. Returns the variable names only.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"environment": map[string]interface{}{
+						"type":        "object",
+						"description": "Variables to set; null removes a variable",
+						"additionalProperties": map[string]interface{}{
+							"type": []string{"string", "null"},
+						},
+					},
+				},
+				"required": []string{"environment"},
+			},
+		},
+		{
+			"name":        "get_environment",
+			"description": "List the names of the environment variables persisted for a conversation with set_environment, which every run gets automatically. Values are never returned.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			"name":        "install_package",
+			"description": "Install pip/npm packages into this conversation's package cache, mounted read-only at /packages in later run_code and run_shell calls so the code can import them without network access. Only the package manager gets network access. Subject to the server's install policy." + h.packagesDescription() + " Returns the package manager's output and the cache size.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"language": languageProperty(caps, "Language whose package manager to use", installs),
+					"version":  versionProperty(caps),
+					"packages": map[string]interface{}{
+						"type":        "array",
+						"description": "Packages to install, e.g. [\"requests\", \"numpy==1.26.4\"]",
+						"items":       map[string]interface{}{"type": "string"},
+					},
+					"reset": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Clear the package cache first (default: false)",
+					},
+				},
+				"required": []string{"language"},
+			},
+		},
+		{
+			"name":        "set_conversation_name",
+			"description": "Give this conversation a short human-friendly name (e.g. \"Q3 sales analysis\"). Operators see it in admin listings, exported bundles and execution history, and it titles share pages. An empty name clears it.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   sandbox.MaxDisplayNameLength,
+						"description": "Display name; empty to clear",
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		{
+			"name":        "set_result_key",
+			"description": "Seal this conversation's results to a client-held X25519 public key so the server operator cannot read them in transit, logs or history. Afterwards run_code and run_shell return output only in the sealed field, and file downloads and resource reads return sealed bytes (" + SealedScheme + ": ephemeral public key, 12-byte nonce, AES-256-GCM ciphertext; HKDF-SHA256 salt is ephemeral||recipient public key). Files are still plaintext inside the sandbox while code runs. An empty key turns sealing off.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"publicKey": map[string]interface{}{
+						"type":        "string",
+						"description": "Base64-encoded 32-byte X25519 public key; empty to turn sealing off",
+					},
+				},
+				"required": []string{"publicKey"},
+			},
+		},
+		{
+			"name":        "start_service",
+			"description": "Start a helper service (e.g. a database) for this conversation on a private network shared only with its run_code and run_shell containers. Later executions get the connection details as environment variables (e.g. DATABASE_URL, REDIS_URL) and can reach the service by name even without network access." + h.servicesDescription() + " Waits until the service is healthy. Services are removed with the sandbox.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"service": h.serviceProperty(),
+				},
+				"required": []string{"service"},
+			},
+		},
+		{
+			"name":        "stop_service",
+			"description": "Stop and remove a helper service started with start_service, discarding its data. Returns the services still running.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"service": h.serviceProperty(),
+				},
+				"required": []string{"service"},
+			},
+		},
+		{
+			"name":        "list_services",
+			"description": "List this conversation's helper services with their status and connection environment variables.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			"name":        "start_process",
+			"description": "Start a long-lived command (a web server, file watcher or worker) in the background in a runner image with the same /data sandbox, limits and network controls as run_shell, and return immediately. Check on it and read its output with list_processes; stop it with stop_process. Processes are removed with the sandbox." + h.processesDescription(),
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"pattern":     "^[a-z0-9][a-z0-9_-]{0,31}$",
+						"description": "Name to refer to the process by (e.g. 'web'); an exited process of the same name is replaced",
+					},
+					"language": languageProperty(caps, "Runner whose image the command runs in", false),
+					"version":  versionProperty(caps),
+					"command": map[string]interface{}{
+						"type":        "string",
+						"description": "Shell command to run in /data (e.g. 'python -m http.server 8000')",
+					},
+					"network": h.networkProperty("Enable network access for the container (default: false)"),
+					"environment": map[string]interface{}{
+						"type":        "object",
+						"description": "Environment variables to pass to the container",
+						"additionalProperties": map[string]interface{}{
+							"type": "string",
+						},
+					},
+				},
+				"required": []string{"name", "language", "command"},
+			},
+		},
+		{
+			"name":        "stop_process",
+			"description": "Stop and remove a background process started with start_process. Returns its final status and output.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Process name",
+					},
+					"tailLines": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     0,
+						"description": "Lines of recent output to return per process (default: 20)",
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		{
+			"name":        "list_processes",
+			"description": "List this conversation's background processes with their status, exit code, preview URL and recent output.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"tailLines": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     0,
+						"description": "Lines of recent output to return per process (default: 20)",
+					},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			"name":        "render_page",
+			"description": "Render an HTML file from the sandbox in headless Chromium and save a PNG screenshot or PDF next to it. Use after run_code has written an HTML report to /data. Returns the artifact's download URL. Requires a browser runner.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"filename": map[string]interface{}{
+						"type":        "string",
+						"description": "HTML file in /data to render (e.g., 'report.html')",
+					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"png", "pdf"},
+						"description": "Output format (default: png)",
+					},
+					"output": map[string]interface{}{
+						"type":        "string",
+						"description": "Output file name (default: filename with the format's extension)",
+					},
+					"width": map[string]interface{}{
+						"type":        "integer",
+						"description": "Viewport width in pixels (default: 1280)",
+					},
+					"height": map[string]interface{}{
+						"type":        "integer",
+						"description": "Viewport height in pixels (default: 800)",
+					},
+					"fullPage": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Capture the full scrollable page for PNGs (default: true)",
+					},
+				},
+				"required": []string{"filename"},
+			},
+		},
+		{
+			"name":        "get_execution_history",
+			"description": "List past run_code executions for this conversation, newest first: language, duration, exit code, truncated output and the files present afterwards. Use to recall what was already run.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"language": map[string]interface{}{
+						"type":        "string",
+						"description": "Only executions in this language",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"maximum":     history.MaxLimit,
+						"description": "Maximum executions to return (default: 20)",
+					},
+					"before": map[string]interface{}{
+						"type":        "integer",
+						"description": "Return executions older than this ID (the nextBefore of a previous call)",
+					},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			"name":        "share_conversation",
+			"description": "Create an expiring, read-only web link to this conversation's files, for sharing results with people who don't have API access. Returns the URL and its expiry.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"expiresInHours": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"maximum":     maxShareHours,
+						"description": "How long the link works (default: 24)",
+					},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			"name":        "create_download_link",
+			"description": "Create an expiring link to one file in the sandbox that works without API access. Unlike the file's /files/ URL it stops working when it expires, when revoke_links is called, or, with singleUse, after the first download. Use it for sensitive artifacts. Returns the URL and its expiry.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"filename": map[string]interface{}{
+						"type":        "string",
+						"description": "Path of the file within the sandbox, e.g. report.pdf or out/results.csv",
+					},
+					"expiresInHours": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"maximum":     maxDownloadLinkHours,
+						"description": "How long the link works (default: 24)",
+					},
+					"singleUse": map[string]interface{}{
+						"type":        "boolean",
+						"description": "The link stops working after one download (default: false)",
+					},
+				},
+				"required": []string{"filename"},
+			},
+		},
+		{
+			"name":        "revoke_links",
+			"description": "Revoke every share_conversation and create_download_link link made for this conversation so far. Links created afterwards work as usual. The files' /files/ URLs are not affected.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			"name":        "create_ingest_link",
+			"description": "Create an expiring upload URL that external systems (scheduled jobs, pipelines) can POST files to, so datasets land in this conversation's sandbox for later analysis. Returns the URL and a signing secret: requests must send ?filename=<name> and an X-Signature-256: sha256=<hex HMAC-SHA256 of the body> header.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"expiresInHours": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"maximum":     maxIngestHours,
+						"description": "How long the link accepts uploads (default: 168)",
+					},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			"name":        "create_from_template",
+			"description": templatesDescription(templateList),
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
 					},
-					"network": map[string]interface{}{
+					"template": map[string]interface{}{
+						"type": "string",
+						"enum": templateNames,
+					},
+					"overwrite": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Enable network access for the container (default: false for security)",
+						"description": "Copy the template into a sandbox that already has files, replacing files with the same names (default: false)",
+					},
+				},
+				"required": []string{"template"},
+			},
+		},
+		{
+			"name":        "snapshot_sandbox",
+			"description": fmt.Sprintf("Checkpoint this conversation's /data: saves every file and directory under a new snapshot ID that restore_sandbox can roll back to, e.g. before a destructive run. The newest %d snapshots are kept. Returns the new snapshot and all kept ones.", sandbox.MaxSnapshots),
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"label": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   sandbox.MaxSnapshotLabelLength,
+						"description": "Optional note describing the state, e.g. \"cleaned data\"",
+					},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			"name":        "restore_sandbox",
+			"description": "Roll this conversation's /data back to a snapshot taken with snapshot_sandbox. Files created since are deleted and changed files are replaced. The snapshot is kept, so it can be restored again.",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"conversationId": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique identifier for the conversation/session (defaults to the MCP session)",
+					},
+					"snapshotId": map[string]interface{}{
+						"type":        "string",
+						"description": "ID returned by snapshot_sandbox",
+					},
+				},
+				"required": []string{"snapshotId"},
+			},
+		},
+		{
+			"name":        "read_output",
+			"description": fmt.Sprintf("Read the next page of oversized run_code output. Pass the stdoutNextToken from run_code (or nextToken from a previous read_output) to get up to %d bytes; repeat until no nextToken is returned. Tokens expire after an hour.", h.outputs.PageSize()),
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"token": map[string]interface{}{
+						"type":        "string",
+						"description": "Continuation token",
+					},
+				},
+				"required": []string{"token"},
+			},
+		},
+		{
+			"name":        "describe_runner",
+			"description": "Describe a runner: its image, limits and the packages actually installed in it (with versions).",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"language": languageProperty(caps, "Runner to describe", false),
+					"version":  versionProperty(caps),
+				},
+				"required": []string{"language"},
+			},
+		},
+		{
+			"name":        "list_runners",
+			"description": "List all available code execution runners and their Docker images. This tool takes no parameters.",
+			"inputSchema": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+				"required":   []string{},
+			},
+		},
+	}
+
+	for _, tool := range tools {
+		if annotations, ok := toolAnnotations[tool["name"].(string)]; ok {
+			tool["annotations"] = annotations
+		}
+		if tool["name"] == "run_code" || tool["name"] == "run_shell" || tool["name"] == "start_process" {
+			h.addPreviewPort(tool)
+		}
+		if tool["name"] == "run_code" || tool["name"] == "run_shell" || tool["name"] == "run_tests" || tool["name"] == "run_pipeline" {
+			h.addSecrets(ctx, tool)
+		}
+		if tool["name"] == "run_code" {
+			h.addGPU(tool)
+		}
+		if tool["name"] == "run_code" || tool["name"] == "run_shell" || tool["name"] == "upload_file" || tool["name"] == "upload_files" {
+			addTemplate(tool, templateNames)
+		}
+	}
+
+	// Only offer the tools the token may call
+	tools = slices.DeleteFunc(tools, func(tool map[string]interface{}) bool {
+		if tool["name"] == "fetch_file" && !h.fetcher.Enabled() {
+			return true
+		}
+		if tool["name"] == "clone_repo" && !h.cloner.Enabled() {
+			return true
+		}
+		return !toolAllowed(ctx, tool["name"].(string))
+	})
+	return tools
+}
+
+// runOutputSchema describes the result of run_code and run_shell
+func runOutputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"success": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Whether the code exited with status 0 within the time limit",
+			},
+			"stdout": map[string]interface{}{
+				"type":        "string",
+				"description": "Standard output (first page only when stdoutNextToken is set)",
+			},
+			"stderr": map[string]interface{}{
+				"type": "string",
+			},
+			"exitCode": map[string]interface{}{
+				"type":        "integer",
+				"description": "Process exit status when non-zero (-1 on timeout)",
+			},
+			"oomKilled": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Set when the process was killed for exceeding the runner's memory limit (exit code 137); stderr starts with an explanation",
+			},
+			"files": map[string]interface{}{
+				"type":        "array",
+				"description": "Files in the sandbox after execution with their download URLs",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{"type": "string"},
+						"url":  map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"name", "url"},
+				},
+			},
+			"stdoutBytes": map[string]interface{}{
+				"type":        "integer",
+				"description": "Total size of stdout in bytes when it was paginated or truncated",
+			},
+			"stdoutNextToken": map[string]interface{}{
+				"type":        "string",
+				"description": "Continuation token for read_output when stdout exceeded the inline cap",
+			},
+			"stderrBytes": map[string]interface{}{
+				"type":        "integer",
+				"description": "Total size of stderr in bytes when output was truncated",
+			},
+			"truncated": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Set when stdout or stderr went past the server's output limit; the rest was dropped",
+			},
+			"log": map[string]interface{}{
+				"type":        "array",
+				"description": "Interleaved output lines in arrival order, when combinedLog was requested",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"elapsedMs": map[string]interface{}{"type": "integer", "description": "Milliseconds since the container started"},
+						"stream":    map[string]interface{}{"type": "string", "enum": []string{"stdout", "stderr"}},
+						"text":      map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"elapsedMs", "stream", "text"},
+				},
+			},
+			"logTruncated": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Set when the log was cut off at 5000 lines",
+			},
+			"usage": map[string]interface{}{
+				"type":        "object",
+				"description": "Resources the container consumed, sampled while it ran; absent for runs too short to sample",
+				"properties": map[string]interface{}{
+					"cpuMs":           map[string]interface{}{"type": "integer", "description": "CPU time across all cores in milliseconds"},
+					"peakMemoryBytes": map[string]interface{}{"type": "integer", "description": "Highest sampled memory use, excluding page cache"},
+					"networkRxBytes":  map[string]interface{}{"type": "integer"},
+					"networkTxBytes":  map[string]interface{}{"type": "integer"},
+				},
+			},
+			"previewUrl": map[string]interface{}{
+				"type":        "string",
+				"description": "Where previewPort was served while the code ran",
+			},
+			"dryRun": map[string]interface{}{
+				"type":        "boolean",
+				"description": "The code was only checked, not run",
+			},
+			"compileOutput": map[string]interface{}{
+				"type":        "string",
+				"description": "Compiler errors and warnings, for compiled languages; stdout and stderr are the program's own",
+			},
+			"compileFailed": map[string]interface{}{
+				"type":        "boolean",
+				"description": "The code failed to compile and was not run; compileOutput has the errors",
+			},
+			"display": map[string]interface{}{
+				"type":        "array",
+				"description": "Rich output of a kernel run: display() calls and the last expression's value",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"text":     map[string]interface{}{"type": "string", "description": "Plain-text representation"},
+						"html":     map[string]interface{}{"type": "string", "description": "HTML representation, e.g. a DataFrame's table"},
+						"markdown": map[string]interface{}{"type": "string"},
+						"file":     map[string]interface{}{"type": "string", "description": "Image saved to the sandbox, listed in files"},
 					},
-					"environment": map[string]interface{}{
-						"type":        "object",
-						"description": "Environment variables to pass to the container (e.g., API keys, configuration)",
-						"additionalProperties": map[string]interface{}{
-							"type": "string",
-						},
+				},
+			},
+			"executionCount": map[string]interface{}{
+				"type":        "integer",
+				"description": "The kernel's execution counter, like a notebook cell's In[n]",
+			},
+			"kernelStarted": map[string]interface{}{
+				"type":        "boolean",
+				"description": "A new kernel was started for this call, so variables from earlier kernel calls are gone",
+			},
+			"diagnostics": map[string]interface{}{
+				"type":        "array",
+				"description": "Problems a dry run found",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"file":     map[string]interface{}{"type": "string", "description": "Omitted for code"},
+						"line":     map[string]interface{}{"type": "integer"},
+						"column":   map[string]interface{}{"type": "integer"},
+						"code":     map[string]interface{}{"type": "string"},
+						"severity": map[string]interface{}{"type": "string"},
+						"message":  map[string]interface{}{"type": "string"},
 					},
+					"required": []string{"line", "column", "severity", "message"},
 				},
-				"required": []string{"conversationId", "language", "code"},
 			},
-		},
-		{
-			"name":        "list_runners",
-			"description": "List all available code execution runners and their Docker images. This tool takes no parameters.",
-			"inputSchema": map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
-				"required":   []string{},
+			"sealed": map[string]interface{}{
+				"type":        "string",
+				"description": "Base64 sealed JSON {stdout, stderr, log} when set_result_key is in effect; stdout and stderr are then empty",
+			},
+			"error": map[string]interface{}{
+				"type":        "object",
+				"description": "Set when the code could not be run at all (e.g. code insufficient_disk_space or package_install_failed)",
+				"properties": map[string]interface{}{
+					"code":    map[string]interface{}{"type": "string"},
+					"message": map[string]interface{}{"type": "string"},
+					"data":    map[string]interface{}{"type": "object"},
+				},
+				"required": []string{"code", "message"},
 			},
 		},
+		"required": []string{"success", "stdout"},
 	}
-
-	result := map[string]interface{}{
-		"tools": tools,
-	}
-
-	log.Printf("[MCP] Returning %d tools", len(tools))
-	return NewSuccessResponse(req.ID, result)
 }
 
 // handleToolCall handles the tools/call method
@@ -239,20 +1309,122 @@ func (h *MCPHandler) handleToolCall(ctx context.Context, req JSONRPCRequest) JSO
 	}
 
 	log.Printf("[MCP] Tool call: %s", params.Name)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("mcp.tool", params.Name))
+
+	if !toolAllowed(ctx, params.Name) {
+		scope, ok := toolScopes[params.Name]
+		if !ok {
+			log.Printf("[MCP] Unknown tool: %s", params.Name)
+			return NewErrorResponse(req.ID, MethodNotFound, fmt.Sprintf("Tool not found: %s", params.Name), nil)
+		}
+		return scopeError(ctx, req.ID, params.Name, scope)
+	}
+	// Other tokens' conversations are rejected before they are staged
+	if errResp := h.toolTenantError(ctx, req.ID, params); errResp != nil {
+		return *errResp
+	}
+	if !tenantFreeTools[params.Name] {
+		syncFiles, errResp := h.stageConversation(ctx, req.ID, toolConversationID(ctx, params))
+		if errResp != nil {
+			return *errResp
+		}
+		defer syncFiles()
+	}
+
+	// Honor _meta.progressToken by forwarding execution progress to the client
+	if params.Meta != nil && params.Meta.ProgressToken != nil {
+		if notifier, ok := notifierFromContext(ctx); ok {
+			ctx = runner.WithProgress(ctx, progressNotifier(notifier, params.Meta.ProgressToken, h.messages))
+		}
+	}
 
 	switch params.Name {
 	case "upload_file":
-		return h.handleUploadFile(req.ID, params.Arguments)
+		return h.handleUploadFile(ctx, req.ID, params.Arguments)
+	case "upload_files":
+		return h.handleUploadFiles(ctx, req.ID, params.Arguments)
+	case "fetch_file":
+		return h.handleFetchFile(ctx, req.ID, params.Arguments)
+	case "clone_repo":
+		return h.handleCloneRepo(ctx, req.ID, params.Arguments)
 	case "run_code":
 		return h.handleRunCode(ctx, req.ID, params.Arguments)
+	case "run_shell":
+		return h.handleRunShell(ctx, req.ID, params.Arguments)
+	case "run_pipeline":
+		return h.handleRunPipeline(ctx, req.ID, params.Arguments)
+	case "run_tests":
+		return h.handleRunTests(ctx, req.ID, params.Arguments)
+	case "lint_and_format":
+		return h.handleLintAndFormat(ctx, req.ID, params.Arguments)
 	case "list_runners":
 		return h.handleListRunners(req.ID)
+	case "describe_runner":
+		return h.handleDescribeRunner(req.ID, params.Arguments)
+	case "read_output":
+		return h.handleReadOutput(req.ID, params.Arguments)
+	case "set_environment":
+		return h.handleSetEnvironment(ctx, req.ID, params.Arguments)
+	case "get_environment":
+		return h.handleGetEnvironment(ctx, req.ID, params.Arguments)
+	case "install_package":
+		return h.handleInstallPackage(ctx, req.ID, params.Arguments)
+	case "set_conversation_name":
+		return h.handleSetConversationName(ctx, req.ID, params.Arguments)
+	case "set_result_key":
+		return h.handleSetResultKey(ctx, req.ID, params.Arguments)
+	case "start_service":
+		return h.handleStartService(ctx, req.ID, params.Arguments)
+	case "stop_service":
+		return h.handleStopService(ctx, req.ID, params.Arguments)
+	case "list_services":
+		return h.handleListServices(ctx, req.ID, params.Arguments)
+	case "start_process":
+		return h.handleStartProcess(ctx, req.ID, params.Arguments)
+	case "stop_process":
+		return h.handleStopProcess(ctx, req.ID, params.Arguments)
+	case "list_processes":
+		return h.handleListProcesses(ctx, req.ID, params.Arguments)
+	case "render_page":
+		return h.handleRenderPage(ctx, req.ID, params.Arguments)
+	case "get_execution_history":
+		return h.handleGetExecutionHistory(ctx, req.ID, params.Arguments)
+	case "create_from_template":
+		return h.handleCreateFromTemplate(ctx, req.ID, params.Arguments)
+	case "create_ingest_link":
+		return h.handleCreateIngestLink(ctx, req.ID, params.Arguments)
+	case "share_conversation":
+		return h.handleShareConversation(ctx, req.ID, params.Arguments)
+	case "create_download_link":
+		return h.handleCreateDownloadLink(ctx, req.ID, params.Arguments)
+	case "revoke_links":
+		return h.handleRevokeLinks(ctx, req.ID, params.Arguments)
+	case "snapshot_sandbox":
+		return h.handleSnapshotSandbox(ctx, req.ID, params.Arguments)
+	case "restore_sandbox":
+		return h.handleRestoreSandbox(ctx, req.ID, params.Arguments)
 	default:
 		log.Printf("[MCP] Unknown tool: %s", params.Name)
 		return NewErrorResponse(req.ID, MethodNotFound, fmt.Sprintf("Tool not found: %s", params.Name), nil)
 	}
 }
 
+// callTool calls a tool outside JSON-RPC (REST and gRPC), with the same
+// scope and tenant checks as tools/call. arguments is marshaled to JSON
+func (h *MCPHandler) callTool(ctx context.Context, name string, arguments interface{}) JSONRPCResponse {
+	argsJSON, err := json.Marshal(arguments)
+	if err != nil {
+		return NewErrorResponse(nil, InvalidParams, "Invalid arguments", err.Error())
+	}
+	params, _ := json.Marshal(ToolCallParams{Name: name, Arguments: argsJSON})
+	return h.handleToolCall(ctx, JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      name,
+		Method:  "tools/call",
+		Params:  params,
+	})
+}
+
 // handleRunCode implements the sandbox.run_code tool
 func (h *MCPHandler) handleRunCode(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
 	log.Printf("[MCP] Parsing run_code arguments")
@@ -262,8 +1434,10 @@ func (h *MCPHandler) handleRunCode(ctx context.Context, id interface{}, argsJSON
 		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
 	}
 
-	log.Printf("[MCP] run_code: conversationId=%s, language=%s, codeLen=%d, network=%v, envVars=%d",
-		args.ConversationID, args.Language, len(args.Code), args.Network, len(args.Environment))
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] run_code: conversationId=%s, language=%s, version=%s, codeLen=%d, files=%d, entrypoint=%q, network=%v, envVars=%d, secrets=%v",
+		args.ConversationID, args.Language, args.Version, len(args.Code), len(args.Files), args.Entrypoint, args.Network, len(args.Environment), args.Secrets)
 
 	// Validate arguments
 	if args.ConversationID == "" {
@@ -274,29 +1448,140 @@ func (h *MCPHandler) handleRunCode(ctx context.Context, id interface{}, argsJSON
 		log.Printf("[MCP] Missing language")
 		return NewErrorResponse(id, InvalidParams, "language is required", nil)
 	}
-	if args.Code == "" {
+	if args.Code == "" && args.Entrypoint == "" {
 		log.Printf("[MCP] Missing code")
-		return NewErrorResponse(id, InvalidParams, "code is required", nil)
+		return NewErrorResponse(id, InvalidParams, "code or entrypoint is required", nil)
+	}
+	if args.Code != "" && args.Entrypoint != "" {
+		return NewErrorResponse(id, InvalidParams, "code and entrypoint are mutually exclusive", nil)
+	}
+	args.Entrypoint = strings.TrimPrefix(args.Entrypoint, "/data/")
+	if args.Entrypoint != "" && !filepath.IsLocal(filepath.FromSlash(args.Entrypoint)) {
+		return NewErrorResponse(id, InvalidParams, "entrypoint must be a path inside /data", nil)
+	}
+
+	if args.DryRun {
+		return h.checkCode(ctx, id, args)
+	}
+	return h.runInSandbox(ctx, id, args, false)
+}
+
+// handleRunShell implements the run_shell tool
+func (h *MCPHandler) handleRunShell(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args RunShellArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] run_shell: conversationId=%s, language=%s, version=%s, commandLen=%d, network=%v, envVars=%d, secrets=%v",
+		args.ConversationID, args.Language, args.Version, len(args.Command), args.Network, len(args.Environment), args.Secrets)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if args.Language == "" {
+		return NewErrorResponse(id, InvalidParams, "language is required", nil)
+	}
+	if args.Command == "" {
+		return NewErrorResponse(id, InvalidParams, "command is required", nil)
+	}
+
+	return h.runInSandbox(ctx, id, RunCodeArguments{
+		ConversationID: args.ConversationID,
+		Language:       args.Language,
+		Version:        args.Version,
+		Code:           args.Command,
+		Network:        args.Network,
+		Environment:    args.Environment,
+		Secrets:        args.Secrets,
+		Stdin:          args.Stdin,
+		PreviewPort:    args.PreviewPort,
+		Template:       args.Template,
+	}, true)
+}
+
+// runInSandbox carries out run_code, or with shell run_shell (args.Code is
+// then the command): it resolves the runner, prepares the sandbox, runs the
+// container and collects the results
+func (h *MCPHandler) runInSandbox(ctx context.Context, id interface{}, args RunCodeArguments, shell bool) JSONRPCResponse {
+	if args.Network.Restricted && !h.executor.EgressEnabled() {
+		return NewErrorResponse(id, InvalidParams, "network \"restricted\" is not available: the server has no EGRESS_ALLOWED_DOMAINS", nil)
+	}
+	if msg := h.previewPortError(args.PreviewPort, args.Network); msg != "" {
+		return NewErrorResponse(id, InvalidParams, msg, nil)
 	}
 
 	// Get runner for language
-	runnerInfo, ok := h.registry.GetRunner(args.Language)
+	runnerInfo, ok := h.registry.GetRunner(args.Language, args.Version)
 	if !ok {
-		log.Printf("[MCP] Unsupported language: %s", args.Language)
+		msg := h.messages.Format(messages.UnsupportedLanguage, messages.Args{"Language": args.Language})
+		if args.Version != "" {
+			msg = h.messages.Format(messages.UnsupportedVersion, messages.Args{"Language": args.Language, "Version": args.Version})
+		}
+		log.Printf("[MCP] %s", msg)
 		result := RunCodeResult{
 			Success: false,
-			Stderr:  fmt.Sprintf("Unsupported language: %s", args.Language),
+			Stderr:  msg,
 		}
 		return h.wrapToolResult(id, result)
 	}
 
 	log.Printf("[MCP] Using runner: %s", runnerInfo.Image)
 
+	if args.Entrypoint != "" && runnerInfo.RunFile == "" && runnerInfo.Compile == "" {
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("The %s runner can't run files (give it a sandbox.run-file label)", runnerInfo.Language), nil)
+	}
+
+	if args.GPU && !shell {
+		switch {
+		case !h.executor.GPUEnabled():
+			return NewErrorResponse(id, InvalidParams, "gpu is not available: the server has no RUNNER_GPUS", nil)
+		case !runnerInfo.GPU:
+			return NewErrorResponse(id, InvalidParams, fmt.Sprintf("The %s runner has no GPU support (give it a sandbox.gpu=true label)", runnerInfo.Language), nil)
+		}
+	}
+
+	if args.Kernel && !shell {
+		switch {
+		case !h.kernels.Enabled():
+			return NewErrorResponse(id, InvalidParams, "Kernel mode is disabled on this server", nil)
+		case runnerInfo.Kernel == "":
+			return NewErrorResponse(id, InvalidParams, fmt.Sprintf("The %s runner has no Jupyter kernel (give it a sandbox.kernel label)", runnerInfo.Language), nil)
+		case args.Entrypoint != "" || args.Stdin != "" || args.PreviewPort != 0 || args.CombinedLog:
+			return NewErrorResponse(id, InvalidParams, "kernel can't be combined with entrypoint, stdin, previewPort or combinedLog", nil)
+		}
+	}
+
+	// An entrypoint always gets stdin; code does only on code-file runners
+	if args.Stdin != "" && !shell && args.Entrypoint == "" && !h.executor.AcceptsStdin(runnerInfo) {
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("The %s runner reads the code from stdin, so stdin can't be given (rebuild it with the sandbox.code-file=true label)", runnerInfo.Language), nil)
+	}
+
+	if len(args.Packages) > 0 {
+		if err := h.installs.Check(args.Packages); err != nil {
+			log.Printf("[MCP] Rejected packages: %v", err)
+			return NewErrorResponse(id, InvalidParams, err.Error(), nil)
+		}
+	}
+
+	// Fail fast rather than letting the code die mid-write with ENOSPC
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return h.wrapToolResult(id, RunCodeResult{
+			Success: false,
+			Stderr:  toolErr.Message,
+			Error:   toolErr,
+		})
+	}
+
 	// Ensure sandbox directory exists (creates on filesystem)
 	// Returns the hashed directory name which is safe to expose in URLs
 	log.Printf("[MCP] Creating sandbox directory for conversation %s", args.ConversationID)
+	_, prepareSpan := tracing.Start(ctx, "sandbox.prepare")
 	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
 	if err != nil {
+		tracing.End(prepareSpan, err)
 		log.Printf("[MCP] Failed to create sandbox directory: %v", err)
 		result := RunCodeResult{
 			Success: false,
@@ -306,43 +1591,376 @@ func (h *MCPHandler) handleRunCode(ctx context.Context, id interface{}, argsJSON
 	}
 	log.Printf("[MCP] Sandbox directory created: %s", hashedDir)
 
+	if errResp := h.seedNewSandbox(id, args.ConversationID, args.Template); errResp != nil {
+		tracing.End(prepareSpan, errors.New(errResp.Error.Message))
+		return *errResp
+	}
+
+	if len(args.Files) > 0 {
+		written, err := h.sandbox.WriteFiles(args.ConversationID, args.Files)
+		if err != nil {
+			tracing.End(prepareSpan, err)
+			log.Printf("[MCP] Failed to write project files: %v", err)
+			return NewErrorResponse(id, InvalidParams, fmt.Sprintf("Invalid files: %v", err), nil)
+		}
+		log.Printf("[MCP] Wrote %d project files", len(written))
+	}
+
 	// Get the host path for bind mounting into runner container
 	sandboxHostPath := h.sandbox.GetSandboxHostPath(args.ConversationID)
 	log.Printf("[MCP] Sandbox host path: %s", sandboxHostPath)
 
 	// Determine network setting (defaults to false/disabled)
-	networkEnabled := false
-	if args.Network != nil {
-		networkEnabled = *args.Network
-	}
+	networkEnabled := args.Network.Enabled
 
-	// Use environment variables if provided, otherwise empty map
-	env := args.Environment
-	if env == nil {
-		env = make(map[string]string)
+	// Start from the conversation's persisted environment; per-call
+	// variables override persisted ones
+	env, err := h.envs.Get(args.ConversationID)
+	if err != nil {
+		tracing.End(prepareSpan, err)
+		log.Printf("[MCP] Failed to load persisted environment: %v", err)
+		result := RunCodeResult{
+			Success: false,
+			Stderr:  fmt.Sprintf("Failed to load persisted environment: %v", err),
+		}
+		return h.wrapToolResult(id, result)
+	}
+	for key, value := range args.Environment {
+		env[key] = value
+	}
+	// Registered secrets take precedence; their values are never logged
+	secretEnv, err := h.secrets.Resolve(ctx, args.Secrets, secretsToken(ctx))
+	if err != nil {
+		tracing.End(prepareSpan, err)
+		log.Printf("[MCP] Failed to resolve secrets: %v", err)
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("Failed to resolve secrets: %v", err), nil)
+	}
+	for key, value := range secretEnv {
+		env[key] = value
 	}
 
+	// Connection variables for the conversation's running services
+	serviceCtx := h.withServices(ctx, hashedDir, env)
+
 	// Inject FILE_BASE_URL so code can generate markdown with correct URLs
-	fileBaseURL := fmt.Sprintf("%s/files/%s", h.signer.GetBaseURL(), hashedDir)
-	env["FILE_BASE_URL"] = fileBaseURL
+	env["FILE_BASE_URL"] = h.signer.FileBaseURL(hashedDir)
+	var previewURL string
+	if args.PreviewPort != 0 {
+		previewURL = h.signer.PreviewURL(hashedDir)
+		env["PREVIEW_URL"] = previewURL
+	}
+
+	// Snapshot inputs before execution so a failure can be reproduced later
+	inputs, err := bundle.Manifest(h.sandbox.GetSandboxDir(args.ConversationID))
+	if err != nil {
+		log.Printf("[MCP] Failed to build input manifest: %v", err)
+	}
+	tracing.End(prepareSpan, nil)
+
+	// Install dependencies first so the code itself never needs the network
+	packages, requirementsDigest, errResp := h.withRequirements(id, args, runnerInfo.Language)
+	if errResp != nil {
+		return *errResp
+	}
+	if len(packages) > 0 {
+		if _, toolErr := h.installPackages(ctx, args.ConversationID, packages, runnerInfo, sandboxHostPath, messages.PackageInstallFailed); toolErr != nil {
+			return h.wrapToolResult(id, RunCodeResult{
+				Success: false,
+				Stderr:  toolErr.Message,
+				Error:   toolErr,
+			})
+		}
+		if requirementsDigest != "" {
+			if err := h.sandbox.MarkRequirementsInstalled(args.ConversationID, runnerInfo.Language, requirementsDigest); err != nil {
+				log.Printf("[MCP] Failed to record installed requirements: %v", err)
+			}
+		}
+	}
+	execCtx := h.withPackages(serviceCtx, args.ConversationID, runnerInfo.Language, env)
 
 	// Execute code in container (use host path for bind mount)
-	log.Printf("[MCP] Executing %s code for conversation %s (network: %v, env vars: %d)", args.Language, args.ConversationID, networkEnabled, len(env))
-	execResult := h.executor.Execute(ctx, runnerInfo.Image, sandboxHostPath, args.Code, networkEnabled, env)
+	log.Printf("[MCP] Executing %s code for conversation %s (network: %v, env vars: %d)", args.Language, args.ConversationID, args.Network, len(env))
+	started := time.Now()
+	if args.CombinedLog {
+		execCtx = runner.WithCombinedLog(execCtx)
+	}
+	if args.Network.Restricted {
+		execCtx = runner.WithRestrictedNetwork(execCtx)
+	}
+	if args.GPU && !shell {
+		execCtx = runner.WithGPU(execCtx)
+	}
+	if args.Stdin != "" {
+		execCtx = runner.WithStdin(execCtx, args.Stdin)
+	}
+	if args.PreviewPort != 0 {
+		// Routed for as long as the container runs
+		unregister := func() {}
+		defer func() { unregister() }()
+		execCtx = runner.WithPreview(execCtx, args.PreviewPort, h.previews.BindHost(), func(hostPort string) string {
+			unregister = h.previews.Register(hashedDir, hostPort)
+			return previewURL
+		})
+	}
+	var execResult runner.ExecutionResult
+	var kernelStarted bool
+	switch {
+	case args.Kernel && !shell:
+		execResult, kernelStarted = h.kernels.Execute(execCtx, hashedDir, kernels.Spec{
+			Runner:      runnerInfo,
+			SandboxDir:  sandboxHostPath,
+			User:        h.sandbox.User(args.ConversationID),
+			Network:     networkEnabled,
+			Restricted:  args.Network.Restricted,
+			GPU:         args.GPU,
+			Environment: env,
+		}, args.Code, args.RestartKernel)
+	case shell:
+		execResult = h.executor.ExecuteShell(execCtx, runnerInfo, sandboxHostPath, h.sandbox.User(args.ConversationID), args.Code, networkEnabled, env)
+	case args.Entrypoint != "":
+		execResult = h.executor.ExecuteFile(execCtx, runnerInfo, sandboxHostPath, h.sandbox.User(args.ConversationID), filepath.ToSlash(filepath.Clean(args.Entrypoint)), networkEnabled, env)
+	default:
+		execResult = h.executor.Execute(execCtx, runnerInfo, sandboxHostPath, h.sandbox.User(args.ConversationID), args.Code, networkEnabled, env)
+	}
+	duration := time.Since(started)
 	log.Printf("[MCP] Execution completed: success=%v, exitCode=%d", execResult.Success, execResult.ExitCode)
 
+	// With a result key, output is only readable by the client: keep it out
+	// of failure bundles and history
+	resultKey := h.sandbox.ConversationResultKey(args.ConversationID)
+	if !execResult.Success && resultKey == nil {
+		h.recordFailure(args, shell, runnerInfo, inputs, env, execResult)
+	}
+
+	_, collectSpan := tracing.Start(ctx, "sandbox.collect")
+	defer tracing.End(collectSpan, nil)
+
 	result := RunCodeResult{
-		Success: execResult.Success,
-		Stdout:  execResult.Stdout,
-		Stderr:  execResult.Stderr,
+		Success:        execResult.Success,
+		Stdout:         execResult.Stdout,
+		Stderr:         execResult.Stderr,
+		ExitCode:       execResult.ExitCode,
+		OOMKilled:      execResult.OOMKilled,
+		Files:          h.listFileDescriptors(args.ConversationID, hashedDir),
+		Log:            execResult.Log,
+		LogTruncated:   execResult.LogTruncated,
+		Usage:          resourceUsage(execResult.Usage),
+		PreviewURL:     previewURL,
+		CompileOutput:  execResult.CompileOutput,
+		CompileFailed:  execResult.CompileFailed,
+		Display:        execResult.Display,
+		ExecutionCount: execResult.ExecutionCount,
+		KernelStarted:  kernelStarted,
+		Error:          h.executionError(execResult),
+	}
+
+	if resultKey != nil {
+		recorded := execResult
+		recorded.Stdout, recorded.Stderr = sealedPlaceholder, ""
+		h.recordHistory(ctx, args, runnerInfo, started, duration, recorded, result.Files)
+
+		// Sealed output is returned whole; paging would need the plaintext
+		if err := sealResult(resultKey, &result); err != nil {
+			log.Printf("[MCP] Failed to seal output: %v", err)
+			return NewErrorResponse(id, InternalError, "Failed to seal output", err.Error())
+		}
+		log.Printf("[MCP] Sealed output: %d bytes", len(result.Sealed))
+	} else {
+		h.recordHistory(ctx, args, runnerInfo, started, duration, execResult, result.Files)
+	}
+
+	// Return only the first page of oversized stdout
+	if page, token := h.outputs.Paginate(result.Stdout); token != "" {
+		log.Printf("[MCP] Paginating stdout: %d bytes", len(execResult.Stdout))
+		result.Stdout = page
+		result.StdoutBytes = len(execResult.Stdout)
+		result.StdoutNextToken = token
+	}
+
+	// Report how much was produced when the executor dropped output
+	if execResult.StdoutTruncated || execResult.StderrTruncated {
+		result.Truncated = true
+		result.StdoutBytes = int(execResult.StdoutBytes)
+		result.StderrBytes = int(execResult.StderrBytes)
 	}
 
-	log.Printf("[MCP] run_code completed successfully")
+	tool := "run_code"
+	if shell {
+		tool = "run_shell"
+	}
+	log.Printf("[MCP] %s completed successfully", tool)
 	return h.wrapToolResult(id, result)
 }
 
+// previewPortError validates a previewPort argument, returning why it
+// can't be used or "" if it can (or wasn't given)
+func (h *MCPHandler) previewPortError(port int, network NetworkSetting) string {
+	switch {
+	case port == 0:
+		return ""
+	case !h.previews.Enabled():
+		return "previewPort is not available: the server has PREVIEW_ENABLED=false"
+	case port < 1 || port > 65535:
+		return "previewPort must be between 1 and 65535"
+	case !network.Enabled:
+		// Docker can only publish ports of containers with a network
+		return "previewPort requires network: true"
+	}
+	return ""
+}
+
+// handleSetEnvironment implements the set_environment tool
+func (h *MCPHandler) handleSetEnvironment(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args SetEnvironmentArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if len(args.Environment) == 0 {
+		return NewErrorResponse(id, InvalidParams, "environment is required", nil)
+	}
+	if _, ok := args.Environment["FILE_BASE_URL"]; ok {
+		return NewErrorResponse(id, InvalidParams, "FILE_BASE_URL is reserved", nil)
+	}
+
+	// Log names only - values are secrets
+	log.Printf("[MCP] set_environment: conversationId=%s, vars=%d", args.ConversationID, len(args.Environment))
+
+	keys, err := h.envs.Update(args.ConversationID, args.Environment)
+	if err != nil {
+		log.Printf("[MCP] Failed to persist environment: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to persist environment", err.Error())
+	}
+	sort.Strings(keys)
+
+	return h.wrapToolResult(id, SetEnvironmentResult{
+		Success:   true,
+		Variables: keys,
+	})
+}
+
+// handleGetEnvironment implements the get_environment tool
+func (h *MCPHandler) handleGetEnvironment(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args GetEnvironmentArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+
+	env, err := h.envs.Get(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to load persisted environment: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to load persisted environment", err.Error())
+	}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	log.Printf("[MCP] get_environment: conversationId=%s, vars=%d", args.ConversationID, len(keys))
+	return h.wrapToolResult(id, GetEnvironmentResult{Variables: keys})
+}
+
+// handleReadOutput implements the read_output tool
+func (h *MCPHandler) handleReadOutput(id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args ReadOutputArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	if args.Token == "" {
+		return NewErrorResponse(id, InvalidParams, "token is required", nil)
+	}
+
+	page, next, offset, total, err := h.outputs.Read(args.Token)
+	if err != nil {
+		log.Printf("[MCP] read_output failed: %v", err)
+		return NewErrorResponse(id, InvalidParams, err.Error(), nil)
+	}
+
+	log.Printf("[MCP] read_output: offset=%d, len=%d, total=%d", offset, len(page), total)
+	return h.wrapToolResult(id, ReadOutputResult{
+		Output:     page,
+		Offset:     offset,
+		TotalBytes: total,
+		NextToken:  next,
+	})
+}
+
+// listFileDescriptors returns download descriptors for every file in a sandbox
+func (h *MCPHandler) listFileDescriptors(conversationID, hashedDir string) []FileDescriptor {
+	files, err := h.sandbox.ListFiles(conversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to list sandbox files: %v", err)
+		return nil
+	}
+
+	baseURL := h.signer.FileBaseURL(hashedDir)
+	descriptors := make([]FileDescriptor, 0, len(files))
+	for _, name := range files {
+		descriptors = append(descriptors, FileDescriptor{
+			Name: name,
+			URL:  fmt.Sprintf("%s/%s", baseURL, url.PathEscape(name)),
+		})
+	}
+	return descriptors
+}
+
+// recordFailure stores a redacted reproduction bundle for a failed execution
+func (h *MCPHandler) recordFailure(
+	args RunCodeArguments,
+	shell bool,
+	runnerInfo runner.RunnerInfo,
+	inputs []bundle.InputFile,
+	env map[string]string,
+	execResult runner.ExecutionResult,
+) {
+	limits := h.executor.Limits(runnerInfo)
+	b := &bundle.Bundle{
+		CreatedAt:        time.Now().UTC(),
+		ConversationName: h.sandbox.ConversationDisplayName(args.ConversationID),
+		Language:         args.Language,
+		Image:            runnerInfo.Image,
+		ImageID:          runnerInfo.ImageID,
+		Code:             args.Code,
+		Shell:            shell,
+		Stdin:            args.Stdin,
+		Entrypoint:       args.Entrypoint,
+		Inputs:           inputs,
+		Limits: bundle.Limits{
+			TimeoutSeconds: limits.Timeout.Seconds(),
+			MemoryBytes:    limits.MemoryBytes,
+			NanoCPUs:       limits.NanoCPUs,
+			Network:        args.Network.Enabled,
+			Restricted:     args.Network.Restricted,
+		},
+		Result: bundle.Result{
+			ExitCode:    execResult.ExitCode,
+			TimedOut:    execResult.TimedOut,
+			Stdout:      execResult.Stdout,
+			Stderr:      execResult.Stderr,
+			Diagnostics: execResult.Diagnostics,
+		},
+	}
+	b.Redact(env)
+
+	h.bundles.Put(args.ConversationID, b)
+	log.Printf("[MCP] Recorded failure bundle for conversation %s", args.ConversationID)
+}
+
 // handleUploadFile implements the upload_file tool
-func (h *MCPHandler) handleUploadFile(id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+func (h *MCPHandler) handleUploadFile(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
 	log.Printf("[MCP] Parsing upload_file arguments")
 	var args UploadFileArguments
 	if err := json.Unmarshal(argsJSON, &args); err != nil {
@@ -350,8 +1968,10 @@ func (h *MCPHandler) handleUploadFile(id interface{}, argsJSON json.RawMessage)
 		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
 	}
 
-	log.Printf("[MCP] upload_file: conversationId=%s, filename=%s, contentLen=%d",
-		args.ConversationID, args.Filename, len(args.Content))
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] upload_file: conversationId=%s, filename=%s, contentLen=%d, extract=%v",
+		args.ConversationID, args.Filename, len(args.Content), args.Extract)
 
 	// Validate arguments
 	if args.ConversationID == "" {
@@ -362,6 +1982,10 @@ func (h *MCPHandler) handleUploadFile(id interface{}, argsJSON json.RawMessage)
 		log.Printf("[MCP] Missing filename")
 		return NewErrorResponse(id, InvalidParams, "filename is required", nil)
 	}
+	if !filepath.IsLocal(args.Filename) {
+		log.Printf("[MCP] Rejecting filename outside the sandbox: %q", args.Filename)
+		return NewErrorResponse(id, InvalidParams, "filename must be a relative path inside the sandbox", nil)
+	}
 	if args.Content == "" {
 		log.Printf("[MCP] Missing content")
 		return NewErrorResponse(id, InvalidParams, "content is required", nil)
@@ -380,16 +2004,29 @@ func (h *MCPHandler) handleUploadFile(id interface{}, argsJSON json.RawMessage)
 
 	log.Printf("[MCP] Decoded %d bytes for file %s", len(content), args.Filename)
 
-	// Write file to sandbox
-	if err := h.sandbox.WriteFile(args.ConversationID, args.Filename, content); err != nil {
-		log.Printf("[MCP] Failed to write file: %v", err)
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
 		result := map[string]interface{}{
 			"success": false,
-			"message": fmt.Sprintf("Failed to write file: %v", err),
+			"message": toolErr.Message,
+			"error":   toolErr,
 		}
 		return h.wrapToolResult(id, result)
 	}
 
+	if errResp := h.seedNewSandbox(id, args.ConversationID, args.Template); errResp != nil {
+		return *errResp
+	}
+
+	if args.Extract {
+		return h.extractUpload(id, args, content)
+	}
+
+	// Write file to sandbox
+	if err := h.sandbox.WriteFile(args.ConversationID, args.Filename, content); err != nil {
+		log.Printf("[MCP] Failed to write file: %v", err)
+		return h.uploadFailure(id, "Failed to write file", err)
+	}
+
 	// Get the hashed directory name for URL
 	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
 	if err != nil {
@@ -402,12 +2039,11 @@ func (h *MCPHandler) handleUploadFile(id interface{}, argsJSON json.RawMessage)
 	}
 
 	// Create file URL
-	baseURL := h.signer.GetBaseURL()
-	fileURL := fmt.Sprintf("%s/files/%s/%s", baseURL, hashedDir, args.Filename)
+	fileURL := fmt.Sprintf("%s/%s", h.signer.FileBaseURL(hashedDir), escapePath(filepath.ToSlash(args.Filename)))
 
 	result := map[string]interface{}{
 		"success": true,
-		"message": fmt.Sprintf("File '%s' uploaded successfully (%d bytes)", args.Filename, len(content)),
+		"message": h.messages.Format(messages.FileUploaded, messages.Args{"Filename": args.Filename, "Bytes": len(content)}),
 		"file": FileDescriptor{
 			Name: args.Filename,
 			URL:  fileURL,
@@ -426,10 +2062,14 @@ func (h *MCPHandler) handleListRunners(id interface{}) JSONRPCResponse {
 
 	descriptors := make([]RunnerDescriptor, 0, len(runners))
 	for _, r := range runners {
-		log.Printf("[MCP] Runner: %s -> %s", r.Language, r.Image)
+		log.Printf("[MCP] Runner: %s %s -> %s", r.Language, r.Version, r.Image)
 		descriptors = append(descriptors, RunnerDescriptor{
-			Language: r.Language,
-			Image:    r.Image,
+			Language:      r.Language,
+			Version:       r.Version,
+			Default:       r.Default,
+			Image:         r.Image,
+			Description:   r.Description,
+			Architectures: r.Architectures(),
 		})
 	}
 
@@ -441,7 +2081,144 @@ func (h *MCPHandler) handleListRunners(id interface{}) JSONRPCResponse {
 	return h.wrapToolResult(id, result)
 }
 
-// wrapToolResult wraps a result in the MCP tool result format as text
+// progressNotifier converts runner progress into notifications/progress
+// messages. Progress is elapsed seconds, which increases monotonically.
+func progressNotifier(notifier Notifier, token interface{}, catalog *messages.Catalog) runner.ProgressFunc {
+	return func(p runner.Progress) {
+		message := catalog.Format(messages.Progress, messages.Args{
+			"Seconds":     int(p.Elapsed.Seconds()),
+			"StdoutBytes": p.StdoutBytes,
+			"StderrBytes": p.StderrBytes,
+		})
+		if p.PreviewURL != "" {
+			message = catalog.Format(messages.PreviewReady, messages.Args{"URL": p.PreviewURL})
+		}
+		if p.Queued {
+			message = catalog.Format(messages.Queued, messages.Args{
+				"Position": p.QueuePosition,
+				"Wait":     p.EstimatedWait.Round(time.Second).String(),
+			})
+		}
+		notifier.Notify(NewNotification("notifications/progress", ProgressParams{
+			ProgressToken: token,
+			Progress:      p.Elapsed.Seconds(),
+			Message:       message,
+		}))
+	}
+}
+
+// checkDiskSpace reports a structured error when the sandbox filesystem is
+// below its free space minimum. Failures to check are logged, not enforced
+func (h *MCPHandler) checkDiskSpace() *ToolError {
+	err := h.sandbox.CheckFreeSpace()
+	if err == nil {
+		return nil
+	}
+
+	var spaceErr *sandbox.InsufficientSpaceError
+	if !errors.As(err, &spaceErr) {
+		log.Printf("[MCP] %v", err)
+		return nil
+	}
+
+	log.Printf("[MCP] Refusing request: %v", spaceErr)
+	return &ToolError{
+		Code: ErrInsufficientDiskSpace,
+		Message: h.messages.Format(messages.InsufficientDiskSpace, messages.Args{
+			"FreeBytes":     spaceErr.FreeBytes,
+			"RequiredBytes": spaceErr.RequiredBytes,
+		}),
+		Data: map[string]int64{
+			"freeBytes":     spaceErr.FreeBytes,
+			"requiredBytes": spaceErr.RequiredBytes,
+		},
+	}
+}
+
+// executionError reports why an execution could not run at all, if it
+// didn't: Docker was down, the queue was full or the sandbox could not be
+// started
+func (h *MCPHandler) executionError(result runner.ExecutionResult) *ToolError {
+	if errors.Is(result.Error, runner.ErrBackendUnavailable) {
+		return &ToolError{
+			Code:    ErrBackendUnavailable,
+			Message: h.messages.Format(messages.BackendUnavailable, nil),
+		}
+	}
+	if errors.Is(result.Error, runner.ErrQueueFull) {
+		return &ToolError{
+			Code:    ErrExecutionQueueFull,
+			Message: h.messages.Format(messages.ExecutionQueueFull, nil),
+		}
+	}
+	return h.sandboxUnavailable(result.Diagnostics)
+}
+
+// sandboxUnavailable reports a structured error, carrying the diagnostics,
+// when the runner container itself could not be run
+func (h *MCPHandler) sandboxUnavailable(diagnostics *runner.Diagnostics) *ToolError {
+	if diagnostics == nil {
+		return nil
+	}
+	return &ToolError{
+		Code:    ErrSandboxUnavailable,
+		Message: h.messages.Format(messages.SandboxUnavailable, messages.Args{"Stage": diagnostics.Stage}),
+		Data:    diagnostics,
+	}
+}
+
+// defaultConversationID falls back to the session's conversation when the
+// caller did not supply one, so models don't have to invent IDs
+func defaultConversationID(ctx context.Context, conversationID string) string {
+	if conversationID != "" {
+		return conversationID
+	}
+	if sess, ok := session.FromContext(ctx); ok && sess != nil {
+		return sess.ConversationID
+	}
+	return ""
+}
+
+// handleDescribeRunner implements the describe_runner tool
+func (h *MCPHandler) handleDescribeRunner(id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args DescribeRunnerArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	if args.Language == "" {
+		return NewErrorResponse(id, InvalidParams, "language is required", nil)
+	}
+
+	r, ok := h.registry.GetRunner(args.Language, args.Version)
+	if !ok {
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("No runner for language %s (version %q)", args.Language, args.Version), nil)
+	}
+
+	limits := h.executor.Limits(r)
+	packages, probed := h.registry.Packages(r)
+	result := DescribeRunnerResult{
+		RunnerDescriptor: RunnerDescriptor{
+			Language:      r.Language,
+			Version:       r.Version,
+			Default:       r.Default,
+			Image:         r.Image,
+			Description:   r.Description,
+			Architectures: r.Architectures(),
+		},
+		TimeoutSeconds: limits.Timeout.Seconds(),
+		MemoryBytes:    limits.MemoryBytes,
+		CPUs:           float64(limits.NanoCPUs) / 1e9,
+		Packages:       packages,
+		PackagesProbed: probed,
+	}
+
+	log.Printf("[MCP] describe_runner: %s %s (%d packages)", r.Language, r.Version, len(packages))
+	return h.wrapToolResult(id, result)
+}
+
+// wrapToolResult wraps a result in the MCP tool result format, both as a
+// JSON text block and as structured content
 func (h *MCPHandler) wrapToolResult(id interface{}, data interface{}) JSONRPCResponse {
 	// Serialize data to JSON for text response
 	jsonData, err := json.MarshalIndent(data, "", "  ")
@@ -457,6 +2234,7 @@ func (h *MCPHandler) wrapToolResult(id interface{}, data interface{}) JSONRPCRes
 				Text: string(jsonData),
 			},
 		},
+		StructuredContent: data,
 	}
 	return NewSuccessResponse(id, toolResult)
 }
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// SetConversationNameArguments represents arguments for set_conversation_name
+type SetConversationNameArguments struct {
+	ConversationID string `json:"conversationId"`
+	Name           string `json:"name"` // Empty clears the name
+}
+
+// SetConversationNameResult represents the result of set_conversation_name
+type SetConversationNameResult struct {
+	Success bool   `json:"success"`
+	Name    string `json:"name,omitempty"`
+}
+
+// handleSetConversationName implements the set_conversation_name tool
+func (h *MCPHandler) handleSetConversationName(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args SetConversationNameArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+
+	if err := h.sandbox.SetDisplayName(args.ConversationID, args.Name); err != nil {
+		log.Printf("[MCP] Failed to set conversation name: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Failed to set conversation name", err.Error())
+	}
+
+	name := h.sandbox.ConversationDisplayName(args.ConversationID)
+	log.Printf("[MCP] set_conversation_name: conversationId=%s, name=%q", args.ConversationID, name)
+	return h.wrapToolResult(id, SetConversationNameResult{
+		Success: true,
+		Name:    name,
+	})
+}
@@ -0,0 +1,482 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// handleOpenAPI serves an OpenAPI 3 document describing the REST, admin and
+// file endpoints: GET /openapi.json. Request schemas come from the same
+// tool definitions as tools/list, so they list the current runners
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s.openAPIDocument(context.Background())); err != nil {
+		log.Printf("[HTTP] Failed to write OpenAPI document: %v", err)
+	}
+}
+
+// openAPIDocument builds the OpenAPI document. ctx carries no token, so
+// every tool's schema is included
+func (s *Server) openAPIDocument(ctx context.Context) map[string]interface{} {
+	runInput := map[string]interface{}{"type": "object"}
+	for _, tool := range s.mcpHandler.toolDefinitions(ctx) {
+		if tool["name"] != "run_code" {
+			continue
+		}
+		// Without sessions the REST API needs the conversation spelled out
+		for key, value := range tool["inputSchema"].(map[string]interface{}) {
+			runInput[key] = value
+		}
+		runInput["required"] = []string{"conversationId", "language"}
+	}
+
+	bearer := []map[string][]string{{"bearerAuth": {}}}
+	adminOp := func(summary string, responses map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"tags":      []string{"admin"},
+			"summary":   summary,
+			"security":  bearer,
+			"responses": withErrors(responses),
+		}
+	}
+
+	paths := map[string]interface{}{
+		"/api/run": map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{"sandbox"},
+				"operationId": "runCode",
+				"summary":     "Run code in a conversation's sandbox",
+				"security":    bearer,
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content":  jsonContent(schemaRef("RunCodeRequest")),
+				},
+				"responses": withErrors(map[string]interface{}{
+					"200": jsonResponse("The run's result; check success and exitCode", schemaRef("RunCodeResult")),
+					"429": jsonResponse("The execution queue is full", schemaRef("RunCodeResult")),
+					"503": jsonResponse("The sandbox or Docker is unavailable", schemaRef("RunCodeResult")),
+					"507": jsonResponse("The sandbox disk is full", schemaRef("RunCodeResult")),
+				}),
+			},
+		},
+		"/api/upload": map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{"sandbox"},
+				"operationId": "uploadFile",
+				"summary":     "Upload a file to a conversation's sandbox",
+				"security":    bearer,
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"multipart/form-data": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"conversationId": map[string]interface{}{"type": "string"},
+									"file":           map[string]interface{}{"type": "string", "format": "binary"},
+									"filename":       map[string]interface{}{"type": "string", "description": "Defaults to the file part's name"},
+									"extract":        map[string]interface{}{"type": "boolean", "description": "Unpack a zip/tar(.gz) archive instead of storing it"},
+									"template":       map[string]interface{}{"type": "string", "description": "Seed a new sandbox from this template first"},
+								},
+								"required": []string{"conversationId", "file"},
+							},
+						},
+					},
+				},
+				"responses": withErrors(map[string]interface{}{
+					"200": jsonResponse("The upload's result", schemaRef("UploadResult")),
+				}),
+			},
+		},
+		"/api/files": map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{"sandbox"},
+				"operationId": "listFiles",
+				"summary":     "List a conversation's sandbox files",
+				"security":    bearer,
+				"parameters": []interface{}{
+					queryParameter("conversationId", "string", true, ""),
+				},
+				"responses": withErrors(map[string]interface{}{
+					"200": jsonResponse("The files with their download URLs", map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"files": map[string]interface{}{"type": "array", "items": schemaRef("FileDescriptor")},
+						},
+					}),
+				}),
+			},
+		},
+		"/api/links": map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{"sandbox"},
+				"operationId": "createDownloadLink",
+				"summary":     "Create an expiring, optionally single-use download link to one sandbox file",
+				"security":    bearer,
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": jsonContent(map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"conversationId": map[string]interface{}{"type": "string"},
+							"filename":       map[string]interface{}{"type": "string"},
+							"expiresInHours": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": maxDownloadLinkHours},
+							"singleUse":      map[string]interface{}{"type": "boolean"},
+						},
+						"required": []string{"conversationId", "filename"},
+					}),
+				},
+				"responses": withErrors(map[string]interface{}{
+					"200": jsonResponse("The link", map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"url":       map[string]interface{}{"type": "string"},
+							"expiresAt": map[string]interface{}{"type": "string", "format": "date-time"},
+							"singleUse": map[string]interface{}{"type": "boolean"},
+						},
+					}),
+				}),
+			},
+		},
+		"/api/links/revoke": map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{"sandbox"},
+				"operationId": "revokeLinks",
+				"summary":     "Revoke all of a conversation's share and download links",
+				"security":    bearer,
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": jsonContent(map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"conversationId": map[string]interface{}{"type": "string"},
+						},
+						"required": []string{"conversationId"},
+					}),
+				},
+				"responses": withErrors(map[string]interface{}{
+					"200": jsonResponse("The links were revoked", map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"success": map[string]interface{}{"type": "boolean"},
+							"message": map[string]interface{}{"type": "string"},
+						},
+					}),
+				}),
+			},
+		},
+		"/render/{hashedDir}/{filename}": map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{"files"},
+				"operationId": "renderFile",
+				"summary":     "Show a Markdown file (.md) or Jupyter notebook (.ipynb) as sanitized HTML; relative links point to the sandbox's files",
+				"security":    []map[string][]string{},
+				"parameters": []interface{}{
+					pathParameter("hashedDir"),
+					pathParameter("filename"),
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "The rendered document",
+						"content": map[string]interface{}{
+							"text/html": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+						},
+					},
+					"304": map[string]interface{}{"description": "Unchanged since the ETag the client has"},
+					"400": map[string]interface{}{"description": "Not a .md or .ipynb file"},
+					"403": map[string]interface{}{"description": "The sandbox's files are sealed"},
+					"404": map[string]interface{}{"description": "No such file"},
+					"413": map[string]interface{}{"description": "The file is over 20MB"},
+					"422": map[string]interface{}{"description": "The .ipynb file isn't a notebook in format 4"},
+				},
+			},
+		},
+		"/download/{token}": map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{"files"},
+				"operationId": "downloadLink",
+				"summary":     "Download the file a download link grants; the token is the capability. HEAD doesn't spend single-use links",
+				"security":    []map[string][]string{},
+				"parameters": []interface{}{
+					pathParameter("token"),
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "The file",
+						"content": map[string]interface{}{
+							"application/octet-stream": map[string]interface{}{
+								"schema": map[string]interface{}{"type": "string", "format": "binary"},
+							},
+						},
+					},
+					"404": map[string]interface{}{"description": "The link is invalid, expired or revoked, or the file is gone"},
+					"410": map[string]interface{}{"description": "The single-use link was already used"},
+				},
+			},
+		},
+		"/api/executions": map[string]interface{}{
+			"get": adminOp("Query the execution history", map[string]interface{}{
+				"200": jsonResponse("One page of executions and the next cursor", objectSchema()),
+			}),
+		},
+		"/files/{conversationId}": map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{"files"},
+				"operationId": "uploadFiles",
+				"summary":     "Upload one or more files to a conversation's sandbox, all or nothing (web UI drag and drop)",
+				"security":    bearer,
+				"parameters": []interface{}{
+					pathParameter("conversationId"),
+				},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"multipart/form-data": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"file":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "format": "binary"}, "description": "Any number of file parts, stored under their base names"},
+									"template": map[string]interface{}{"type": "string", "description": "Seed a new sandbox from this template first"},
+								},
+								"required": []string{"file"},
+							},
+						},
+					},
+				},
+				"responses": withErrors(map[string]interface{}{
+					"200": jsonResponse("The upload's result", map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"success": map[string]interface{}{"type": "boolean"},
+							"message": map[string]interface{}{"type": "string"},
+							"files":   map[string]interface{}{"type": "array", "items": schemaRef("FileDescriptor")},
+						},
+					}),
+				}),
+			},
+		},
+		"/files/{hashedDir}/": map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{"files"},
+				"operationId": "browseFiles",
+				"summary":     "List a sandbox's files with sizes, types and download links; browsers (Accept: text/html) get a page",
+				"security":    []map[string][]string{},
+				"parameters": []interface{}{
+					pathParameter("hashedDir"),
+					queryParameter("format", "string", false, "json or html (default: from the Accept header)"),
+				},
+				"responses": map[string]interface{}{
+					"200": jsonResponse("The files, sorted by path, and whether the listing was truncated", map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"files": map[string]interface{}{"type": "array", "items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"name":     map[string]interface{}{"type": "string"},
+									"size":     map[string]interface{}{"type": "integer"},
+									"type":     map[string]interface{}{"type": "string"},
+									"modified": map[string]interface{}{"type": "string", "format": "date-time"},
+									"url":      map[string]interface{}{"type": "string"},
+								},
+							}},
+							"truncated": map[string]interface{}{"type": "boolean"},
+						},
+					}),
+					"404": map[string]interface{}{"description": "No such sandbox"},
+				},
+			},
+		},
+		"/files/{hashedDir}/{filename}": map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{"files"},
+				"operationId": "downloadFile",
+				"summary":     "Download a sandbox file; the hashed directory is the capability, no token needed. HEAD, Range, If-None-Match and If-Modified-Since are supported",
+				"security":    []map[string][]string{},
+				"parameters": []interface{}{
+					pathParameter("hashedDir"),
+					pathParameter("filename"),
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "The file",
+						"content": map[string]interface{}{
+							"application/octet-stream": map[string]interface{}{
+								"schema": map[string]interface{}{"type": "string", "format": "binary"},
+							},
+						},
+					},
+					"206": map[string]interface{}{"description": "The requested byte range"},
+					"304": map[string]interface{}{"description": "Unchanged since the ETag or date the client has"},
+					"404": map[string]interface{}{"description": "No such file"},
+					"416": map[string]interface{}{"description": "The range is outside the file"},
+				},
+			},
+		},
+		"/admin/sandboxes": map[string]interface{}{
+			"get": adminOp("List sandboxes, most recently active first", map[string]interface{}{
+				"200": jsonResponse("The sandboxes and their total size", objectSchema()),
+			}),
+		},
+		"/admin/sandboxes/{hashedDir}": map[string]interface{}{
+			"parameters": []interface{}{pathParameter("hashedDir")},
+			"delete": adminOp("Delete a sandbox with its processes and services", map[string]interface{}{
+				"204": map[string]interface{}{"description": "Deleted"},
+			}),
+		},
+		"/admin/executions": map[string]interface{}{
+			"get": adminOp("List running executions and the queue", map[string]interface{}{
+				"200": jsonResponse("The running executions", objectSchema()),
+			}),
+		},
+		"/admin/executions/{id}": map[string]interface{}{
+			"parameters": []interface{}{pathParameter("id")},
+			"delete": adminOp("Kill a running execution by container ID or prefix", map[string]interface{}{
+				"204": map[string]interface{}{"description": "Killed"},
+			}),
+		},
+		"/admin/runners": map[string]interface{}{
+			"get": adminOp("Show the runner registry", map[string]interface{}{
+				"200": jsonResponse("The runners with their effective limits", objectSchema()),
+			}),
+		},
+		"/admin/runners/refresh": map[string]interface{}{
+			"post": adminOp("Rediscover runner images now", map[string]interface{}{
+				"200": jsonResponse("Whether the runners changed, and the runners", objectSchema()),
+			}),
+		},
+		"/admin/reload": map[string]interface{}{
+			"post": adminOp("Reload the configuration", map[string]interface{}{
+				"200": jsonResponse("The configuration was applied", objectSchema()),
+			}),
+		},
+		"/admin/gc": map[string]interface{}{
+			"parameters": []interface{}{
+				queryParameter("maxAge", "string", false, "Go duration, e.g. 720h (default: SANDBOX_RETENTION)"),
+				queryParameter("dryRun", "boolean", false, "Only report candidates (GET always does)"),
+			},
+			"get":  adminOp("Report sandboxes garbage collection would delete", map[string]interface{}{"200": jsonResponse("The report", objectSchema())}),
+			"post": adminOp("Delete inactive sandboxes", map[string]interface{}{"200": jsonResponse("The report", objectSchema())}),
+		},
+		"/admin/metrics": map[string]interface{}{
+			"get": adminOp("Per-language execution metrics", map[string]interface{}{
+				"200": jsonResponse("The metrics", objectSchema()),
+			}),
+		},
+		"/admin/bundles/{conversationId}": map[string]interface{}{
+			"parameters": []interface{}{pathParameter("conversationId")},
+			"get": adminOp("Download the reproduction bundle of the conversation's last failed execution", map[string]interface{}{
+				"200": map[string]interface{}{"description": "The bundle (tar.gz)"},
+			}),
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "mcp-code-sandbox",
+			"version":     "1.0.0",
+			"description": "Sandboxed code execution. MCP clients use POST /mcp (JSON-RPC), which isn't described here.",
+		},
+		"servers": []map[string]string{{"url": s.signer.GetBaseURL()}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+			"schemas": map[string]interface{}{
+				"RunCodeRequest": runInput,
+				"RunCodeResult":  runOutputSchema(),
+				"FileDescriptor": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{"type": "string"},
+						"url":  map[string]interface{}{"type": "string", "format": "uri"},
+					},
+				},
+				"UploadResult": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"success": map[string]interface{}{"type": "boolean"},
+						"message": map[string]interface{}{"type": "string"},
+						"file":    schemaRef("FileDescriptor"),
+					},
+				},
+				"Error": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"error": map[string]interface{}{"type": "string"},
+						"data":  map[string]interface{}{},
+					},
+				},
+			},
+		},
+	}
+}
+
+// withErrors adds the error responses every authenticated endpoint can give
+func withErrors(responses map[string]interface{}) map[string]interface{} {
+	for status, description := range map[string]string{
+		"400": "Invalid request",
+		"401": "Missing or invalid bearer token",
+		"403": "The token lacks a scope or the conversation belongs to another token",
+		"404": "Not found",
+		"413": "Request body too large",
+	} {
+		if _, ok := responses[status]; !ok {
+			responses[status] = jsonResponse(description, schemaRef("Error"))
+		}
+	}
+	return responses
+}
+
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content":     jsonContent(schema),
+	}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func objectSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+
+func pathParameter(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":     name,
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}
+}
+
+func queryParameter(name, typ string, required bool, description string) map[string]interface{} {
+	param := map[string]interface{}{
+		"name":     name,
+		"in":       "query",
+		"required": required,
+		"schema":   map[string]interface{}{"type": typ},
+	}
+	if description != "" {
+		param["description"] = description
+	}
+	return param
+}
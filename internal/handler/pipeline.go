@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// maxPipelineSteps caps the steps in one run_pipeline call
+const maxPipelineSteps = 20
+
+// PipelineStep is one step of run_pipeline
+type PipelineStep struct {
+	Name            string   `json:"name,omitempty"` // Shown in the results; defaults to the step number
+	Language        string   `json:"language"`
+	Version         string   `json:"version,omitempty"`
+	Code            string   `json:"code"`
+	Packages        []string `json:"packages,omitempty"`
+	ContinueOnError bool     `json:"continueOnError,omitempty"` // Run the next step even if this one fails
+}
+
+// RunPipelineArguments represents arguments for run_pipeline. Network,
+// environment and secrets apply to every step
+type RunPipelineArguments struct {
+	ConversationID string            `json:"conversationId"`
+	Steps          []PipelineStep    `json:"steps"`
+	Network        NetworkSetting    `json:"network,omitempty"`
+	Environment    map[string]string `json:"environment,omitempty"`
+	Secrets        []string          `json:"secrets,omitempty"`
+	Template       string            `json:"template,omitempty"`
+}
+
+// PipelineStepResult is the result of one step: a run_code result, or
+// Skipped when an earlier step failed. The sandbox files are only listed
+// once, in RunPipelineResult
+type PipelineStepResult struct {
+	Name    string `json:"name"`
+	Skipped bool   `json:"skipped,omitempty"`
+	RunCodeResult
+}
+
+// RunPipelineResult represents the result of run_pipeline
+type RunPipelineResult struct {
+	Success   bool                 `json:"success"`   // Every step ran and succeeded
+	Completed int                  `json:"completed"` // Steps that ran
+	Steps     []PipelineStepResult `json:"steps"`
+	Files     []FileDescriptor     `json:"files,omitempty"`
+}
+
+// handleRunPipeline implements the run_pipeline tool: it runs the steps in
+// order in one sandbox, stopping at the first failure unless the failed step
+// has continueOnError
+func (h *MCPHandler) handleRunPipeline(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args RunPipelineArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] run_pipeline: conversationId=%s, steps=%d, network=%v, envVars=%d, secrets=%v",
+		args.ConversationID, len(args.Steps), args.Network, len(args.Environment), args.Secrets)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if len(args.Steps) == 0 {
+		return NewErrorResponse(id, InvalidParams, "steps is required", nil)
+	}
+	if len(args.Steps) > maxPipelineSteps {
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("At most %d steps are allowed", maxPipelineSteps), nil)
+	}
+	// Reject a bad step before anything runs
+	for i, step := range args.Steps {
+		if step.Language == "" {
+			return NewErrorResponse(id, InvalidParams, fmt.Sprintf("steps[%d].language is required", i), nil)
+		}
+		if step.Code == "" {
+			return NewErrorResponse(id, InvalidParams, fmt.Sprintf("steps[%d].code is required", i), nil)
+		}
+	}
+
+	result := RunPipelineResult{Success: true, Steps: make([]PipelineStepResult, len(args.Steps))}
+	stopped := false
+	for i, step := range args.Steps {
+		stepResult := &result.Steps[i]
+		stepResult.Name = step.Name
+		if stepResult.Name == "" {
+			stepResult.Name = fmt.Sprint(i + 1)
+		}
+		if stopped {
+			stepResult.Skipped = true
+			continue
+		}
+
+		log.Printf("[MCP] run_pipeline step %s: language=%s, codeLen=%d", stepResult.Name, step.Language, len(step.Code))
+		resp := h.runInSandbox(ctx, id, RunCodeArguments{
+			ConversationID: args.ConversationID,
+			Language:       step.Language,
+			Version:        step.Version,
+			Code:           step.Code,
+			Packages:       step.Packages,
+			Network:        args.Network,
+			Environment:    args.Environment,
+			Secrets:        args.Secrets,
+			Template:       args.Template,
+		}, false)
+		result.Completed++
+
+		if resp.Error != nil {
+			// The step was rejected, e.g. a disallowed package
+			stepResult.Stderr = resp.Error.Message
+			stepResult.Error = &ToolError{Code: ErrStepRejected, Message: resp.Error.Message, Data: resp.Error.Data}
+		} else if toolResult, ok := resp.Result.(ToolResult); ok {
+			if run, ok := toolResult.StructuredContent.(RunCodeResult); ok {
+				stepResult.RunCodeResult = run
+			}
+		}
+		if files := stepResult.Files; files != nil {
+			result.Files = files
+		}
+		stepResult.Files = nil
+
+		if !stepResult.Success {
+			result.Success = false
+			stopped = !step.ContinueOnError
+		}
+	}
+
+	log.Printf("[MCP] run_pipeline completed: success=%v, completed=%d of %d", result.Success, result.Completed, len(args.Steps))
+	return h.wrapToolResult(id, result)
+}
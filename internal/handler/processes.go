@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/processes"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+)
+
+// StartProcessArguments represents arguments for start_process
+type StartProcessArguments struct {
+	ConversationID string            `json:"conversationId"`
+	Name           string            `json:"name"`
+	Language       string            `json:"language"` // Selects the runner image
+	Version        string            `json:"version,omitempty"`
+	Command        string            `json:"command"`
+	Network        NetworkSetting    `json:"network,omitempty"`
+	Environment    map[string]string `json:"environment,omitempty"`
+	PreviewPort    int               `json:"previewPort,omitempty"`
+}
+
+// ProcessArguments represents arguments for stop_process and list_processes
+type ProcessArguments struct {
+	ConversationID string `json:"conversationId"`
+	Name           string `json:"name,omitempty"`      // stop_process only
+	TailLines      *int   `json:"tailLines,omitempty"` // Output lines per process
+}
+
+// ProcessesResult represents the result of the process tools
+type ProcessesResult struct {
+	Success   bool                `json:"success"`
+	Processes []processes.Process `json:"processes"`
+}
+
+// handleStartProcess implements the start_process tool
+func (h *MCPHandler) handleStartProcess(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args StartProcessArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] start_process: conversationId=%s, name=%s, language=%s, commandLen=%d, network=%v",
+		args.ConversationID, args.Name, args.Language, len(args.Command), args.Network)
+
+	switch {
+	case args.ConversationID == "":
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	case !h.processes.Enabled():
+		return NewErrorResponse(id, InvalidParams, "Background processes are disabled on this server (MAX_PROCESSES_PER_CONVERSATION=0)", nil)
+	case args.Name == "":
+		return NewErrorResponse(id, InvalidParams, "name is required", nil)
+	case args.Language == "":
+		return NewErrorResponse(id, InvalidParams, "language is required", nil)
+	case args.Command == "":
+		return NewErrorResponse(id, InvalidParams, "command is required", nil)
+	case args.Network.Restricted && !h.executor.EgressEnabled():
+		return NewErrorResponse(id, InvalidParams, "network \"restricted\" is not available: the server has no EGRESS_ALLOWED_DOMAINS", nil)
+	}
+	if msg := h.previewPortError(args.PreviewPort, args.Network); msg != "" {
+		return NewErrorResponse(id, InvalidParams, msg, nil)
+	}
+
+	runnerInfo, ok := h.registry.GetRunner(args.Language, args.Version)
+	if !ok {
+		msg := h.messages.Format(messages.UnsupportedLanguage, messages.Args{"Language": args.Language})
+		if args.Version != "" {
+			msg = h.messages.Format(messages.UnsupportedVersion, messages.Args{"Language": args.Language, "Version": args.Version})
+		}
+		return NewErrorResponse(id, InvalidParams, msg, nil)
+	}
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return NewErrorResponse(id, InternalError, toolErr.Message, nil)
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to ensure sandbox directory: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create sandbox directory", err.Error())
+	}
+
+	// The same environment as run_code and run_shell get
+	env, err := h.envs.Get(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to load persisted environment: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to load persisted environment", err.Error())
+	}
+	for key, value := range args.Environment {
+		env[key] = value
+	}
+	execCtx := h.withServices(ctx, hashedDir, env)
+	env["FILE_BASE_URL"] = h.signer.FileBaseURL(hashedDir)
+	var previewURL string
+	if args.PreviewPort != 0 {
+		previewURL = h.signer.PreviewURL(hashedDir)
+		env["PREVIEW_URL"] = previewURL
+	}
+	execCtx = h.withPackages(execCtx, args.ConversationID, runnerInfo.Language, env)
+	if args.Network.Restricted {
+		execCtx = runner.WithRestrictedNetwork(execCtx)
+	}
+
+	process, err := h.processes.Start(execCtx, hashedDir, args.Name, processes.Spec{
+		Runner:      runnerInfo,
+		SandboxDir:  h.sandbox.GetSandboxHostPath(args.ConversationID),
+		User:        h.sandbox.User(args.ConversationID),
+		Command:     args.Command,
+		Network:     args.Network.Enabled,
+		Environment: env,
+		PreviewPort: args.PreviewPort,
+		PreviewURL:  previewURL,
+	})
+	if err != nil {
+		log.Printf("[MCP] Failed to start process: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Failed to start process", err.Error())
+	}
+	return h.wrapToolResult(id, ProcessesResult{
+		Success:   true,
+		Processes: []processes.Process{process},
+	})
+}
+
+// handleStopProcess implements the stop_process tool
+func (h *MCPHandler) handleStopProcess(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	args, hashedDir, errResp := h.processArguments(ctx, id, argsJSON)
+	if errResp != nil {
+		return *errResp
+	}
+	if args.Name == "" {
+		return NewErrorResponse(id, InvalidParams, "name is required", nil)
+	}
+
+	log.Printf("[MCP] stop_process: conversationId=%s, name=%s", args.ConversationID, args.Name)
+	process, err := h.processes.Stop(ctx, hashedDir, args.Name, tailLines(args.TailLines), h.signer.PreviewURL(hashedDir))
+	if err != nil {
+		log.Printf("[MCP] Failed to stop process: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Failed to stop process", err.Error())
+	}
+	return h.wrapToolResult(id, ProcessesResult{
+		Success:   true,
+		Processes: []processes.Process{process},
+	})
+}
+
+// handleListProcesses implements the list_processes tool
+func (h *MCPHandler) handleListProcesses(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	args, hashedDir, errResp := h.processArguments(ctx, id, argsJSON)
+	if errResp != nil {
+		return *errResp
+	}
+
+	list, err := h.processes.List(ctx, hashedDir, tailLines(args.TailLines), h.signer.PreviewURL(hashedDir))
+	if err != nil {
+		log.Printf("[MCP] Failed to list processes: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to list processes", err.Error())
+	}
+	if list == nil {
+		list = []processes.Process{}
+	}
+	return h.wrapToolResult(id, ProcessesResult{
+		Success:   true,
+		Processes: list,
+	})
+}
+
+// processArguments parses and validates stop_process and list_processes
+// arguments, returning the sandbox's hashed directory
+func (h *MCPHandler) processArguments(ctx context.Context, id interface{}, argsJSON json.RawMessage) (ProcessArguments, string, *JSONRPCResponse) {
+	var args ProcessArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		resp := NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+		return args, "", &resp
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		resp := NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+		return args, "", &resp
+	}
+	if !h.processes.Enabled() {
+		resp := NewErrorResponse(id, InvalidParams, "Background processes are disabled on this server (MAX_PROCESSES_PER_CONVERSATION=0)", nil)
+		return args, "", &resp
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to ensure sandbox directory: %v", err)
+		resp := NewErrorResponse(id, InternalError, "Failed to create sandbox directory", err.Error())
+		return args, "", &resp
+	}
+	return args, hashedDir, nil
+}
+
+// tailLines is the number of output lines to return, defaulting when unset
+func tailLines(requested *int) int {
+	if requested == nil {
+		return processes.DefaultTailLines
+	}
+	return *requested
+}
+
+// processesDescription summarizes the process limits for tool descriptions
+func (h *MCPHandler) processesDescription() string {
+	if !h.processes.Enabled() {
+		return " Background processes are disabled on this server."
+	}
+	lifetime, idle, max := h.processes.Limits()
+	return fmt.Sprintf(" Up to %d per conversation; each is removed after %s, or after %s without a process tool call or preview request.", max, lifetime, idle)
+}
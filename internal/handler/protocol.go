@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jsc/mcp-code-sandbox/internal/session"
+)
+
+// ProtocolVersionHeader carries the negotiated protocol version on requests
+// after initialize (MCP 2025-06-18)
+const ProtocolVersionHeader = "MCP-Protocol-Version"
+
+// Protocol versions the server speaks, newest first
+const (
+	protocolVersion20250618 = "2025-06-18"
+	protocolVersion20250326 = "2025-03-26"
+	protocolVersion20241105 = "2024-11-05"
+)
+
+var supportedProtocolVersions = []string{
+	protocolVersion20250618,
+	protocolVersion20250326,
+	protocolVersion20241105,
+}
+
+// defaultProtocolVersion is assumed when a request carries neither a
+// session with a negotiated version nor a version header, as the spec says
+const defaultProtocolVersion = protocolVersion20250326
+
+// InitializeParams represents the params of an initialize request
+type InitializeParams struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	ClientInfo      struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"clientInfo"`
+}
+
+// negotiateProtocolVersion answers the client's requested version with that
+// version if supported, otherwise the latest one the server speaks
+func negotiateProtocolVersion(requested string) string {
+	if supportedProtocolVersion(requested) {
+		return requested
+	}
+	return supportedProtocolVersions[0]
+}
+
+// supportedProtocolVersion reports whether the server speaks version
+func supportedProtocolVersion(version string) bool {
+	return slices.Contains(supportedProtocolVersions, version)
+}
+
+// protocolVersion returns the protocol version negotiated for the request's
+// session
+func protocolVersion(ctx context.Context) string {
+	if sess, ok := session.FromContext(ctx); ok && sess != nil {
+		if version := sess.ProtocolVersion(); version != "" {
+			return version
+		}
+	}
+	return defaultProtocolVersion
+}
+
+// Versions are dates, so they compare as strings
+func structuredOutputSupported(version string) bool { return version >= protocolVersion20250618 }
+func toolAnnotationsSupported(version string) bool  { return version >= protocolVersion20250326 }
+
+// adaptToolsList drops tool fields the negotiated version doesn't define:
+// outputSchema (2025-06-18) and annotations (2025-03-26)
+func adaptToolsList(ctx context.Context, tools []map[string]interface{}) {
+	version := protocolVersion(ctx)
+	for _, tool := range tools {
+		if !structuredOutputSupported(version) {
+			delete(tool, "outputSchema")
+		}
+		if !toolAnnotationsSupported(version) {
+			delete(tool, "annotations")
+		}
+	}
+}
+
+// adaptToolResult drops structuredContent from a tools/call result for
+// versions before 2025-06-18; the text block carries the same data
+func adaptToolResult(ctx context.Context, resp JSONRPCResponse) JSONRPCResponse {
+	if structuredOutputSupported(protocolVersion(ctx)) {
+		return resp
+	}
+	if result, ok := resp.Result.(ToolResult); ok {
+		result.StructuredContent = nil
+		resp.Result = result
+	}
+	return resp
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// handleReload reapplies the configuration, like SIGHUP: POST /admin/reload.
+// Invalid configuration is reported and leaves the running settings alone
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.reload == nil {
+		http.Error(w, "Reload not supported", http.StatusNotImplemented)
+		return
+	}
+
+	log.Printf("[HTTP] Configuration reload requested by %s", r.RemoteAddr)
+	if err := s.reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]bool{"reloaded": true}); err != nil {
+		log.Printf("[HTTP] Failed to write reload response: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"context"
+	"log"
+)
+
+// stageConversation brings a conversation's sandbox up to date with object
+// storage, if configured, and returns a function that copies changes back.
+// Call it before the tenant check, which reads the conversation's metadata
+func (h *MCPHandler) stageConversation(ctx context.Context, id interface{}, conversationID string) (func(), *JSONRPCResponse) {
+	if !h.sandbox.RemoteEnabled() || conversationID == "" {
+		return func() {}, nil
+	}
+	if err := h.sandbox.Stage(ctx, conversationID); err != nil {
+		log.Printf("[MCP] Failed to stage sandbox files: %v", err)
+		resp := NewErrorResponse(id, InternalError, "Failed to load sandbox files from storage", err.Error())
+		return nil, &resp
+	}
+	return func() {
+		// Store the results even if the client went away
+		if err := h.sandbox.Sync(context.WithoutCancel(ctx), conversationID); err != nil {
+			log.Printf("[MCP] Failed to store sandbox files: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+)
+
+// browserLanguage is the runner language serving render_page
+const browserLanguage = "browser"
+
+// renderScript drives headless Chromium via Playwright. Options arrive as
+// JSON in RENDER_OPTIONS so no user input is interpolated into the code.
+// Chromium's own sandbox is disabled: the container is the sandbox.
+const renderScript = `import json, os
+from urllib.parse import quote
+from playwright.sync_api import sync_playwright
+
+opts = json.loads(os.environ["RENDER_OPTIONS"])
+with sync_playwright() as p:
+    browser = p.chromium.launch(args=["--no-sandbox"])
+    page = browser.new_page(viewport={"width": opts["width"], "height": opts["height"]})
+    page.goto("file:///data/" + quote(opts["source"]))
+    page.wait_for_load_state("networkidle")
+    if opts["format"] == "pdf":
+        page.pdf(path=opts["output"], print_background=True)
+    else:
+        page.screenshot(path=opts["output"], full_page=opts["fullPage"])
+    browser.close()
+print(opts["output"])
+`
+
+// RenderPageArguments represents arguments for render_page
+type RenderPageArguments struct {
+	ConversationID string `json:"conversationId"`
+	Filename       string `json:"filename"`
+	Format         string `json:"format,omitempty"`   // png (default) or pdf
+	Output         string `json:"output,omitempty"`   // Defaults to filename with the format's extension
+	Width          int    `json:"width,omitempty"`    // Viewport width, default 1280
+	Height         int    `json:"height,omitempty"`   // Viewport height, default 800
+	FullPage       *bool  `json:"fullPage,omitempty"` // Screenshot the full scrollable page, default true
+}
+
+// RenderPageResult represents the result of render_page
+type RenderPageResult struct {
+	Success bool            `json:"success"`
+	File    *FileDescriptor `json:"file,omitempty"`
+	Stderr  string          `json:"stderr,omitempty"`
+	Error   *ToolError      `json:"error,omitempty"`
+}
+
+// handleRenderPage implements the render_page tool
+func (h *MCPHandler) handleRenderPage(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args RenderPageArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if !isPlainFilename(args.Filename) {
+		return NewErrorResponse(id, InvalidParams, "filename must be a file name in /data", nil)
+	}
+	if args.Format == "" {
+		args.Format = "png"
+	}
+	if args.Format != "png" && args.Format != "pdf" {
+		return NewErrorResponse(id, InvalidParams, "format must be png or pdf", nil)
+	}
+	if args.Output == "" {
+		args.Output = strings.TrimSuffix(args.Filename, filepath.Ext(args.Filename)) + "." + args.Format
+	}
+	if !isPlainFilename(args.Output) {
+		return NewErrorResponse(id, InvalidParams, "output must be a file name in /data", nil)
+	}
+	if args.Width <= 0 {
+		args.Width = 1280
+	}
+	if args.Height <= 0 {
+		args.Height = 800
+	}
+	fullPage := true
+	if args.FullPage != nil {
+		fullPage = *args.FullPage
+	}
+
+	log.Printf("[MCP] render_page: conversationId=%s, filename=%s, format=%s, output=%s",
+		args.ConversationID, args.Filename, args.Format, args.Output)
+
+	runnerInfo, ok := h.registry.GetRunner(browserLanguage, "")
+	if !ok {
+		return h.wrapToolResult(id, RenderPageResult{
+			Success: false,
+			Stderr:  h.messages.Format(messages.NoBrowserRunner, nil),
+		})
+	}
+
+	if _, err := h.sandbox.ReadFile(args.ConversationID, args.Filename); err != nil {
+		return h.wrapToolResult(id, RenderPageResult{
+			Success: false,
+			Stderr:  fmt.Sprintf("Cannot read %s: %v", args.Filename, err),
+		})
+	}
+
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return h.wrapToolResult(id, RenderPageResult{
+			Success: false,
+			Stderr:  toolErr.Message,
+			Error:   toolErr,
+		})
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		return h.wrapToolResult(id, RenderPageResult{
+			Success: false,
+			Stderr:  fmt.Sprintf("Failed to create sandbox: %v", err),
+		})
+	}
+
+	options, _ := json.Marshal(map[string]interface{}{
+		"source":   args.Filename,
+		"output":   args.Output,
+		"format":   args.Format,
+		"width":    args.Width,
+		"height":   args.Height,
+		"fullPage": fullPage,
+	})
+	env := map[string]string{"RENDER_OPTIONS": string(options)}
+
+	execResult := h.executor.Execute(ctx, runnerInfo, h.sandbox.GetSandboxHostPath(args.ConversationID), h.sandbox.User(args.ConversationID), renderScript, false, env)
+	if !execResult.Success {
+		log.Printf("[MCP] render_page failed: exitCode=%d", execResult.ExitCode)
+		return h.wrapToolResult(id, RenderPageResult{
+			Success: false,
+			Stderr:  execResult.Stderr,
+		})
+	}
+
+	log.Printf("[MCP] render_page completed: %s", args.Output)
+	return h.wrapToolResult(id, RenderPageResult{
+		Success: true,
+		File: &FileDescriptor{
+			Name: args.Output,
+			URL:  fmt.Sprintf("%s/%s", h.signer.FileBaseURL(hashedDir), url.PathEscape(args.Output)),
+		},
+	})
+}
+
+// isPlainFilename reports whether name is a single path element
+func isPlainFilename(name string) bool {
+	return name != "" && name != "." && name != ".." && name == filepath.Base(name) && !strings.Contains(name, "\\")
+}
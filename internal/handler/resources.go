@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jsc/mcp-code-sandbox/internal/sealed"
+)
+
+// resourceScheme is the URI scheme for sandbox files: sandbox://{conversationId}/{filename}
+const resourceScheme = "sandbox"
+
+// maxResourceSize caps how much of a file resources/read will return inline
+const maxResourceSize = 10 * 1024 * 1024 // 10MB
+
+// handleResourcesList lists the files in a conversation's sandbox as resources
+func (h *MCPHandler) handleResourcesList(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	var params ResourcesListParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			log.Printf("[MCP] Failed to parse resources/list params: %v", err)
+			return NewErrorResponse(req.ID, InvalidParams, "Invalid params", err.Error())
+		}
+	}
+
+	params.ConversationID = defaultConversationID(ctx, params.ConversationID)
+	if errResp := h.tenantError(ctx, req.ID, params.ConversationID); errResp != nil {
+		return *errResp
+	}
+	syncFiles, errResp := h.stageConversation(ctx, req.ID, params.ConversationID)
+	if errResp != nil {
+		return *errResp
+	}
+	defer syncFiles()
+
+	// Without a conversation there is nothing to enumerate; clients can use
+	// the resource template to address files directly
+	resources := []Resource{}
+	if params.ConversationID != "" {
+		files, err := h.sandbox.ListFiles(params.ConversationID)
+		if err != nil {
+			log.Printf("[MCP] Failed to list sandbox files: %v", err)
+			return NewErrorResponse(req.ID, InternalError, "Failed to list resources", err.Error())
+		}
+		for _, name := range files {
+			resources = append(resources, Resource{
+				URI:      makeResourceURI(params.ConversationID, name),
+				Name:     name,
+				MimeType: typeByExtension(name),
+			})
+		}
+	}
+
+	log.Printf("[MCP] Returning %d resources for conversation %s", len(resources), params.ConversationID)
+	return NewSuccessResponse(req.ID, map[string]interface{}{
+		"resources": resources,
+	})
+}
+
+// handleResourceTemplatesList advertises the sandbox file URI template
+func (h *MCPHandler) handleResourceTemplatesList(req JSONRPCRequest) JSONRPCResponse {
+	templates := []ResourceTemplate{
+		{
+			URITemplate: resourceScheme + "://{conversationId}/{filename}",
+			Name:        "Sandbox file",
+			Description: "A file in a conversation's sandbox /data directory",
+		},
+	}
+	return NewSuccessResponse(req.ID, map[string]interface{}{
+		"resourceTemplates": templates,
+	})
+}
+
+// handleResourcesRead returns the contents of a sandbox file
+func (h *MCPHandler) handleResourcesRead(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	var params ResourcesReadParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		log.Printf("[MCP] Failed to parse resources/read params: %v", err)
+		return NewErrorResponse(req.ID, InvalidParams, "Invalid params", err.Error())
+	}
+
+	conversationID, filename, err := parseResourceURI(params.URI)
+	if err != nil {
+		log.Printf("[MCP] Invalid resource URI %q: %v", params.URI, err)
+		return NewErrorResponse(req.ID, InvalidParams, "Invalid resource URI", err.Error())
+	}
+	if errResp := h.tenantError(ctx, req.ID, conversationID); errResp != nil {
+		return *errResp
+	}
+	syncFiles, errResp := h.stageConversation(ctx, req.ID, conversationID)
+	if errResp != nil {
+		return *errResp
+	}
+	defer syncFiles()
+
+	data, err := h.sandbox.ReadFile(conversationID, filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewErrorResponse(req.ID, ResourceNotFound, "Resource not found", map[string]string{"uri": params.URI})
+		}
+		log.Printf("[MCP] Failed to read resource %s: %v", params.URI, err)
+		return NewErrorResponse(req.ID, InternalError, "Failed to read resource", err.Error())
+	}
+	if len(data) > maxResourceSize {
+		return NewErrorResponse(req.ID, InvalidParams,
+			fmt.Sprintf("Resource exceeds %d bytes; download it via its file URL instead", maxResourceSize), nil)
+	}
+
+	mimeType := detectContentType(filename, data)
+
+	contents := ResourceContents{
+		URI:      params.URI,
+		MimeType: mimeType,
+	}
+	if key := h.sandbox.ConversationResultKey(conversationID); key != nil {
+		if data, err = sealed.Seal(key, data); err != nil {
+			log.Printf("[MCP] Failed to seal resource %s: %v", params.URI, err)
+			return NewErrorResponse(req.ID, InternalError, "Failed to seal resource", err.Error())
+		}
+		contents.MimeType = "application/octet-stream"
+		contents.Blob = base64.StdEncoding.EncodeToString(data)
+	} else if isTextMimeType(mimeType) && utf8.Valid(data) {
+		contents.Text = string(data)
+	} else {
+		contents.Blob = base64.StdEncoding.EncodeToString(data)
+	}
+
+	log.Printf("[MCP] Read resource %s (%d bytes, %s)", params.URI, len(data), mimeType)
+	return NewSuccessResponse(req.ID, map[string]interface{}{
+		"contents": []ResourceContents{contents},
+	})
+}
+
+// makeResourceURI builds the resource URI for a sandbox file
+func makeResourceURI(conversationID, filename string) string {
+	return fmt.Sprintf("%s://%s/%s", resourceScheme, url.PathEscape(conversationID), url.PathEscape(filename))
+}
+
+// parseResourceURI extracts the conversation ID and filename from a resource URI
+func parseResourceURI(uri string) (string, string, error) {
+	prefix := resourceScheme + "://"
+	if !strings.HasPrefix(uri, prefix) {
+		return "", "", fmt.Errorf("expected %s URI", prefix)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(uri, prefix), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected %s{conversationId}/{filename}", prefix)
+	}
+
+	conversationID, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid conversation ID: %w", err)
+	}
+	filename, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid filename: %w", err)
+	}
+	return conversationID, filename, nil
+}
+
+// isTextMimeType reports whether a MIME type should be returned as text
+func isTextMimeType(mimeType string) bool {
+	base, _, _ := strings.Cut(mimeType, ";")
+	switch {
+	case strings.HasPrefix(base, "text/"):
+		return true
+	case base == "application/json", base == "application/xml",
+		base == "application/javascript", base == "image/svg+xml",
+		base == "application/x-ndjson", base == "application/yaml",
+		strings.HasSuffix(base, "+json"): // Notebooks, GeoJSON
+		return true
+	}
+	return false
+}
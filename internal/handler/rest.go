@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
+)
+
+// The REST API offers run_code, upload_file, download links and the sandbox file list to
+// scripts and CI systems that don't speak JSON-RPC. Requests go through the
+// same tool handlers as MCP, so scopes, tenants and limits apply alike
+
+// handleAPIRun runs code: POST /api/run with run_code's arguments as the
+// JSON body. The response is run_code's result
+func (s *Server) handleAPIRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.requestMaxBytes))
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	s.writeToolResponse(w, s.callTool(r, "run_code", body))
+}
+
+// handleAPIUpload stores a file: POST /api/upload as multipart/form-data
+// with a "file" part and "conversationId" (and optionally "filename",
+// "extract" and "template") fields. The response is upload_file's result
+func (s *Server) handleAPIUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.uploadBodyLimit())
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyError(w, err)
+			return
+		}
+		writeAPIError(w, http.StatusBadRequest, "Expected multipart/form-data with a file part", nil)
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	args := UploadFileArguments{
+		ConversationID: r.FormValue("conversationId"),
+		Filename:       r.FormValue("filename"),
+		Content:        base64.StdEncoding.EncodeToString(content),
+		Template:       r.FormValue("template"),
+	}
+	if args.Filename == "" {
+		args.Filename = header.Filename
+	}
+	if !filepath.IsLocal(args.Filename) {
+		writeAPIError(w, http.StatusBadRequest, "filename must be a relative path inside the sandbox", nil)
+		return
+	}
+	if v := r.FormValue("extract"); v != "" {
+		if args.Extract, err = strconv.ParseBool(v); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "Invalid extract", nil)
+			return
+		}
+	}
+	argsJSON, _ := json.Marshal(args)
+	s.writeToolResponse(w, s.callTool(r, "upload_file", argsJSON))
+}
+
+// handleFileUpload stores files dropped on the web UI: POST
+// /files/{conversationId} as multipart/form-data with one or more file parts
+// (any field name) and optionally a "template" field. Files keep their base
+// names and are written all or nothing. The response is upload_files's result
+func (s *Server) handleFileUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.uploadBodyLimit())
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Expected multipart/form-data", nil)
+		return
+	}
+
+	args := UploadFilesArguments{ConversationID: r.PathValue("conversationId")}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		content, err := io.ReadAll(part)
+		part.Close()
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		switch {
+		case part.FileName() != "":
+			args.Files = append(args.Files, UploadEntry{
+				Filename: part.FileName(),
+				Content:  base64.StdEncoding.EncodeToString(content),
+			})
+		case part.FormName() == "template":
+			args.Template = string(content)
+		}
+	}
+	if len(args.Files) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "Expected at least one file part", nil)
+		return
+	}
+	argsJSON, _ := json.Marshal(args)
+	s.writeToolResponse(w, s.callTool(r, "upload_files", argsJSON))
+}
+
+// handleAPIFiles lists a conversation's sandbox files with their download
+// URLs: GET /api/files?conversationId=...
+func (s *Server) handleAPIFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conversationID := r.URL.Query().Get("conversationId")
+	if conversationID == "" {
+		writeAPIError(w, http.StatusBadRequest, "conversationId is required", nil)
+		return
+	}
+	if !auth.Allowed(r.Context(), auth.ScopeReadFiles) {
+		writeRPCError(w, scopeError(r.Context(), nil, "/api/files", auth.ScopeReadFiles).Error)
+		return
+	}
+	if errResp := s.mcpHandler.tenantError(r.Context(), nil, conversationID); errResp != nil {
+		writeRPCError(w, errResp.Error)
+		return
+	}
+	syncFiles, errResp := s.mcpHandler.stageConversation(r.Context(), nil, conversationID)
+	if errResp != nil {
+		writeRPCError(w, errResp.Error)
+		return
+	}
+	defer syncFiles()
+
+	hashedDir, err := s.sandbox.EnsureSandboxDir(conversationID)
+	if err != nil {
+		log.Printf("[HTTP] Failed to get sandbox directory: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	files := s.mcpHandler.listFileDescriptors(conversationID, hashedDir)
+	if files == nil {
+		files = []FileDescriptor{}
+	}
+	writeAPIJSON(w, http.StatusOK, map[string]interface{}{"files": files})
+}
+
+// handleAPILinks creates a download link: POST /api/links with
+// create_download_link's arguments as the JSON body
+func (s *Server) handleAPILinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.requestMaxBytes))
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	s.writeToolResponse(w, s.callTool(r, "create_download_link", body))
+}
+
+// handleAPIRevokeLinks revokes a conversation's share and download links:
+// POST /api/links/revoke with {"conversationId": "..."}
+func (s *Server) handleAPIRevokeLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.requestMaxBytes))
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	s.writeToolResponse(w, s.callTool(r, "revoke_links", body))
+}
+
+// callTool calls an MCP tool on behalf of a REST request
+func (s *Server) callTool(r *http.Request, name string, arguments json.RawMessage) JSONRPCResponse {
+	log.Printf("[HTTP] REST %s from %s", name, r.RemoteAddr)
+	return s.mcpHandler.callTool(r.Context(), name, arguments)
+}
+
+// writeToolResponse writes a tool's structured result as the response
+// body. Protocol errors map to HTTP statuses, and so do results of code
+// that couldn't be run at all
+func (s *Server) writeToolResponse(w http.ResponseWriter, resp JSONRPCResponse) {
+	if resp.Error != nil {
+		writeRPCError(w, resp.Error)
+		return
+	}
+
+	result, ok := resp.Result.(ToolResult)
+	if !ok {
+		writeAPIJSON(w, http.StatusOK, resp.Result)
+		return
+	}
+	status := http.StatusOK
+	if run, ok := result.StructuredContent.(RunCodeResult); ok && run.Error != nil {
+		switch run.Error.Code {
+		case ErrExecutionQueueFull:
+			status = http.StatusTooManyRequests
+		case ErrInsufficientDiskSpace:
+			status = http.StatusInsufficientStorage
+		default:
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeAPIJSON(w, status, result.StructuredContent)
+}
+
+// writeRPCError writes a JSON-RPC error with the matching HTTP status
+func writeRPCError(w http.ResponseWriter, rpcErr *RPCError) {
+	status := http.StatusInternalServerError
+	switch rpcErr.Code {
+	case InvalidParams, InvalidRequest:
+		status = http.StatusBadRequest
+	case Forbidden:
+		status = http.StatusForbidden
+	case MethodNotFound, ResourceNotFound:
+		status = http.StatusNotFound
+	}
+	writeAPIError(w, status, rpcErr.Message, rpcErr.Data)
+}
+
+// multipartOverhead is allowed on top of uploadMaxBytes for multipart
+// boundaries, part headers and form fields
+const multipartOverhead = 1 << 20
+
+// uploadBodyLimit returns the body limit for multipart uploads: the upload
+// file size limit plus framing, or the request limit when uploads are unbounded
+func (s *Server) uploadBodyLimit() int64 {
+	if s.uploadMaxBytes <= 0 {
+		return s.requestMaxBytes
+	}
+	return s.uploadMaxBytes + multipartOverhead
+}
+
+// writeBodyError reports a request body that couldn't be read
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "Request body too large", nil)
+		return
+	}
+	writeAPIError(w, http.StatusBadRequest, "Failed to read request", nil)
+}
+
+// writeAPIError writes a REST API error: {"error": message, "data": ...}
+func writeAPIError(w http.ResponseWriter, status int, message string, data interface{}) {
+	body := map[string]interface{}{"error": message}
+	if data != nil {
+		body["data"] = data
+	}
+	writeAPIJSON(w, status, body)
+}
+
+// writeAPIJSON writes a REST API response
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[HTTP] Failed to write API response: %v", err)
+	}
+}
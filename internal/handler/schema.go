@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+)
+
+// languageProperty builds the inputSchema property for a language argument
+// from the live runners, with per-language hints. The enum is left out when
+// no runners are available, since an empty enum would reject every value
+func languageProperty(caps []runner.LanguageCapabilities, purpose string, installs bool) map[string]interface{} {
+	property := map[string]interface{}{"type": "string"}
+	if len(caps) == 0 {
+		property["description"] = purpose + ". No runners are currently available"
+		return property
+	}
+
+	languages := make([]string, 0, len(caps))
+	hints := make([]string, 0, len(caps))
+	for _, c := range caps {
+		languages = append(languages, c.Language)
+		var notes []string
+		if len(c.Versions) > 0 {
+			notes = append(notes, "versions "+strings.Join(c.Versions, ", "))
+		}
+		if installs && c.Install {
+			notes = append(notes, "packages installable")
+		}
+		if len(notes) == 0 {
+			hints = append(hints, c.Language)
+		} else {
+			hints = append(hints, fmt.Sprintf("%s (%s)", c.Language, strings.Join(notes, "; ")))
+		}
+	}
+	property["enum"] = languages
+	property["description"] = fmt.Sprintf("%s. Available: %s", purpose, strings.Join(hints, ", "))
+	return property
+}
+
+// versionProperty builds the inputSchema property for a version argument,
+// listing each language's versions and its default
+func versionProperty(caps []runner.LanguageCapabilities) map[string]interface{} {
+	property := map[string]interface{}{"type": "string"}
+
+	var versions, hints []string
+	seen := make(map[string]bool)
+	for _, c := range caps {
+		if len(c.Versions) == 0 {
+			continue
+		}
+		hints = append(hints, fmt.Sprintf("%s: %s (default %s)", c.Language, strings.Join(c.Versions, ", "), c.DefaultVersion))
+		for _, v := range c.Versions {
+			if !seen[v] {
+				seen[v] = true
+				versions = append(versions, v)
+			}
+		}
+	}
+	if len(versions) == 0 {
+		property["description"] = "Runner version (optional); no versioned runners are currently available"
+		return property
+	}
+	property["enum"] = versions
+	property["description"] = "Runner version (optional, defaults to the language's default). " + strings.Join(hints, "; ")
+	return property
+}
+
+// networkProperty builds the inputSchema property for a network argument,
+// offering "restricted" only when the egress proxy is configured
+func (h *MCPHandler) networkProperty(description string) map[string]interface{} {
+	domains := h.executor.EgressDomains()
+	if len(domains) == 0 {
+		return map[string]interface{}{
+			"type":        "boolean",
+			"description": description,
+		}
+	}
+	return map[string]interface{}{
+		"type":        []string{"boolean", "string"},
+		"enum":        []interface{}{false, true, NetworkRestricted},
+		"description": fmt.Sprintf("%s. Use %q to allow only these domains, over HTTP(S) through a proxy: %s", description, NetworkRestricted, strings.Join(domains, ", ")),
+	}
+}
+
+// addTemplate offers the template argument on a tool when the server has
+// sandbox templates
+func addTemplate(tool map[string]interface{}, templateNames []string) {
+	if len(templateNames) == 0 {
+		return
+	}
+	properties := tool["inputSchema"].(map[string]interface{})["properties"].(map[string]interface{})
+	properties["template"] = map[string]interface{}{
+		"type":        "string",
+		"enum":        templateNames,
+		"description": "Start a new conversation's sandbox from this template (see create_from_template) before anything else happens. Ignored once the sandbox has files, so it is safe to pass on every call",
+	}
+}
+
+// addPreviewPort offers the previewPort argument on a tool when the server
+// proxies web app previews
+func (h *MCPHandler) addPreviewPort(tool map[string]interface{}) {
+	if !h.previews.Enabled() {
+		return
+	}
+	properties := tool["inputSchema"].(map[string]interface{})["properties"].(map[string]interface{})
+	properties["previewPort"] = map[string]interface{}{
+		"type":        "integer",
+		"minimum":     1,
+		"maximum":     65535,
+		"description": "Port a web server in the container listens on (on 0.0.0.0), to preview it in a browser while it runs. Requires network: true. The preview URL is in PREVIEW_URL; use relative links, as the app is served under a path prefix",
+	}
+}
+
+// addGPU offers the gpu argument when the server hands out GPUs
+func (h *MCPHandler) addGPU(tool map[string]interface{}) {
+	if !h.executor.GPUEnabled() {
+		return
+	}
+	properties := tool["inputSchema"].(map[string]interface{})["properties"].(map[string]interface{})
+	properties["gpu"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Give the code the host's NVIDIA GPUs, e.g. for torch or tensorflow. Only runners built for it support this; see list_runners (default: false)",
+	}
+}
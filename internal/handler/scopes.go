@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"log"
+
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
+)
+
+// scopeFreeTools don't touch a sandbox, so every token may call them
+var scopeFreeTools = map[string]bool{
+	"list_runners":    true,
+	"describe_runner": true,
+}
+
+// toolScopes are the scopes the other tools require. Tools in neither map
+// are refused, so a new tool can't be called until it is given a scope
+var toolScopes = map[string]auth.Scope{
+	"run_code":             auth.ScopeExecute,
+	"run_shell":            auth.ScopeExecute,
+	"run_pipeline":         auth.ScopeExecute,
+	"run_tests":            auth.ScopeExecute,
+	"lint_and_format":      auth.ScopeExecute,
+	"install_package":      auth.ScopeExecute,
+	"set_environment":      auth.ScopeExecute,
+	"start_service":        auth.ScopeExecute,
+	"stop_service":         auth.ScopeExecute,
+	"start_process":        auth.ScopeExecute,
+	"stop_process":         auth.ScopeExecute,
+	"render_page":          auth.ScopeExecute,
+	"create_from_template": auth.ScopeExecute,
+	"snapshot_sandbox":     auth.ScopeExecute,
+	"restore_sandbox":      auth.ScopeExecute,
+
+	"upload_file":        auth.ScopeUpload,
+	"upload_files":       auth.ScopeUpload,
+	"create_ingest_link": auth.ScopeUpload,
+	"fetch_file":         auth.ScopeUpload,
+	"clone_repo":         auth.ScopeUpload,
+
+	"get_environment":       auth.ScopeReadFiles,
+	"read_output":           auth.ScopeReadFiles,
+	"list_services":         auth.ScopeReadFiles,
+	"list_processes":        auth.ScopeReadFiles,
+	"get_execution_history": auth.ScopeReadFiles,
+	"share_conversation":    auth.ScopeReadFiles,
+	"create_download_link":  auth.ScopeReadFiles,
+	"revoke_links":          auth.ScopeReadFiles,
+	"set_conversation_name": auth.ScopeReadFiles,
+	"set_result_key":        auth.ScopeReadFiles,
+}
+
+// toolAllowed reports whether the request's token may call a tool
+func toolAllowed(ctx context.Context, tool string) bool {
+	if scopeFreeTools[tool] {
+		return true
+	}
+	scope, ok := toolScopes[tool]
+	return ok && auth.Allowed(ctx, scope)
+}
+
+// scopeError is the response to a call the token lacks the scope for
+func scopeError(ctx context.Context, id interface{}, what string, scope auth.Scope) JSONRPCResponse {
+	identity, _ := auth.FromContext(ctx)
+	log.Printf("[MCP] Token %q lacks the %s scope for %s", identity.Name, scope, what)
+	return NewErrorResponse(id, Forbidden, "Token lacks the "+string(scope)+" scope for "+what, nil)
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdh"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+	"github.com/jsc/mcp-code-sandbox/internal/sealed"
+)
+
+// SealedScheme names the encryption used for sealed results and downloads
+const SealedScheme = "x25519-hkdf-sha256-aes256gcm"
+
+// sealedPlaceholder replaces sealed output in history and logs
+const sealedPlaceholder = "[sealed]"
+
+// SetResultKeyArguments represents arguments for set_result_key
+type SetResultKeyArguments struct {
+	ConversationID string `json:"conversationId"`
+	PublicKey      string `json:"publicKey"` // Base64 X25519 public key; empty turns sealing off
+}
+
+// SetResultKeyResult represents the result of set_result_key
+type SetResultKeyResult struct {
+	Success bool   `json:"success"`
+	Sealed  bool   `json:"sealed"`
+	Scheme  string `json:"scheme,omitempty"`
+}
+
+// SealedOutput is the plaintext of RunCodeResult.Sealed
+type SealedOutput struct {
+	Stdout        string            `json:"stdout"`
+	Stderr        string            `json:"stderr,omitempty"`
+	Log           []runner.LogEntry `json:"log,omitempty"`
+	CompileOutput string            `json:"compileOutput,omitempty"`
+	Display       []runner.Display  `json:"display,omitempty"`
+}
+
+// handleSetResultKey implements the set_result_key tool
+func (h *MCPHandler) handleSetResultKey(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args SetResultKeyArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+
+	if err := h.sandbox.SetResultKey(args.ConversationID, args.PublicKey); err != nil {
+		log.Printf("[MCP] Failed to set result key: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Failed to set result key", err.Error())
+	}
+
+	result := SetResultKeyResult{Success: true}
+	if h.sandbox.ConversationResultKey(args.ConversationID) != nil {
+		result.Sealed = true
+		result.Scheme = SealedScheme
+	}
+	log.Printf("[MCP] set_result_key: conversationId=%s, sealed=%v", args.ConversationID, result.Sealed)
+	return h.wrapToolResult(id, result)
+}
+
+// sealResult moves a result's output into its Sealed field, encrypted to key
+func sealResult(key *ecdh.PublicKey, result *RunCodeResult) error {
+	plaintext, err := json.Marshal(SealedOutput{
+		Stdout:        result.Stdout,
+		Stderr:        result.Stderr,
+		Log:           result.Log,
+		CompileOutput: result.CompileOutput,
+		Display:       result.Display,
+	})
+	if err != nil {
+		return err
+	}
+	result.Sealed, err = sealed.SealString(key, plaintext)
+	if err != nil {
+		return err
+	}
+	result.Stdout = ""
+	result.Stderr = ""
+	result.Log = nil
+	result.LogTruncated = false
+	result.CompileOutput = ""
+	result.Display = nil
+	return nil
+}
+
+// fileETag derives a strong validator from a file's size and modification
+// time, which changes whenever code rewrites the file
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// serveSandboxFile serves a file from the sandbox in hashedDir, sealed to the
+// conversation's result key if it has one. The media type comes from the
+// extension or the content; "?download=1" makes browsers save even images
+// and HTML rather than show them. Unsealed files support HEAD, byte ranges
+// and conditional requests on their ETag and modification time. Only regular
+// files inside the sandbox are served
+func (s *Server) serveSandboxFile(w http.ResponseWriter, r *http.Request, hashedDir, filename string) {
+	f, info, err := s.sandbox.OpenFile(hashedDir, filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[HTTP] Failed to open file: %v", err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	download, _ := strconv.ParseBool(r.URL.Query().Get("download"))
+	key := s.sandbox.ResultKey(hashedDir)
+	if key == nil {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(f, head)
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			log.Printf("[HTTP] Failed to read file: %v", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		contentType := detectContentType(filename, head[:n])
+		if s.reports != nil && !download && strings.HasPrefix(contentType, "text/html") {
+			// Render as an interactive report rather than static HTML
+			w.Header().Set("Content-Security-Policy", s.reports.ContentSecurityPolicy)
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", contentDisposition(filename, contentType, download))
+		// Files change in place, so clients revalidate; unchanged ones get a
+		// 304. Callers may have asked for stricter caching already
+		w.Header().Set("ETag", fileETag(info))
+		if w.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+		return
+	}
+
+	// Sealing needs the whole file in memory, so it gets the same ceiling as
+	// resources/read
+	if err := s.sandbox.CheckFileSize(filename, info.Size()); err != nil {
+		http.Error(w, "File too large to seal", http.StatusRequestEntityTooLarge)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, info.Size()))
+	if err != nil {
+		log.Printf("[HTTP] Failed to read file: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	data, err = sealed.Seal(key, data)
+	if err != nil {
+		log.Printf("[HTTP] Failed to seal file: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", contentDisposition(filename, "application/octet-stream", true))
+	w.Header().Set("X-Content-Sealed", SealedScheme)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
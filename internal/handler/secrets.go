@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
+)
+
+// secretsToken is the token name secrets are checked against. Admin tokens
+// and requests without a token (run-once) may use every secret
+func secretsToken(ctx context.Context) string {
+	identity, ok := auth.FromContext(ctx)
+	if !ok || identity.Has(auth.ScopeAdmin) {
+		return ""
+	}
+	return identity.Name
+}
+
+// addSecrets offers the secrets argument on a tool when the token may use
+// any registered secrets
+func (h *MCPHandler) addSecrets(ctx context.Context, tool map[string]interface{}) {
+	names := h.secrets.Names(secretsToken(ctx))
+	if len(names) == 0 {
+		return
+	}
+	properties := tool["inputSchema"].(map[string]interface{})["properties"].(map[string]interface{})
+	properties["secrets"] = map[string]interface{}{
+		"type":        "array",
+		"description": "Server-held secrets to inject as environment variables, by name. Use these instead of putting API keys in environment; their values are only given to the container, never logged or stored. Available: " + strings.Join(names, ", "),
+		"items": map[string]interface{}{
+			"type": "string",
+			"enum": names,
+		},
+	}
+}
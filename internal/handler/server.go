@@ -1,18 +1,29 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jsc/mcp-code-sandbox/internal/auth"
+	"github.com/jsc/mcp-code-sandbox/internal/bundle"
+	"github.com/jsc/mcp-code-sandbox/internal/events"
 	"github.com/jsc/mcp-code-sandbox/internal/filesign"
+	"github.com/jsc/mcp-code-sandbox/internal/gc"
+	"github.com/jsc/mcp-code-sandbox/internal/history"
+	"github.com/jsc/mcp-code-sandbox/internal/metrics"
 	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+	"github.com/jsc/mcp-code-sandbox/internal/security"
+	"github.com/jsc/mcp-code-sandbox/internal/session"
+	"github.com/jsc/mcp-code-sandbox/internal/tracing"
 )
 
 // Server handles HTTP requests
@@ -20,7 +31,27 @@ type Server struct {
 	mcpHandler *MCPHandler
 	signer     *filesign.Signer
 	sandbox    *sandbox.Manager
-	apiToken   string
+	bundles    *bundle.Store
+	sessions   *session.Store
+	metrics    *metrics.Collector
+	history    *history.Store
+	gc         *gc.Collector
+	retention  time.Duration // Default maxAge for /admin/gc (0 = none)
+	tokens     *auth.TokenStore
+	basePath   string
+	jwt        *auth.JWTVerifier // nil without JWT authentication
+	limiter    *auth.RateLimiter
+	lockout    *auth.Lockout    // nil without lockout or failure delay
+	origins    []string         // Browser origins allowed on /mcp and admin endpoints
+	reload     func() error     // Reapplies the configuration; nil disables /admin/reload
+	keepAlive  time.Duration    // SSE keepalive comment interval (0 disables)
+	events     events.Store     // SSE events kept for Last-Event-ID; nil disables resumption
+	reports    *security.Policy // Policy for HTML files whose scripts may run; nil keeps them disabled
+	inflight   sync.Map         // Session ID and request ID -> cancel, for notifications/cancelled
+
+	ingestMaxBytes  int64 // Body limit for /ingest uploads
+	requestMaxBytes int64 // Body limit for /mcp and the REST API's JSON endpoints
+	uploadMaxBytes  int64 // File size limit for /api/upload and web UI uploads
 }
 
 // NewServer creates a new HTTP server
@@ -28,32 +59,135 @@ func NewServer(
 	mcpHandler *MCPHandler,
 	signer *filesign.Signer,
 	sandbox *sandbox.Manager,
-	apiToken string,
+	bundles *bundle.Store,
+	sessions *session.Store,
+	metrics *metrics.Collector,
+	history *history.Store,
+	gc *gc.Collector,
+	retention time.Duration,
+	tokens *auth.TokenStore,
+	basePath string,
+	ingestMaxBytes int64,
+	requestMaxBytes int64,
+	uploadMaxBytes int64,
+	jwt *auth.JWTVerifier,
+	limiter *auth.RateLimiter,
+	lockout *auth.Lockout,
+	origins []string,
+	reload func() error,
+	keepAlive time.Duration,
+	eventStore events.Store,
+	reports *security.Policy,
 ) *Server {
 	return &Server{
 		mcpHandler: mcpHandler,
 		signer:     signer,
 		sandbox:    sandbox,
-		apiToken:   apiToken,
+		bundles:    bundles,
+		sessions:   sessions,
+		metrics:    metrics,
+		history:    history,
+		gc:         gc,
+		retention:  retention,
+		tokens:     tokens,
+		basePath:   basePath,
+		jwt:        jwt,
+		limiter:    limiter,
+		lockout:    lockout,
+		origins:    origins,
+		reload:     reload,
+		keepAlive:  keepAlive,
+		events:     eventStore,
+		reports:    reports,
+
+		ingestMaxBytes:  ingestMaxBytes,
+		requestMaxBytes: requestMaxBytes,
+		uploadMaxBytes:  uploadMaxBytes,
 	}
 }
 
 // SetupRoutes sets up all HTTP routes
+// When a base path is configured, all routes are mounted beneath it
 func (s *Server) SetupRoutes(mux *http.ServeMux) {
+	routes := mux
+	if s.basePath != "" {
+		routes = http.NewServeMux()
+		mux.Handle(s.basePath+"/", http.StripPrefix(s.basePath, routes))
+	}
+
+	uiHeaders := security.Headers(security.UIPolicy)
+	// Browsers may only call the API from allowed origins (DNS rebinding)
+	originCheck := security.Origins(s.origins)
+	apiHeaders := func(next http.Handler) http.Handler {
+		return security.Headers(security.APIPolicy)(originCheck(next))
+	}
+	fileHeaders := security.Headers(security.FilePolicy)
+
 	// Homepage - web interface for testing
-	mux.HandleFunc("/", s.handleHomepage)
+	routes.Handle("/", uiHeaders(http.HandlerFunc(s.handleHomepage)))
 
 	// MCP endpoint with authentication (supports both POST and GET)
 	// Per MCP spec: single endpoint for HTTP + SSE transport
-	authMW := auth.Middleware(s.apiToken)
-	mux.Handle("/mcp", authMW(http.HandlerFunc(s.handleMCP)))
+	// Tools check their own scopes
+	authMW := auth.Middleware(s.tokens, s.jwt, s.lockout, "")
+	routes.Handle("/mcp", apiHeaders(s.limiter.ByIP(authMW(s.limiter.ByToken(http.HandlerFunc(s.handleMCP))))))
+
+	// Plain REST API over the same tool handlers, for scripts and CI
+	routes.Handle("/api/run", apiHeaders(s.limiter.ByIP(authMW(s.limiter.ByToken(http.HandlerFunc(s.handleAPIRun))))))
+	routes.Handle("/api/upload", apiHeaders(s.limiter.ByIP(authMW(s.limiter.ByToken(http.HandlerFunc(s.handleAPIUpload))))))
+	routes.Handle("/api/files", apiHeaders(s.limiter.ByIP(authMW(s.limiter.ByToken(http.HandlerFunc(s.handleAPIFiles))))))
+	routes.Handle("/api/links", apiHeaders(s.limiter.ByIP(authMW(s.limiter.ByToken(http.HandlerFunc(s.handleAPILinks))))))
+	routes.Handle("/api/links/revoke", apiHeaders(s.limiter.ByIP(authMW(s.limiter.ByToken(http.HandlerFunc(s.handleAPIRevokeLinks))))))
+	// Web UI uploads; GET /files/... below stays the unauthenticated download
+	routes.Handle("POST /files/{conversationId}", apiHeaders(s.limiter.ByIP(authMW(s.limiter.ByToken(http.HandlerFunc(s.handleFileUpload))))))
+
+	// Admin endpoints (bearer token with the admin scope)
+	adminMW := auth.Middleware(s.tokens, s.jwt, s.lockout, auth.ScopeAdmin)
+	routes.Handle("/admin/bundles/", apiHeaders(adminMW(http.HandlerFunc(s.handleBundleDownload))))
+	routes.Handle("/admin/metrics", apiHeaders(adminMW(http.HandlerFunc(s.handleAdminMetrics))))
+	routes.Handle("/admin/gc", apiHeaders(adminMW(http.HandlerFunc(s.handleGC))))
+	routes.Handle("/admin/reload", apiHeaders(adminMW(http.HandlerFunc(s.handleReload))))
+	routes.Handle("/admin/sandboxes", apiHeaders(adminMW(http.HandlerFunc(s.handleAdminSandboxes))))
+	routes.Handle("/admin/sandboxes/", apiHeaders(adminMW(http.HandlerFunc(s.handleAdminSandboxes))))
+	routes.Handle("/admin/executions", apiHeaders(adminMW(http.HandlerFunc(s.handleAdminExecutions))))
+	routes.Handle("/admin/executions/", apiHeaders(adminMW(http.HandlerFunc(s.handleAdminExecutions))))
+	routes.Handle("/admin/runners", apiHeaders(adminMW(http.HandlerFunc(s.handleAdminRunners))))
+	routes.Handle("/admin/runners/", apiHeaders(adminMW(http.HandlerFunc(s.handleAdminRunners))))
+	routes.Handle("/api/executions", apiHeaders(adminMW(http.HandlerFunc(s.handleExecutions))))
+
+	// OpenAPI document for the REST, admin and file endpoints (no auth, it
+	// only describes them)
+	routes.Handle("/openapi.json", apiHeaders(http.HandlerFunc(s.handleOpenAPI)))
+
+	// Prometheus scrape endpoint (configure the scraper with the bearer token)
+	routes.Handle("/metrics", apiHeaders(adminMW(http.HandlerFunc(s.handleMetrics))))
 
 	// File download endpoint (no auth, URLs use hashed directory names for security)
-	mux.HandleFunc("/files/", s.handleFileDownload)
+	routes.Handle("/files/", fileHeaders(s.limiter.ByIP(http.HandlerFunc(s.handleFileDownload))))
+
+	// Markdown and notebooks as HTML (no auth, like /files)
+	routes.Handle("/render/", fileHeaders(s.limiter.ByIP(http.HandlerFunc(s.handleRender))))
+
+	// External uploads (no bearer auth; the encrypted token selects the
+	// conversation and the body must be signed with the link's secret)
+	routes.Handle("/ingest/", security.Headers(security.APIPolicy)(s.limiter.ByIP(http.HandlerFunc(s.handleIngest))))
+
+	// Share links (no auth, the encrypted token grants expiring read-only access)
+	routes.Handle("/share/", fileHeaders(s.limiter.ByIP(http.HandlerFunc(s.handleShare))))
+
+	// Download links (no auth, the encrypted token grants one file until it
+	// expires, is revoked or, for single-use links, is spent)
+	routes.Handle("/download/", fileHeaders(s.limiter.ByIP(http.HandlerFunc(s.handleDownloadLink))))
+
+	// Web app previews (no auth, like /files the hashed directory is the capability)
+	if s.mcpHandler.previews.Enabled() {
+		routes.Handle("/preview/", security.Headers(security.PreviewPolicy)(s.mcpHandler.previews))
+	}
 }
 
 // handleMCP handles MCP requests (HTTP + SSE transport)
-// Per MCP spec: single endpoint supporting POST (JSON-RPC) and GET (SSE resumption)
+// Per MCP spec: single endpoint supporting POST (JSON-RPC), GET (SSE resumption)
+// and DELETE (session termination)
 func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[HTTP] MCP %s request from %s", r.Method, r.RemoteAddr)
 
@@ -62,25 +196,69 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 		s.handleMCPPost(w, r)
 	case http.MethodGet:
 		s.handleMCPGet(w, r)
+	case http.MethodDelete:
+		s.handleMCPDelete(w, r)
 	default:
-		log.Printf("[HTTP] Invalid method: %s (only POST and GET allowed)", r.Method)
+		log.Printf("[HTTP] Invalid method: %s (only POST, GET and DELETE allowed)", r.Method)
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
+// requireSession resolves the Mcp-Session-Id header to an active session
+// Writes 400 if the header is missing and 404 if the session is unknown or
+// expired (which tells the client to re-initialize)
+func (s *Server) requireSession(w http.ResponseWriter, r *http.Request) (*session.Session, bool) {
+	id := r.Header.Get(session.HeaderName)
+	if id == "" {
+		log.Printf("[HTTP] Missing %s header", session.HeaderName)
+		http.Error(w, "Missing "+session.HeaderName+" header; call initialize first", http.StatusBadRequest)
+		return nil, false
+	}
+
+	sess, ok := s.sessions.Get(id)
+	if !ok {
+		log.Printf("[HTTP] Unknown or expired session: %s", id)
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil, false
+	}
+	return sess, true
+}
+
+// handleMCPDelete terminates a session
+func (s *Server) handleMCPDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(session.HeaderName)
+	if id == "" {
+		http.Error(w, "Missing "+session.HeaderName+" header", http.StatusBadRequest)
+		return
+	}
+
+	if !s.sessions.Delete(id) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("[HTTP] Session terminated: %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // handleMCPPost handles POST requests with JSON-RPC messages
 func (s *Server) handleMCPPost(w http.ResponseWriter, r *http.Request) {
 	// Read request body
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.requestMaxBytes))
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		log.Printf("[HTTP] Failed to read request body: %v", err)
 		http.Error(w, "Failed to read request", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	log.Printf("[HTTP] Request body: %s", string(body))
-
+	// Bodies aren't logged: tool arguments carry code, file contents and
+	// set_environment values
 	// Parse JSON-RPC request
 	var req JSONRPCRequest
 	if err := json.Unmarshal(body, &req); err != nil {
@@ -106,18 +284,123 @@ func (s *Server) handleMCPPost(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("[HTTP] Client accepts SSE: %v (Accept: %s)", acceptsSSE, acceptHeader)
 
+	// Notifications get no response, just an acknowledgement
+	if req.IsNotification() {
+		sess, ok := s.requireSession(w, r)
+		if !ok {
+			return
+		}
+		if req.Method == "notifications/cancelled" {
+			s.cancelRequest(sess, req.Params)
+		}
+		s.mcpHandler.HandleNotification(session.WithSession(r.Context(), sess), req)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// initialize starts a new session; everything else must present one
+	var sess *session.Session
+	if req.Method == "initialize" {
+		sess = s.sessions.Create()
+		w.Header().Set(session.HeaderName, sess.ID)
+		log.Printf("[HTTP] Session created: %s", sess.ID)
+	} else {
+		var ok bool
+		if sess, ok = s.requireSession(w, r); !ok {
+			return
+		}
+		if !sess.Initialized() {
+			log.Printf("[HTTP] %s on session %s before notifications/initialized", req.Method, sess.ID)
+		}
+		if version := r.Header.Get(ProtocolVersionHeader); version != "" && !supportedProtocolVersion(version) {
+			log.Printf("[HTTP] Unsupported protocol version: %s", version)
+			http.Error(w, "Unsupported protocol version", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Handle request
-	resp := s.mcpHandler.Handle(r.Context(), req)
+	// Clients accepting SSE can receive notifications (e.g. progress) before
+	// the response; the reply switches to SSE only if one is actually sent
+	stream := newResponseStream(w, s.events, sess.ID)
+	ctx, cancel := s.requestContext(r, sess, req, stream)
+	defer cancel()
+	ctx = session.WithSession(tracing.Extract(ctx, r.Header), sess)
+	if acceptsSSE {
+		ctx = withNotifier(ctx, stream)
+	}
+	var stopKeepAlive func()
+	if acceptsSSE {
+		stopKeepAlive = stream.KeepAlive(s.keepAlive)
+	}
+	resp := s.mcpHandler.Handle(ctx, req)
+	if stopKeepAlive != nil {
+		stopKeepAlive()
+	}
+
+	if stream.Streaming() {
+		log.Printf("[HTTP] Sending SSE response for method=%s", req.Method)
+		stream.WriteResponse(resp)
+		return
+	}
 
-	// For simple request/response, use JSON
-	// In the future, we could use SSE for streaming responses
 	log.Printf("[HTTP] Sending JSON response for method=%s", req.Method)
 	s.writeJSONResponse(w, resp)
 }
 
+// requestContext returns the context a request is handled in, canceled by
+// notifications/cancelled. Once its response has switched to SSE, a client
+// disconnect no longer cancels it when events are kept: the client can
+// reconnect with Last-Event-ID and get the rest, including the response
+func (s *Server) requestContext(r *http.Request, sess *session.Session, req JSONRPCRequest, stream *responseStream) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	stopWatch := context.AfterFunc(r.Context(), func() {
+		if s.events != nil && stream.Streaming() {
+			log.Printf("[HTTP] Client disconnected from %s; it continues for resumption", req.Method)
+			return
+		}
+		cancel()
+	})
+
+	key := inflightKey(sess.ID, req.ID)
+	s.inflight.Store(key, cancel)
+	return ctx, func() {
+		s.inflight.Delete(key)
+		stopWatch()
+		cancel()
+	}
+}
+
+// cancelRequest cancels the session's in-flight request named by a
+// notifications/cancelled notification
+func (s *Server) cancelRequest(sess *session.Session, params json.RawMessage) {
+	var cancelled struct {
+		RequestID interface{} `json:"requestId"`
+		Reason    string      `json:"reason"`
+	}
+	if err := json.Unmarshal(params, &cancelled); err != nil || cancelled.RequestID == nil {
+		return
+	}
+	if cancel, ok := s.inflight.Load(inflightKey(sess.ID, cancelled.RequestID)); ok {
+		log.Printf("[HTTP] Cancelling request %v (%s)", cancelled.RequestID, cancelled.Reason)
+		cancel.(context.CancelFunc)()
+	}
+}
+
+// inflightKey identifies a request among all sessions' in-flight requests
+func inflightKey(sessionID string, requestID interface{}) string {
+	return fmt.Sprintf("%s/%v", sessionID, requestID)
+}
+
 // handleMCPGet handles GET requests for SSE event stream resumption
 func (s *Server) handleMCPGet(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[HTTP] SSE stream request (Last-Event-ID: %s)", r.Header.Get("Last-Event-ID"))
+	lastEventID := r.Header.Get("Last-Event-ID")
+	log.Printf("[HTTP] SSE stream request (Last-Event-ID: %s)", lastEventID)
+
+	sess, ok := s.requireSession(w, r)
+	if !ok {
+		return
+	}
 
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -133,17 +416,113 @@ func (s *Server) handleMCPGet(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("[HTTP] SSE stream established")
 
-	// Keep connection open
+	// Keep connection open, past the server's write timeout
 	// In the future, we can send server-initiated requests/notifications here
-	<-r.Context().Done()
-	log.Printf("[HTTP] SSE stream closed")
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	var keepAlive <-chan time.Time
+	if s.keepAlive > 0 {
+		ticker := time.NewTicker(s.keepAlive)
+		defer ticker.Stop()
+		keepAlive = ticker.C
+	}
+
+	// Resuming replays the events missed on the stream Last-Event-ID came
+	// from, then follows that stream until it ends
+	var changed <-chan struct{}
+	resume := func() {
+		batch, err := s.events.After(sess.ID, lastEventID)
+		if err != nil {
+			log.Printf("[HTTP] Cannot resume from event %s: %v", lastEventID, err)
+			changed = nil
+			return
+		}
+		for _, event := range batch.Events {
+			writeEvent(w, event)
+			lastEventID = event.ID
+		}
+		changed = batch.Changed
+		if batch.Closed {
+			changed = nil
+		}
+	}
+	if lastEventID != "" && s.events != nil {
+		resume()
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("[HTTP] SSE stream closed")
+			return
+		case <-changed:
+			resume()
+		case <-keepAlive:
+			writeKeepAlive(w)
+		}
+	}
 }
 
+// handleBundleDownload returns the reproduction bundle for a conversation's
+// most recent failed execution: GET /admin/bundles/{conversationId}
+func (s *Server) handleBundleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conversationID := strings.TrimPrefix(r.URL.Path, "/admin/bundles/")
+	if conversationID == "" {
+		http.Error(w, "Missing conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	b, ok := s.bundles.Get(conversationID)
+	if !ok {
+		http.Error(w, "No failed execution recorded", http.StatusNotFound)
+		return
+	}
 
-// handleFileDownload handles file download requests
+	log.Printf("[HTTP] Serving reproduction bundle for conversation %s", conversationID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="bundle-%s.json"`, b.CreatedAt.Format("20060102T150405Z")))
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		log.Printf("[HTTP] Failed to write bundle: %v", err)
+	}
+}
+
+// handleMetrics serves execution metrics in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.metrics.WritePrometheus(w)
+}
+
+// handleAdminMetrics returns per-language execution statistics as JSON
+func (s *Server) handleAdminMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"languages": s.metrics.Snapshot(),
+		"hosts":     s.mcpHandler.executor.Hosts(),
+		"queue":     s.mcpHandler.executor.Queue(),
+	}); err != nil {
+		log.Printf("[HTTP] Failed to write metrics: %v", err)
+	}
+}
+
+// handleFileDownload handles file download requests (GET and HEAD)
 // URLs are secure because the hashedDir is SHA256(conversationID + secret)
 func (s *Server) handleFileDownload(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -151,13 +530,8 @@ func (s *Server) handleFileDownload(w http.ResponseWriter, r *http.Request) {
 	// Parse URL path: /files/{hashedDir}/{filename}
 	path := strings.TrimPrefix(r.URL.Path, "/files/")
 	parts := strings.SplitN(path, "/", 2)
-	if len(parts) != 2 {
-		http.Error(w, "Invalid file path", http.StatusBadRequest)
-		return
-	}
 
 	hashedDir := parts[0]
-	filename := parts[1]
 
 	// Validate hashedDir is a valid hex string (16 chars for truncated SHA256)
 	if len(hashedDir) != 16 {
@@ -165,37 +539,29 @@ func (s *Server) handleFileDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get file path using the hashed directory
-	filePath := s.sandbox.GetFilePath(hashedDir, filename)
-
-	// Check if file exists and is regular file
-	info, err := os.Stat(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			http.Error(w, "File not found", http.StatusNotFound)
-			return
-		}
-		log.Printf("Error checking file: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+	// /files/{hashedDir}/ lists the files; redirect the bare directory so
+	// the listing's relative links resolve
+	if len(parts) == 1 {
+		http.Redirect(w, r, hashedDir+"/", http.StatusMovedPermanently)
 		return
 	}
-
-	if info.IsDir() {
-		http.Error(w, "Not a file", http.StatusBadRequest)
+	filename := parts[1]
+	if filename == "" {
+		s.serveFileListing(w, r, hashedDir)
 		return
 	}
 
-	// Prevent path traversal - ensure file is within sandbox root
-	sandboxRoot := s.sandbox.GetSandboxRoot()
-	if !strings.HasPrefix(filepath.Clean(filePath), filepath.Clean(sandboxRoot)) {
-		log.Printf("Path traversal attempt: %s", filePath)
-		http.Error(w, "Invalid file path", http.StatusForbidden)
+	// Another instance may have written it; only object storage has it then
+	if err := s.sandbox.FetchFile(r.Context(), hashedDir, filename); err != nil && !os.IsNotExist(err) {
+		log.Printf("[HTTP] Failed to fetch file from storage: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
 	}
 
-	// Serve file
-	log.Printf("Serving file: %s", filePath)
-	http.ServeFile(w, r, filePath)
+	// serveSandboxFile opens the file through the sandbox's root, so paths
+	// can't lead out of it, and serves regular files only
+	log.Printf("Serving file: %s", s.sandbox.GetFilePath(hashedDir, filename))
+	s.serveSandboxFile(w, r, hashedDir, filename)
 }
 
 // handleHomepage serves the web interface
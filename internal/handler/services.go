@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+	"github.com/jsc/mcp-code-sandbox/internal/services"
+)
+
+// ServiceArguments represents arguments for start_service and stop_service
+type ServiceArguments struct {
+	ConversationID string `json:"conversationId"`
+	Service        string `json:"service"`
+}
+
+// ListServicesArguments represents arguments for list_services
+type ListServicesArguments struct {
+	ConversationID string `json:"conversationId"`
+}
+
+// ServicesResult represents the result of the service tools
+type ServicesResult struct {
+	Success  bool               `json:"success"`
+	Services []services.Service `json:"services"`
+}
+
+// handleStartService implements the start_service tool
+func (h *MCPHandler) handleStartService(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	args, hashedDir, errResp := h.serviceArguments(ctx, id, argsJSON)
+	if errResp != nil {
+		return *errResp
+	}
+
+	log.Printf("[MCP] start_service: conversationId=%s, service=%s", args.ConversationID, args.Service)
+	if !h.executor.Available() {
+		return NewErrorResponse(id, InternalError, "Failed to start service", h.messages.Format(messages.BackendUnavailable, nil))
+	}
+	service, err := h.services.Start(ctx, hashedDir, args.Service)
+	if err != nil {
+		log.Printf("[MCP] Failed to start service: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Failed to start service", err.Error())
+	}
+	return h.wrapToolResult(id, ServicesResult{
+		Success:  true,
+		Services: []services.Service{service},
+	})
+}
+
+// handleStopService implements the stop_service tool
+func (h *MCPHandler) handleStopService(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	args, hashedDir, errResp := h.serviceArguments(ctx, id, argsJSON)
+	if errResp != nil {
+		return *errResp
+	}
+
+	log.Printf("[MCP] stop_service: conversationId=%s, service=%s", args.ConversationID, args.Service)
+	if err := h.services.Stop(ctx, hashedDir, args.Service); err != nil {
+		log.Printf("[MCP] Failed to stop service: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Failed to stop service", err.Error())
+	}
+	remaining, err := h.services.List(ctx, hashedDir)
+	if err != nil {
+		log.Printf("[MCP] Failed to list services: %v", err)
+	}
+	return h.wrapToolResult(id, ServicesResult{
+		Success:  true,
+		Services: remaining,
+	})
+}
+
+// handleListServices implements the list_services tool
+func (h *MCPHandler) handleListServices(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args ListServicesArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to ensure sandbox directory: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create sandbox directory", err.Error())
+	}
+	list, err := h.services.List(ctx, hashedDir)
+	if err != nil {
+		log.Printf("[MCP] Failed to list services: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to list services", err.Error())
+	}
+	if list == nil {
+		list = []services.Service{}
+	}
+	return h.wrapToolResult(id, ServicesResult{
+		Success:  true,
+		Services: list,
+	})
+}
+
+// serviceArguments parses and validates start_service and stop_service
+// arguments, returning the sandbox's hashed directory
+func (h *MCPHandler) serviceArguments(ctx context.Context, id interface{}, argsJSON json.RawMessage) (ServiceArguments, string, *JSONRPCResponse) {
+	var args ServiceArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		resp := NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+		return args, "", &resp
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		resp := NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+		return args, "", &resp
+	}
+	if !h.services.Enabled() {
+		resp := NewErrorResponse(id, InvalidParams, "No services are enabled on this server (SANDBOX_SERVICES)", nil)
+		return args, "", &resp
+	}
+	if args.Service == "" {
+		resp := NewErrorResponse(id, InvalidParams, "service is required", nil)
+		return args, "", &resp
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to ensure sandbox directory: %v", err)
+		resp := NewErrorResponse(id, InternalError, "Failed to create sandbox directory", err.Error())
+		return args, "", &resp
+	}
+	return args, hashedDir, nil
+}
+
+// withServices attaches an execution to the conversation's service network
+// and adds its services' connection variables to env, without overriding
+// variables that are already set
+func (h *MCPHandler) withServices(ctx context.Context, hashedDir string, env map[string]string) context.Context {
+	network, serviceEnv, err := h.services.Environment(ctx, hashedDir)
+	if err != nil {
+		log.Printf("[MCP] Failed to look up services: %v", err)
+		return ctx
+	}
+	if network == "" {
+		return ctx
+	}
+	for key, value := range serviceEnv {
+		if _, ok := env[key]; !ok {
+			env[key] = value
+		}
+	}
+	return runner.WithServiceNetwork(ctx, network)
+}
+
+// servicesDescription lists the services that can be started
+func (h *MCPHandler) servicesDescription() string {
+	if !h.services.Enabled() {
+		return " No services are enabled on this server."
+	}
+	return " Available: " + strings.Join(h.services.Available(), ", ") + "."
+}
+
+// serviceProperty is the schema of the service argument
+func (h *MCPHandler) serviceProperty() map[string]interface{} {
+	property := map[string]interface{}{
+		"type":        "string",
+		"description": "Service name",
+	}
+	if available := h.services.Available(); len(available) > 0 {
+		property["enum"] = available
+	}
+	return property
+}
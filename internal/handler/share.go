@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/filesign"
+)
+
+// Share link lifetimes
+const (
+	defaultShareHours = 24
+	maxShareHours     = 30 * 24
+)
+
+// ShareConversationArguments represents arguments for share_conversation
+type ShareConversationArguments struct {
+	ConversationID string `json:"conversationId"`
+	ExpiresInHours int    `json:"expiresInHours,omitempty"` // Default 24, at most 720
+}
+
+// ShareConversationResult represents the result of share_conversation
+type ShareConversationResult struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// handleShareConversation implements the share_conversation tool
+func (h *MCPHandler) handleShareConversation(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args ShareConversationArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if args.ExpiresInHours == 0 {
+		args.ExpiresInHours = defaultShareHours
+	}
+	if args.ExpiresInHours < 0 || args.ExpiresInHours > maxShareHours {
+		return NewErrorResponse(id, InvalidParams, "expiresInHours must be between 1 and 720", nil)
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to ensure sandbox directory: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create sandbox directory", err.Error())
+	}
+
+	expires := time.Now().Add(time.Duration(args.ExpiresInHours) * time.Hour).Truncate(time.Second)
+	shareURL, err := h.signer.MakeShareURL(hashedDir, h.sandbox.LinkEpoch(hashedDir), expires)
+	if err != nil {
+		log.Printf("[MCP] Failed to create share link: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create share link", err.Error())
+	}
+
+	log.Printf("[MCP] share_conversation: conversationId=%s, expires=%s", args.ConversationID, expires.UTC().Format(time.RFC3339))
+	return h.wrapToolResult(id, ShareConversationResult{
+		URL:       shareURL,
+		ExpiresAt: expires.UTC(),
+	})
+}
+
+// shareListing renders a shared sandbox's file browser. Links are relative to
+// the share URL (which ends in "/") so files are served through the same token
+var shareListing = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{if .Name}}{{.Name}} - {{end}}Shared files</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 720px; margin: 2em auto; color: #222; }
+li { margin: 0.3em 0; }
+.muted { color: #777; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{if .Name}}{{.Name}}{{else}}Shared files{{end}}</h1>
+<p class="muted">Read-only link, expires {{.Expires}}</p>
+{{if .Files}}<ul>
+{{range .Files}}<li><a href="{{.Href}}">{{.Name}}</a></li>
+{{end}}</ul>{{else}}<p>No files yet.</p>{{end}}
+</body>
+</html>
+`))
+
+// handleShare serves a share link: GET /share/{token}/ lists the files and
+// GET (or HEAD) /share/{token}/{filename} downloads one
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, filename, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/share/"), "/")
+	hashedDir, epoch, expires, err := s.signer.ParseShareToken(token)
+	if err == nil && epoch != s.sandbox.LinkEpoch(hashedDir) {
+		err = filesign.ErrInvalidShareToken
+	}
+	if err != nil {
+		http.Error(w, "Share link is invalid, expired or revoked", http.StatusNotFound)
+		return
+	}
+
+	if filename == "" {
+		s.serveShareListing(w, r, token, hashedDir, expires)
+		return
+	}
+	if !isPlainFilename(filename) {
+		http.Error(w, "Invalid file path", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sandbox.FetchFile(r.Context(), hashedDir, filename); err != nil && !os.IsNotExist(err) {
+		log.Printf("[HTTP] Failed to fetch shared file from storage: %v", err)
+	}
+	log.Printf("[HTTP] Serving shared file: %s", s.sandbox.GetFilePath(hashedDir, filename))
+	s.serveSandboxFile(w, r, hashedDir, filename)
+}
+
+// serveShareListing renders the file browser for a share link
+func (s *Server) serveShareListing(w http.ResponseWriter, r *http.Request, token, hashedDir string, expires time.Time) {
+	// Redirect /share/{token} to /share/{token}/ so relative links resolve
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, token+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	if err := s.sandbox.StageHashedDir(r.Context(), hashedDir); err != nil {
+		log.Printf("[HTTP] Failed to stage shared files: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	files, err := s.sandbox.ListHashedDir(hashedDir)
+	if err != nil {
+		log.Printf("[HTTP] Failed to list shared files: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	sort.Strings(files)
+
+	type entry struct{ Name, Href string }
+	data := struct {
+		Name    string
+		Expires string
+		Files   []entry
+	}{
+		Name:    s.sandbox.DisplayName(hashedDir),
+		Expires: expires.UTC().Format("2006-01-02 15:04 MST"),
+	}
+	for _, name := range files {
+		data.Files = append(data.Files, entry{Name: name, Href: url.PathEscape(name)})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := shareListing.Execute(w, data); err != nil {
+		log.Printf("[HTTP] Failed to render share listing: %v", err)
+	}
+}
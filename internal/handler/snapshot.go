@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// SnapshotSandboxArguments represents arguments for snapshot_sandbox
+type SnapshotSandboxArguments struct {
+	ConversationID string `json:"conversationId"`
+	Label          string `json:"label,omitempty"`
+}
+
+// SnapshotSandboxResult represents the result of snapshot_sandbox
+type SnapshotSandboxResult struct {
+	Snapshot  *sandbox.Snapshot  `json:"snapshot,omitempty"`
+	Snapshots []sandbox.Snapshot `json:"snapshots"` // All kept snapshots, oldest first
+	Error     *ToolError         `json:"error,omitempty"`
+}
+
+// RestoreSandboxArguments represents arguments for restore_sandbox
+type RestoreSandboxArguments struct {
+	ConversationID string `json:"conversationId"`
+	SnapshotID     string `json:"snapshotId"`
+}
+
+// RestoreSandboxResult represents the result of restore_sandbox
+type RestoreSandboxResult struct {
+	Snapshot *sandbox.Snapshot `json:"snapshot,omitempty"`
+	Files    []FileDescriptor  `json:"files"`
+	Error    *ToolError        `json:"error,omitempty"`
+}
+
+// handleSnapshotSandbox implements the snapshot_sandbox tool
+func (h *MCPHandler) handleSnapshotSandbox(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args SnapshotSandboxArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return h.wrapToolResult(id, SnapshotSandboxResult{Snapshots: []sandbox.Snapshot{}, Error: toolErr})
+	}
+
+	snapshot, err := h.sandbox.CreateSnapshot(args.ConversationID, args.Label)
+	if err != nil {
+		log.Printf("[MCP] Failed to snapshot sandbox: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Failed to snapshot sandbox", err.Error())
+	}
+	snapshots, err := h.sandbox.ListSnapshots(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to list snapshots: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to list snapshots", err.Error())
+	}
+
+	log.Printf("[MCP] snapshot_sandbox: conversationId=%s, snapshot=%s, files=%d, bytes=%d",
+		args.ConversationID, snapshot.ID, snapshot.Files, snapshot.SizeBytes)
+	return h.wrapToolResult(id, SnapshotSandboxResult{
+		Snapshot:  snapshot,
+		Snapshots: snapshots,
+	})
+}
+
+// handleRestoreSandbox implements the restore_sandbox tool
+func (h *MCPHandler) handleRestoreSandbox(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args RestoreSandboxArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if args.SnapshotID == "" {
+		return NewErrorResponse(id, InvalidParams, "snapshotId is required", nil)
+	}
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return h.wrapToolResult(id, RestoreSandboxResult{Files: []FileDescriptor{}, Error: toolErr})
+	}
+
+	snapshot, err := h.sandbox.RestoreSnapshot(args.ConversationID, args.SnapshotID)
+	if errors.Is(err, sandbox.ErrSnapshotNotFound) {
+		return NewErrorResponse(id, InvalidParams, "Snapshot not found: "+args.SnapshotID, nil)
+	}
+	if err != nil {
+		log.Printf("[MCP] Failed to restore snapshot %s: %v", args.SnapshotID, err)
+		return NewErrorResponse(id, InternalError, "Failed to restore snapshot", err.Error())
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to ensure sandbox directory: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create sandbox directory", err.Error())
+	}
+
+	log.Printf("[MCP] restore_sandbox: conversationId=%s, snapshot=%s", args.ConversationID, snapshot.ID)
+	return h.wrapToolResult(id, RestoreSandboxResult{
+		Snapshot: snapshot,
+		Files:    h.listFileDescriptors(args.ConversationID, hashedDir),
+	})
+}
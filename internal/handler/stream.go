@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/events"
+)
+
+// Notifier sends server-to-client notifications while a request is in flight
+type Notifier interface {
+	Notify(notification JSONRPCNotification)
+}
+
+type notifierKey struct{}
+
+// withNotifier returns a context carrying n
+func withNotifier(ctx context.Context, n Notifier) context.Context {
+	return context.WithValue(ctx, notifierKey{}, n)
+}
+
+// notifierFromContext returns the notifier carried by ctx, if any
+func notifierFromContext(ctx context.Context) (Notifier, bool) {
+	n, ok := ctx.Value(notifierKey{}).(Notifier)
+	return n, ok
+}
+
+// responseStream upgrades a POST response to an SSE stream on the first
+// notification. If nothing is ever sent, the final response is plain JSON.
+type responseStream struct {
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	started   bool
+	finished  bool         // The response was written; no more keepalives
+	events    events.Store // Records events for resumption; nil disables it
+	sessionID string
+	streamID  string // Set once streaming, if events is set
+}
+
+func newResponseStream(w http.ResponseWriter, store events.Store, sessionID string) *responseStream {
+	return &responseStream{w: w, events: store, sessionID: sessionID}
+}
+
+// Notify writes a notification as an SSE event, switching to SSE if needed
+func (s *responseStream) Notify(notification JSONRPCNotification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.startLocked()
+	s.writeEventLocked(notification)
+}
+
+// Streaming reports whether the response has switched to SSE
+func (s *responseStream) Streaming() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
+
+// WriteResponse writes the final JSON-RPC response as an SSE event
+func (s *responseStream) WriteResponse(resp JSONRPCResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.startLocked()
+	s.writeEventLocked(resp)
+	if s.streamID != "" {
+		s.events.Close(s.streamID)
+	}
+}
+
+// KeepAlive sends an SSE comment whenever interval passes without a
+// response, switching to SSE if needed, so proxies don't drop the
+// connection while a long execution runs silently. The returned function
+// stops it and must be called before the response is written
+func (s *responseStream) KeepAlive(interval time.Duration) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				if !s.finished {
+					s.startLocked()
+					writeKeepAlive(s.w)
+				}
+				s.mu.Unlock()
+			}
+		}
+	}()
+	return func() {
+		s.mu.Lock()
+		s.finished = true
+		s.mu.Unlock()
+		close(stop)
+		<-done
+	}
+}
+
+// startLocked sends the SSE headers once; caller must hold s.mu
+func (s *responseStream) startLocked() {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	h := s.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	s.w.WriteHeader(http.StatusOK)
+	if s.events != nil {
+		s.streamID = s.events.Open(s.sessionID)
+	}
+
+	// A stream lasts as long as the execution, past the server's write timeout
+	http.NewResponseController(s.w).SetWriteDeadline(time.Time{})
+}
+
+// writeEventLocked writes one SSE message event, recording it for
+// resumption; caller must hold s.mu
+func (s *responseStream) writeEventLocked(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("[HTTP] Failed to marshal SSE message: %v", err)
+		return
+	}
+
+	event := events.Event{Data: data}
+	if s.streamID != "" {
+		event.ID = s.events.Append(s.streamID, data)
+	}
+	writeEvent(s.w, event)
+}
+
+// writeEvent writes an SSE message event, with its ID if it has one
+func writeEvent(w http.ResponseWriter, event events.Event) {
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: message\ndata: %s\n\n", event.Data)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeKeepAlive writes an SSE comment, which clients ignore
+func writeKeepAlive(w http.ResponseWriter) {
+	fmt.Fprintf(w, ": keepalive\n\n")
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
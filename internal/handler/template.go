@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/templates"
+)
+
+// CreateFromTemplateArguments represents arguments for create_from_template
+type CreateFromTemplateArguments struct {
+	ConversationID string `json:"conversationId"`
+	Template       string `json:"template"`
+	Overwrite      bool   `json:"overwrite,omitempty"` // Seed a sandbox that already has files
+}
+
+// CreateFromTemplateResult represents the result of create_from_template
+type CreateFromTemplateResult struct {
+	Template    string           `json:"template"`
+	Files       []FileDescriptor `json:"files"`
+	Environment []string         `json:"environment,omitempty"` // Names of preset variables
+	Error       *ToolError       `json:"error,omitempty"`
+}
+
+// templatesDescription builds the create_from_template tool description
+func templatesDescription(list []*templates.Template) string {
+	var b strings.Builder
+	b.WriteString("Start a conversation's sandbox from a server-defined template: copies its seed files into /data and persists its environment presets (as set_environment would). Refuses sandboxes that already have files unless overwrite is true.")
+	if len(list) == 0 {
+		b.WriteString("\n\nNo templates are configured on this server.")
+		return b.String()
+	}
+	b.WriteString("\n\nAvailable templates:")
+	for _, t := range list {
+		fmt.Fprintf(&b, "\n- %s", t.Name)
+		if t.Description != "" {
+			fmt.Fprintf(&b, ": %s", t.Description)
+		}
+		switch {
+		case len(t.Files) > 0 && t.Archive != "":
+			fmt.Fprintf(&b, " (files: %s, plus an archive)", strings.Join(t.Files, ", "))
+		case len(t.Files) > 0:
+			fmt.Fprintf(&b, " (files: %s)", strings.Join(t.Files, ", "))
+		case t.Archive != "":
+			b.WriteString(" (files from an archive)")
+		}
+	}
+	return b.String()
+}
+
+// handleCreateFromTemplate implements the create_from_template tool
+func (h *MCPHandler) handleCreateFromTemplate(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args CreateFromTemplateArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	tmpl, ok := h.templates.Get(args.Template)
+	if !ok {
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("Unknown template: %q", args.Template), nil)
+	}
+
+	hasFiles, err := h.sandbox.HasFiles(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to list sandbox files: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to read sandbox", err.Error())
+	}
+	if hasFiles && !args.Overwrite {
+		return NewErrorResponse(id, InvalidParams, "The sandbox already has files; pass overwrite: true to add the template's files anyway", nil)
+	}
+
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return h.wrapToolResult(id, CreateFromTemplateResult{Template: tmpl.Name, Error: toolErr})
+	}
+
+	envNames, err := h.applyTemplate(args.ConversationID, tmpl)
+	if err != nil {
+		log.Printf("[MCP] Failed to apply template %s: %v", tmpl.Name, err)
+		return NewErrorResponse(id, InternalError, "Failed to apply template", err.Error())
+	}
+
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to ensure sandbox directory: %v", err)
+		return NewErrorResponse(id, InternalError, "Failed to create sandbox directory", err.Error())
+	}
+
+	log.Printf("[MCP] create_from_template: conversationId=%s, template=%s, files=%d, archive=%q, env vars=%d",
+		args.ConversationID, tmpl.Name, len(tmpl.Files), tmpl.Archive, len(envNames))
+	return h.wrapToolResult(id, CreateFromTemplateResult{
+		Template:    tmpl.Name,
+		Files:       h.listFileDescriptors(args.ConversationID, hashedDir),
+		Environment: envNames,
+	})
+}
+
+// applyTemplate copies a template's seed archive and files into a
+// conversation's sandbox and persists its environment presets, returning the
+// preset names
+func (h *MCPHandler) applyTemplate(conversationID string, tmpl *templates.Template) ([]string, error) {
+	if tmpl.Archive != "" {
+		data, err := tmpl.ReadArchive()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := h.sandbox.ExtractArchive(conversationID, tmpl.Archive, data); err != nil {
+			return nil, fmt.Errorf("failed to unpack %s: %w", tmpl.Archive, err)
+		}
+	}
+
+	files := make(map[string]string, len(tmpl.Files))
+	for _, name := range tmpl.Files {
+		content, err := tmpl.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = string(content)
+	}
+	if _, err := h.sandbox.WriteFiles(conversationID, files); err != nil {
+		return nil, fmt.Errorf("failed to copy seed files: %w", err)
+	}
+
+	var envNames []string
+	if len(tmpl.Environment) > 0 {
+		changes := make(map[string]*string, len(tmpl.Environment))
+		for key, value := range tmpl.Environment {
+			changes[key] = &value
+			envNames = append(envNames, key)
+		}
+		if _, err := h.envs.Update(conversationID, changes); err != nil {
+			return nil, fmt.Errorf("failed to apply template environment: %w", err)
+		}
+		sort.Strings(envNames)
+	}
+	return envNames, nil
+}
+
+// seedNewSandbox applies the template named by a tool's template argument if
+// the conversation's sandbox has no files yet; later calls may pass the same
+// argument and leave the sandbox alone. It returns a JSON-RPC error for
+// unknown templates and failures
+func (h *MCPHandler) seedNewSandbox(id interface{}, conversationID, name string) *JSONRPCResponse {
+	if name == "" {
+		return nil
+	}
+	tmpl, ok := h.templates.Get(name)
+	if !ok {
+		resp := NewErrorResponse(id, InvalidParams, fmt.Sprintf("Unknown template: %q", name), nil)
+		return &resp
+	}
+	hasFiles, err := h.sandbox.HasFiles(conversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to list sandbox files: %v", err)
+		resp := NewErrorResponse(id, InternalError, "Failed to read sandbox", err.Error())
+		return &resp
+	}
+	if hasFiles {
+		return nil
+	}
+	if _, err := h.applyTemplate(conversationID, tmpl); err != nil {
+		log.Printf("[MCP] Failed to apply template %s: %v", tmpl.Name, err)
+		resp := NewErrorResponse(id, InternalError, "Failed to apply template", err.Error())
+		return &resp
+	}
+	log.Printf("[MCP] Seeded conversation %s from template %s", conversationID, tmpl.Name)
+	return nil
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/jsc/mcp-code-sandbox/internal/auth"
+)
+
+// tenantFreeTools don't touch a conversation, so calling them claims none
+var tenantFreeTools = map[string]bool{
+	"list_runners":    true,
+	"describe_runner": true,
+	"read_output":     true, // Continuation tokens are unguessable and short-lived
+}
+
+// tenantError binds a conversation to the token that first uses it and
+// returns the response for any other token. Tokens with the admin scope use
+// every conversation without claiming it, as do requests without a token (run-once)
+func (h *MCPHandler) tenantError(ctx context.Context, id interface{}, conversationID string) *JSONRPCResponse {
+	identity, ok := auth.FromContext(ctx)
+	if !ok || conversationID == "" || identity.Has(auth.ScopeAdmin) {
+		return nil
+	}
+	tenant, err := h.sandbox.ClaimTenant(ctx, conversationID, identity.Name)
+	if err != nil {
+		log.Printf("[MCP] Failed to check conversation tenant: %v", err)
+		resp := NewErrorResponse(id, InternalError, "Failed to check conversation access", err.Error())
+		return &resp
+	}
+	if tenant == identity.Name {
+		return nil
+	}
+	log.Printf("[MCP] Token %q denied access to a conversation of %q", identity.Name, tenant)
+	resp := NewErrorResponse(id, Forbidden, "This conversation belongs to another token", nil)
+	return &resp
+}
+
+// toolTenantError applies tenantError to a tool call's conversation
+func (h *MCPHandler) toolTenantError(ctx context.Context, id interface{}, params ToolCallParams) *JSONRPCResponse {
+	if tenantFreeTools[params.Name] {
+		return nil
+	}
+	return h.tenantError(ctx, id, toolConversationID(ctx, params))
+}
+
+// toolConversationID returns the conversation a tool call touches
+func toolConversationID(ctx context.Context, params ToolCallParams) string {
+	// Malformed arguments are reported by the tool itself
+	var target struct {
+		ConversationID string `json:"conversationId"`
+	}
+	json.Unmarshal(params.Arguments, &target)
+	return defaultConversationID(ctx, target.ConversationID)
+}
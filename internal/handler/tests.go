@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jsc/mcp-code-sandbox/internal/testreport"
+)
+
+// Limits on what run_tests returns, so a broken suite can't flood the context
+const (
+	maxTestFailures       = 20
+	maxTestFailureDetails = 2000
+	maxTestOutput         = 4000
+)
+
+// testFrameworks maps each supported framework to the language whose runner
+// has it
+var testFrameworks = map[string]string{
+	"pytest": "python",
+	"jest":   "typescript",
+	"bun":    "typescript",
+}
+
+// defaultTestFrameworks is the framework used when only the language is given
+var defaultTestFrameworks = map[string]string{
+	"python":     "pytest",
+	"typescript": "bun",
+}
+
+// RunTestsArguments represents arguments for run_tests
+type RunTestsArguments struct {
+	ConversationID string            `json:"conversationId"`
+	Framework      string            `json:"framework,omitempty"` // pytest, jest or bun; defaults from language
+	Language       string            `json:"language,omitempty"`  // Defaults from framework
+	Version        string            `json:"version,omitempty"`
+	Path           string            `json:"path,omitempty"` // File or directory to test, relative to /data
+	Args           []string          `json:"args,omitempty"` // Extra arguments for the framework, e.g. ["-k", "parse"]
+	Network        NetworkSetting    `json:"network,omitempty"`
+	Environment    map[string]string `json:"environment,omitempty"`
+	Secrets        []string          `json:"secrets,omitempty"`
+}
+
+// RunTestsResult represents the result of run_tests
+type RunTestsResult struct {
+	Success           bool             `json:"success"` // The suite ran and every test passed
+	Framework         string           `json:"framework"`
+	Passed            int              `json:"passed"`
+	Failed            int              `json:"failed"`
+	Errors            int              `json:"errors"`
+	Skipped           int              `json:"skipped"`
+	Total             int              `json:"total"`
+	Failures          []TestFailure    `json:"failures,omitempty"`
+	FailuresTruncated bool             `json:"failuresTruncated,omitempty"` // More tests failed than are listed
+	Message           string           `json:"message,omitempty"`
+	Stdout            string           `json:"stdout,omitempty"` // Tail of the framework's output
+	Stderr            string           `json:"stderr,omitempty"`
+	ExitCode          int              `json:"exitCode,omitempty"`
+	Files             []FileDescriptor `json:"files,omitempty"`
+	Error             *ToolError       `json:"error,omitempty"` // Set when the tests could not be run at all
+}
+
+// TestFailure describes a failed or errored test
+type TestFailure struct {
+	Name    string `json:"name"`
+	File    string `json:"file,omitempty"`
+	Message string `json:"message,omitempty"`
+	Details string `json:"details,omitempty"` // Traceback, truncated to its last 2000 characters
+	Error   bool   `json:"error,omitempty"`   // The test errored (e.g. a failed import) rather than failed
+}
+
+// handleRunTests implements the run_tests tool: it runs the framework in the
+// sandbox with a machine-readable reporter and summarizes the report
+func (h *MCPHandler) handleRunTests(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args RunTestsArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] run_tests: conversationId=%s, framework=%s, language=%s, path=%s, args=%d, network=%v",
+		args.ConversationID, args.Framework, args.Language, args.Path, len(args.Args), args.Network)
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if args.Framework == "" {
+		args.Framework = defaultTestFrameworks[args.Language]
+		if args.Framework == "" {
+			return NewErrorResponse(id, InvalidParams, "framework is required (pytest, jest or bun)", nil)
+		}
+	}
+	language, ok := testFrameworks[args.Framework]
+	if !ok {
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("Unsupported framework %q (use pytest, jest or bun)", args.Framework), nil)
+	}
+	if args.Language == "" {
+		args.Language = language
+	}
+	args.Path = strings.TrimPrefix(args.Path, "/data/")
+	if args.Path != "" && !filepath.IsLocal(filepath.FromSlash(args.Path)) {
+		return NewErrorResponse(id, InvalidParams, "path must be within the sandbox", nil)
+	}
+	// The report is read back from the sandbox, which sealing keeps from the server
+	if h.sandbox.ConversationResultKey(args.ConversationID) != nil {
+		return NewErrorResponse(id, InvalidParams, "run_tests is not available for conversations with a result key; use run_shell", nil)
+	}
+
+	reportName, err := scratchName(".run-tests-")
+	if err != nil {
+		return NewErrorResponse(id, InternalError, "Failed to run tests", err.Error())
+	}
+	if args.Framework == "jest" {
+		reportName += ".json"
+	} else {
+		reportName += ".xml"
+	}
+
+	resp := h.runInSandbox(ctx, id, RunCodeArguments{
+		ConversationID: args.ConversationID,
+		Language:       args.Language,
+		Version:        args.Version,
+		Code:           testCommand(args.Framework, "/data/"+reportName, args.Path, args.Args),
+		Network:        args.Network,
+		Environment:    args.Environment,
+		Secrets:        args.Secrets,
+	}, true)
+	if resp.Error != nil {
+		return resp
+	}
+	toolResult, _ := resp.Result.(ToolResult)
+	run, ok := toolResult.StructuredContent.(RunCodeResult)
+	if !ok {
+		return resp
+	}
+
+	result := RunTestsResult{
+		Framework: args.Framework,
+		Stdout:    tail(run.Stdout, maxTestOutput),
+		Stderr:    tail(run.Stderr, maxTestOutput),
+		ExitCode:  run.ExitCode,
+		Files:     run.Files,
+		Error:     run.Error,
+	}
+	if run.Error != nil {
+		result.Message = run.Error.Message
+		return h.wrapToolResult(id, result)
+	}
+
+	reportPath := filepath.Join(h.sandbox.GetSandboxDir(args.ConversationID), reportName)
+	data, err := h.sandbox.ReadPath(args.ConversationID, reportName)
+	if err != nil {
+		// The framework didn't start: not installed, bad arguments, or no runner
+		result.Message = fmt.Sprintf("%s wrote no report; see stdout and stderr", args.Framework)
+		return h.wrapToolResult(id, result)
+	}
+	os.Remove(reportPath)
+	if hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID); err == nil {
+		result.Files = h.listFileDescriptors(args.ConversationID, hashedDir)
+	}
+
+	var report *testreport.Report
+	if args.Framework == "jest" {
+		report, err = testreport.ParseJest(data)
+	} else {
+		report, err = testreport.ParseJUnit(data)
+	}
+	if err != nil {
+		log.Printf("[MCP] Failed to parse %s report: %v", args.Framework, err)
+		result.Message = err.Error()
+		return h.wrapToolResult(id, result)
+	}
+
+	result.Passed, result.Failed, result.Errors, result.Skipped = report.Passed, report.Failed, report.Errors, report.Skipped
+	result.Total = report.Total()
+	result.Success = result.Failed == 0 && result.Errors == 0 && result.Total > 0
+	for i, failure := range report.Failures {
+		if i == maxTestFailures {
+			result.FailuresTruncated = true
+			break
+		}
+		result.Failures = append(result.Failures, TestFailure{
+			Name:    failure.Name,
+			File:    failure.File,
+			Message: failure.Message,
+			Details: tail(failure.Details, maxTestFailureDetails),
+			Error:   failure.Error,
+		})
+	}
+	switch {
+	case result.Total == 0:
+		result.Message = "No tests were collected"
+	case result.Success:
+		result.Message = fmt.Sprintf("%d passed", result.Passed)
+	default:
+		result.Message = fmt.Sprintf("%d failed, %d errors, %d passed", result.Failed, result.Errors, result.Passed)
+	}
+
+	log.Printf("[MCP] run_tests completed: passed=%d, failed=%d, errors=%d, skipped=%d",
+		result.Passed, result.Failed, result.Errors, result.Skipped)
+	return h.wrapToolResult(id, result)
+}
+
+// testCommand builds the shell command running framework with its report
+// written to reportPath
+func testCommand(framework, reportPath, path string, extra []string) string {
+	var argv []string
+	switch framework {
+	case "pytest":
+		argv = []string{"python", "-m", "pytest", "-p", "no:cacheprovider", "-q", "--tb=short", "--junitxml=" + reportPath}
+	case "jest":
+		argv = []string{"bun", "x", "jest", "--ci", "--json", "--outputFile=" + reportPath}
+	case "bun":
+		argv = []string{"bun", "test", "--reporter=junit", "--reporter-outfile=" + reportPath}
+	}
+	argv = append(argv, extra...)
+	if path != "" {
+		argv = append(argv, path)
+	}
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes s as a single /bin/sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// tail returns the last max bytes of s, without splitting a UTF-8 sequence
+func tail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := len(s) - max
+	for cut < len(s) && !utf8.RuneStart(s[cut]) {
+		cut++
+	}
+	return "…" + s[cut:]
+}
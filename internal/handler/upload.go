@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/sandbox"
+)
+
+// UploadFilesArguments represents arguments for upload_files
+type UploadFilesArguments struct {
+	ConversationID string        `json:"conversationId"`
+	Files          []UploadEntry `json:"files"`
+	Template       string        `json:"template,omitempty"` // Seed the sandbox if it has no files yet
+}
+
+// UploadEntry is one file of an upload_files call
+type UploadEntry struct {
+	Filename string `json:"filename"`
+	Content  string `json:"content"` // Base64 encoded file content
+}
+
+// handleUploadFiles implements the upload_files tool
+func (h *MCPHandler) handleUploadFiles(ctx context.Context, id interface{}, argsJSON json.RawMessage) JSONRPCResponse {
+	var args UploadFilesArguments
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		log.Printf("[MCP] Failed to parse arguments: %v", err)
+		return NewErrorResponse(id, InvalidParams, "Invalid arguments", err.Error())
+	}
+	args.ConversationID = defaultConversationID(ctx, args.ConversationID)
+
+	log.Printf("[MCP] upload_files: conversationId=%s, files=%d", args.ConversationID, len(args.Files))
+
+	if args.ConversationID == "" {
+		return NewErrorResponse(id, InvalidParams, "conversationId is required", nil)
+	}
+	if len(args.Files) == 0 {
+		return NewErrorResponse(id, InvalidParams, "files is required", nil)
+	}
+	if len(args.Files) > sandbox.MaxBatchFiles {
+		return NewErrorResponse(id, InvalidParams, fmt.Sprintf("at most %d files can be uploaded at once", sandbox.MaxBatchFiles), nil)
+	}
+
+	files := make([]sandbox.BatchFile, len(args.Files))
+	var total int
+	for i, entry := range args.Files {
+		if entry.Filename == "" {
+			return NewErrorResponse(id, InvalidParams, fmt.Sprintf("files[%d].filename is required", i), nil)
+		}
+		content, err := base64.StdEncoding.DecodeString(entry.Content)
+		if err != nil {
+			return h.wrapToolResult(id, map[string]interface{}{
+				"success": false,
+				"message": fmt.Sprintf("Failed to decode base64 content of %s: %v", entry.Filename, err),
+			})
+		}
+		files[i] = sandbox.BatchFile{Name: entry.Filename, Data: content}
+		total += len(content)
+	}
+
+	if toolErr := h.checkDiskSpace(); toolErr != nil {
+		return h.wrapToolResult(id, map[string]interface{}{
+			"success": false,
+			"message": toolErr.Message,
+			"error":   toolErr,
+		})
+	}
+	if errResp := h.seedNewSandbox(id, args.ConversationID, args.Template); errResp != nil {
+		return *errResp
+	}
+
+	written, err := h.sandbox.WriteBatch(args.ConversationID, files)
+	if err != nil {
+		log.Printf("[MCP] Failed to write files: %v", err)
+		return h.uploadFailure(id, "Failed to write files", err)
+	}
+	hashedDir, err := h.sandbox.EnsureSandboxDir(args.ConversationID)
+	if err != nil {
+		log.Printf("[MCP] Failed to get hashed directory: %v", err)
+		return h.wrapToolResult(id, map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Failed to get directory: %v", err),
+		})
+	}
+
+	baseURL := h.signer.FileBaseURL(hashedDir)
+	descriptors := make([]FileDescriptor, 0, len(written))
+	for _, name := range written {
+		descriptors = append(descriptors, FileDescriptor{
+			Name: name,
+			URL:  fmt.Sprintf("%s/%s", baseURL, escapePath(name)),
+		})
+	}
+
+	log.Printf("[MCP] upload_files completed: %d file(s), %d bytes", len(written), total)
+	return h.wrapToolResult(id, map[string]interface{}{
+		"success": true,
+		"message": h.messages.Format(messages.FilesUploaded, messages.Args{"Files": len(written), "Bytes": total}),
+		"files":   descriptors,
+	})
+}
@@ -0,0 +1,186 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver
+)
+
+// maxOutputBytes caps the stdout/stderr kept per execution
+const maxOutputBytes = 4096
+
+// truncatedMarker is appended to output cut at maxOutputBytes
+const truncatedMarker = "\n... [truncated]"
+
+// Default and maximum page sizes for Query
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS executions (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	conversation_id TEXT    NOT NULL,
+	language        TEXT    NOT NULL,
+	version         TEXT    NOT NULL DEFAULT '',
+	image           TEXT    NOT NULL,
+	started_at      INTEGER NOT NULL,
+	duration_ms     INTEGER NOT NULL,
+	exit_code       INTEGER NOT NULL,
+	success         INTEGER NOT NULL,
+	timed_out       INTEGER NOT NULL,
+	stdout          TEXT    NOT NULL,
+	stderr          TEXT    NOT NULL,
+	files           TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS executions_conversation ON executions (conversation_id, id);
+`
+
+// Record is one run_code invocation
+type Record struct {
+	ID               int64     `json:"id"`
+	ConversationID   string    `json:"conversationId"`
+	ConversationName string    `json:"conversationName,omitempty"` // Current display name; not stored
+	Language         string    `json:"language"`
+	Version          string    `json:"version,omitempty"`
+	Image            string    `json:"image"`
+	StartedAt        time.Time `json:"startedAt"`
+	DurationMs       int64     `json:"durationMs"`
+	ExitCode         int       `json:"exitCode"`
+	Success          bool      `json:"success"`
+	TimedOut         bool      `json:"timedOut,omitempty"`
+	Stdout           string    `json:"stdout"` // Truncated to 4KB
+	Stderr           string    `json:"stderr"` // Truncated to 4KB
+	Files            []string  `json:"files"`  // Sandbox files after the run
+}
+
+// Filter selects records for Query. Results are newest first; pass the last
+// ID of a page as Before to get the next one
+type Filter struct {
+	ConversationID string
+	Language       string
+	Before         int64
+	Limit          int
+}
+
+// Store persists execution records in an embedded SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (creating if needed) the history database at path
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// SQLite allows one writer; serializing avoids SQLITE_BUSY under load
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history schema: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add records an execution, truncating its output
+func (s *Store) Add(ctx context.Context, r Record) error {
+	files, err := json.Marshal(r.Files)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO executions
+		(conversation_id, language, version, image, started_at, duration_ms, exit_code, success, timed_out, stdout, stderr, files)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ConversationID, r.Language, r.Version, r.Image, r.StartedAt.UnixMilli(), r.DurationMs,
+		r.ExitCode, r.Success, r.TimedOut, truncate(r.Stdout), truncate(r.Stderr), string(files))
+	if err != nil {
+		return fmt.Errorf("failed to record execution: %w", err)
+	}
+	return nil
+}
+
+// Query returns records matching the filter, newest first
+func (s *Store) Query(ctx context.Context, f Filter) ([]Record, error) {
+	if f.Limit <= 0 {
+		f.Limit = DefaultLimit
+	}
+	if f.Limit > MaxLimit {
+		f.Limit = MaxLimit
+	}
+
+	var where []string
+	var args []interface{}
+	if f.ConversationID != "" {
+		where = append(where, "conversation_id = ?")
+		args = append(args, f.ConversationID)
+	}
+	if f.Language != "" {
+		where = append(where, "language = ?")
+		args = append(args, f.Language)
+	}
+	if f.Before > 0 {
+		where = append(where, "id < ?")
+		args = append(args, f.Before)
+	}
+
+	query := `SELECT id, conversation_id, language, version, image, started_at, duration_ms,
+		exit_code, success, timed_out, stdout, stderr, files FROM executions`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		var startedAt int64
+		var files string
+		if err := rows.Scan(&r.ID, &r.ConversationID, &r.Language, &r.Version, &r.Image, &startedAt,
+			&r.DurationMs, &r.ExitCode, &r.Success, &r.TimedOut, &r.Stdout, &r.Stderr, &files); err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		r.StartedAt = time.UnixMilli(startedAt).UTC()
+		json.Unmarshal([]byte(files), &r.Files)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// truncate caps output at maxOutputBytes without splitting a UTF-8 sequence
+func truncate(s string) string {
+	if len(s) <= maxOutputBytes {
+		return s
+	}
+	cut := maxOutputBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + truncatedMarker
+}
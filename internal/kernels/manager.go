@@ -0,0 +1,232 @@
+package kernels
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+)
+
+const (
+	// Labels on kernel containers
+	conversationLabel = "sandbox.kernel.conversation" // Hashed sandbox directory
+	settingsLabel     = "sandbox.kernel.settings"     // Digest of the Spec it was started with
+	startedLabel      = "sandbox.kernel.started"      // Unix seconds
+)
+
+// Spec describes the kernel an execution needs. A conversation's kernel is
+// replaced when a call needs different settings
+type Spec struct {
+	Runner      runner.RunnerInfo
+	SandboxDir  string // Host path mounted at /data
+	User        string
+	Network     bool
+	Restricted  bool
+	GPU         bool
+	Environment map[string]string
+}
+
+// digest identifies the settings a kernel was started with. The
+// environment is hashed, so secrets never end up in a label
+func (s Spec) digest() string {
+	h := sha256.New()
+	image := s.Runner.ImageID
+	if image == "" {
+		image = s.Runner.Image
+	}
+	fmt.Fprintf(h, "%s\n%s\n%v\n%v\n%v\n", image, s.User, s.Network, s.Restricted, s.GPU)
+	keys := make([]string, 0, len(s.Environment))
+	for key := range s.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s=%s\n", key, s.Environment[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Manager keeps one Jupyter kernel container per conversation, for
+// run_code's kernel mode, and removes it once it sits idle or loses its
+// sandbox. A nil Manager has kernels disabled
+type Manager struct {
+	cli         *client.Client
+	executor    *runner.Executor
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	lastUsed map[string]time.Time // Hashed sandbox directory -> last execution
+}
+
+// NewManager creates a kernel manager. It returns nil when idleTimeout is 0
+func NewManager(cli *client.Client, executor *runner.Executor, idleTimeout time.Duration) *Manager {
+	if idleTimeout <= 0 {
+		return nil
+	}
+	return &Manager{
+		cli:         cli,
+		executor:    executor,
+		idleTimeout: idleTimeout,
+		lastUsed:    make(map[string]time.Time),
+	}
+}
+
+// Enabled reports whether kernels can be used
+func (m *Manager) Enabled() bool {
+	return m != nil
+}
+
+// containerName is the name of a sandbox's kernel container
+func containerName(hashedDir string) string {
+	return "sandbox-kernel-" + hashedDir
+}
+
+// Execute runs code in the kernel of the sandbox in hashedDir, starting one
+// if there is none yet, it has died or it was started with other settings.
+// restart replaces a running kernel, discarding its state. started reports
+// that the code ran in a new kernel. ctx carries the execution options
+// (packages, services, restricted network) used when starting one
+func (m *Manager) Execute(ctx context.Context, hashedDir string, spec Spec, code string, restart bool) (result runner.ExecutionResult, started bool) {
+	id, started, err := m.ensure(ctx, hashedDir, spec, restart)
+	if err != nil {
+		log.Printf("Failed to start kernel for sandbox %s: %v", hashedDir, err)
+		return runner.ExecutionResult{Success: false, Stderr: err.Error(), Error: err}, false
+	}
+	result = m.executor.ExecuteInKernel(ctx, spec.Runner, spec.SandboxDir, id, code)
+
+	m.mu.Lock()
+	m.lastUsed[hashedDir] = time.Now()
+	m.mu.Unlock()
+	return result, started
+}
+
+// ensure returns the ID of the sandbox's running kernel container with the
+// settings of spec, starting one if needed
+func (m *Manager) ensure(ctx context.Context, hashedDir string, spec Spec, restart bool) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	digest := spec.digest()
+	existing, err := m.containers(ctx, hashedDir)
+	if err != nil {
+		return "", false, err
+	}
+	for _, c := range existing {
+		if !restart && c.State == "running" && c.Labels[settingsLabel] == digest {
+			m.lastUsed[hashedDir] = time.Now()
+			return c.ID, false, nil
+		}
+		if err := m.removeLocked(ctx, c.ID, hashedDir); err != nil {
+			return "", false, err
+		}
+	}
+
+	labels := map[string]string{
+		conversationLabel: hashedDir,
+		settingsLabel:     digest,
+		startedLabel:      strconv.FormatInt(time.Now().Unix(), 10),
+	}
+	id, err := m.executor.StartProcess(ctx, spec.Runner, spec.SandboxDir, spec.User, containerName(hashedDir), spec.Runner.Kernel, spec.Network, spec.Environment, labels)
+	if err != nil {
+		return "", false, err
+	}
+	m.lastUsed[hashedDir] = time.Now()
+	log.Printf("Kernel started for sandbox %s (%s)", hashedDir, spec.Runner.Image)
+	return id, true, nil
+}
+
+// Loop removes idle and orphaned kernels every interval until ctx is done
+func (m *Manager) Loop(ctx context.Context, interval time.Duration, exists func(hashedDir string) bool) {
+	if m == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.reap(ctx, exists)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap removes kernels idle for longer than the idle timeout, that have
+// exited, or whose sandbox no longer exists
+func (m *Manager) reap(ctx context.Context, exists func(hashedDir string) bool) {
+	found, err := m.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", conversationLabel)),
+	})
+	if err != nil {
+		log.Printf("Failed to list kernel containers: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, c := range found {
+		hashedDir := c.Labels[conversationLabel]
+		lastUsed, ok := m.lastUsed[hashedDir]
+		if !ok {
+			// Unknown after a restart; the idle timeout starts over
+			lastUsed = now
+			m.lastUsed[hashedDir] = now
+		}
+
+		var reason string
+		switch {
+		case !exists(hashedDir):
+			reason = "its sandbox was deleted"
+		case c.State != "running":
+			reason = "it exited"
+		case now.Sub(lastUsed) > m.idleTimeout:
+			reason = fmt.Sprintf("it was idle for %s", m.idleTimeout)
+		}
+		if reason == "" {
+			continue
+		}
+		if err := m.removeLocked(ctx, c.ID, hashedDir); err != nil {
+			log.Printf("Failed to remove kernel of sandbox %s: %v", hashedDir, err)
+			continue
+		}
+		log.Printf("Removed kernel of sandbox %s: %s", hashedDir, reason)
+	}
+}
+
+// removeLocked force-removes a kernel container
+func (m *Manager) removeLocked(ctx context.Context, id, hashedDir string) error {
+	delete(m.lastUsed, hashedDir)
+	removeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err := m.cli.ContainerRemove(removeCtx, id, container.RemoveOptions{Force: true}); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to remove kernel container: %w", err)
+	}
+	return nil
+}
+
+// containers lists a sandbox's kernel containers, running or not
+func (m *Manager) containers(ctx context.Context, hashedDir string) ([]container.Summary, error) {
+	found, err := m.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", conversationLabel+"="+hashedDir)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list kernels: %w", err)
+	}
+	return found, nil
+}
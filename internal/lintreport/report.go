@@ -0,0 +1,134 @@
+package lintreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is one problem a linter reported
+type Diagnostic struct {
+	File     string // Relative to /data
+	Line     int
+	Column   int
+	Code     string // Rule, e.g. F401 or no-unused-vars; empty for syntax errors
+	Severity string // error or warning
+	Message  string
+	Fixable  bool // The linter can fix it automatically
+}
+
+type ruffDiagnostic struct {
+	Code     *string         `json:"code"`
+	Message  string          `json:"message"`
+	Filename string          `json:"filename"`
+	Fix      json.RawMessage `json:"fix"`
+	Location struct {
+		Row    int `json:"row"`
+		Column int `json:"column"`
+	} `json:"location"`
+}
+
+// ParseRuff reads the report ruff check --output-format=json writes. Ruff
+// has no severities: syntax errors are errors, rule violations warnings
+func ParseRuff(data []byte) ([]Diagnostic, error) {
+	var ruff []ruffDiagnostic
+	if err := json.Unmarshal(data, &ruff); err != nil {
+		return nil, fmt.Errorf("invalid ruff report: %w", err)
+	}
+	diagnostics := make([]Diagnostic, 0, len(ruff))
+	for _, d := range ruff {
+		diagnostic := Diagnostic{
+			File:     relative(d.Filename),
+			Line:     d.Location.Row,
+			Column:   d.Location.Column,
+			Severity: "error",
+			Message:  d.Message,
+			Fixable:  len(d.Fix) > 0 && string(d.Fix) != "null",
+		}
+		if d.Code != nil {
+			diagnostic.Code = *d.Code
+			diagnostic.Severity = "warning"
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics, nil
+}
+
+type eslintFile struct {
+	FilePath string `json:"filePath"`
+	Messages []struct {
+		RuleID   *string         `json:"ruleId"`
+		Severity int             `json:"severity"` // 1 warning, 2 error
+		Message  string          `json:"message"`
+		Line     int             `json:"line"`
+		Column   int             `json:"column"`
+		Fix      json.RawMessage `json:"fix"`
+	} `json:"messages"`
+}
+
+// ParseESLint reads the report eslint --format json writes
+func ParseESLint(data []byte) ([]Diagnostic, error) {
+	var files []eslintFile
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, fmt.Errorf("invalid ESLint report: %w", err)
+	}
+	var diagnostics []Diagnostic
+	for _, file := range files {
+		for _, m := range file.Messages {
+			diagnostic := Diagnostic{
+				File:     relative(file.FilePath),
+				Line:     m.Line,
+				Column:   m.Column,
+				Severity: "warning",
+				Message:  m.Message,
+				Fixable:  len(m.Fix) > 0 && string(m.Fix) != "null",
+			}
+			if m.RuleID != nil {
+				diagnostic.Code = *m.RuleID
+			}
+			if m.Severity == 2 {
+				diagnostic.Severity = "error"
+			}
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	return diagnostics, nil
+}
+
+// tscLineRe matches a tsc --pretty false diagnostic:
+// file(line,col): error TS2322: message, or without the location
+var tscLineRe = regexp.MustCompile(`^(?:(.+)\((\d+),(\d+)\): )?(error|warning) (\w+): (.*)$`)
+
+// ParseTSC reads the diagnostics tsc --pretty false prints. Indented lines
+// continue the previous message
+func ParseTSC(output []byte) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimRight(line, "\r")
+		m := tscLineRe.FindStringSubmatch(line)
+		if m == nil {
+			if last := len(diagnostics) - 1; last >= 0 && strings.TrimSpace(line) != "" {
+				diagnostics[last].Message += "\n" + strings.TrimSpace(line)
+			}
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		diagnostics = append(diagnostics, Diagnostic{
+			File:     relative(m[1]),
+			Line:     lineNo,
+			Column:   column,
+			Code:     m[5],
+			Severity: m[4],
+			Message:  m[6],
+		})
+	}
+	return diagnostics
+}
+
+// relative strips the sandbox mount point from paths in reports
+func relative(path string) string {
+	return strings.TrimPrefix(path, "/data/")
+}
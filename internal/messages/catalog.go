@@ -0,0 +1,161 @@
+package messages
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Message keys for server-generated text shown to end users
+const (
+	ExecutionTimeout      = "execution_timeout"
+	ExecutionCancelled    = "execution_cancelled"
+	OutOfMemory           = "out_of_memory"
+	UnsupportedLanguage   = "unsupported_language"
+	UnsupportedVersion    = "unsupported_version"
+	InsufficientDiskSpace = "insufficient_disk_space"
+	PackageInstallFailed  = "package_install_failed"
+	PackagesNotInstalled  = "packages_not_installed"
+	PackageCacheFull      = "package_cache_full"
+	NoBrowserRunner       = "no_browser_runner"
+	FileUploaded          = "file_uploaded"
+	FilesUploaded         = "files_uploaded"
+	ArchiveExtracted      = "archive_extracted"
+	FileFetched           = "file_fetched"
+	FileTooLarge          = "file_too_large"
+	SandboxFull           = "sandbox_full"
+	TooManyFiles          = "too_many_files"
+	RepoCloned            = "repo_cloned"
+	Progress              = "progress"
+	SandboxUnavailable    = "sandbox_unavailable"
+	Queued                = "queued"
+	ExecutionQueueFull    = "execution_queue_full"
+	PreviewReady          = "preview_ready"
+	BackendUnavailable    = "backend_unavailable"
+	ExecutionKilled       = "execution_killed"
+)
+
+// defaults are the built-in English messages (Go text/template syntax)
+var defaults = map[string]string{
+	ExecutionTimeout:      "Execution timed out after {{.Timeout}}",
+	ExecutionCancelled:    "Execution cancelled after {{.Elapsed}}",
+	ExecutionKilled:       "Execution stopped by an administrator after {{.Elapsed}}",
+	OutOfMemory:           "Killed for running out of memory (limit {{.Limit}}); use less memory, e.g. by processing data in chunks, or a runner with a higher limit",
+	UnsupportedLanguage:   "Unsupported language: {{.Language}}",
+	UnsupportedVersion:    "Unsupported version {{.Version}} for language {{.Language}}",
+	InsufficientDiskSpace: "The sandbox host is low on disk space; try again later or delete unneeded files",
+	PackageInstallFailed:  "Package install failed; the code was not run",
+	PackagesNotInstalled:  "Failed to install {{.Packages}}",
+	PackageCacheFull:      "The package cache grew past its {{.Limit}} limit and was cleared; install fewer packages",
+	NoBrowserRunner:       "No browser runner available (build an image labelled sandbox.language=browser)",
+	FileUploaded:          "File '{{.Filename}}' uploaded successfully ({{.Bytes}} bytes)",
+	FilesUploaded:         "Uploaded {{.Files}} file(s) ({{.Bytes}} bytes)",
+	ArchiveExtracted:      "Extracted {{.Files}} file(s) from '{{.Filename}}' into /data",
+	FileFetched:           "Downloaded '{{.Filename}}' from {{.URL}} ({{.Bytes}} bytes)",
+	FileTooLarge:          "'{{.Filename}}' is larger than the {{.Limit}} file size limit; nothing was written",
+	SandboxFull:           "The sandbox's files would exceed its {{.Limit}} limit; delete unneeded files first. Nothing was written",
+	TooManyFiles:          "The sandbox would hold more than {{.Limit}} files; delete unneeded files first. Nothing was written",
+	RepoCloned:            "Cloned {{.URL}} into '{{.Directory}}' ({{.Files}} file(s) at commit {{.Commit}})",
+	Progress:              "Running for {{.Seconds}}s (stdout: {{.StdoutBytes}} bytes, stderr: {{.StderrBytes}} bytes)",
+	SandboxUnavailable:    "The sandbox could not be started ({{.Stage}} failed); this is a server problem, not a problem with the code",
+	Queued:                "Queued at position {{.Position}} (estimated wait {{.Wait}})",
+	ExecutionQueueFull:    "Too many executions are waiting to run; try again shortly",
+	PreviewReady:          "Preview available at {{.URL}} while the code runs",
+	BackendUnavailable:    "The execution backend is unavailable (the container engine is restarting or unreachable); this is a server problem, try again shortly",
+}
+
+// Args are the values a message template may reference
+type Args map[string]interface{}
+
+// Catalog renders messages from templates. A nil Catalog uses the defaults
+type Catalog struct {
+	templates map[string]*template.Template
+}
+
+// Default returns the built-in English catalog
+func Default() *Catalog {
+	c, err := parse(defaults)
+	if err != nil {
+		panic(err) // The defaults are constants
+	}
+	return c
+}
+
+// Load reads a YAML file mapping message keys to templates, falling back to
+// the defaults for keys it doesn't set. An empty path yields the defaults
+func Load(path string) (*Catalog, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages file: %w", err)
+	}
+	var overrides map[string]string
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse messages file: %w", err)
+	}
+
+	merged := make(map[string]string, len(defaults))
+	for key, text := range defaults {
+		merged[key] = text
+	}
+	for key, text := range overrides {
+		if _, ok := defaults[key]; !ok {
+			return nil, fmt.Errorf("unknown message %q (known: %s)", key, strings.Join(Keys(), ", "))
+		}
+		merged[key] = text
+	}
+	return parse(merged)
+}
+
+// Keys returns the known message keys, sorted
+func Keys() []string {
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func parse(texts map[string]string) (*Catalog, error) {
+	c := &Catalog{templates: make(map[string]*template.Template, len(texts))}
+	for key, text := range texts {
+		tmpl, err := template.New(key).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("message %s: %w", key, err)
+		}
+		c.templates[key] = tmpl
+	}
+	return c, nil
+}
+
+// Format renders a message. A template that fails to render falls back to
+// the default text so users never see an empty message
+func (c *Catalog) Format(key string, args Args) string {
+	if c == nil {
+		c = builtin
+	}
+	tmpl, ok := c.templates[key]
+	if !ok {
+		tmpl = builtin.templates[key]
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, args); err != nil {
+		log.Printf("Failed to render message %s: %v", key, err)
+		b.Reset()
+		builtin.templates[key].Execute(&b, args)
+	}
+	return b.String()
+}
+
+var builtin = Default()
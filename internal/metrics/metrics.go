@@ -0,0 +1,310 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// buckets are the histogram upper bounds in seconds
+var buckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Collector tracks per-language execution concurrency, wait time, duration
+// and outcomes. A nil Collector records nothing
+type Collector struct {
+	mu        sync.Mutex
+	languages map[string]*languageStats
+}
+
+// languageStats holds the counters for one language
+type languageStats struct {
+	running     int
+	peakRunning int
+	successes   uint64
+	failures    uint64
+	timeouts    uint64
+	killed      uint64 // Containers the watchdog removed after their deadline
+	wait        histogram
+	duration    histogram
+
+	// Resource usage of executions whose stats were sampled
+	sampled    uint64
+	cpuSeconds float64
+	memorySum  float64 // Sum of peak memory, for the mean
+	memoryMax  uint64
+	networkRx  uint64
+	networkTx  uint64
+}
+
+// histogram is a cumulative Prometheus-style histogram
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	for i, bound := range buckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (h *histogram) mean() float64 {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / float64(h.count)
+}
+
+// New creates an empty collector
+func New() *Collector {
+	return &Collector{languages: make(map[string]*languageStats)}
+}
+
+// Run tracks a single execution from request to completion
+type Run struct {
+	c        *Collector
+	language string
+	queued   time.Time
+	started  time.Time
+}
+
+// Start records that an execution was requested
+func (c *Collector) Start(language string) *Run {
+	return &Run{c: c, language: language, queued: time.Now()}
+}
+
+// Started records that the container is running; the time since Start is
+// the execution's wait (queueing plus container creation)
+func (r *Run) Started() {
+	if r.c == nil || !r.started.IsZero() {
+		return
+	}
+	r.started = time.Now()
+
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+	stats := r.c.statsLocked(r.language)
+	stats.wait.observe(r.started.Sub(r.queued).Seconds())
+	stats.running++
+	if stats.running > stats.peakRunning {
+		stats.peakRunning = stats.running
+	}
+}
+
+// Usage records the resources an execution consumed
+func (r *Run) Usage(cpu time.Duration, peakMemory, networkRx, networkTx uint64) {
+	if r.c == nil {
+		return
+	}
+
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+	stats := r.c.statsLocked(r.language)
+	stats.sampled++
+	stats.cpuSeconds += cpu.Seconds()
+	stats.memorySum += float64(peakMemory)
+	if peakMemory > stats.memoryMax {
+		stats.memoryMax = peakMemory
+	}
+	stats.networkRx += networkRx
+	stats.networkTx += networkTx
+}
+
+// Finish records the execution's outcome
+func (r *Run) Finish(success, timedOut bool) {
+	if r.c == nil {
+		return
+	}
+
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+	stats := r.c.statsLocked(r.language)
+	if !r.started.IsZero() {
+		stats.running--
+		stats.duration.observe(time.Since(r.started).Seconds())
+	}
+	switch {
+	case timedOut:
+		stats.timeouts++
+	case success:
+		stats.successes++
+	default:
+		stats.failures++
+	}
+}
+
+// WatchdogKill records that a container outlived its deadline and was
+// force-removed by the watchdog
+func (c *Collector) WatchdogKill(language string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statsLocked(language).killed++
+}
+
+func (c *Collector) statsLocked(language string) *languageStats {
+	stats, ok := c.languages[language]
+	if !ok {
+		stats = &languageStats{}
+		c.languages[language] = stats
+	}
+	return stats
+}
+
+// LanguageSnapshot summarizes one language's executions
+type LanguageSnapshot struct {
+	Language            string  `json:"language"`
+	Running             int     `json:"running"`
+	PeakRunning         int     `json:"peakRunning"`
+	Executions          uint64  `json:"executions"`
+	Failures            uint64  `json:"failures"`
+	Timeouts            uint64  `json:"timeouts"`
+	WatchdogKills       uint64  `json:"watchdogKills"`
+	FailureRate         float64 `json:"failureRate"` // Failures and timeouts over executions
+	MeanWaitSeconds     float64 `json:"meanWaitSeconds"`
+	MeanDurationSeconds float64 `json:"meanDurationSeconds"`
+
+	// Over executions whose resource usage was sampled
+	CPUSeconds          float64 `json:"cpuSeconds"`
+	MeanPeakMemoryBytes float64 `json:"meanPeakMemoryBytes"`
+	MaxPeakMemoryBytes  uint64  `json:"maxPeakMemoryBytes"`
+}
+
+// Snapshot returns the current statistics, sorted by language
+func (c *Collector) Snapshot() []LanguageSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshots := make([]LanguageSnapshot, 0, len(c.languages))
+	for language, stats := range c.languages {
+		executions := stats.successes + stats.failures + stats.timeouts
+		snapshot := LanguageSnapshot{
+			Language:            language,
+			Running:             stats.running,
+			PeakRunning:         stats.peakRunning,
+			Executions:          executions,
+			Failures:            stats.failures,
+			Timeouts:            stats.timeouts,
+			WatchdogKills:       stats.killed,
+			MeanWaitSeconds:     stats.wait.mean(),
+			MeanDurationSeconds: stats.duration.mean(),
+			CPUSeconds:          stats.cpuSeconds,
+			MaxPeakMemoryBytes:  stats.memoryMax,
+		}
+		if stats.sampled > 0 {
+			snapshot.MeanPeakMemoryBytes = stats.memorySum / float64(stats.sampled)
+		}
+		if executions > 0 {
+			snapshot.FailureRate = float64(stats.failures+stats.timeouts) / float64(executions)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Language < snapshots[j].Language
+	})
+	return snapshots
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (c *Collector) WritePrometheus(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	languages := make([]string, 0, len(c.languages))
+	for language := range c.languages {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	fmt.Fprintln(w, "# HELP sandbox_executions_running Executions currently running.")
+	fmt.Fprintln(w, "# TYPE sandbox_executions_running gauge")
+	for _, language := range languages {
+		fmt.Fprintf(w, "sandbox_executions_running{language=%q} %d\n", label(language), c.languages[language].running)
+	}
+
+	fmt.Fprintln(w, "# HELP sandbox_executions_total Completed executions by outcome.")
+	fmt.Fprintln(w, "# TYPE sandbox_executions_total counter")
+	for _, language := range languages {
+		stats := c.languages[language]
+		fmt.Fprintf(w, "sandbox_executions_total{language=%q,outcome=\"success\"} %d\n", label(language), stats.successes)
+		fmt.Fprintf(w, "sandbox_executions_total{language=%q,outcome=\"failure\"} %d\n", label(language), stats.failures)
+		fmt.Fprintf(w, "sandbox_executions_total{language=%q,outcome=\"timeout\"} %d\n", label(language), stats.timeouts)
+	}
+
+	fmt.Fprintln(w, "# HELP sandbox_watchdog_kills_total Containers force-removed after exceeding their deadline plus grace.")
+	fmt.Fprintln(w, "# TYPE sandbox_watchdog_kills_total counter")
+	for _, language := range languages {
+		fmt.Fprintf(w, "sandbox_watchdog_kills_total{language=%q} %d\n", label(language), c.languages[language].killed)
+	}
+
+	fmt.Fprintln(w, "# HELP sandbox_execution_cpu_seconds_total CPU time consumed by executions.")
+	fmt.Fprintln(w, "# TYPE sandbox_execution_cpu_seconds_total counter")
+	for _, language := range languages {
+		fmt.Fprintf(w, "sandbox_execution_cpu_seconds_total{language=%q} %g\n", label(language), c.languages[language].cpuSeconds)
+	}
+
+	fmt.Fprintln(w, "# HELP sandbox_execution_peak_memory_bytes Peak memory of sampled executions.")
+	fmt.Fprintln(w, "# TYPE sandbox_execution_peak_memory_bytes summary")
+	for _, language := range languages {
+		stats := c.languages[language]
+		fmt.Fprintf(w, "sandbox_execution_peak_memory_bytes_sum{language=%q} %g\n", label(language), stats.memorySum)
+		fmt.Fprintf(w, "sandbox_execution_peak_memory_bytes_count{language=%q} %d\n", label(language), stats.sampled)
+	}
+
+	fmt.Fprintln(w, "# HELP sandbox_execution_network_bytes_total Network traffic of executions.")
+	fmt.Fprintln(w, "# TYPE sandbox_execution_network_bytes_total counter")
+	for _, language := range languages {
+		stats := c.languages[language]
+		fmt.Fprintf(w, "sandbox_execution_network_bytes_total{language=%q,direction=\"rx\"} %d\n", label(language), stats.networkRx)
+		fmt.Fprintf(w, "sandbox_execution_network_bytes_total{language=%q,direction=\"tx\"} %d\n", label(language), stats.networkTx)
+	}
+
+	writeHistogram(w, "sandbox_execution_wait_seconds", "Time from request until the container started.", languages, func(language string) *histogram {
+		return &c.languages[language].wait
+	})
+	writeHistogram(w, "sandbox_execution_duration_seconds", "Time containers spent running.", languages, func(language string) *histogram {
+		return &c.languages[language].duration
+	})
+}
+
+func writeHistogram(w io.Writer, name, help string, languages []string, get func(string) *histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, language := range languages {
+		h := get(language)
+		var cumulative uint64
+		for i, bound := range buckets {
+			if h.counts != nil {
+				cumulative += h.counts[i]
+			}
+			fmt.Fprintf(w, "%s_bucket{language=%q,le=\"%g\"} %d\n", name, label(language), bound, cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{language=%q,le=\"+Inf\"} %d\n", name, label(language), h.count)
+		fmt.Fprintf(w, "%s_sum{language=%q} %g\n", name, label(language), h.sum)
+		fmt.Fprintf(w, "%s_count{language=%q} %d\n", name, label(language), h.count)
+	}
+}
+
+// label strips characters %q would escape differently from Prometheus
+func label(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,159 @@
+package pager
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrExpired is returned when a continuation token refers to output that has
+// been evicted or never existed
+var ErrExpired = errors.New("output expired or not found")
+
+// ErrInvalidToken is returned for malformed continuation tokens
+var ErrInvalidToken = errors.New("invalid continuation token")
+
+type entry struct {
+	data    string
+	expires time.Time
+}
+
+// Store keeps oversized outputs in memory so they can be read page by page
+type Store struct {
+	mu         sync.Mutex
+	entries    map[string]*entry
+	order      []string
+	pageSize   int
+	ttl        time.Duration
+	maxEntries int
+}
+
+// NewStore creates an output store returning pages of pageSize bytes.
+// Outputs expire after ttl; at most maxEntries are retained.
+func NewStore(pageSize int, ttl time.Duration, maxEntries int) *Store {
+	return &Store{
+		entries:    make(map[string]*entry),
+		pageSize:   pageSize,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+// PageSize returns the maximum number of bytes returned per page
+func (s *Store) PageSize() int {
+	return s.pageSize
+}
+
+// Paginate returns the first page of data. If data fits in one page it is
+// returned unchanged with an empty token; otherwise the full output is stored
+// and a continuation token for the next page is returned.
+func (s *Store) Paginate(data string) (string, string) {
+	if len(data) <= s.pageSize {
+		return data, ""
+	}
+
+	id := newID()
+	s.mu.Lock()
+	s.evictLocked(time.Now())
+	s.entries[id] = &entry{data: data, expires: time.Now().Add(s.ttl)}
+	s.order = append(s.order, id)
+	s.mu.Unlock()
+
+	page, next := s.slice(data, 0)
+	return page, makeToken(id, next)
+}
+
+// Read returns the page referenced by token, the token for the following page
+// (empty on the last page), the page's byte offset and the total output size
+func (s *Store) Read(token string) (page, nextToken string, offset, total int, err error) {
+	id, offset, err := parseToken(token)
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+
+	s.mu.Lock()
+	e, ok := s.entries[id]
+	if ok && time.Now().After(e.expires) {
+		ok = false
+	}
+	s.mu.Unlock()
+	if !ok {
+		return "", "", 0, 0, ErrExpired
+	}
+	if offset > len(e.data) {
+		return "", "", 0, 0, ErrInvalidToken
+	}
+
+	page, next := s.slice(e.data, offset)
+	if next < len(e.data) {
+		nextToken = makeToken(id, next)
+	}
+	return page, nextToken, offset, len(e.data), nil
+}
+
+// slice returns one page starting at offset, ending on a UTF-8 boundary, and
+// the offset of the following page
+func (s *Store) slice(data string, offset int) (string, int) {
+	end := offset + s.pageSize
+	if end >= len(data) {
+		return data[offset:], len(data)
+	}
+	for end > offset && !utf8.RuneStart(data[end]) {
+		end--
+	}
+	if end == offset {
+		end = offset + s.pageSize
+	}
+	return data[offset:end], end
+}
+
+// evictLocked drops expired entries and the oldest entries beyond the limit;
+// caller must hold s.mu
+func (s *Store) evictLocked(now time.Time) {
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if e, ok := s.entries[id]; ok && now.Before(e.expires) {
+			kept = append(kept, id)
+		} else {
+			delete(s.entries, id)
+		}
+	}
+	s.order = kept
+
+	for len(s.order) >= s.maxEntries && len(s.order) > 0 {
+		delete(s.entries, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func makeToken(id string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id + ":" + strconv.Itoa(offset)))
+}
+
+func parseToken(token string) (string, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", 0, ErrInvalidToken
+	}
+	id, offsetStr, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return "", 0, ErrInvalidToken
+	}
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil || offset < 0 {
+		return "", 0, ErrInvalidToken
+	}
+	return id, offset, nil
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,155 @@
+package preview
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry routes /preview/{hashedDir}/ requests to the ports published by
+// running executions. A nil Registry has previews disabled
+type Registry struct {
+	bindHost     string // Host address containers publish preview ports on
+	upstreamHost string // Address the server reaches those ports at
+
+	mu       sync.Mutex
+	routes   map[string]string    // Hashed sandbox directory -> upstream host:port
+	requests map[string]time.Time // Hashed sandbox directory -> last proxied request
+}
+
+// New creates a registry publishing ports on bindHost. upstreamHost is
+// where the server connects to them, e.g. host.docker.internal when the
+// server itself runs in a container; empty uses bindHost
+func New(bindHost, upstreamHost string) *Registry {
+	if upstreamHost == "" {
+		upstreamHost = bindHost
+		if ip := net.ParseIP(bindHost); ip != nil && ip.IsUnspecified() {
+			upstreamHost = "127.0.0.1"
+		}
+	}
+	return &Registry{
+		bindHost:     bindHost,
+		upstreamHost: upstreamHost,
+		routes:       make(map[string]string),
+		requests:     make(map[string]time.Time),
+	}
+}
+
+// Enabled reports whether previews can be published
+func (r *Registry) Enabled() bool {
+	return r != nil
+}
+
+// BindHost returns the host address preview ports are published on
+func (r *Registry) BindHost() string {
+	return r.bindHost
+}
+
+// Register routes a sandbox's previews to a published host port until the
+// returned function is called. A later registration for the same sandbox
+// replaces it
+func (r *Registry) Register(hashedDir, hostPort string) func() {
+	upstream := net.JoinHostPort(r.upstreamHost, hostPort)
+	r.mu.Lock()
+	r.routes[hashedDir] = upstream
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.routes[hashedDir] == upstream {
+			delete(r.routes, hashedDir)
+			delete(r.requests, hashedDir)
+		}
+	}
+}
+
+// LastRequest returns when a sandbox's preview was last requested, or the
+// zero time if it hasn't been since it was registered
+func (r *Registry) LastRequest(hashedDir string) time.Time {
+	if r == nil {
+		return time.Time{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[hashedDir]
+}
+
+// lookup returns the upstream address of a sandbox's preview, recording
+// the request
+func (r *Registry) lookup(hashedDir string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upstream, ok := r.routes[hashedDir]
+	if ok {
+		r.requests[hashedDir] = time.Now()
+	}
+	return upstream, ok
+}
+
+// ServeHTTP proxies /preview/{hashedDir}/{path} to the sandbox's app as
+// /{path}. The request path must already be relative to the server's base
+// path
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rest := strings.TrimPrefix(req.URL.Path, "/preview/")
+	hashedDir, path, found := strings.Cut(rest, "/")
+	if !found {
+		// Relative links in the app only resolve under a trailing slash. The
+		// Location is relative as the path may have lost a base path prefix
+		w.Header().Set("Location", hashedDir+"/")
+		w.WriteHeader(http.StatusMovedPermanently)
+		return
+	}
+	if len(hashedDir) != 16 {
+		http.Error(w, "Invalid directory hash", http.StatusBadRequest)
+		return
+	}
+	upstream, ok := r.lookup(hashedDir)
+	if !ok {
+		http.Error(w, "No preview is running for this sandbox", http.StatusBadGateway)
+		return
+	}
+
+	// Pages run with an opaque origin (see security.PreviewPolicy), so the
+	// app's own fetch() calls are cross-origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", req.Header.Get("Access-Control-Request-Method"))
+		if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host = upstream
+			pr.Out.URL.Path = "/" + path
+			pr.Out.URL.RawPath = ""
+			pr.Out.Host = upstream
+			// Never hand the server's credentials or other apps' cookies to sandbox code
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("Cookie")
+			pr.SetXForwarded()
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// The proxy's own headers apply; the app can't loosen them, or
+			// set cookies every other preview on the origin would receive
+			resp.Header.Del("Content-Security-Policy")
+			resp.Header.Del("Access-Control-Allow-Origin")
+			resp.Header.Del("Set-Cookie")
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Printf("[HTTP] Preview %s unreachable: %v", hashedDir, err)
+			http.Error(w, fmt.Sprintf("The app is not accepting connections: %v", err), http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, req)
+}
@@ -0,0 +1,430 @@
+package processes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/jsc/mcp-code-sandbox/internal/preview"
+	"github.com/jsc/mcp-code-sandbox/internal/runner"
+)
+
+const (
+	// Labels on process containers
+	conversationLabel = "sandbox.process.conversation" // Hashed sandbox directory
+	nameLabel         = "sandbox.process"              // Process name
+	languageLabel     = "sandbox.process.language"
+	commandLabel      = "sandbox.process.command"
+	startedLabel      = "sandbox.process.started"      // Unix seconds
+	previewPortLabel  = "sandbox.process.preview-port" // Container port proxied under /preview/
+
+	// DefaultTailLines is how much output list_processes returns per process
+	DefaultTailLines = 20
+	maxTailLines     = 1000
+)
+
+// validName restricts process names to something safe in a container name
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Process is a conversation's background process
+type Process struct {
+	Name       string    `json:"name"`
+	Language   string    `json:"language"`
+	Command    string    `json:"command"`
+	Status     string    `json:"status"`             // "running" or "exited"
+	ExitCode   *int      `json:"exitCode,omitempty"` // Set once exited
+	StartedAt  time.Time `json:"startedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"` // Removed by then at the latest
+	PreviewURL string    `json:"previewUrl,omitempty"`
+	Output     string    `json:"output,omitempty"` // Last lines of stdout and stderr
+}
+
+// Spec describes a process to start
+type Spec struct {
+	Runner      runner.RunnerInfo
+	SandboxDir  string // Host path mounted at /data
+	User        string
+	Command     string
+	Network     bool
+	Environment map[string]string
+	PreviewPort int
+	PreviewURL  string
+}
+
+// Manager runs long-lived runner containers for conversations and removes
+// them when they outlive their lifetime, sit idle or lose their sandbox.
+// A nil Manager has processes disabled
+type Manager struct {
+	cli      *client.Client
+	executor *runner.Executor
+	previews *preview.Registry
+
+	lifetime           time.Duration // Since start
+	idleTimeout        time.Duration // Since the last tool call or preview request
+	maxPerConversation int
+
+	mu         sync.Mutex
+	lastUsed   map[string]time.Time // Container name -> last tool call
+	unregister map[string]func()    // Container name -> preview route removal
+}
+
+// NewManager creates a process manager. It returns nil when
+// maxPerConversation is 0
+func NewManager(cli *client.Client, executor *runner.Executor, previews *preview.Registry, lifetime, idleTimeout time.Duration, maxPerConversation int) *Manager {
+	if maxPerConversation <= 0 {
+		return nil
+	}
+	return &Manager{
+		cli:                cli,
+		executor:           executor,
+		previews:           previews,
+		lifetime:           lifetime,
+		idleTimeout:        idleTimeout,
+		maxPerConversation: maxPerConversation,
+		lastUsed:           make(map[string]time.Time),
+		unregister:         make(map[string]func()),
+	}
+}
+
+// Enabled reports whether processes can be started
+func (m *Manager) Enabled() bool {
+	return m != nil
+}
+
+// Limits returns the process lifetime, idle timeout and per-conversation maximum
+func (m *Manager) Limits() (lifetime, idleTimeout time.Duration, maxPerConversation int) {
+	return m.lifetime, m.idleTimeout, m.maxPerConversation
+}
+
+// containerName is the name of a sandbox's process container
+func containerName(hashedDir, name string) string {
+	return "sandbox-proc-" + hashedDir + "-" + name
+}
+
+// Start starts a named process for the sandbox in hashedDir. A process that
+// has exited is replaced; a running one must be stopped first. ctx carries
+// the execution options (packages, services, restricted network)
+func (m *Manager) Start(ctx context.Context, hashedDir, name string, spec Spec) (Process, error) {
+	if !validName.MatchString(name) {
+		return Process{}, fmt.Errorf("invalid process name %q: use up to 32 lowercase letters, digits, - and _", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cname := containerName(hashedDir, name)
+	existing, err := m.containers(ctx, hashedDir)
+	if err != nil {
+		return Process{}, err
+	}
+	running := 0
+	for _, c := range existing {
+		if c.Labels[nameLabel] == name {
+			if c.State == "running" {
+				return Process{}, fmt.Errorf("process %q is already running; stop it first", name)
+			}
+			m.removeLocked(ctx, c.ID, cname)
+			continue
+		}
+		if c.State == "running" {
+			running++
+		}
+	}
+	if running >= m.maxPerConversation {
+		return Process{}, fmt.Errorf("at most %d processes may run per conversation; stop one first", m.maxPerConversation)
+	}
+
+	started := time.Now()
+	labels := map[string]string{
+		conversationLabel: hashedDir,
+		nameLabel:         name,
+		languageLabel:     spec.Runner.Language,
+		commandLabel:      spec.Command,
+		startedLabel:      strconv.FormatInt(started.Unix(), 10),
+	}
+	if spec.PreviewPort != 0 {
+		labels[previewPortLabel] = strconv.Itoa(spec.PreviewPort)
+		ctx = runner.WithPreview(ctx, spec.PreviewPort, m.previews.BindHost(), func(hostPort string) string {
+			m.unregister[cname] = m.previews.Register(hashedDir, hostPort)
+			return spec.PreviewURL
+		})
+	}
+
+	if _, err := m.executor.StartProcess(ctx, spec.Runner, spec.SandboxDir, spec.User, cname, spec.Command, spec.Network, spec.Environment, labels); err != nil {
+		return Process{}, err
+	}
+	m.lastUsed[cname] = started
+	log.Printf("Process %s started for sandbox %s", name, hashedDir)
+
+	return Process{
+		Name:       name,
+		Language:   spec.Runner.Language,
+		Command:    spec.Command,
+		Status:     "running",
+		StartedAt:  started,
+		ExpiresAt:  started.Add(m.lifetime),
+		PreviewURL: spec.PreviewURL,
+	}, nil
+}
+
+// Stop removes a sandbox's process and returns its final state and output
+func (m *Manager) Stop(ctx context.Context, hashedDir, name string, tailLines int, previewURL string) (Process, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cname := containerName(hashedDir, name)
+	found, err := m.containers(ctx, hashedDir)
+	if err != nil {
+		return Process{}, err
+	}
+	for _, c := range found {
+		if c.Labels[nameLabel] != name {
+			continue
+		}
+		process := m.describe(ctx, c, tailLines, previewURL)
+		if err := m.removeLocked(ctx, c.ID, cname); err != nil {
+			return Process{}, err
+		}
+		log.Printf("Process %s stopped for sandbox %s", name, hashedDir)
+		return process, nil
+	}
+	return Process{}, fmt.Errorf("no process named %q", name)
+}
+
+// List returns a sandbox's processes with the last tailLines lines of
+// their output, and counts as using them
+func (m *Manager) List(ctx context.Context, hashedDir string, tailLines int, previewURL string) ([]Process, error) {
+	if m == nil {
+		return nil, nil
+	}
+	found, err := m.containers(ctx, hashedDir)
+	if err != nil {
+		return nil, err
+	}
+
+	processes := make([]Process, 0, len(found))
+	now := time.Now()
+	m.mu.Lock()
+	for _, c := range found {
+		m.lastUsed[containerName(hashedDir, c.Labels[nameLabel])] = now
+	}
+	m.mu.Unlock()
+	for _, c := range found {
+		processes = append(processes, m.describe(ctx, c, tailLines, previewURL))
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].Name < processes[j].Name })
+	return processes, nil
+}
+
+// Teardown removes all of a sandbox's processes
+func (m *Manager) Teardown(ctx context.Context, hashedDir string) error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found, err := m.containers(ctx, hashedDir)
+	if err != nil {
+		return err
+	}
+	for _, c := range found {
+		if err := m.removeLocked(ctx, c.ID, containerName(hashedDir, c.Labels[nameLabel])); err != nil {
+			return err
+		}
+	}
+	if len(found) > 0 {
+		log.Printf("Removed %d process(es) for sandbox %s", len(found), hashedDir)
+	}
+	return nil
+}
+
+// Loop removes expired, idle and orphaned processes every interval until
+// ctx is done. It also restores preview routes after a server restart
+func (m *Manager) Loop(ctx context.Context, interval time.Duration, exists func(hashedDir string) bool) {
+	if m == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.reap(ctx, exists)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap removes processes past their lifetime or idle timeout, or whose
+// sandbox no longer exists
+func (m *Manager) reap(ctx context.Context, exists func(hashedDir string) bool) {
+	found, err := m.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", conversationLabel)),
+	})
+	if err != nil {
+		log.Printf("Failed to list process containers: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, c := range found {
+		hashedDir, name := c.Labels[conversationLabel], c.Labels[nameLabel]
+		cname := containerName(hashedDir, name)
+		started := labelTime(c.Labels[startedLabel])
+		lastUsed, ok := m.lastUsed[cname]
+		if !ok {
+			// Unknown after a restart; the idle timeout starts over
+			lastUsed = now
+			m.lastUsed[cname] = now
+		}
+		if c.Labels[previewPortLabel] != "" {
+			if request := m.previews.LastRequest(hashedDir); request.After(lastUsed) {
+				lastUsed = request
+			}
+		}
+
+		var reason string
+		switch {
+		case !exists(hashedDir):
+			reason = "its sandbox was deleted"
+		case now.Sub(started) > m.lifetime:
+			reason = fmt.Sprintf("it reached its %s lifetime", m.lifetime)
+		case now.Sub(lastUsed) > m.idleTimeout:
+			reason = fmt.Sprintf("it was idle for %s", m.idleTimeout)
+		}
+		if reason == "" {
+			if c.State == "running" {
+				m.restorePreviewLocked(c, hashedDir, cname)
+			}
+			continue
+		}
+		if err := m.removeLocked(ctx, c.ID, cname); err != nil {
+			log.Printf("Failed to remove process %s of sandbox %s: %v", name, hashedDir, err)
+			continue
+		}
+		log.Printf("Removed process %s of sandbox %s: %s", name, hashedDir, reason)
+	}
+}
+
+// restorePreviewLocked routes a running process's preview again if the
+// server has restarted since it was started
+func (m *Manager) restorePreviewLocked(c container.Summary, hashedDir, cname string) {
+	if _, ok := m.unregister[cname]; ok || !m.previews.Enabled() {
+		return
+	}
+	port, err := strconv.Atoi(c.Labels[previewPortLabel])
+	if err != nil {
+		return
+	}
+	for _, p := range c.Ports {
+		if int(p.PrivatePort) == port && p.PublicPort != 0 {
+			m.unregister[cname] = m.previews.Register(hashedDir, strconv.Itoa(int(p.PublicPort)))
+			return
+		}
+	}
+}
+
+// removeLocked force-removes a process container and its preview route
+func (m *Manager) removeLocked(ctx context.Context, id, cname string) error {
+	if unregister, ok := m.unregister[cname]; ok {
+		unregister()
+		delete(m.unregister, cname)
+	}
+	delete(m.lastUsed, cname)
+	removeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err := m.cli.ContainerRemove(removeCtx, id, container.RemoveOptions{Force: true}); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to remove process container: %w", err)
+	}
+	return nil
+}
+
+// containers lists a sandbox's process containers, running or not
+func (m *Manager) containers(ctx context.Context, hashedDir string) ([]container.Summary, error) {
+	found, err := m.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", conversationLabel+"="+hashedDir)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	return found, nil
+}
+
+// describe builds a process's state from its container, with output
+func (m *Manager) describe(ctx context.Context, c container.Summary, tailLines int, previewURL string) Process {
+	started := labelTime(c.Labels[startedLabel])
+	process := Process{
+		Name:      c.Labels[nameLabel],
+		Language:  c.Labels[languageLabel],
+		Command:   c.Labels[commandLabel],
+		Status:    c.State,
+		StartedAt: started,
+		ExpiresAt: started.Add(m.lifetime),
+	}
+	if c.Labels[previewPortLabel] != "" && c.State == "running" {
+		process.PreviewURL = previewURL
+	}
+	if c.State != "running" {
+		process.Status = "exited"
+		if inspect, err := m.cli.ContainerInspect(ctx, c.ID); err == nil && inspect.State != nil {
+			exitCode := inspect.State.ExitCode
+			process.ExitCode = &exitCode
+		}
+	}
+	process.Output = m.output(ctx, c.ID, tailLines)
+	return process
+}
+
+// output returns the last lines a process container wrote
+func (m *Manager) output(ctx context.Context, id string, tailLines int) string {
+	if tailLines <= 0 {
+		return ""
+	}
+	tailLines = min(tailLines, maxTailLines)
+	logs, err := m.cli.ContainerLogs(ctx, id, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(tailLines),
+	})
+	if err != nil {
+		log.Printf("Failed to read process output: %v", err)
+		return ""
+	}
+	defer logs.Close()
+
+	// Interleaved in the order they were logged
+	var buf bytes.Buffer
+	if _, err := stdcopy.StdCopy(&buf, &buf, logs); err != nil {
+		log.Printf("Failed to read process output: %v", err)
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// labelTime parses a Unix seconds label
+func labelTime(value string) time.Time {
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Backend is the container engine behind the Docker API
+type Backend string
+
+// Supported backends
+const (
+	BackendDocker Backend = "docker"
+	BackendPodman Backend = "podman" // Rootless Podman via its Docker-compatible API
+)
+
+// PodmanSocket returns the API socket of the user's Podman service, or of
+// the system service if the user has none, as a DOCKER_HOST-style URL
+func PodmanSocket() string {
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates,
+		fmt.Sprintf("/run/user/%d/podman/podman.sock", os.Getuid()),
+		"/run/podman/podman.sock",
+	)
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return "unix://" + path
+		}
+	}
+	return "unix://" + candidates[0]
+}
+
+// bind formats a bind mount. Podman hosts usually run SELinux, which blocks
+// containers from unlabelled host directories, so mounts are relabelled
+// with the shared label (z) that lets several containers use them at once
+func (b Backend) bind(source, target string, readOnly bool) string {
+	var options []string
+	if readOnly {
+		options = append(options, "ro")
+	}
+	if b == BackendPodman {
+		options = append(options, "z")
+	}
+	if len(options) == 0 {
+		return source + ":" + target
+	}
+	return source + ":" + target + ":" + strings.Join(options, ",")
+}
+
+// applyHostConfig adapts a runner's host config to the backend
+func (b Backend) applyHostConfig(hostConfig *container.HostConfig, networkDisabled bool) {
+	if b != BackendPodman {
+		return
+	}
+	// Rootless Podman runs containers in a user namespace where container UID
+	// 1000 is some subordinate host UID that can't write the sandbox
+	// directory. keep-id maps the server's own user to the runner user
+	// instead, so files are owned by the server on both sides
+	hostConfig.UsernsMode = container.UsernsMode("keep-id:uid=1000,gid=1000")
+	if networkDisabled && hostConfig.NetworkMode == "" {
+		// The compat API doesn't always honour NetworkDisabled
+		hostConfig.NetworkMode = "none"
+	}
+}
@@ -0,0 +1,30 @@
+package runner
+
+// LanguageCapabilities summarizes what one language's runners offer
+type LanguageCapabilities struct {
+	Language       string
+	Versions       []string // Ascending; empty when the runner is unversioned
+	DefaultVersion string
+	Install        bool // The default runner can install packages
+}
+
+// Capabilities groups runners (as returned by ListRunners, sorted by language
+// and version) by language. Deriving everything from one ListRunners result
+// keeps tool schemas consistent even if a refresh swaps the index meanwhile
+func Capabilities(runners []RunnerInfo) []LanguageCapabilities {
+	var caps []LanguageCapabilities
+	for _, r := range runners {
+		if len(caps) == 0 || caps[len(caps)-1].Language != r.Language {
+			caps = append(caps, LanguageCapabilities{Language: r.Language})
+		}
+		c := &caps[len(caps)-1]
+		if r.Version != "" {
+			c.Versions = append(c.Versions, r.Version)
+		}
+		if r.Default {
+			c.DefaultVersion = r.Version
+			c.Install = r.Install != ""
+		}
+	}
+	return caps
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CodeFile is where the code is mounted, read-only, for runners that take
+// it as a file (sandbox.code-file). Their stdin then belongs to the program
+const CodeFile = "/sandbox/code"
+
+// Staging is a directory the server writes per-execution files to and
+// containers mount them from
+type Staging struct {
+	Dir     string // Server's view
+	HostDir string // The same directory on the Docker host
+}
+
+// defaultFileRunners run a file from the sandbox (passed as "$1") for
+// well-known languages; images can override or add one with the
+// sandbox.run-file label
+var defaultFileRunners = map[string]string{
+	"python":     "exec python \"$1\"",
+	"browser":    "exec python \"$1\"",
+	"typescript": "exec bun run \"$1\"",
+	"javascript": "exec node \"$1\"",
+}
+
+type stdinKey struct{}
+
+// WithStdin returns a context that pipes data to the program's stdin
+func WithStdin(ctx context.Context, data string) context.Context {
+	return context.WithValue(ctx, stdinKey{}, data)
+}
+
+// stdinFromContext returns the stdin data carried by ctx, if any
+func stdinFromContext(ctx context.Context) string {
+	data, _ := ctx.Value(stdinKey{}).(string)
+	return data
+}
+
+// codeKey is the context key for a staged code file's host path
+type codeKey struct{}
+
+// AcceptsStdin reports whether programs on a runner can be given stdin.
+// Runners without sandbox.code-file or sandbox.compile read their code from
+// stdin
+func (e *Executor) AcceptsStdin(runner RunnerInfo) bool {
+	return (runner.CodeFile || runner.Compile != "") && e.staging.Dir != ""
+}
+
+// stageCode writes code to a new file in the staging directory and returns
+// a context that mounts it at CodeFile, and a function removing the file
+func (e *Executor) stageCode(ctx context.Context, code string) (context.Context, func(), error) {
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		return ctx, nil, err
+	}
+	file := hex.EncodeToString(name)
+	path := filepath.Join(e.staging.Dir, file)
+	// Readable by whichever UID the runner uses
+	if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+		return ctx, nil, fmt.Errorf("failed to stage code: %w", err)
+	}
+	cleanup := func() { os.Remove(path) }
+	return context.WithValue(ctx, codeKey{}, filepath.Join(e.staging.HostDir, file)), cleanup, nil
+}
+
+// codeBinds returns the bind mount for a staged code file, if any
+func (e *Executor) codeBinds(ctx context.Context) []string {
+	hostPath, ok := ctx.Value(codeKey{}).(string)
+	if !ok {
+		return nil
+	}
+	return []string{e.backend.bind(hostPath, CodeFile, true)}
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLogEntries bounds the combined log; later lines are dropped
+const maxLogEntries = 5000
+
+// LogEntry is one line of output in the order it was received
+type LogEntry struct {
+	ElapsedMs int64  `json:"elapsedMs"` // Since the container started
+	Stream    string `json:"stream"`    // "stdout" or "stderr"
+	Text      string `json:"text"`
+}
+
+type combinedLogKey struct{}
+
+// WithCombinedLog returns a context that makes Execute also record stdout
+// and stderr as a single timestamped, interleaved log
+func WithCombinedLog(ctx context.Context) context.Context {
+	return context.WithValue(ctx, combinedLogKey{}, true)
+}
+
+// combinedLogRequested reports whether ctx asks for a combined log
+func combinedLogRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(combinedLogKey{}).(bool)
+	return requested
+}
+
+// combinedLog splits both streams into lines stamped with the time their
+// first byte arrived
+type combinedLog struct {
+	mu        sync.Mutex
+	start     time.Time
+	entries   []LogEntry
+	partial   map[string]*LogEntry // Unterminated line per stream
+	truncated bool
+}
+
+func newCombinedLog() *combinedLog {
+	return &combinedLog{
+		start:   time.Now(),
+		partial: make(map[string]*LogEntry),
+	}
+}
+
+// writer returns an io.Writer appending to the log as stream
+func (l *combinedLog) writer(stream string) io.Writer {
+	return logWriter{log: l, stream: stream}
+}
+
+type logWriter struct {
+	log    *combinedLog
+	stream string
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.log.append(w.stream, string(p))
+	return len(p), nil
+}
+
+func (l *combinedLog) append(stream, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for text != "" {
+		entry := l.partial[stream]
+		if entry == nil {
+			entry = &LogEntry{ElapsedMs: time.Since(l.start).Milliseconds(), Stream: stream}
+			l.partial[stream] = entry
+		}
+
+		line, rest, complete := strings.Cut(text, "\n")
+		entry.Text += line
+		if !complete {
+			return
+		}
+		l.addLocked(*entry)
+		delete(l.partial, stream)
+		text = rest
+	}
+}
+
+func (l *combinedLog) addLocked(entry LogEntry) {
+	if len(l.entries) >= maxLogEntries {
+		l.truncated = true
+		return
+	}
+	l.entries = append(l.entries, entry)
+}
+
+// Entries flushes unterminated lines and returns the log in arrival order
+func (l *combinedLog) Entries() ([]LogEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, stream := range []string{"stdout", "stderr"} {
+		if entry := l.partial[stream]; entry != nil {
+			l.addLocked(*entry)
+			delete(l.partial, stream)
+		}
+	}
+	// Flushed partial lines may be older than the last complete one
+	sort.SliceStable(l.entries, func(i, j int) bool {
+		return l.entries[i].ElapsedMs < l.entries[j].ElapsedMs
+	})
+	return l.entries, l.truncated
+}
@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/metrics"
+	"github.com/jsc/mcp-code-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BuildDir is where compiled runners (sandbox.compile) leave their artifact.
+// It is a fresh, writable directory per execution, mounted in both the
+// compile and the run container, and removed afterwards
+const BuildDir = "/build"
+
+// buildKey is the context key for a build directory's host path
+type buildKey struct{}
+
+// compileAndRun runs the runner's Compile command on the source file in
+// /data or at CodeFile, then its Artifact command. A failed compile skips
+// the run: the result then has CompileFailed set and the compiler's output
+// in CompileOutput, leaving Stdout and Stderr to the program
+func (e *Executor) compileAndRun(ctx context.Context, runner RunnerInfo, sandboxDir, user, source string, networkEnabled bool, environment map[string]string) ExecutionResult {
+	dir, err := os.MkdirTemp(e.staging.Dir, "build-")
+	if err != nil {
+		log.Printf("Failed to create build directory for %s: %v", runner.Image, err)
+		return ExecutionResult{Success: false, Stderr: err.Error(), Error: err}
+	}
+	defer os.RemoveAll(dir)
+	// Writable by whichever UID the runner uses
+	if err := os.Chmod(dir, 0o777); err != nil {
+		return ExecutionResult{Success: false, Stderr: err.Error(), Error: err}
+	}
+	ctx = context.WithValue(ctx, buildKey{}, filepath.Join(e.staging.HostDir, filepath.Base(dir)))
+
+	compiled := e.compile(ctx, runner, sandboxDir, user, source, networkEnabled, environment)
+	output := strings.TrimSpace(compiled.Stdout + "\n" + compiled.Stderr)
+	if !compiled.Success {
+		if compiled.Error != nil || compiled.Diagnostics != nil {
+			// The compiler never ran; report it as the execution's failure
+			return compiled
+		}
+		return ExecutionResult{
+			Success:       false,
+			ExitCode:      compiled.ExitCode,
+			TimedOut:      compiled.TimedOut,
+			OOMKilled:     compiled.OOMKilled,
+			Usage:         compiled.Usage,
+			CompileOutput: output,
+			CompileFailed: true,
+		}
+	}
+
+	entrypoint := []string{"/bin/sh", "-c", "cd /data && " + runner.Artifact, "run"}
+	result := e.execute(ctx, runner, sandboxDir, user, entrypoint, stdinFromContext(ctx), networkEnabled, environment)
+	result.CompileOutput = output
+	return result
+}
+
+// compile runs the runner's Compile command with the source path as "$1".
+// It isn't counted as an execution, publishes no preview port and reads no
+// stdin, but gets the runner's timeout and the caller's network setting so
+// compilers can fetch modules
+func (e *Executor) compile(ctx context.Context, runner RunnerInfo, sandboxDir, user, source string, networkEnabled bool, environment map[string]string) (result ExecutionResult) {
+	ctx, span := tracing.Start(ctx, "container.compile", trace.WithAttributes(
+		attribute.String("runner.language", runner.Language),
+		attribute.String("runner.image", runner.Image),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("exit_code", result.ExitCode), attribute.Bool("timed_out", result.TimedOut))
+		tracing.End(span, result.Error)
+	}()
+
+	ctx = context.WithValue(ctx, previewKey{}, nil)
+	entrypoint := []string{"/bin/sh", "-c", "cd /data && " + runner.Compile, "compile", source}
+	untracked := (*metrics.Collector)(nil).Start(runner.Language)
+	started := time.Now()
+	result = e.run(ctx, runner, sandboxDir, user, entrypoint, "", e.Limits(runner).Timeout, networkEnabled, environment, untracked)
+	log.Printf("Compiled %s code in %v: success=%v, exitCode=%d", runner.Language, time.Since(started).Round(time.Millisecond), result.Success, result.ExitCode)
+	return result
+}
+
+// buildBinds returns the bind mount for a build directory, if any
+func (e *Executor) buildBinds(ctx context.Context) []string {
+	hostPath, ok := ctx.Value(buildKey{}).(string)
+	if !ok {
+		return nil
+	}
+	return []string{e.backend.bind(hostPath, BuildDir, false)}
+}
@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"gopkg.in/yaml.v3"
+)
+
+// runnersFile is the on-disk format of the static runners config (YAML or JSON)
+type runnersFile struct {
+	Runners []runnerEntry `yaml:"runners" json:"runners"`
+}
+
+// runnerEntry describes one statically configured runner image
+type runnerEntry struct {
+	Language    string   `yaml:"language" json:"language"`
+	Version     string   `yaml:"version" json:"version"`
+	Image       string   `yaml:"image" json:"image"` // e.g. "repo:tag", or "repo@sha256:..." to pin it
+	Default     bool     `yaml:"default" json:"default"`
+	Description string   `yaml:"description" json:"description"`
+	Timeout     string   `yaml:"timeout" json:"timeout"` // e.g. "60s"
+	Memory      string   `yaml:"memory" json:"memory"`   // e.g. "512m"
+	CPUs        float64  `yaml:"cpus" json:"cpus"`       // e.g. 1.5
+	ShmSize     string   `yaml:"shmSize" json:"shmSize"` // e.g. "1g"
+	CapAdd      []string `yaml:"capAdd" json:"capAdd"`   // subject to RUNNER_ALLOWED_CAPS
+	Probe       string   `yaml:"probe" json:"probe"`     // shell command listing installed packages
+	Install     string   `yaml:"install" json:"install"` // shell command installing the packages in "$@"
+
+	// Reads code from /sandbox/code, leaving stdin to the program
+	CodeFile bool `yaml:"codeFile" json:"codeFile"`
+	// Shell command running the /data file in "$1" (run_code entrypoint)
+	RunFile string `yaml:"runFile" json:"runFile"`
+	// Two-phase runners: compile the source in "$1" into /build, then run
+	// the artifact. Both or neither
+	Compile  string `yaml:"compile" json:"compile"`
+	Artifact string `yaml:"artifact" json:"artifact"`
+	// Shell command starting a Jupyter kernel that writes its connection
+	// file to /tmp/kernel.json (run_code kernel mode)
+	Kernel string `yaml:"kernel" json:"kernel"`
+	// Can use host GPUs when run_code asks for them (subject to RUNNER_GPUS)
+	GPU bool `yaml:"gpu" json:"gpu"`
+
+	// Confinement; empty uses the server-wide profiles
+	Seccomp  string `yaml:"seccomp" json:"seccomp"`   // profile name from RUNNER_SECCOMP_DIR
+	AppArmor string `yaml:"apparmor" json:"apparmor"` // AppArmor profile loaded on the host
+}
+
+// LoadConfig reads statically configured runners from a YAML or JSON file
+func LoadConfig(path string) ([]RunnerInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runners config: %w", err)
+	}
+
+	// JSON is valid YAML, so one decoder handles both formats
+	var file runnersFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse runners config: %w", err)
+	}
+
+	runners := make([]RunnerInfo, 0, len(file.Runners))
+	for i, entry := range file.Runners {
+		info, err := entry.toRunnerInfo()
+		if err != nil {
+			return nil, fmt.Errorf("runners[%d]: %w", i, err)
+		}
+		runners = append(runners, info)
+	}
+	return runners, nil
+}
+
+// toRunnerInfo validates an entry and converts it to a RunnerInfo
+func (e runnerEntry) toRunnerInfo() (RunnerInfo, error) {
+	if e.Language == "" {
+		return RunnerInfo{}, fmt.Errorf("language is required")
+	}
+	if e.Image == "" {
+		return RunnerInfo{}, fmt.Errorf("image is required")
+	}
+	if _, digest := splitDigest(e.Image); digest != "" && !digestPattern.MatchString(digest) {
+		return RunnerInfo{}, fmt.Errorf("invalid digest in image %q (want @sha256: and 64 hex digits)", e.Image)
+	}
+
+	info := RunnerInfo{
+		Image:       e.Image,
+		Language:    e.Language,
+		Version:     e.Version,
+		Default:     e.Default,
+		Description: e.Description,
+		Probe:       e.Probe,
+		Install:     e.Install,
+		NanoCPUs:    int64(e.CPUs * 1e9),
+		CodeFile:    e.CodeFile,
+		RunFile:     e.RunFile,
+		Compile:     e.Compile,
+		Artifact:    e.Artifact,
+		Kernel:      e.Kernel,
+		GPU:         e.GPU,
+		Seccomp:     e.Seccomp,
+		AppArmor:    e.AppArmor,
+	}
+
+	if (e.Compile == "") != (e.Artifact == "") {
+		return RunnerInfo{}, fmt.Errorf("compile and artifact must be set together")
+	}
+	if e.Timeout != "" {
+		d, err := time.ParseDuration(e.Timeout)
+		if err != nil || d <= 0 {
+			return RunnerInfo{}, fmt.Errorf("invalid timeout %q", e.Timeout)
+		}
+		info.Timeout = d
+	}
+	if e.Memory != "" {
+		size, err := units.RAMInBytes(e.Memory)
+		if err != nil || size <= 0 {
+			return RunnerInfo{}, fmt.Errorf("invalid memory %q", e.Memory)
+		}
+		info.MemoryBytes = size
+	}
+	if e.ShmSize != "" {
+		size, err := units.RAMInBytes(e.ShmSize)
+		if err != nil || size <= 0 {
+			return RunnerInfo{}, fmt.Errorf("invalid shmSize %q", e.ShmSize)
+		}
+		info.ShmSize = size
+	}
+	for _, capability := range e.CapAdd {
+		info.CapAdd = append(info.CapAdd, strings.ToUpper(strings.TrimSpace(capability)))
+	}
+
+	return info, nil
+}
@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// diagnoseTimeout bounds collecting diagnostics, which may run against a
+// daemon that is already misbehaving
+const diagnoseTimeout = 5 * time.Second
+
+// Diagnostics describes the host's state when a runner container could not
+// be created, attached to or started, so infrastructure failures can be
+// investigated without shell access to the Docker host
+type Diagnostics struct {
+	Stage            string          `json:"stage"` // create, network, attach, start or wait
+	Error            string          `json:"error"`
+	Daemon           DaemonInfo      `json:"daemon"`
+	Image            ImageDiagnostic `json:"image"`
+	Mounts           []MountInfo     `json:"mounts"`
+	ContainerRemoved *bool           `json:"containerRemoved,omitempty"` // Whether the failed container was cleaned up
+}
+
+// DaemonInfo is what the Docker daemon reports about itself
+type DaemonInfo struct {
+	Reachable         bool     `json:"reachable"`
+	Error             string   `json:"error,omitempty"`
+	ServerVersion     string   `json:"serverVersion,omitempty"`
+	APIVersion        string   `json:"apiVersion,omitempty"`
+	OS                string   `json:"os,omitempty"`
+	Architecture      string   `json:"architecture,omitempty"`
+	Containers        int      `json:"containers,omitempty"`
+	ContainersRunning int      `json:"containersRunning,omitempty"`
+	MemTotal          int64    `json:"memTotal,omitempty"`
+	NCPU              int      `json:"ncpu,omitempty"`
+	Warnings          []string `json:"warnings,omitempty"`
+}
+
+// ImageDiagnostic reports whether the runner image is present
+type ImageDiagnostic struct {
+	Name         string `json:"name"`
+	Present      bool   `json:"present"`
+	ID           string `json:"id,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// MountInfo reports whether a bind mount source exists. Sources are Docker
+// host paths, so a missing one may only be invisible to the server (e.g. when
+// SANDBOX_HOST_PATH differs from SANDBOX_ROOT)
+type MountInfo struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Exists bool   `json:"exists"`
+	Error  string `json:"error,omitempty"`
+}
+
+// diagnose collects diagnostics for a failed stage. If containerID is set
+// the container is removed, and the result records whether that worked
+func (e *Executor) diagnose(cli *client.Client, stage string, cause error, runner RunnerInfo, binds []string, containerID string) *Diagnostics {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnoseTimeout)
+	defer cancel()
+
+	d := &Diagnostics{
+		Stage: stage,
+		Error: cause.Error(),
+		Image: ImageDiagnostic{Name: runner.Image},
+	}
+
+	if containerID != "" {
+		err := cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true})
+		removed := err == nil || errdefs.IsNotFound(err)
+		d.ContainerRemoved = &removed
+	}
+
+	if info, err := cli.Info(ctx); err != nil {
+		d.Daemon.Error = err.Error()
+	} else {
+		d.Daemon = DaemonInfo{
+			Reachable:         true,
+			ServerVersion:     info.ServerVersion,
+			APIVersion:        cli.ClientVersion(),
+			OS:                info.OperatingSystem,
+			Architecture:      normalizeArch(info.Architecture),
+			Containers:        info.Containers,
+			ContainersRunning: info.ContainersRunning,
+			MemTotal:          info.MemTotal,
+			NCPU:              info.NCPU,
+			Warnings:          info.Warnings,
+		}
+	}
+
+	if inspect, err := cli.ImageInspect(ctx, runner.Image); err == nil {
+		d.Image.Present = true
+		d.Image.ID = inspect.ID
+		d.Image.Architecture = inspect.Architecture
+	} else if !errdefs.IsNotFound(err) {
+		d.Image.Error = err.Error()
+	}
+
+	for _, bind := range binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		mount := MountInfo{Source: parts[0], Target: parts[1]}
+		if _, err := os.Stat(mount.Source); err == nil {
+			mount.Exists = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			mount.Error = err.Error()
+		}
+		d.Mounts = append(d.Mounts, mount)
+	}
+
+	log.Printf("Container %s failed for %s: %v (daemon reachable: %v, image present: %v)",
+		stage, runner.Image, cause, d.Daemon.Reachable, d.Image.Present)
+	return d
+}
@@ -0,0 +1,42 @@
+package runner
+
+import "context"
+
+// Egress routes restricted executions through an allowlisting HTTP(S) proxy
+type Egress struct {
+	Network  string   // Internal Docker network with no route out except the proxy
+	ProxyURL string   // Proxy address as seen from that network
+	Domains  []string // Domains the proxy allows, for tool descriptions
+}
+
+// EgressDomains returns the domains restricted executions may reach, or nil
+// when restricted networking isn't configured
+func (e *Executor) EgressDomains() []string {
+	if e.egress == nil {
+		return nil
+	}
+	return e.egress.Domains
+}
+
+// restrictedKey is the context key for restricted network executions
+type restrictedKey struct{}
+
+// WithRestrictedNetwork runs executions using ctx on the egress network, so
+// they can only reach allowlisted domains through the proxy
+func WithRestrictedNetwork(ctx context.Context) context.Context {
+	return context.WithValue(ctx, restrictedKey{}, true)
+}
+
+// restrictedNetwork reports whether ctx asks for a restricted network
+func restrictedNetwork(ctx context.Context) bool {
+	restricted, _ := ctx.Value(restrictedKey{}).(bool)
+	return restricted
+}
+
+// EgressEnabled reports whether restricted network executions are available
+func (e *Executor) EgressEnabled() bool {
+	return e.egress != nil
+}
+
+// proxyEnv are the variables most HTTP clients use to find a proxy
+var proxyEnv = []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"}
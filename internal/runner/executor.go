@@ -3,14 +3,26 @@ package runner
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-units"
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/metrics"
+	"github.com/jsc/mcp-code-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ExecutionResult holds the result of a code execution
@@ -21,79 +33,325 @@ type ExecutionResult struct {
 	ExitCode int
 	TimedOut bool
 	Error    error
+
+	// Killed by the kernel for exceeding the memory limit
+	OOMKilled bool
+
+	// Output past the executor's limit is dropped; the totals count it
+	StdoutTruncated bool
+	StderrTruncated bool
+	StdoutBytes     int64
+	StderrBytes     int64
+
+	// Sampled while the container ran; nil if it exited before a sample
+	Usage *ResourceUsage
+
+	// Interleaved output, only recorded when requested via WithCombinedLog
+	Log          []LogEntry
+	LogTruncated bool
+
+	// Set when the container itself could not be run
+	Diagnostics *Diagnostics
+
+	// Compiler output for runners with sandbox.compile. CompileFailed means
+	// the program never ran, and Stdout and Stderr are empty
+	CompileOutput string
+	CompileFailed bool
+
+	// Set by kernel executions (ExecuteInKernel)
+	Display        []Display
+	ExecutionCount int
+}
+
+// Limits describes the resource limits applied to runner containers
+type Limits struct {
+	Timeout     time.Duration
+	MemoryBytes int64
+	NanoCPUs    int64
+	OutputBytes int64 // Kept per stream; the rest is counted and dropped
 }
 
 // Executor handles Docker container execution
 type Executor struct {
-	cli     *client.Client
-	timeout time.Duration
+	cli         *client.Client
+	timeout     time.Duration
+	allowedCaps map[string]bool // Capabilities runner images may request via sandbox.cap-add
+	metrics     *metrics.Collector
+	messages    *messages.Catalog
+
+	egress *Egress // Restricted network setup; nil when not configured
+
+	backend Backend // Engine behind the Docker API
+	pool    *Pool   // Hosts executions are scheduled on
+
+	limiter *Limiter // Concurrency limits; nil runs everything immediately
+
+	// Replaced by SetLimits on reload; executions already started keep theirs
+	limitsMu    sync.RWMutex
+	hardening   Hardening // Security baseline for runner containers
+	outputLimit int64     // Bytes of stdout and of stderr kept per execution
+
+	staging Staging // Where code files are written; empty pipes code on stdin
+
+	gpus GPUPolicy // Host GPUs sandbox.gpu runners may be given
+
+	verifier *Verifier // Checks image signatures before they run or are pulled; nil for none
+
+	unavailable atomic.Bool // Set by Supervise while the daemon is unreachable
+	killed      sync.Map    // IDs of containers stopped by KillExecution
 }
 
+const (
+	outputLimit = 10 * 1024 * 1024  // 10MB per stream
+	memoryLimit = 256 * 1024 * 1024 // 256MB
+	cpuLimit    = 500000000         // 0.5 CPU
+	defaultUser = "1000:1000"       // Runner user baked into the images
+)
+
 // NewExecutor creates a new container executor
-func NewExecutor(cli *client.Client, timeout time.Duration) *Executor {
+// allowedCaps lists the Linux capabilities runner images may request via the
+// sandbox.cap-add label; any other requested capability is dropped.
+// collector may be nil when metrics aren't needed; a nil catalog uses the
+// default messages. egress may be nil
+// when restricted networking isn't configured. backend adapts mounts and
+// user namespaces to the container engine. pool may be nil to run
+// everything on cli. limiter may be nil to never queue executions.
+// hardening is applied to every runner container. maxOutput caps the
+// bytes kept of each output stream (0 for the 10MB default). staging is
+// where code files for sandbox.code-file runners are written. gpus limits
+// the host GPUs executions may request; the zero value disables them.
+// verifier may be nil to run and pull images without checking signatures
+func NewExecutor(cli *client.Client, timeout time.Duration, allowedCaps []string, collector *metrics.Collector, catalog *messages.Catalog, egress *Egress, backend Backend, pool *Pool, limiter *Limiter, hardening Hardening, maxOutput int64, staging Staging, gpus GPUPolicy, verifier *Verifier) *Executor {
 	if timeout == 0 {
 		timeout = 30 * time.Second
 	}
+	if maxOutput <= 0 {
+		maxOutput = outputLimit
+	}
+	if pool == nil {
+		pool = singleHostPool(cli)
+	}
+	allowed := make(map[string]bool, len(allowedCaps))
+	for _, capability := range allowedCaps {
+		allowed[capability] = true
+	}
 	return &Executor{
-		cli:     cli,
-		timeout: timeout,
+		cli:         cli,
+		timeout:     timeout,
+		allowedCaps: allowed,
+		metrics:     collector,
+		messages:    catalog,
+		egress:      egress,
+		backend:     backend,
+		pool:        pool,
+		limiter:     limiter,
+		hardening:   hardening,
+		outputLimit: maxOutput,
+		staging:     staging,
+		gpus:        gpus,
+		verifier:    verifier,
+	}
+}
+
+// SetLimits replaces the hardening and output cap applied to executions
+// started from now on (maxOutput 0 for the 10MB default)
+func (e *Executor) SetLimits(hardening Hardening, maxOutput int64) {
+	if maxOutput <= 0 {
+		maxOutput = outputLimit
 	}
+	e.limitsMu.Lock()
+	defer e.limitsMu.Unlock()
+	e.hardening = hardening
+	e.outputLimit = maxOutput
+}
+
+// currentHardening returns the hardening for a new container
+func (e *Executor) currentHardening() Hardening {
+	e.limitsMu.RLock()
+	defer e.limitsMu.RUnlock()
+	return e.hardening
 }
 
 // Execute runs code in a Docker container with a bind mount to the sandbox directory
-func (e *Executor) Execute(ctx context.Context, imageName, sandboxDir, code string, networkEnabled bool, environment map[string]string) ExecutionResult {
-	// Create context with timeout
-	execCtx, cancel := context.WithTimeout(ctx, e.timeout)
-	defer cancel()
+// user ("uid:gid") must own sandboxDir; empty runs as the default 1000:1000
+// Runners that take their code as a file get it mounted at CodeFile and the
+// WithStdin data on stdin; others read the code itself from stdin. Compiled
+// runners build the file at CodeFile before running the artifact
+func (e *Executor) Execute(ctx context.Context, runner RunnerInfo, sandboxDir, user, code string, networkEnabled bool, environment map[string]string) ExecutionResult {
+	if !e.AcceptsStdin(runner) {
+		if runner.Compile != "" {
+			err := fmt.Errorf("runner %s compiles code, which needs a staging directory", runner.Image)
+			return ExecutionResult{Success: false, Stderr: err.Error(), Error: err}
+		}
+		return e.execute(ctx, runner, sandboxDir, user, nil, code, networkEnabled, environment)
+	}
+	ctx, cleanup, err := e.stageCode(ctx, code)
+	if err != nil {
+		log.Printf("Failed to stage code for %s: %v", runner.Image, err)
+		return ExecutionResult{Success: false, Stderr: err.Error(), Error: err}
+	}
+	defer cleanup()
+	if runner.Compile != "" {
+		return e.compileAndRun(ctx, runner, sandboxDir, user, CodeFile, networkEnabled, environment)
+	}
+	return e.execute(ctx, runner, sandboxDir, user, nil, stdinFromContext(ctx), networkEnabled, environment)
+}
+
+// ExecuteShell runs a shell command with /bin/sh -c instead of the image's
+// entrypoint, with the same mount, limits and network setting as Execute.
+// The WithStdin data is piped to the command
+func (e *Executor) ExecuteShell(ctx context.Context, runner RunnerInfo, sandboxDir, user, command string, networkEnabled bool, environment map[string]string) ExecutionResult {
+	return e.execute(ctx, runner, sandboxDir, user, []string{"/bin/sh", "-c", command}, stdinFromContext(ctx), networkEnabled, environment)
+}
 
-	// Convert environment map to Docker format (KEY=value)
-	envVars := make([]string, 0, len(environment))
-	for key, value := range environment {
-		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+// ExecuteFile runs a file in the sandbox (path relative to /data) with the
+// runner's RunFile command, from /data so the project's local imports
+// resolve. Compiled runners build the file first. The WithStdin data is
+// piped to the program
+func (e *Executor) ExecuteFile(ctx context.Context, runner RunnerInfo, sandboxDir, user, path string, networkEnabled bool, environment map[string]string) ExecutionResult {
+	if runner.Compile != "" && e.staging.Dir != "" {
+		return e.compileAndRun(ctx, runner, sandboxDir, user, "/data/"+path, networkEnabled, environment)
+	}
+	if runner.RunFile == "" {
+		err := fmt.Errorf("runner %s cannot run files", runner.Image)
+		return ExecutionResult{Success: false, Stderr: err.Error(), Error: err}
 	}
+	entrypoint := []string{"/bin/sh", "-c", "cd /data && " + runner.RunFile, "run", "/data/" + path}
+	return e.execute(ctx, runner, sandboxDir, user, entrypoint, stdinFromContext(ctx), networkEnabled, environment)
+}
 
-	// Create container
-	containerConfig := &container.Config{
-		Image:           imageName,
-		WorkingDir:      "/data",
-		OpenStdin:       true,
-		StdinOnce:       true,
-		AttachStdin:     true,
-		AttachStdout:    true,
-		AttachStderr:    true,
-		NetworkDisabled: !networkEnabled, // Network disabled by default for security
-		User:            "1000:1000",     // Run as non-root user (must match chown in sandbox manager)
-		Env:             envVars,         // Environment variables
+// execute runs a tracked, traced execution with the runner's limits
+func (e *Executor) execute(ctx context.Context, runner RunnerInfo, sandboxDir, user string, entrypoint []string, input string, networkEnabled bool, environment map[string]string) (result ExecutionResult) {
+	run := e.metrics.Start(runner.Language)
+	ctx, span := tracing.Start(ctx, "container.execute", trace.WithAttributes(
+		attribute.String("runner.language", runner.Language),
+		attribute.String("runner.image", runner.Image),
+	))
+	defer func() {
+		if usage := result.Usage; usage != nil {
+			run.Usage(usage.CPUTime, usage.PeakMemoryBytes, usage.NetworkRxBytes, usage.NetworkTxBytes)
+		}
+		run.Finish(result.Success, result.TimedOut)
+		span.SetAttributes(attribute.Int("exit_code", result.ExitCode), attribute.Bool("timed_out", result.TimedOut))
+		tracing.End(span, result.Error)
+	}()
+
+	// Runner settings may override the default timeout
+	return e.run(ctx, runner, sandboxDir, user, entrypoint, input, e.Limits(runner).Timeout, networkEnabled, environment, run)
+}
+
+// run starts a runner container with the sandbox mounted at /data, feeds it
+// input on stdin and collects its output. A nil entrypoint uses the image's
+func (e *Executor) run(ctx context.Context, runner RunnerInfo, sandboxDir, user string, entrypoint []string, input string, timeout time.Duration, networkEnabled bool, environment map[string]string, run *metrics.Run) ExecutionResult {
+	limits := e.Limits(runner)
+	reportProgress := progressFromContext(ctx)
+	started := time.Now()
+
+	if !e.Available() {
+		return ExecutionResult{
+			Success: false,
+			Stderr:  e.messages.Format(messages.BackendUnavailable, nil),
+			Error:   ErrBackendUnavailable,
+		}
 	}
 
-	// Bind mount the sandbox directory to /data in the container
-	hostConfig := &container.HostConfig{
-		Binds: []string{sandboxDir + ":/data"},
-		Resources: container.Resources{
-			Memory:   256 * 1024 * 1024, // 256MB
-			NanoCPUs: 500000000,         // 0.5 CPU
-		},
+	// Wait for a slot before the timeout starts, so queueing doesn't eat
+	// into the code's time
+	release, err := e.limiter.Acquire(ctx, sandboxDir, func(status QueueStatus) {
+		if reportProgress != nil {
+			reportProgress(Progress{
+				Elapsed:       time.Since(started),
+				Queued:        true,
+				QueuePosition: status.Position,
+				EstimatedWait: status.EstimatedWait,
+			})
+		}
+	})
+	if errors.Is(err, ErrQueueFull) {
+		log.Printf("Execution queue full, rejecting %s execution", runner.Language)
+		return ExecutionResult{
+			Success: false,
+			Stderr:  e.messages.Format(messages.ExecutionQueueFull, nil),
+			Error:   err,
+		}
+	} else if err != nil {
+		return ExecutionResult{
+			Success: false,
+			Stderr:  fmt.Sprintf("Execution cancelled while queued: %v", err),
+			Error:   err,
+		}
 	}
+	defer release()
+
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	resp, err := e.cli.ContainerCreate(execCtx, containerConfig, hostConfig, nil, nil, "")
+	spec, err := e.containerSpec(ctx, runner, sandboxDir, user, entrypoint, networkEnabled, environment)
 	if err != nil {
+		log.Printf("Refusing to run %s: %v", runner.Image, err)
 		return ExecutionResult{
 			Success: false,
-			Stderr:  fmt.Sprintf("Failed to create container: %v", err),
+			Stderr:  err.Error(),
 			Error:   err,
 		}
 	}
+	spec.config.Labels = executionLabels(runner, sandboxDir, time.Now().Add(timeout))
+	containerConfig, hostConfig, binds, services := spec.config, spec.hostConfig, spec.binds, spec.services
+	preview, hasPreview := previewFromContext(ctx)
+
+	// Host-local networks (egress proxy, services) only exist on the
+	// primary, which is also the host RUNNER_GPUS describes
+	host, release := e.pool.acquire(ctx, runner, spec.restricted || serviceNetwork(ctx) != "" || gpuRequested(ctx))
+	defer release()
+	cli := host.cli
+	image, err := e.resolveImage(ctx, host, runner)
+	if err != nil {
+		log.Printf("Refusing to run %s: %v", runner.Image, err)
+		return ExecutionResult{
+			Success: false,
+			Stderr:  err.Error(),
+			Error:   err,
+		}
+	}
+	containerConfig.Image = image
+	if len(e.pool.hosts) > 1 {
+		log.Printf("Running %s on Docker host %s", image, host.name)
+	}
+
+	_, createSpan := tracing.Start(execCtx, "container.create")
+	resp, err := cli.ContainerCreate(execCtx, containerConfig, hostConfig, nil, nil, "")
+	tracing.End(createSpan, err)
+	if err != nil {
+		return ExecutionResult{
+			Success:     false,
+			Stderr:      fmt.Sprintf("Failed to create container: %v", err),
+			Error:       err,
+			Diagnostics: e.diagnose(cli, "create", err, runner, binds, ""),
+		}
+	}
 
 	containerID := resp.ID
 	defer func() {
 		// Clean up container
 		removeCtx, removeCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer removeCancel()
-		e.cli.ContainerRemove(removeCtx, containerID, container.RemoveOptions{Force: true})
+		cli.ContainerRemove(removeCtx, containerID, container.RemoveOptions{Force: true})
 	}()
 
+	// Join the service network alongside the bridge or egress network
+	if services != "" {
+		if err := cli.NetworkConnect(execCtx, services, containerID, nil); err != nil {
+			return ExecutionResult{
+				Success:     false,
+				Stderr:      fmt.Sprintf("Failed to attach service network: %v", err),
+				Error:       err,
+				Diagnostics: e.diagnose(cli, "network", err, runner, binds, containerID),
+			}
+		}
+	}
+
 	// Attach to container to get stdin/stdout/stderr
-	attachResp, err := e.cli.ContainerAttach(execCtx, containerID, container.AttachOptions{
+	attachResp, err := cli.ContainerAttach(execCtx, containerID, container.AttachOptions{
 		Stream: true,
 		Stdin:  true,
 		Stdout: true,
@@ -101,64 +359,159 @@ func (e *Executor) Execute(ctx context.Context, imageName, sandboxDir, code stri
 	})
 	if err != nil {
 		return ExecutionResult{
-			Success: false,
-			Stderr:  fmt.Sprintf("Failed to attach to container: %v", err),
-			Error:   err,
+			Success:     false,
+			Stderr:      fmt.Sprintf("Failed to attach to container: %v", err),
+			Error:       err,
+			Diagnostics: e.diagnose(cli, "attach", err, runner, binds, containerID),
 		}
 	}
 	defer attachResp.Close()
 
 	// Start container
-	if err := e.cli.ContainerStart(execCtx, containerID, container.StartOptions{}); err != nil {
+	_, startSpan := tracing.Start(execCtx, "container.start")
+	err = cli.ContainerStart(execCtx, containerID, container.StartOptions{})
+	tracing.End(startSpan, err)
+	if err != nil {
 		return ExecutionResult{
-			Success: false,
-			Stderr:  fmt.Sprintf("Failed to start container: %v", err),
-			Error:   err,
+			Success:     false,
+			Stderr:      fmt.Sprintf("Failed to start container: %v", err),
+			Error:       err,
+			Diagnostics: e.diagnose(cli, "start", err, runner, binds, containerID),
+		}
+	}
+	run.Started()
+	sampler := sampleStats(cli, containerID)
+	if hasPreview {
+		if url, err := preview.publish(cli, containerID); err != nil {
+			log.Printf("Preview unavailable: %v", err)
+		} else if reportProgress != nil {
+			reportProgress(Progress{Elapsed: time.Since(started), PreviewURL: url})
 		}
 	}
 
 	// Write code to stdin
 	go func() {
-		io.WriteString(attachResp.Conn, code)
+		io.WriteString(attachResp.Conn, input)
 		attachResp.CloseWrite()
 	}()
 
-	// Read output - demultiplex stdout and stderr
+	// Read output - demultiplex stdout and stderr, keeping each up to the
+	// output limit while counting everything
 	var stdoutBuf, stderrBuf bytes.Buffer
-	go stdcopy.StdCopy(&stdoutBuf, &stderrBuf, attachResp.Reader)
+	var stdoutSink, stderrSink io.Writer = &stdoutBuf, &stderrBuf
+	var combined *combinedLog
+	if combinedLogRequested(ctx) {
+		combined = newCombinedLog()
+		stdoutSink = io.MultiWriter(&stdoutBuf, combined.writer("stdout"))
+		stderrSink = io.MultiWriter(&stderrBuf, combined.writer("stderr"))
+	}
+	if fn := outputFromContext(ctx); fn != nil {
+		stdoutSink = io.MultiWriter(stdoutSink, outputWriter{fn: fn, stream: "stdout"})
+		stderrSink = io.MultiWriter(stderrSink, outputWriter{fn: fn, stream: "stderr"})
+	}
+	stdoutCap := &cappedWriter{w: stdoutSink, limit: limits.OutputBytes}
+	stderrCap := &cappedWriter{w: stderrSink, limit: limits.OutputBytes}
+	stdoutCounter := &countingWriter{w: stdoutCap}
+	stderrCounter := &countingWriter{w: stderrCap}
+	copyDone := make(chan struct{})
+	go func() {
+		defer close(copyDone)
+		stdcopy.StdCopy(stdoutCounter, stderrCounter, attachResp.Reader)
+	}()
 
 	// Wait for container to finish
-	statusCh, errCh := e.cli.ContainerWait(execCtx, containerID, container.WaitConditionNotRunning)
+	_, waitSpan := tracing.Start(execCtx, "container.wait")
+	statusCh, errCh := cli.ContainerWait(execCtx, containerID, container.WaitConditionNotRunning)
 
 	var exitCode int64
-	var timedOut bool
+	var timedOut, cancelled bool
 
-	select {
-	case err := <-errCh:
-		if err != nil {
-			return ExecutionResult{
-				Success: false,
-				Stdout:  stdoutBuf.String(),
-				Stderr:  fmt.Sprintf("Container wait error: %v\n%s", err, stderrBuf.String()),
-				Error:   err,
+	// Report progress periodically if the caller asked for it
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+wait:
+	for {
+		select {
+		case err := <-errCh:
+			if err != nil && execCtx.Err() != nil {
+				// The wait was cut short by the timeout or cancellation
+				cancelled = ctx.Err() != nil
+				timedOut = !cancelled
+				exitCode = -1
+				break wait
+			}
+			if err != nil {
+				// Not a timeout or cancelled request: the daemon failed
+				tracing.End(waitSpan, err)
+				sampler.stop()
+				stopContainer(cli, containerID)
+				drainOutput(copyDone, attachResp.Close)
+				return ExecutionResult{
+					Success:     false,
+					Stdout:      stdoutBuf.String(),
+					Stderr:      fmt.Sprintf("Container wait error: %v\n%s", err, stderrBuf.String()),
+					Error:       err,
+					Diagnostics: e.diagnose(cli, "wait", err, runner, binds, containerID),
+				}
+			}
+			break wait
+		case status := <-statusCh:
+			exitCode = status.StatusCode
+			break wait
+		case <-execCtx.Done():
+			// The caller going away isn't the code's fault
+			cancelled = ctx.Err() != nil
+			timedOut = !cancelled
+			exitCode = -1
+			break wait
+		case <-ticker.C:
+			if reportProgress != nil {
+				reportProgress(Progress{
+					Elapsed:     time.Since(started),
+					StdoutBytes: stdoutCounter.n.Load(),
+					StderrBytes: stderrCounter.n.Load(),
+				})
 			}
 		}
-	case status := <-statusCh:
-		exitCode = status.StatusCode
-	case <-execCtx.Done():
-		timedOut = true
-		exitCode = -1
 	}
 
-	// Give a moment for output to be fully read
-	time.Sleep(100 * time.Millisecond)
+	tracing.End(waitSpan, nil)
+	usage := sampler.stop()
+
+	// Read everything the container wrote before looking at the buffers. A
+	// container still running after a timeout is killed so its streams end
+	_, collectSpan := tracing.Start(ctx, "output.collect")
+	defer tracing.End(collectSpan, nil)
+	if timedOut || cancelled {
+		stopContainer(cli, containerID)
+	}
+	drainOutput(copyDone, attachResp.Close)
+
+	// Exit code 137 alone doesn't tell an OOM kill from any other SIGKILL
+	killed := e.wasKilled(containerID)
+	oomKilled := exitCode != 0 && !timedOut && !cancelled && !killed && containerOOMKilled(cli, containerID)
 
 	// Get stdout and stderr separately
 	stdout := stdoutBuf.String()
 	stderr := stderrBuf.String()
 
+	if oomKilled {
+		oomMsg := e.messages.Format(messages.OutOfMemory, messages.Args{
+			"Limit": units.BytesSize(float64(limits.MemoryBytes)),
+		})
+		if stderr != "" {
+			stderr = oomMsg + "\n" + stderr
+		} else {
+			stderr = oomMsg
+		}
+	}
+
 	if timedOut {
-		timeoutMsg := fmt.Sprintf("Execution timed out after %v", e.timeout)
+		timeoutMsg := e.messages.Format(messages.ExecutionTimeout, messages.Args{
+			"Timeout": timeout,
+			"Seconds": int(timeout.Seconds()),
+		})
 		if stderr != "" {
 			stderr = timeoutMsg + "\n" + stderr
 		} else {
@@ -166,26 +519,186 @@ func (e *Executor) Execute(ctx context.Context, imageName, sandboxDir, code stri
 		}
 	}
 
-	success := exitCode == 0 && !timedOut
+	if cancelled {
+		// Partial output is kept for history and failure bundles
+		cancelMsg := e.messages.Format(messages.ExecutionCancelled, messages.Args{
+			"Elapsed": time.Since(started).Round(time.Millisecond),
+		})
+		if stderr != "" {
+			stderr = cancelMsg + "\n" + stderr
+		} else {
+			stderr = cancelMsg
+		}
+	}
+
+	if killed {
+		killedMsg := e.messages.Format(messages.ExecutionKilled, messages.Args{
+			"Elapsed": time.Since(started).Round(time.Millisecond),
+		})
+		if stderr != "" {
+			stderr = killedMsg + "\n" + stderr
+		} else {
+			stderr = killedMsg
+		}
+	}
+
+	success := exitCode == 0 && !timedOut && !cancelled && !killed
 
-	return ExecutionResult{
+	result := ExecutionResult{
 		Success:  success,
 		Stdout:   stdout,
 		Stderr:   stderr,
 		ExitCode: int(exitCode),
 		TimedOut: timedOut,
+
+		StdoutTruncated: stdoutCap.truncated.Load(),
+		StderrTruncated: stderrCap.truncated.Load(),
+		StdoutBytes:     stdoutCounter.n.Load(),
+		StderrBytes:     stderrCounter.n.Load(),
+
+		OOMKilled: oomKilled,
+		Usage:     usage,
+	}
+	if cancelled {
+		result.Error = ctx.Err()
+	}
+	if result.StdoutTruncated || result.StderrTruncated {
+		log.Printf("Output truncated at %d bytes per stream (stdout: %d bytes, stderr: %d bytes)",
+			limits.OutputBytes, result.StdoutBytes, result.StderrBytes)
 	}
+	if combined != nil {
+		result.Log, result.LogTruncated = combined.Entries()
+	}
+	return result
 }
 
-// PullImage pulls a Docker image if it doesn't exist locally
+// containerOOMKilled reports whether the kernel killed a stopped container
+// for exceeding its memory limit
+func containerOOMKilled(cli *client.Client, containerID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		log.Printf("Failed to inspect container %s: %v", containerID[:12], err)
+		return false
+	}
+	if inspect.State == nil || !inspect.State.OOMKilled {
+		return false
+	}
+	log.Printf("Container %s was killed for exceeding its memory limit", containerID[:12])
+	return true
+}
+
+// drainTimeout bounds waiting for the output streams to end once the
+// container has stopped, in case the daemon never closes the attach stream
+const drainTimeout = 2 * time.Second
+
+// stopContainer kills a container that may still be running so its output
+// streams end. It is removed later either way
+func stopContainer(cli *client.Client, containerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.ContainerKill(ctx, containerID, "KILL"); err != nil && !errdefs.IsNotFound(err) && !errdefs.IsConflict(err) {
+		log.Printf("Failed to kill container %s: %v", containerID[:12], err)
+	}
+}
+
+// drainOutput waits for the output copy to reach the end of the attach
+// stream, so the buffers are complete and no longer written to. If the
+// stream stays open it is closed, which ends the copy
+func drainOutput(done <-chan struct{}, closeStream func()) {
+	select {
+	case <-done:
+		return
+	case <-time.After(drainTimeout):
+	}
+	log.Printf("Output stream still open %s after the container stopped; closing it", drainTimeout)
+	closeStream()
+	<-done
+}
+
+// permittedCaps filters a runner's requested capabilities through the policy
+func (e *Executor) permittedCaps(runner RunnerInfo) []string {
+	var caps []string
+	for _, capability := range runner.CapAdd {
+		if e.allowedCaps[capability] {
+			caps = append(caps, capability)
+		} else {
+			log.Printf("Runner %s requested capability %s which is not allowed; dropping", runner.Image, capability)
+		}
+	}
+	return caps
+}
+
+// Limits returns the resource limits applied to an execution on a runner
+// Runner-specific settings override the executor defaults
+func (e *Executor) Limits(runner RunnerInfo) Limits {
+	e.limitsMu.RLock()
+	limits := Limits{
+		Timeout:     e.timeout,
+		MemoryBytes: memoryLimit,
+		NanoCPUs:    cpuLimit,
+		OutputBytes: e.outputLimit,
+	}
+	e.limitsMu.RUnlock()
+	if runner.Timeout > 0 {
+		limits.Timeout = runner.Timeout
+	}
+	if runner.MemoryBytes > 0 {
+		limits.MemoryBytes = runner.MemoryBytes
+	}
+	if runner.NanoCPUs > 0 {
+		limits.NanoCPUs = runner.NanoCPUs
+	}
+	return limits
+}
+
+// PullImage pulls a Docker image, returning any error reported in the pull stream
 func (e *Executor) PullImage(ctx context.Context, imageName string) error {
-	reader, err := e.cli.ImagePull(ctx, imageName, image.PullOptions{})
+	return e.pull(ctx, e.cli, imageName, "")
+}
+
+// pull pulls an image on a host, for a platform such as "linux/arm64" or
+// else the host's default. With a verifier it pulls the digest whose
+// signature verified, and points the image's tag at it
+func (e *Executor) pull(ctx context.Context, cli *client.Client, imageName, platform string) error {
+	ref := imageName
+	if e.verifier.Enabled() {
+		verified, err := e.verifier.Verify(ctx, imageName)
+		if err != nil {
+			return err
+		}
+		ref = verified
+	}
+
+	reader, err := cli.ImagePull(ctx, ref, image.PullOptions{Platform: platform})
 	if err != nil {
 		return err
 	}
 	defer reader.Close()
 
-	// Consume the pull output
-	io.Copy(io.Discard, reader)
+	// Consume the pull output; failures (e.g. unauthorized) arrive as messages
+	if err := jsonmessage.DisplayJSONMessagesStream(reader, io.Discard, 0, false, nil); err != nil {
+		return err
+	}
+	if _, digest := splitDigest(imageName); ref != imageName && digest == "" {
+		if err := cli.ImageTag(ctx, ref, imageName); err != nil {
+			return fmt.Errorf("failed to tag %s: %w", imageName, err)
+		}
+	}
 	return nil
 }
+
+// EnsureImage pulls an image unless it is already present locally,
+// reporting whether a pull happened
+func (e *Executor) EnsureImage(ctx context.Context, imageName string) (bool, error) {
+	if _, err := e.cli.ImageInspect(ctx, imageName); err == nil {
+		return false, nil
+	} else if !errdefs.IsNotFound(err) {
+		return false, err
+	}
+	if err := e.PullImage(ctx, imageName); err != nil {
+		return false, fmt.Errorf("failed to pull %s: %w", imageName, err)
+	}
+	return true, nil
+}
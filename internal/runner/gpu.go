@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// GPUPolicy is the server's limit on the host GPUs an execution may use.
+// Only runners with the sandbox.gpu label get them, and only when asked
+type GPUPolicy struct {
+	Count   int      // GPUs per execution; -1 for all of them, 0 disables GPUs
+	Devices []string // Device indexes or UUIDs to expose instead of a count
+}
+
+// Enabled reports whether executions may request GPUs
+func (p GPUPolicy) Enabled() bool {
+	return p.Count != 0 || len(p.Devices) > 0
+}
+
+// deviceRequest asks the NVIDIA container runtime for the policy's GPUs
+func (p GPUPolicy) deviceRequest() container.DeviceRequest {
+	request := container.DeviceRequest{
+		Driver:       "nvidia",
+		Capabilities: [][]string{{"gpu"}},
+	}
+	if len(p.Devices) > 0 {
+		request.DeviceIDs = p.Devices
+	} else {
+		request.Count = p.Count
+	}
+	return request
+}
+
+type gpuKey struct{}
+
+// WithGPU gives executions using ctx the host GPUs the policy allows. The
+// runner must have the sandbox.gpu label
+func WithGPU(ctx context.Context) context.Context {
+	return context.WithValue(ctx, gpuKey{}, true)
+}
+
+// gpuRequested reports whether ctx asks for GPUs
+func gpuRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(gpuKey{}).(bool)
+	return requested
+}
+
+// GPUEnabled reports whether executions may request GPUs
+func (e *Executor) GPUEnabled() bool {
+	return e.gpus.Enabled()
+}
+
+// applyGPU adds the GPU device request to a runner's host config when ctx
+// asks for GPUs
+func (e *Executor) applyGPU(ctx context.Context, hostConfig *container.HostConfig, runner RunnerInfo) error {
+	if !gpuRequested(ctx) {
+		return nil
+	}
+	if !runner.GPU {
+		return fmt.Errorf("runner %s has no GPU support (sandbox.gpu)", runner.Image)
+	}
+	if !e.gpus.Enabled() {
+		return fmt.Errorf("GPU access is not enabled on this server (RUNNER_GPUS)")
+	}
+	hostConfig.DeviceRequests = append(hostConfig.DeviceRequests, e.gpus.deviceRequest())
+	return nil
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Hardening is the security baseline applied to every runner container, to
+// contain fork bombs and privilege escalation attempts by generated code
+type Hardening struct {
+	ReadonlyRootfs  bool     // Only /data, the package mounts and /tmp are writable
+	CapDrop         []string // Usually ALL; sandbox.cap-add is granted on top
+	NoNewPrivileges bool     // setuid binaries can't raise privileges
+	PidsLimit       int64    // Processes and threads per container (0 for no limit)
+	TmpfsBytes      int64    // Size of the tmpfs mounted at /tmp (0 for none)
+
+	// Mandatory access control. Empty keeps the engine's default profiles
+	SeccompProfile  string            // JSON profile, or "unconfined"
+	SeccompProfiles map[string]string // Named JSON profiles runners may select
+	AppArmorProfile string            // Name of a profile loaded on the host
+}
+
+// DefaultHardening is the baseline used unless configured otherwise
+func DefaultHardening() Hardening {
+	return Hardening{
+		ReadonlyRootfs:  true,
+		CapDrop:         []string{"ALL"},
+		NoNewPrivileges: true,
+		PidsLimit:       256,
+		TmpfsBytes:      64 * 1024 * 1024,
+	}
+}
+
+// apply sets the hardening options on a runner's host config. A runner
+// selecting a seccomp profile that isn't configured is an error rather than
+// falling back to a weaker one
+func (h Hardening) apply(hostConfig *container.HostConfig, runner RunnerInfo) error {
+	hostConfig.ReadonlyRootfs = h.ReadonlyRootfs
+	hostConfig.CapDrop = h.CapDrop
+	if h.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges")
+	}
+	if h.PidsLimit > 0 {
+		pids := h.PidsLimit
+		hostConfig.PidsLimit = &pids
+	}
+	if h.TmpfsBytes > 0 {
+		// Sticky and world-writable like a normal /tmp, so any sandbox UID can use it
+		hostConfig.Tmpfs = map[string]string{
+			"/tmp": fmt.Sprintf("rw,nosuid,nodev,mode=1777,size=%d", h.TmpfsBytes),
+		}
+	}
+
+	seccomp := h.SeccompProfile
+	if runner.Seccomp != "" {
+		profile, ok := h.SeccompProfiles[runner.Seccomp]
+		if !ok {
+			return fmt.Errorf("runner %s requires seccomp profile %q, which is not configured", runner.Image, runner.Seccomp)
+		}
+		seccomp = profile
+	}
+	if seccomp != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+seccomp)
+	}
+	apparmor := h.AppArmorProfile
+	if runner.AppArmor != "" {
+		apparmor = runner.AppArmor
+	}
+	if apparmor != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "apparmor="+apparmor)
+	}
+	return nil
+}
+
+// LoadSeccompProfile reads a seccomp profile for the Docker API, which
+// takes the profile's JSON rather than a path. "unconfined" is passed through
+func LoadSeccompProfile(path string) (string, error) {
+	if path == "unconfined" {
+		return path, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read seccomp profile: %w", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return "", fmt.Errorf("invalid seccomp profile %s: %w", path, err)
+	}
+	return compact.String(), nil
+}
+
+// LoadSeccompProfiles reads every <name>.json in dir as a named profile
+func LoadSeccompProfiles(dir string) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]string, len(paths))
+	for _, path := range paths {
+		profile, err := LoadSeccompProfile(path)
+		if err != nil {
+			return nil, err
+		}
+		profiles[strings.TrimSuffix(filepath.Base(path), ".json")] = profile
+	}
+	return profiles, nil
+}
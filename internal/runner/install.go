@@ -0,0 +1,200 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/metrics"
+	"github.com/jsc/mcp-code-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// installTimeout bounds the dependency-install phase
+const installTimeout = 5 * time.Minute
+
+// PackagesDir is where a conversation's package cache is mounted inside the
+// container, next to /data. It is writable only during installs
+const PackagesDir = "/packages"
+
+// packagesKey is the context key for the package cache mount
+type packagesKey struct{}
+
+// packagesMount is a package cache to bind mount at PackagesDir
+type packagesMount struct {
+	hostDir       string
+	writable      bool
+	downloadCache string // Docker host path of the install's download cache, if any
+}
+
+// WithPackages mounts a conversation's package cache (a Docker host path)
+// read-only at PackagesDir for executions using ctx
+func WithPackages(ctx context.Context, hostDir string) context.Context {
+	return context.WithValue(ctx, packagesKey{}, packagesMount{hostDir: hostDir})
+}
+
+// packagesBinds returns the bind mounts for the package cache, if any. Only
+// installs mount it writable, and only they get the tenant's download cache
+func (e *Executor) packagesBinds(ctx context.Context) []string {
+	mount, ok := ctx.Value(packagesKey{}).(packagesMount)
+	if !ok || mount.hostDir == "" {
+		return nil
+	}
+	if !mount.writable {
+		return []string{e.backend.bind(mount.hostDir, PackagesDir, true)}
+	}
+	binds := []string{e.backend.bind(mount.hostDir, PackagesDir, false)}
+	if mount.downloadCache != "" {
+		binds = append(binds, e.backend.bind(mount.downloadCache, DownloadCacheDir, false))
+	}
+	return binds
+}
+
+// DownloadCacheDir is where the tenant's package download cache is mounted
+// during installs. Package managers only reuse downloaded archives from it;
+// each conversation still installs into its own package cache
+const DownloadCacheDir = "/cache"
+
+// downloadCacheEnv points the package managers at DownloadCacheDir
+var downloadCacheEnv = map[string]string{
+	"PIP_CACHE_DIR":         DownloadCacheDir + "/pip",
+	"npm_config_cache":      DownloadCacheDir + "/npm",
+	"BUN_INSTALL_CACHE_DIR": DownloadCacheDir + "/bun",
+}
+
+// defaultInstallers install packages (passed as "$@") for well-known
+// languages; images can override or add one with the sandbox.install label
+var defaultInstallers = map[string]string{
+	"python":     "pip install --disable-pip-version-check --quiet --target " + PackagesDir + "/python \"$@\"",
+	"typescript": "mkdir -p " + PackagesDir + "/node && cd " + PackagesDir + "/node && bun add \"$@\"",
+	"javascript": "npm install --no-fund --no-audit --prefix " + PackagesDir + "/node \"$@\"",
+}
+
+// packagesEnv points each language's module resolution at PackagesDir
+var packagesEnv = map[string][2]string{
+	"python":     {"PYTHONPATH", PackagesDir + "/python"},
+	"typescript": {"NODE_PATH", PackagesDir + "/node/node_modules"},
+	"javascript": {"NODE_PATH", PackagesDir + "/node/node_modules"},
+}
+
+// PackagesEnv returns the variable that makes installed packages importable
+// for a language, if it has one
+func PackagesEnv(language string) (string, string, bool) {
+	kv, ok := packagesEnv[language]
+	return kv[0], kv[1], ok
+}
+
+// packageSpec accepts registry package names with an optional version
+// constraint, e.g. "requests", "numpy==1.26.4", "pandas>=2,<3",
+// "@types/node@20", "zod@^3.22". URLs, paths and flags are rejected so an
+// install can only reach the package registry
+var packageSpec = regexp.MustCompile(`^(@[a-z0-9][a-z0-9._-]*/)?[a-z0-9][a-z0-9._-]*(\[[a-z0-9,._-]+\])?(@[\^~]?[a-z0-9][a-z0-9.*+^~-]*|(==|>=|<=|~=|!=|>|<)[a-z0-9][a-z0-9.*+^~-]*(,(==|>=|<=|~=|!=|>|<)[a-z0-9][a-z0-9.*+^~-]*)*)?$`)
+
+// packageName strips extras and version constraints from a package spec
+func packageName(spec string) string {
+	// Skip a scope's leading @ so it isn't taken for a version separator
+	if i := strings.IndexAny(spec[1:], "[=<>~!@"); i >= 0 {
+		return spec[:i+1]
+	}
+	return spec
+}
+
+// InstallPolicy decides which packages may be installed with network access
+// and how large a conversation's package cache may grow
+type InstallPolicy struct {
+	allowed []string
+	denied  []string
+
+	// MaxCacheBytes caps a conversation's package cache (0 for no limit)
+	MaxCacheBytes int64
+}
+
+// NewInstallPolicy creates a policy allowing packages whose names match one of
+// allowed and none of denied (path.Match globs, e.g. "*" or "django-*"). With
+// no allowed patterns installs are disabled
+func NewInstallPolicy(allowed, denied []string, maxCacheBytes int64) *InstallPolicy {
+	return &InstallPolicy{
+		allowed:       lowerAll(allowed),
+		denied:        lowerAll(denied),
+		MaxCacheBytes: maxCacheBytes,
+	}
+}
+
+func lowerAll(patterns []string) []string {
+	lowered := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lowered[i] = strings.ToLower(pattern)
+	}
+	return lowered
+}
+
+// Enabled reports whether any package may be installed
+func (p *InstallPolicy) Enabled() bool {
+	return len(p.allowed) > 0
+}
+
+// Check validates package specs against the policy
+func (p *InstallPolicy) Check(packages []string) error {
+	if !p.Enabled() {
+		return fmt.Errorf("package installs are disabled on this server")
+	}
+	for _, spec := range packages {
+		if !packageSpec.MatchString(strings.ToLower(spec)) {
+			return fmt.Errorf("invalid package %q: only registry names with an optional version are allowed", spec)
+		}
+		name := packageName(strings.ToLower(spec))
+		if matchAny(p.denied, name) || !matchAny(p.allowed, name) {
+			return fmt.Errorf("package %q is not allowed by the install policy", spec)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// A bare * also covers scoped npm names, which path.Match won't
+		if pattern == "*" {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Install runs the runner's install command with network enabled, mounting
+// the sandbox like Execute does and the package cache (packagesDir, a Docker
+// host path) writable at PackagesDir, plus downloadCache, a Docker host path
+// ("" for none), at DownloadCacheDir. Only the package manager runs in this
+// phase; user code runs afterwards via Execute with its own network setting
+func (e *Executor) Install(ctx context.Context, runner RunnerInfo, sandboxDir, packagesDir, downloadCache, user string, packages []string) (result ExecutionResult) {
+	ctx, span := tracing.Start(ctx, "container.install", trace.WithAttributes(
+		attribute.String("runner.language", runner.Language),
+		attribute.StringSlice("packages", packages),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("exit_code", result.ExitCode))
+		tracing.End(span, result.Error)
+	}()
+
+	if runner.Install == "" {
+		err := fmt.Errorf("runner %s does not support package installs", runner.Image)
+		return ExecutionResult{Stderr: err.Error(), Error: err}
+	}
+
+	ctx = context.WithValue(ctx, packagesKey{}, packagesMount{hostDir: packagesDir, writable: true, downloadCache: downloadCache})
+
+	// Packages are positional parameters, never interpolated into the script
+	entrypoint := append([]string{"/bin/sh", "-c", runner.Install, "install"}, packages...)
+	var env map[string]string
+	if downloadCache != "" {
+		env = downloadCacheEnv
+	}
+	untracked := (*metrics.Collector)(nil).Start(runner.Language)
+	return e.run(ctx, runner, sandboxDir, user, entrypoint, "", installTimeout, true, env, untracked)
+}
@@ -0,0 +1,263 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/jsc/mcp-code-sandbox/internal/messages"
+	"github.com/jsc/mcp-code-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// KernelConnectionFile is where a runner's kernel command (sandbox.kernel)
+// must write the Jupyter connection file
+const KernelConnectionFile = "/tmp/kernel.json"
+
+// KernelDisplayDir is where images a kernel displays are saved, under /data
+const KernelDisplayDir = "display"
+
+// kernelInterruptGrace bounds waiting for the code to stop after a timeout
+// interrupted it
+const kernelInterruptGrace = 5 * time.Second
+
+// Display is rich output of a kernel execution: a display() call or the
+// value of the code's last expression. Images are saved to the sandbox and
+// named by File instead of being returned inline
+type Display struct {
+	Text     string `json:"text,omitempty"`     // text/plain
+	HTML     string `json:"html,omitempty"`     // text/html, e.g. a DataFrame's table
+	Markdown string `json:"markdown,omitempty"` // text/markdown
+	File     string `json:"file,omitempty"`     // Image path relative to /data
+}
+
+// kernelReply is what kernelClient prints
+type kernelReply struct {
+	Status         string    `json:"status"` // "ok" or "error"
+	ExecutionCount int       `json:"executionCount"`
+	Stdout         string    `json:"stdout"`
+	Stderr         string    `json:"stderr"`
+	Display        []Display `json:"display"`
+}
+
+// kernelClient runs in the kernel's container with the code on stdin. It
+// talks to the kernel over ZMQ with jupyter_client, collects the messages
+// the execution produces and prints them as a kernelReply. Tracebacks lose
+// their ANSI colors, and images go to KernelDisplayDir
+const kernelClient = `
+import base64, json, os, re, sys, time
+from jupyter_client import BlockingKernelClient
+
+connection = sys.argv[1]
+for _ in range(300):
+    if os.path.exists(connection) and os.path.getsize(connection) > 0:
+        break
+    time.sleep(0.1)
+client = BlockingKernelClient(connection_file=connection)
+client.load_connection_file()
+client.start_channels()
+client.wait_for_ready(timeout=60)
+
+ansi = re.compile(r'\x1b\[[0-9;]*[A-Za-z]')
+images = (('image/png', 'png'), ('image/jpeg', 'jpg'), ('image/svg+xml', 'svg'))
+texts = (('text/plain', 'text'), ('text/html', 'html'), ('text/markdown', 'markdown'))
+reply = {'status': 'ok', 'executionCount': 0, 'stdout': '', 'stderr': '', 'display': []}
+
+def display(data):
+    out = {}
+    for mime, ext in images:
+        if mime in data:
+            os.makedirs('/data/' + sys.argv[2], exist_ok=True)
+            name = '%s/%d-%d.%s' % (sys.argv[2], reply['executionCount'], len(reply['display']) + 1, ext)
+            content = data[mime]
+            with open('/data/' + name, 'wb') as f:
+                f.write(content.encode() if ext == 'svg' else base64.b64decode(content))
+            out['file'] = name
+            break
+    for mime, key in texts:
+        if mime in data:
+            out[key] = data[mime]
+    if out:
+        reply['display'].append(out)
+
+msg_id = client.execute(sys.stdin.read(), allow_stdin=False)
+while True:
+    msg = client.get_iopub_msg()
+    if msg['parent_header'].get('msg_id') != msg_id:
+        continue
+    kind, content = msg['msg_type'], msg['content']
+    if kind == 'execute_input':
+        reply['executionCount'] = content['execution_count']
+    elif kind == 'stream':
+        reply[content['name']] += content['text']
+    elif kind in ('display_data', 'execute_result'):
+        display(content['data'])
+    elif kind == 'error':
+        reply['status'] = 'error'
+        reply['stderr'] += ansi.sub('', '\n'.join(content['traceback'])) + '\n'
+    elif kind == 'status' and content['execution_state'] == 'idle':
+        break
+json.dump(reply, sys.stdout)
+`
+
+// ExecuteInKernel runs code in the Jupyter kernel of a container started
+// with the runner's Kernel command, so variables and imports persist across
+// calls. It is tracked and limited like an execution, with the runner's
+// timeout. A timeout interrupts the code but keeps the kernel and its state
+func (e *Executor) ExecuteInKernel(ctx context.Context, runner RunnerInfo, sandboxDir, containerID, code string) (result ExecutionResult) {
+	run := e.metrics.Start(runner.Language)
+	ctx, span := tracing.Start(ctx, "kernel.execute", trace.WithAttributes(
+		attribute.String("runner.language", runner.Language),
+		attribute.String("runner.image", runner.Image),
+	))
+	defer func() {
+		run.Finish(result.Success, result.TimedOut)
+		span.SetAttributes(attribute.Int("exit_code", result.ExitCode), attribute.Bool("timed_out", result.TimedOut))
+		tracing.End(span, result.Error)
+	}()
+
+	if !e.Available() {
+		return ExecutionResult{
+			Success: false,
+			Stderr:  e.messages.Format(messages.BackendUnavailable, nil),
+			Error:   ErrBackendUnavailable,
+		}
+	}
+	release, err := e.limiter.Acquire(ctx, sandboxDir, nil)
+	if errors.Is(err, ErrQueueFull) {
+		return ExecutionResult{
+			Success: false,
+			Stderr:  e.messages.Format(messages.ExecutionQueueFull, nil),
+			Error:   err,
+		}
+	} else if err != nil {
+		return ExecutionResult{
+			Success: false,
+			Stderr:  fmt.Sprintf("Execution cancelled while queued: %v", err),
+			Error:   err,
+		}
+	}
+	defer release()
+
+	timeout := e.Limits(runner).Timeout
+	started := time.Now()
+	exec, err := e.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          []string{"python", "-c", kernelClient, KernelConnectionFile, KernelDisplayDir},
+		WorkingDir:   "/data",
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to reach kernel: %w", err)
+		return ExecutionResult{Success: false, Stderr: err.Error(), Error: err}
+	}
+	attach, err := e.cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to reach kernel: %w", err)
+		return ExecutionResult{Success: false, Stderr: err.Error(), Error: err}
+	}
+	defer attach.Close()
+
+	go func() {
+		io.WriteString(attach.Conn, code)
+		attach.CloseWrite()
+	}()
+	var stdout, stderr bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		stdcopy.StdCopy(&stdout, &stderr, attach.Reader)
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	cancelled := false
+	select {
+	case <-done:
+	case <-timer.C:
+		result.TimedOut = true
+	case <-ctx.Done():
+		cancelled = true
+	}
+	if result.TimedOut || cancelled {
+		// SIGINT raises KeyboardInterrupt in the code, not in the kernel
+		killCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		if err := e.cli.ContainerKill(killCtx, containerID, "SIGINT"); err != nil {
+			log.Printf("Failed to interrupt kernel %s: %v", shortID(containerID), err)
+		}
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(kernelInterruptGrace):
+			attach.Close()
+			<-done
+		}
+	}
+
+	var reply kernelReply
+	if err := json.Unmarshal(stdout.Bytes(), &reply); err != nil {
+		// The client failed, or the interrupt cut it off
+		reply = kernelReply{Stderr: strings.TrimSpace(stderr.String())}
+		if !result.TimedOut && !cancelled {
+			result.Error = fmt.Errorf("kernel client failed: %w", err)
+		}
+	}
+	log.Printf("Kernel execution finished in %v: status=%s, timedOut=%v", time.Since(started).Round(time.Millisecond), reply.Status, result.TimedOut)
+
+	limit := e.Limits(runner).OutputBytes
+	result.Success = reply.Status == "ok" && !result.TimedOut && !cancelled
+	result.Stdout = truncateKernelOutput(reply.Stdout, limit, &result.StdoutTruncated, &result.StdoutBytes)
+	result.Stderr = truncateKernelOutput(reply.Stderr, limit, &result.StderrTruncated, &result.StderrBytes)
+	result.Display = reply.Display
+	result.ExecutionCount = reply.ExecutionCount
+	if result.Error != nil && result.Stderr == "" {
+		result.Stderr = result.Error.Error()
+	}
+	if !result.Success {
+		result.ExitCode = 1
+	}
+	if result.TimedOut {
+		result.ExitCode = -1
+		timeoutMsg := e.messages.Format(messages.ExecutionTimeout, messages.Args{
+			"Timeout": timeout,
+			"Seconds": int(timeout.Seconds()),
+		})
+		if result.Stderr != "" {
+			result.Stderr = timeoutMsg + "\n" + result.Stderr
+		} else {
+			result.Stderr = timeoutMsg
+		}
+	}
+	if cancelled {
+		cancelMsg := e.messages.Format(messages.ExecutionCancelled, messages.Args{
+			"Elapsed": time.Since(started).Round(time.Millisecond),
+		})
+		if result.Stderr != "" {
+			result.Stderr = cancelMsg + "\n" + result.Stderr
+		} else {
+			result.Stderr = cancelMsg
+		}
+	}
+	return result
+}
+
+// truncateKernelOutput applies the output limit to a stream of a kernel
+// execution, recording the stream's size when it cuts it
+func truncateKernelOutput(output string, limit int64, truncated *bool, size *int64) string {
+	if limit <= 0 || int64(len(output)) <= limit {
+		return output
+	}
+	*truncated = true
+	*size = int64(len(output))
+	return output[:limit]
+}
@@ -0,0 +1,218 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when an execution can't even be queued
+var ErrQueueFull = errors.New("execution queue is full")
+
+// Limiter caps how many containers run at once, overall and per
+// conversation, queueing the rest in arrival order up to a bound
+type Limiter struct {
+	global   int // 0 for no limit
+	perKey   int // 0 for no limit
+	maxQueue int
+
+	mu      sync.Mutex
+	running int
+	perRun  map[string]int
+	queue   []*waiter
+	average time.Duration // Moving average of how long a slot is held
+}
+
+// waiter is a queued execution
+type waiter struct {
+	key   string
+	ready chan struct{}
+}
+
+// NewLimiter creates a limiter allowing global executions at once and
+// perConversation per conversation (0 for no limit), with at most maxQueue
+// waiting
+func NewLimiter(global, perConversation, maxQueue int) *Limiter {
+	return &Limiter{
+		global:   global,
+		perKey:   perConversation,
+		maxQueue: maxQueue,
+		perRun:   make(map[string]int),
+		average:  5 * time.Second, // Until real executions are measured
+	}
+}
+
+// SetLimits changes the limits, as for NewLimiter. Running executions are
+// unaffected; queued ones start as soon as the new limits allow
+func (l *Limiter) SetLimits(global, perConversation, maxQueue int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global = global
+	l.perKey = perConversation
+	l.maxQueue = maxQueue
+	l.dispatchLocked()
+}
+
+// QueueStatus describes a queued execution
+type QueueStatus struct {
+	Position      int // 1 is next
+	EstimatedWait time.Duration
+}
+
+// Acquire waits for a slot for the conversation identified by key, calling
+// onQueued with the queue position whenever it may have changed. The
+// returned release frees the slot. A nil Limiter never waits
+func (l *Limiter) Acquire(ctx context.Context, key string, onQueued func(QueueStatus)) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	if len(l.queue) == 0 && l.fits(key) {
+		release := l.grantLocked(key)
+		l.mu.Unlock()
+		return release, nil
+	}
+	if len(l.queue) >= l.maxQueue {
+		l.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	w := &waiter{key: key, ready: make(chan struct{})}
+	l.queue = append(l.queue, w)
+	l.mu.Unlock()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		if onQueued != nil {
+			if status, ok := l.status(w); ok {
+				onQueued(status)
+			}
+		}
+		select {
+		case <-w.ready:
+			return l.releaseFunc(key), nil
+		case <-ctx.Done():
+			l.mu.Lock()
+			select {
+			case <-w.ready:
+				// Granted while giving up; hand the slot on
+				l.mu.Unlock()
+				l.releaseFunc(key)()
+			default:
+				l.removeLocked(w)
+				l.mu.Unlock()
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// fits reports whether key can start now; l.mu must be held
+func (l *Limiter) fits(key string) bool {
+	return (l.global <= 0 || l.running < l.global) && (l.perKey <= 0 || l.perRun[key] < l.perKey)
+}
+
+// grantLocked takes a slot for key; l.mu must be held
+func (l *Limiter) grantLocked(key string) func() {
+	l.running++
+	l.perRun[key]++
+	return l.releaseFunc(key)
+}
+
+// releaseFunc returns the function that frees key's slot, once
+func (l *Limiter) releaseFunc(key string) func() {
+	granted := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.running--
+			if l.perRun[key]--; l.perRun[key] <= 0 {
+				delete(l.perRun, key)
+			}
+			l.average = (l.average*4 + time.Since(granted)) / 5
+			l.dispatchLocked()
+		})
+	}
+}
+
+// dispatchLocked starts queued executions that now fit, oldest first. A
+// conversation at its own limit doesn't hold up others behind it
+func (l *Limiter) dispatchLocked() {
+	for i := 0; i < len(l.queue); {
+		w := l.queue[i]
+		if l.global > 0 && l.running >= l.global {
+			return
+		}
+		if !l.fits(w.key) {
+			i++
+			continue
+		}
+		l.running++
+		l.perRun[w.key]++
+		l.queue = append(l.queue[:i], l.queue[i+1:]...)
+		close(w.ready)
+	}
+}
+
+// removeLocked drops a waiter from the queue; l.mu must be held
+func (l *Limiter) removeLocked(w *waiter) {
+	for i, queued := range l.queue {
+		if queued == w {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// status returns a waiter's position and estimated wait, if still queued
+func (l *Limiter) status(w *waiter) (QueueStatus, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, queued := range l.queue {
+		if queued != w {
+			continue
+		}
+		// Each round of slots frees up after about one average execution
+		slots := l.global
+		if slots <= 0 {
+			slots = 1
+		}
+		rounds := i/slots + 1
+		return QueueStatus{Position: i + 1, EstimatedWait: time.Duration(rounds) * l.average}, true
+	}
+	return QueueStatus{}, false
+}
+
+// QueueSnapshot is the limiter's state, for the admin API
+type QueueSnapshot struct {
+	Running         int `json:"running"`
+	Queued          int `json:"queued"`
+	MaxConcurrent   int `json:"maxConcurrent"`
+	MaxQueue        int `json:"maxQueue"`
+	PerConversation int `json:"perConversation"`
+}
+
+// Queue reports how many executions are running and waiting
+func (e *Executor) Queue() QueueSnapshot {
+	l := e.limiter
+	if l == nil {
+		return QueueSnapshot{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return QueueSnapshot{
+		Running:         l.running,
+		Queued:          len(l.queue),
+		MaxConcurrent:   l.global,
+		MaxQueue:        l.maxQueue,
+		PerConversation: l.perKey,
+	}
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Labels on every container the executor creates
+const (
+	managedLabel      = "sandbox.managed"
+	conversationLabel = "sandbox.conversation" // Hashed sandbox directory; absent on probes
+)
+
+// managedLabels marks a container as the executor's, for the sandbox mounted
+// from sandboxDir ("" for none)
+func managedLabels(sandboxDir string) map[string]string {
+	labels := map[string]string{managedLabel: "true"}
+	if sandboxDir != "" {
+		labels[conversationLabel] = filepath.Base(sandboxDir)
+	}
+	return labels
+}
+
+// RemoveOrphans force-removes the executions and probes a previous run of
+// the server left behind on every host, e.g. after a crash. Nothing of this
+// run's can exist yet, so call it at startup before anything is executed.
+// Background processes carry no deadline and are left to their manager
+func (e *Executor) RemoveOrphans(ctx context.Context) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", managedLabel+"=true")
+	filterArgs.Add("label", deadlineLabel)
+
+	for _, host := range e.pool.hosts {
+		containers, err := host.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: filterArgs})
+		if err != nil {
+			log.Printf("Failed to list orphaned containers on %s: %v", host.name, err)
+			continue
+		}
+
+		removed := 0
+		for _, c := range containers {
+			removeCtx, cancel := context.WithTimeout(ctx, watchdogRemoveTimeout)
+			err := host.cli.ContainerRemove(removeCtx, c.ID, container.RemoveOptions{Force: true})
+			cancel()
+			if err != nil {
+				log.Printf("Failed to remove orphaned container %s: %v", shortID(c.ID), err)
+				continue
+			}
+			removed++
+		}
+		if removed > 0 {
+			log.Printf("Removed %d orphaned container(s) on %s", removed, host.name)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package runner
+
+import "context"
+
+// OutputFunc receives a run's output as it is written, on stream "stdout"
+// or "stderr". Output past the limit isn't passed on. data is only valid
+// during the call
+type OutputFunc func(stream string, data []byte)
+
+type outputKey struct{}
+
+// WithOutput returns a context that makes Execute pass its output to fn as
+// it is written
+func WithOutput(ctx context.Context, fn OutputFunc) context.Context {
+	return context.WithValue(ctx, outputKey{}, fn)
+}
+
+// outputFromContext returns the output callback carried by ctx, if any
+func outputFromContext(ctx context.Context) OutputFunc {
+	fn, _ := ctx.Value(outputKey{}).(OutputFunc)
+	return fn
+}
+
+// outputWriter passes writes to an OutputFunc
+type outputWriter struct {
+	fn     OutputFunc
+	stream string
+}
+
+func (o outputWriter) Write(p []byte) (int, error) {
+	o.fn(o.stream, p)
+	return len(p), nil
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// normalizeArch maps the machine names the Docker daemon reports (x86_64,
+// aarch64) to the architecture names images are built for (amd64, arm64)
+func normalizeArch(arch string) string {
+	switch arch {
+	case "x86_64", "x86-64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "armv6l", "armv7l", "armhf":
+		return "arm"
+	case "i386", "i686":
+		return "386"
+	}
+	return arch
+}
+
+// imageFor returns the runner's image for an architecture, and whether it
+// is built for it. Unknown architectures match any image
+func (r RunnerInfo) imageFor(arch string) (string, bool) {
+	if image, ok := r.ArchImages[arch]; ok {
+		return image, true
+	}
+	return r.Image, arch == "" || r.Architecture == "" || r.Architecture == arch
+}
+
+// Architectures lists the architectures the runner has images for, empty
+// if unknown
+func (r RunnerInfo) Architectures() []string {
+	var archs []string
+	if r.Architecture != "" {
+		archs = append(archs, r.Architecture)
+	}
+	for arch := range r.ArchImages {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+	return archs
+}
+
+// mergeArchitectures adds an image of a runner's language and version to
+// it. The image replaces the one for its architecture. The image for arch,
+// the primary host's, keeps the runner's settings (or else the existing one
+// does); the others are only used on hosts of their architecture
+func mergeArchitectures(existing, info RunnerInfo, arch string) RunnerInfo {
+	images := make(map[string]string, len(existing.ArchImages)+2)
+	for a, image := range existing.ArchImages {
+		images[a] = image
+	}
+	images[existing.Architecture] = existing.Image
+	images[info.Architecture] = info.Image
+
+	merged := existing
+	if info.Architecture == arch || info.Architecture == existing.Architecture {
+		merged = info
+	}
+	delete(images, merged.Architecture)
+	merged.ArchImages = images
+	return merged
+}
+
+// resolveImage returns the image to run the runner with on a host. When
+// the host lacks an image built for its architecture, or its image's
+// signature doesn't verify, it pulls the host's platform of the runner's
+// image, which works for multi-platform images in a registry. Otherwise
+// there is no usable image and it fails
+func (e *Executor) resolveImage(ctx context.Context, h *Host, runner RunnerInfo) (string, error) {
+	arch := h.architecture(ctx)
+	image, _ := runner.imageFor(arch)
+	record, present := h.inspectImage(ctx, image)
+	if !present && arch == "" {
+		// The daemon can't be reached; creating the container reports it
+		return image, nil
+	}
+	var unverified error
+	if present && (arch == "" || record.arch == "" || record.arch == arch) {
+		if unverified = e.verifier.verifyLocal(ctx, image, record.digests); unverified == nil {
+			return image, nil
+		}
+	}
+
+	var platform string
+	if arch != "" {
+		platform = "linux/" + arch
+	}
+	if err := e.pull(ctx, h.cli, image, platform); err != nil {
+		switch {
+		case unverified != nil:
+			return "", fmt.Errorf("image %s on Docker host %s is not trusted (%v), and pulling a signed one failed: %w",
+				image, h.name, unverified, err)
+		case present:
+			return "", fmt.Errorf("no %s image of the %s runner for Docker host %s: %s is built for %s, and pulling it for %s failed: %w",
+				arch, runner.Language, h.name, image, record.arch, platform, err)
+		default:
+			return "", fmt.Errorf("image %s of the %s runner is not on Docker host %s, and pulling it for %s failed: %w",
+				image, runner.Language, h.name, platform, err)
+		}
+	}
+	log.Printf("Pulled %s on Docker host %s", image, h.name)
+	h.forgetImage(image)
+	if record, _ = h.inspectImage(ctx, image); arch != "" && record.arch != "" && record.arch != arch {
+		return "", fmt.Errorf("no %s image of the %s runner for Docker host %s: %s is only built for %s",
+			arch, runner.Language, h.name, image, record.arch)
+	}
+	return image, nil
+}
@@ -0,0 +1,255 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// imagePresenceTTL is how long a host's image check is trusted
+const imagePresenceTTL = 5 * time.Minute
+
+// Host is one Docker host executions can be scheduled on
+type Host struct {
+	name     string
+	cli      *client.Client
+	inflight atomic.Int64
+	capacity atomic.Int64 // CPUs the daemon reports; at least 1
+	healthy  atomic.Bool
+
+	mu     sync.Mutex
+	arch   string                 // Architecture the daemon reports, e.g. "arm64"
+	images map[string]imageRecord // Images seen on this host
+}
+
+// imageRecord is a cached image check
+type imageRecord struct {
+	seen    time.Time
+	arch    string   // Architecture the image is built for
+	digests []string // Registry digests the image was pulled by
+}
+
+// HostStatus is a host's scheduling state, for the admin API
+type HostStatus struct {
+	Name         string `json:"name"`
+	Healthy      bool   `json:"healthy"`
+	Inflight     int64  `json:"inflight"`
+	Capacity     int64  `json:"capacity"`
+	Architecture string `json:"architecture,omitempty"`
+}
+
+// Pool schedules executions across Docker hosts by load. The first host is
+// the primary: it runs everything needing host-local networks (restricted
+// egress, helper services) and image discovery. All hosts must see the
+// sandbox directory at SANDBOX_HOST_PATH, e.g. over NFS
+type Pool struct {
+	hosts []*Host
+}
+
+// NewPool creates a pool of the primary client and clients for hosts (Docker
+// host URLs such as tcp://10.0.0.2:2376; TLS settings come from the
+// DOCKER_CERT_PATH/DOCKER_TLS_VERIFY environment)
+func NewPool(ctx context.Context, primary *client.Client, hosts []string) (*Pool, error) {
+	p := &Pool{}
+	p.add(ctx, "primary", primary)
+	for _, url := range hosts {
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithHost(url), client.WithAPIVersionNegotiation())
+		if err != nil {
+			return nil, fmt.Errorf("invalid Docker host %s: %w", url, err)
+		}
+		p.add(ctx, url, cli)
+	}
+	return p, nil
+}
+
+// singleHostPool wraps one client, for executors without a pool
+func singleHostPool(cli *client.Client) *Pool {
+	h := &Host{name: "primary", cli: cli, images: make(map[string]imageRecord)}
+	h.capacity.Store(1)
+	h.healthy.Store(true)
+	return &Pool{hosts: []*Host{h}}
+}
+
+// add registers a host, checking it once so a down host starts unhealthy
+func (p *Pool) add(ctx context.Context, name string, cli *client.Client) {
+	h := &Host{name: name, cli: cli, images: make(map[string]imageRecord)}
+	h.capacity.Store(1)
+	p.hosts = append(p.hosts, h)
+	if err := h.check(ctx); err != nil {
+		log.Printf("Docker host %s is unavailable: %v", name, err)
+	} else {
+		log.Printf("Docker host %s: %d CPUs, %s", name, h.capacity.Load(), h.architecture(ctx))
+	}
+}
+
+// Close closes the clients of all hosts but the primary
+func (p *Pool) Close() {
+	for _, h := range p.hosts[1:] {
+		h.cli.Close()
+	}
+}
+
+// Monitor re-checks every host's health and capacity each interval until
+// ctx is done
+func (p *Pool) Monitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, h := range p.hosts {
+				wasHealthy := h.healthy.Load()
+				err := h.check(ctx)
+				switch {
+				case err != nil && wasHealthy:
+					log.Printf("Docker host %s is unavailable: %v", h.name, err)
+				case err == nil && !wasHealthy:
+					log.Printf("Docker host %s is available again", h.name)
+				}
+			}
+		}
+	}
+}
+
+// check updates a host's health and capacity from the daemon
+func (h *Host) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	info, err := h.cli.Info(ctx)
+	if err != nil {
+		h.healthy.Store(false)
+		return err
+	}
+	if info.NCPU > 0 {
+		h.capacity.Store(int64(info.NCPU))
+	}
+	h.mu.Lock()
+	h.arch = normalizeArch(info.Architecture)
+	h.mu.Unlock()
+	h.healthy.Store(true)
+	return nil
+}
+
+// architecture returns the host's architecture, asking the daemon if it
+// isn't known yet. It is empty while the daemon can't be reached
+func (h *Host) architecture(ctx context.Context) string {
+	h.mu.Lock()
+	arch := h.arch
+	h.mu.Unlock()
+	if arch != "" {
+		return arch
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	info, err := h.cli.Info(ctx)
+	if err != nil {
+		return ""
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.arch = normalizeArch(info.Architecture)
+	return h.arch
+}
+
+// acquire picks the least loaded healthy host that has an image of the
+// runner for its architecture, relative to its CPUs, and counts an
+// execution against it until release is called. pinned executions, and
+// those no host has an image for, run on the primary
+func (p *Pool) acquire(ctx context.Context, runner RunnerInfo, pinned bool) (*Host, func()) {
+	chosen := p.hosts[0]
+	if !pinned && len(p.hosts) > 1 {
+		var best *Host
+		for _, h := range p.hosts {
+			if !h.healthy.Load() || !h.canRun(ctx, runner) {
+				continue
+			}
+			if best == nil || load(h) < load(best) {
+				best = h
+			}
+		}
+		if best != nil {
+			chosen = best
+		}
+	}
+	chosen.inflight.Add(1)
+	return chosen, func() { chosen.inflight.Add(-1) }
+}
+
+// load is a host's in-flight executions per CPU
+func load(h *Host) float64 {
+	return float64(h.inflight.Load()) / float64(h.capacity.Load())
+}
+
+// canRun reports whether the host has an image of the runner built for
+// its architecture
+func (h *Host) canRun(ctx context.Context, runner RunnerInfo) bool {
+	arch := h.architecture(ctx)
+	image, ok := runner.imageFor(arch)
+	if !ok {
+		return false
+	}
+	record, present := h.inspectImage(ctx, image)
+	return present && (arch == "" || record.arch == "" || record.arch == arch)
+}
+
+// inspectImage reports whether the host has an image, with its
+// architecture and digests, caching positive answers
+func (h *Host) inspectImage(ctx context.Context, image string) (imageRecord, bool) {
+	h.mu.Lock()
+	record, ok := h.images[image]
+	h.mu.Unlock()
+	if ok && time.Since(record.seen) < imagePresenceTTL {
+		return record, true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	inspect, err := h.cli.ImageInspect(ctx, image)
+	if err != nil {
+		return imageRecord{}, false
+	}
+	record = imageRecord{seen: time.Now(), arch: inspect.Architecture, digests: inspect.RepoDigests}
+	h.mu.Lock()
+	h.images[image] = record
+	h.mu.Unlock()
+	return record, true
+}
+
+// forgetImage drops a cached image check, after the image was replaced
+func (h *Host) forgetImage(image string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.images, image)
+}
+
+// Hosts reports the load of the Docker hosts executions run on
+func (e *Executor) Hosts() []HostStatus {
+	return e.pool.Hosts()
+}
+
+// Hosts reports the scheduling state of every host
+func (p *Pool) Hosts() []HostStatus {
+	status := make([]HostStatus, 0, len(p.hosts))
+	for _, h := range p.hosts {
+		h.mu.Lock()
+		arch := h.arch
+		h.mu.Unlock()
+		status = append(status, HostStatus{
+			Name:         h.name,
+			Healthy:      h.healthy.Load(),
+			Inflight:     h.inflight.Load(),
+			Capacity:     h.capacity.Load(),
+			Architecture: arch,
+		})
+	}
+	return status
+}
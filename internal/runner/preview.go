@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+)
+
+// PreviewFunc is told the host port a preview was published on once the
+// container is running, and returns the URL it is reachable at
+type PreviewFunc func(hostPort string) string
+
+// preview is a container port to publish for a web app preview
+type preview struct {
+	port      int
+	bindHost  string
+	published PreviewFunc
+}
+
+type previewKey struct{}
+
+// WithPreview returns a context that publishes a container port on
+// bindHost, at a port Docker picks, and reports it to published. The
+// execution needs network access, which port publishing relies on
+func WithPreview(ctx context.Context, port int, bindHost string, published PreviewFunc) context.Context {
+	return context.WithValue(ctx, previewKey{}, preview{port: port, bindHost: bindHost, published: published})
+}
+
+// previewFromContext returns the preview ctx asks for, if any
+func previewFromContext(ctx context.Context) (preview, bool) {
+	p, ok := ctx.Value(previewKey{}).(preview)
+	return p, ok
+}
+
+// apply exposes the preview port in the container configuration
+func (p preview) apply(config *container.Config, hostConfig *container.HostConfig) {
+	port := nat.Port(strconv.Itoa(p.port) + "/tcp")
+	config.ExposedPorts = nat.PortSet{port: {}}
+	hostConfig.PortBindings = nat.PortMap{port: {{HostIP: p.bindHost}}}
+}
+
+// publish looks up the host port Docker assigned and reports it
+func (p preview) publish(cli *client.Client, containerID string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if inspect.NetworkSettings != nil {
+		for _, binding := range inspect.NetworkSettings.Ports[nat.Port(strconv.Itoa(p.port)+"/tcp")] {
+			if binding.HostPort != "" {
+				url := p.published(binding.HostPort)
+				log.Printf("Preview of port %d published on %s:%s", p.port, p.bindHost, binding.HostPort)
+				return url, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("port %d was not published", p.port)
+}
@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// probeTimeout bounds each library probe container
+const probeTimeout = 60 * time.Second
+
+// defaultProbes lists installed packages for well-known languages; images can
+// override or add one with the sandbox.probe label (a shell command)
+var defaultProbes = map[string]string{
+	"python":     "pip list --format json",
+	"typescript": "bun pm ls -g",
+	"javascript": "npm ls -g --depth=0 --json",
+}
+
+// Package is an installed library reported by a runner's probe
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// packageLine matches "name@version" (npm/bun trees) and "name==version" (pip freeze)
+var packageLine = regexp.MustCompile(`(@?[A-Za-z0-9_.-]+(?:/[A-Za-z0-9_.-]+)?)(?:@|==)([0-9][A-Za-z0-9_.+-]*)`)
+
+// ProbePackages runs each runner's probe command once and caches the result
+// by image ID, so unchanged images are not probed again after a refresh
+func (r *Registry) ProbePackages(ctx context.Context, e *Executor) {
+	for _, info := range r.ListRunners() {
+		if info.Probe == "" {
+			continue
+		}
+		if _, ok := r.Packages(info); ok {
+			continue
+		}
+
+		output, err := e.Probe(ctx, info, info.Probe)
+		if err != nil {
+			log.Printf("Library probe failed for %s: %v", info.Image, err)
+			continue
+		}
+		packages := parsePackages(output)
+		log.Printf("Probed %s: %d package(s)", info.Image, len(packages))
+		r.setPackages(info, packages)
+	}
+}
+
+// Probe runs a shell command in a throwaway container of the runner's image
+// (no network, no mounts) and returns its stdout
+func (e *Executor) Probe(ctx context.Context, runner RunnerInfo, command string) (string, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	image, err := e.resolveImage(probeCtx, e.pool.hosts[0], runner)
+	if err != nil {
+		return "", err
+	}
+	labels := managedLabels("")
+	labels[deadlineLabel] = strconv.FormatInt(time.Now().Add(probeTimeout).Unix(), 10)
+	resp, err := e.cli.ContainerCreate(probeCtx, &container.Config{
+		Image:           image,
+		Entrypoint:      []string{"/bin/sh", "-c", command},
+		NetworkDisabled: true,
+		User:            defaultUser,
+		Labels:          labels,
+	}, &container.HostConfig{
+		Resources: container.Resources{
+			Memory:   memoryLimit,
+			NanoCPUs: cpuLimit,
+		},
+	}, nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create probe container: %w", err)
+	}
+	defer func() {
+		removeCtx, removeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer removeCancel()
+		e.cli.ContainerRemove(removeCtx, resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	if err := e.cli.ContainerStart(probeCtx, resp.ID, container.StartOptions{}); err != nil {
+		return "", fmt.Errorf("failed to start probe container: %w", err)
+	}
+
+	statusCh, errCh := e.cli.ContainerWait(probeCtx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return "", fmt.Errorf("probe wait error: %w", err)
+		}
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return "", fmt.Errorf("probe exited with status %d", status.StatusCode)
+		}
+	}
+
+	logs, err := e.cli.ContainerLogs(probeCtx, resp.ID, container.LogsOptions{ShowStdout: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to read probe output: %w", err)
+	}
+	defer logs.Close()
+
+	var stdout bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &bytes.Buffer{}, logs); err != nil {
+		return "", fmt.Errorf("failed to read probe output: %w", err)
+	}
+	return stdout.String(), nil
+}
+
+// parsePackages understands pip's JSON list, package.json/npm JSON
+// dependency maps, and line-oriented "name@version" or "name==version" output
+func parsePackages(output string) []Package {
+	output = strings.TrimSpace(output)
+
+	var list []Package
+	if err := json.Unmarshal([]byte(output), &list); err == nil {
+		return sortPackages(list)
+	}
+
+	var deps struct {
+		Dependencies map[string]json.RawMessage `json:"dependencies"`
+	}
+	if err := json.Unmarshal([]byte(output), &deps); err == nil && deps.Dependencies != nil {
+		packages := make([]Package, 0, len(deps.Dependencies))
+		for name, raw := range deps.Dependencies {
+			// package.json maps to a version string, npm ls to an object
+			var version string
+			if json.Unmarshal(raw, &version) != nil {
+				var entry struct {
+					Version string `json:"version"`
+				}
+				json.Unmarshal(raw, &entry)
+				version = entry.Version
+			}
+			packages = append(packages, Package{Name: name, Version: strings.TrimLeft(version, "^~")})
+		}
+		return sortPackages(packages)
+	}
+
+	var packages []Package
+	for _, line := range strings.Split(output, "\n") {
+		if m := packageLine.FindStringSubmatch(line); m != nil {
+			packages = append(packages, Package{Name: m[1], Version: m[2]})
+		}
+	}
+	return sortPackages(packages)
+}
+
+func sortPackages(packages []Package) []Package {
+	sort.Slice(packages, func(i, j int) bool {
+		return strings.ToLower(packages[i].Name) < strings.ToLower(packages[j].Name)
+	})
+	return packages
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// StartProcess starts a detached runner container that runs command with
+// /bin/sh -c, for servers and watchers that outlive a tool call. It gets
+// the same mount, limits, hardening and network controls as an execution,
+// but no timeout and no concurrency slot: the caller owns its lifetime and
+// removes it by ID. labels are added to the managed ones. Processes always
+// run on the primary Docker host
+func (e *Executor) StartProcess(ctx context.Context, runner RunnerInfo, sandboxDir, user, name, command string, networkEnabled bool, environment map[string]string, labels map[string]string) (string, error) {
+	if !e.Available() {
+		return "", ErrBackendUnavailable
+	}
+	spec, err := e.containerSpec(ctx, runner, sandboxDir, user, []string{"/bin/sh", "-c", command}, networkEnabled, environment)
+	if err != nil {
+		return "", err
+	}
+	if spec.config.Image, err = e.resolveImage(ctx, e.pool.hosts[0], runner); err != nil {
+		return "", err
+	}
+	spec.config.OpenStdin, spec.config.StdinOnce, spec.config.AttachStdin = false, false, false
+	spec.config.AttachStdout, spec.config.AttachStderr = false, false
+	spec.config.Labels = managedLabels(sandboxDir)
+	for key, value := range labels {
+		spec.config.Labels[key] = value
+	}
+
+	createCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	resp, err := e.cli.ContainerCreate(createCtx, spec.config, spec.hostConfig, nil, nil, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	remove := func() {
+		removeCtx, removeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer removeCancel()
+		e.cli.ContainerRemove(removeCtx, resp.ID, container.RemoveOptions{Force: true})
+	}
+
+	if spec.services != "" {
+		if err := e.cli.NetworkConnect(createCtx, spec.services, resp.ID, nil); err != nil {
+			remove()
+			return "", fmt.Errorf("failed to attach service network: %w", err)
+		}
+	}
+	if err := e.cli.ContainerStart(createCtx, resp.ID, container.StartOptions{}); err != nil {
+		remove()
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+	if preview, ok := previewFromContext(ctx); ok {
+		if _, err := preview.publish(e.cli, resp.ID); err != nil {
+			log.Printf("Preview unavailable: %v", err)
+		}
+	}
+	return resp.ID, nil
+}
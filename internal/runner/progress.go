@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often progress is reported while a container runs
+const progressInterval = time.Second
+
+// Progress describes a running or queued execution. Elapsed counts from
+// when the execution was requested, including time spent queued
+type Progress struct {
+	Elapsed     time.Duration
+	StdoutBytes int64
+	StderrBytes int64
+
+	// Set while waiting for a concurrency slot
+	Queued        bool
+	QueuePosition int
+	EstimatedWait time.Duration
+
+	// Set once, when a WithPreview port has been published
+	PreviewURL string
+}
+
+// ProgressFunc receives periodic progress updates during an execution
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context that makes Execute report progress to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFromContext returns the progress callback carried by ctx, if any
+func progressFromContext(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// countingWriter counts bytes written so progress can be read concurrently
+// without touching the underlying buffer
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// cappedWriter passes on at most limit bytes and discards the rest, so a
+// run printing gigabytes can't exhaust the server's memory. Writes always
+// succeed so the container isn't blocked on a full pipe
+type cappedWriter struct {
+	w         io.Writer
+	limit     int64
+	written   int64 // Only touched by the single stdcopy goroutine
+	truncated atomic.Bool
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	kept := p
+	if keep := c.limit - c.written; int64(len(p)) > keep {
+		c.truncated.Store(true)
+		kept = p[:max(keep, 0)]
+	}
+	if len(kept) == 0 {
+		return len(p), nil
+	}
+	n, err := c.w.Write(kept)
+	c.written += int64(n)
+	if err != nil {
+		return n, err
+	}
+	return len(p), nil
+}
@@ -3,38 +3,137 @@ package runner
 import (
 	"context"
 	"fmt"
+	"log"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
 )
 
 // RunnerInfo holds information about a discovered runner image
 type RunnerInfo struct {
-	Image    string
-	Language string
+	Image       string
+	ImageID     string // Content-addressed image ID (sha256 digest)
+	Language    string
+	Version     string // From the sandbox.version label; empty if unversioned
+	Default     bool   // Whether this is the language's default version
+	Description string // Optional human-readable description
+	Static      bool   // Registered via the runners config file rather than labels
+	Probe       string // Shell command listing installed packages (sandbox.probe)
+	Install     string // Shell command installing the packages in "$@" (sandbox.install)
+	CodeFile    bool   // Reads code from CodeFile, leaving stdin to the program (sandbox.code-file)
+	RunFile     string // Shell command running the /data file in "$1" (sandbox.run-file)
+	Compile     string // Shell command compiling the source file in "$1" into BuildDir (sandbox.compile)
+	Artifact    string // Shell command running what Compile left in BuildDir (sandbox.artifact)
+	Kernel      string // Shell command starting a Jupyter kernel on KernelConnectionFile (sandbox.kernel)
+	GPU         bool   // Can use host GPUs when an execution asks for them (sandbox.gpu)
+
+	// Platform of the image; Docker hosts of another architecture run the
+	// image for theirs from ArchImages, with this runner's settings
+	Architecture string            // e.g. "arm64"; empty if the image isn't present locally
+	ArchImages   map[string]string // Same language and version built for other architectures
+
+	// Optional per-runner execution settings from image labels or config
+	Timeout     time.Duration // sandbox.timeout, e.g. "120s" (0 = executor default)
+	ShmSize     int64         // sandbox.shm-size in bytes, e.g. "1g" (0 = Docker default)
+	CapAdd      []string      // sandbox.cap-add, comma separated (subject to executor policy)
+	MemoryBytes int64         // sandbox.memory, e.g. "512m" (0 = executor default)
+	NanoCPUs    int64         // sandbox.cpus, e.g. "1.5" (0 = executor default)
+	Seccomp     string        // sandbox.seccomp, a profile name from RUNNER_SECCOMP_DIR
+	AppArmor    string        // sandbox.apparmor, an AppArmor profile loaded on the host
+}
+
+// languageRunners holds every version of one language's runner
+type languageRunners struct {
+	versions       map[string]RunnerInfo
+	defaultVersion string
 }
 
 // Registry manages available runner images
+// The index is rebuilt by Refresh and may be swapped while requests read it
 type Registry struct {
-	runnersByLanguage map[string]RunnerInfo
+	cli      *client.Client
+	verifier *Verifier // Rejects images whose signature doesn't verify; nil for none
+
+	mu                sync.RWMutex
+	static            []RunnerInfo // Replaced by SetStatic on reload
+	runnersByLanguage map[string]*languageRunners
+
+	packagesMu sync.RWMutex
+	packages   map[string][]Package // Probed packages keyed by image ID (or name)
 }
 
 // NewRegistry creates a new registry by discovering runner images from Docker
-func NewRegistry(ctx context.Context, cli *client.Client) (*Registry, error) {
+// and merging statically configured runners (which win on conflicts).
+// Images may carry a sandbox.version label to offer several versions of a
+// language; the one labelled sandbox.default=true (or else the highest
+// version) is used when no version is requested. Images of one version
+// built for different architectures are combined into one runner that
+// prefers the Docker host's architecture. With a verifier, images whose
+// signature doesn't verify are left out
+func NewRegistry(ctx context.Context, cli *client.Client, static []RunnerInfo, verifier *Verifier) (*Registry, error) {
+	r := &Registry{
+		cli:      cli,
+		verifier: verifier,
+		static:   static,
+		packages: make(map[string][]Package),
+	}
+	if _, err := r.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Refresh rediscovers runner images and atomically replaces the index,
+// reporting whether the set of runners changed
+func (r *Registry) Refresh(ctx context.Context) (bool, error) {
+	runners, arch, err := r.discover(ctx)
+	if err != nil {
+		return false, err
+	}
+	index := indexRunners(runners, arch)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := !sameRunners(r.runnersByLanguage, index)
+	r.runnersByLanguage = index
+	return changed, nil
+}
+
+// SetStatic replaces the statically configured runners. They are served
+// from the next Refresh
+func (r *Registry) SetStatic(static []RunnerInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.static = static
+}
+
+// discover lists labelled runner images and appends the static runners. It
+// also returns the Docker host's architecture, empty if unknown
+func (r *Registry) discover(ctx context.Context) ([]RunnerInfo, string, error) {
 	// List images with label sandbox.runner=true
 	filterArgs := filters.NewArgs()
 	filterArgs.Add("label", "sandbox.runner=true")
 
-	images, err := cli.ImageList(ctx, image.ListOptions{
+	images, err := r.cli.ImageList(ctx, image.ListOptions{
 		Filters: filterArgs,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list docker images: %w", err)
+		return nil, "", fmt.Errorf("failed to list docker images: %w", err)
+	}
+	var arch string
+	if info, err := r.cli.Info(ctx); err == nil {
+		arch = normalizeArch(info.Architecture)
 	}
 
-	runnersByLanguage := make(map[string]RunnerInfo)
-
+	var runners []RunnerInfo
 	for _, img := range images {
 		// Extract language from labels
 		language, ok := img.Labels["sandbox.language"]
@@ -42,34 +141,315 @@ func NewRegistry(ctx context.Context, cli *client.Client) (*Registry, error) {
 			continue
 		}
 
-		// Use first RepoTag as image name, or ID if no tags
+		// Use first RepoTag as image name, or the digest it was pulled by
+		// (or its ID) if it has no tags
 		imageName := img.ID
 		if len(img.RepoTags) > 0 {
 			imageName = img.RepoTags[0]
+		} else if len(img.RepoDigests) > 0 {
+			imageName = img.RepoDigests[0]
+		}
+		if err := r.verifier.verifyLocal(ctx, imageName, img.RepoDigests); err != nil {
+			log.Printf("Skipping runner image %s: %v", imageName, err)
+			continue
+		}
+
+		info := RunnerInfo{
+			Image:       imageName,
+			ImageID:     img.ID,
+			Language:    language,
+			Version:     img.Labels["sandbox.version"],
+			Default:     img.Labels["sandbox.default"] == "true",
+			Description: img.Labels["sandbox.description"],
 		}
+		applyLabelSettings(&info, img.Labels)
+		// Image summaries don't carry the architecture
+		if inspect, err := r.cli.ImageInspect(ctx, img.ID); err == nil {
+			info.Architecture = inspect.Architecture
+		}
+		if info.Probe == "" {
+			info.Probe = defaultProbes[language]
+		}
+		if info.Install == "" {
+			info.Install = defaultInstallers[language]
+		}
+		if info.RunFile == "" {
+			info.RunFile = defaultFileRunners[language]
+		}
+		runners = append(runners, info)
+	}
+
+	// Static runners may reference images without labels; resolve their IDs
+	// when the image is present locally
+	r.mu.RLock()
+	static := r.static
+	r.mu.RUnlock()
+	for _, info := range static {
+		// Images not pulled yet are verified when they are
+		if inspect, err := r.cli.ImageInspect(ctx, info.Image); err == nil {
+			if err := r.verifier.verifyLocal(ctx, info.Image, inspect.RepoDigests); err != nil {
+				log.Printf("Skipping configured runner image %s: %v", info.Image, err)
+				continue
+			}
+			info.ImageID = inspect.ID
+			info.Architecture = inspect.Architecture
+		} else {
+			log.Printf("Configured runner image %s not found locally: %v", info.Image, err)
+		}
+		info.Static = true
+		if info.Probe == "" {
+			info.Probe = defaultProbes[info.Language]
+		}
+		if info.Install == "" {
+			info.Install = defaultInstallers[info.Language]
+		}
+		if info.RunFile == "" {
+			info.RunFile = defaultFileRunners[info.Language]
+		}
+		runners = append(runners, info)
+	}
+
+	return runners, arch, nil
+}
+
+// indexRunners indexes runners by language and version; later entries replace
+// earlier ones with the same language and version. Images with a known
+// architecture only replace the one for theirs, preferring arch for the
+// runner's settings
+func indexRunners(runners []RunnerInfo, arch string) map[string]*languageRunners {
+	runnersByLanguage := make(map[string]*languageRunners)
+	explicitDefaults := make(map[string]string)
+
+	for _, info := range runners {
+		lr, ok := runnersByLanguage[info.Language]
+		if !ok {
+			lr = &languageRunners{versions: make(map[string]RunnerInfo)}
+			runnersByLanguage[info.Language] = lr
+		}
+		if info.Default {
+			explicitDefaults[info.Language] = info.Version
+		}
+		info.Default = false
+		if existing, ok := lr.versions[info.Version]; ok && existing.Architecture != "" && info.Architecture != "" {
+			info = mergeArchitectures(existing, info, arch)
+		}
+		lr.versions[info.Version] = info
+	}
+
+	// Fall back to the highest version where no default was chosen
+	for language, lr := range runnersByLanguage {
+		if version, ok := explicitDefaults[language]; ok {
+			if _, exists := lr.versions[version]; exists {
+				lr.defaultVersion = version
+			}
+		} else {
+			for version := range lr.versions {
+				if lr.defaultVersion == "" || compareVersions(version, lr.defaultVersion) > 0 {
+					lr.defaultVersion = version
+				}
+			}
+		}
+		info := lr.versions[lr.defaultVersion]
+		info.Default = true
+		lr.versions[lr.defaultVersion] = info
+	}
+
+	return runnersByLanguage
+}
 
-		runnersByLanguage[language] = RunnerInfo{
-			Image:    imageName,
-			Language: language,
+// sameRunners reports whether two indexes map the same languages and
+// versions to the same images
+func sameRunners(a, b map[string]*languageRunners) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for language, la := range a {
+		lb, ok := b[language]
+		if !ok || len(la.versions) != len(lb.versions) || la.defaultVersion != lb.defaultVersion {
+			return false
+		}
+		for version, ra := range la.versions {
+			rb, ok := lb.versions[version]
+			if !ok || ra.Image != rb.Image || ra.ImageID != rb.ImageID || !maps.Equal(ra.ArchImages, rb.ArchImages) {
+				return false
+			}
 		}
 	}
+	return true
+}
 
-	return &Registry{
-		runnersByLanguage: runnersByLanguage,
-	}, nil
+// applyLabelSettings reads optional execution settings from image labels
+// Invalid values are logged and ignored so one bad label doesn't hide a runner
+func applyLabelSettings(info *RunnerInfo, labels map[string]string) {
+	if v := labels["sandbox.timeout"]; v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			info.Timeout = d
+		} else {
+			log.Printf("Runner %s: ignoring invalid sandbox.timeout %q", info.Image, v)
+		}
+	}
+	if v := labels["sandbox.shm-size"]; v != "" {
+		if size, err := units.RAMInBytes(v); err == nil && size > 0 {
+			info.ShmSize = size
+		} else {
+			log.Printf("Runner %s: ignoring invalid sandbox.shm-size %q", info.Image, v)
+		}
+	}
+	if v := labels["sandbox.memory"]; v != "" {
+		if size, err := units.RAMInBytes(v); err == nil && size > 0 {
+			info.MemoryBytes = size
+		} else {
+			log.Printf("Runner %s: ignoring invalid sandbox.memory %q", info.Image, v)
+		}
+	}
+	if v := labels["sandbox.cpus"]; v != "" {
+		if cpus, err := strconv.ParseFloat(v, 64); err == nil && cpus > 0 {
+			info.NanoCPUs = int64(cpus * 1e9)
+		} else {
+			log.Printf("Runner %s: ignoring invalid sandbox.cpus %q", info.Image, v)
+		}
+	}
+	if v := labels["sandbox.probe"]; v != "" {
+		info.Probe = v
+	}
+	if v := labels["sandbox.install"]; v != "" {
+		info.Install = v
+	}
+	info.CodeFile = labels["sandbox.code-file"] == "true"
+	if v := labels["sandbox.run-file"]; v != "" {
+		info.RunFile = v
+	}
+	info.GPU = labels["sandbox.gpu"] == "true"
+	if v := labels["sandbox.kernel"]; v != "" {
+		info.Kernel = v
+	}
+	if compile, artifact := labels["sandbox.compile"], labels["sandbox.artifact"]; compile != "" && artifact != "" {
+		info.Compile, info.Artifact = compile, artifact
+	} else if compile != "" || artifact != "" {
+		log.Printf("Runner %s: ignoring sandbox.compile and sandbox.artifact, which need each other", info.Image)
+	}
+	if v := labels["sandbox.seccomp"]; v != "" {
+		info.Seccomp = v
+	}
+	if v := labels["sandbox.apparmor"]; v != "" {
+		if v == "unconfined" {
+			// Images may tighten confinement but not remove it
+			log.Printf("Runner %s: ignoring sandbox.apparmor=unconfined", info.Image)
+		} else {
+			info.AppArmor = v
+		}
+	}
+	if v := labels["sandbox.cap-add"]; v != "" {
+		for _, capability := range strings.Split(v, ",") {
+			if capability = strings.TrimSpace(capability); capability != "" {
+				info.CapAdd = append(info.CapAdd, strings.ToUpper(capability))
+			}
+		}
+	}
 }
 
-// GetRunner returns the runner info for a given language
-func (r *Registry) GetRunner(language string) (RunnerInfo, bool) {
-	runner, ok := r.runnersByLanguage[language]
+// GetRunner returns the runner info for a language and version
+// An empty version selects the language's default
+func (r *Registry) GetRunner(language, version string) (RunnerInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	lr, ok := r.runnersByLanguage[language]
+	if !ok {
+		return RunnerInfo{}, false
+	}
+	if version == "" {
+		version = lr.defaultVersion
+	}
+	runner, ok := lr.versions[version]
 	return runner, ok
 }
 
-// ListRunners returns all available runners
+// ListRunners returns all available runners, sorted by language and version
 func (r *Registry) ListRunners() []RunnerInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	runners := make([]RunnerInfo, 0, len(r.runnersByLanguage))
-	for _, runner := range r.runnersByLanguage {
-		runners = append(runners, runner)
+	for _, lr := range r.runnersByLanguage {
+		for _, runner := range lr.versions {
+			runners = append(runners, runner)
+		}
 	}
+	sort.Slice(runners, func(i, j int) bool {
+		if runners[i].Language != runners[j].Language {
+			return runners[i].Language < runners[j].Language
+		}
+		return compareVersions(runners[i].Version, runners[j].Version) < 0
+	})
 	return runners
 }
+
+// ListLanguages returns the distinct languages with at least one runner
+func (r *Registry) ListLanguages() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	languages := make([]string, 0, len(r.runnersByLanguage))
+	for language := range r.runnersByLanguage {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Packages returns the probed packages for a runner, if its probe succeeded
+func (r *Registry) Packages(info RunnerInfo) ([]Package, bool) {
+	r.packagesMu.RLock()
+	defer r.packagesMu.RUnlock()
+	packages, ok := r.packages[packageKey(info)]
+	return packages, ok
+}
+
+// setPackages caches probed packages for a runner
+func (r *Registry) setPackages(info RunnerInfo, packages []Package) {
+	r.packagesMu.Lock()
+	defer r.packagesMu.Unlock()
+	r.packages[packageKey(info)] = packages
+}
+
+// packageKey identifies a runner's image for the package cache
+func packageKey(info RunnerInfo) string {
+	if info.ImageID != "" {
+		return info.ImageID
+	}
+	return info.Image
+}
+
+// compareVersions compares dotted versions numerically where possible
+// ("3.9" < "3.12"), falling back to string comparison per component
+func compareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var ap, bp string
+		if i < len(as) {
+			ap = as[i]
+		}
+		if i < len(bs) {
+			bp = bs[i]
+		}
+		an, aErr := strconv.Atoi(ap)
+		bn, bErr := strconv.Atoi(bp)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case ap != bp:
+			if ap < bp {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// requirementsFiles are the dependency files read for each language, in /data
+var requirementsFiles = map[string]string{
+	"python":     "requirements.txt",
+	"typescript": "package.json",
+	"javascript": "package.json",
+}
+
+// ReadRequirements reads the package specs from the language's dependency
+// file in sandboxDir: requirements.txt for Python, the dependencies of
+// package.json for TypeScript and JavaScript. It also returns a digest of
+// the specs, so an unchanged file needn't be installed again. A missing file
+// gives no specs and no error
+func ReadRequirements(sandboxDir, language string) (specs []string, digest string, err error) {
+	name, ok := requirementsFiles[language]
+	if !ok {
+		return nil, "", nil
+	}
+	data, err := readRequirementsFile(sandboxDir, name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if name == "package.json" {
+		specs, err = parsePackageJSON(data)
+	} else {
+		specs, err = parseRequirementsTxt(data)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	sorted := append([]string(nil), specs...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(language + "\n" + strings.Join(sorted, "\n")))
+	return specs, hex.EncodeToString(sum[:]), nil
+}
+
+// readRequirementsFile reads the regular file name in sandboxDir. Sandboxed
+// code writes the directory, so it reads through a root, where symlinks
+// can't lead to the server's files, and doesn't block on FIFOs
+func readRequirementsFile(sandboxDir, name string) ([]byte, error) {
+	root, err := os.OpenRoot(sandboxDir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	f, err := root.OpenFile(name, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+	return io.ReadAll(io.LimitReader(f, info.Size()))
+}
+
+// parseRequirementsTxt reads a pip requirements file. Options (-r, -e,
+// --index-url, ...) and environment markers aren't supported, since the
+// install policy only allows registry names with versions
+func parseRequirementsTxt(data []byte) ([]string, error) {
+	var specs []string
+	for i, line := range strings.Split(string(data), "\n") {
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		line = strings.Join(fields, "")
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "-"):
+			return nil, fmt.Errorf("line %d: options such as %s aren't supported", i+1, fields[0])
+		case strings.Contains(line, ";"):
+			return nil, fmt.Errorf("line %d: environment markers aren't supported", i+1)
+		}
+		specs = append(specs, line)
+	}
+	return specs, nil
+}
+
+// parsePackageJSON reads the dependencies of a package.json as name@range
+// specs. Any version ("*", "latest" or empty) installs the bare name
+func parsePackageJSON(data []byte) ([]string, error) {
+	var pkg struct {
+		Dependencies map[string]string `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+	specs := make([]string, 0, len(pkg.Dependencies))
+	for name, version := range pkg.Dependencies {
+		switch version = strings.TrimSpace(version); version {
+		case "", "*", "latest":
+			specs = append(specs, name)
+		default:
+			specs = append(specs, name+"@"+version)
+		}
+	}
+	sort.Strings(specs)
+	return specs, nil
+}
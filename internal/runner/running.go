@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// ErrExecutionNotFound is returned when killing an execution that isn't
+// running
+var ErrExecutionNotFound = errors.New("execution not found")
+
+// Execution is a running execution container, for the admin API
+type Execution struct {
+	ID        string    `json:"id"`
+	Host      string    `json:"host"`
+	Language  string    `json:"language"`
+	Image     string    `json:"image"`
+	HashedDir string    `json:"hashedDir"`
+	Started   time.Time `json:"started"`
+	Deadline  time.Time `json:"deadline"`
+}
+
+// Executions lists the executions running on every host. Background
+// processes and package probes aren't executions
+func (e *Executor) Executions(ctx context.Context) ([]Execution, error) {
+	executions := []Execution{}
+	for _, host := range e.pool.hosts {
+		containers, err := host.cli.ContainerList(ctx, container.ListOptions{Filters: executionFilter("")})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers on %s: %w", host.name, err)
+		}
+		for _, c := range containers {
+			execution := Execution{
+				ID:        c.ID,
+				Host:      host.name,
+				Language:  c.Labels[languageLabel],
+				Image:     c.Image,
+				HashedDir: c.Labels[conversationLabel],
+				Started:   time.Unix(c.Created, 0).UTC(),
+			}
+			if unix, err := strconv.ParseInt(c.Labels[deadlineLabel], 10, 64); err == nil {
+				execution.Deadline = time.Unix(unix, 0).UTC()
+			}
+			executions = append(executions, execution)
+		}
+	}
+	return executions, nil
+}
+
+// KillExecution stops the running execution with the container ID (or an
+// unambiguous prefix of it). The caller gets the output so far and a
+// message saying an administrator stopped it
+func (e *Executor) KillExecution(ctx context.Context, id string) error {
+	for _, host := range e.pool.hosts {
+		containers, err := host.cli.ContainerList(ctx, container.ListOptions{Filters: executionFilter(id)})
+		if err != nil {
+			return fmt.Errorf("failed to list containers on %s: %w", host.name, err)
+		}
+		switch {
+		case len(containers) > 1:
+			return fmt.Errorf("execution ID %q is ambiguous", id)
+		case len(containers) == 0:
+			continue
+		}
+
+		c := containers[0]
+		e.killed.Store(c.ID, true)
+		if err := host.cli.ContainerKill(ctx, c.ID, "KILL"); err != nil {
+			e.killed.Delete(c.ID)
+			return fmt.Errorf("failed to kill container %s: %w", shortID(c.ID), err)
+		}
+		log.Printf("Killed execution %s (%s) on %s", shortID(c.ID), c.Labels[languageLabel], host.name)
+		return nil
+	}
+	return ErrExecutionNotFound
+}
+
+// wasKilled reports, once, whether KillExecution stopped a container
+func (e *Executor) wasKilled(containerID string) bool {
+	_, killed := e.killed.LoadAndDelete(containerID)
+	return killed
+}
+
+// executionFilter matches running execution containers, with the given ID
+// prefix if not empty
+func executionFilter(id string) filters.Args {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", managedLabel+"=true")
+	filterArgs.Add("label", executionLabel+"=true")
+	if id != "" {
+		filterArgs.Add("id", id)
+	}
+	return filterArgs
+}
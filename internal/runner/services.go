@@ -0,0 +1,19 @@
+package runner
+
+import "context"
+
+// serviceNetworkKey is the context key for a conversation's service network
+type serviceNetworkKey struct{}
+
+// WithServiceNetwork attaches executions using ctx to a conversation's
+// service network as well as their usual network, so code can reach its
+// helper services
+func WithServiceNetwork(ctx context.Context, network string) context.Context {
+	return context.WithValue(ctx, serviceNetworkKey{}, network)
+}
+
+// serviceNetwork returns the service network ctx asks for, or ""
+func serviceNetwork(ctx context.Context) string {
+	network, _ := ctx.Value(serviceNetworkKey{}).(string)
+	return network
+}
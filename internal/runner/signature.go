@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// verifyTimeout bounds one signature check, which fetches the signature
+// (and for keyless signatures the transparency log entry) over the network
+const verifyTimeout = time.Minute
+
+// digestPattern matches the digest of a pinned image reference
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// splitDigest splits "repository[:tag]@sha256:..." into the repository
+// without its tag and the digest, which is empty for unpinned references
+func splitDigest(ref string) (string, string) {
+	name, digest, _ := strings.Cut(ref, "@")
+	// A colon after the last slash starts a tag, not a registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name, digest
+}
+
+// Verifier checks the cosign signatures of runner images, so a compromised
+// registry can't slip a runner into the execution path: images are only
+// registered and run when the digest they were pulled by has a valid
+// signature, and pulls fetch the digest that verified rather than a tag.
+// It runs the cosign CLI, which reads registry credentials like Docker does.
+// A nil Verifier accepts every image
+type Verifier struct {
+	cosign string   // Path of the cosign binary
+	args   []string // Key or keyless identity flags
+
+	mu       sync.Mutex
+	verified map[string]bool // Digest references whose signature verified
+}
+
+// NewVerifier creates a verifier for signatures made with key (a public key
+// file or KMS URI), or else keyless signatures by identity as issued by
+// issuer. It returns nil when neither is set. Verifiers need cosign on the
+// PATH
+func NewVerifier(key, identity, issuer string) (*Verifier, error) {
+	var args []string
+	switch {
+	case key != "":
+		args = []string{"--key", key}
+	case identity != "":
+		args = []string{"--certificate-identity", identity, "--certificate-oidc-issuer", issuer}
+	default:
+		return nil, nil
+	}
+	cosign, err := exec.LookPath("cosign")
+	if err != nil {
+		return nil, fmt.Errorf("cosign is required to verify runner images: %w", err)
+	}
+	return &Verifier{cosign: cosign, args: args, verified: make(map[string]bool)}, nil
+}
+
+// Enabled reports whether images are verified
+func (v *Verifier) Enabled() bool {
+	return v != nil
+}
+
+// cosignPayload is the part of a verified signature's payload, as cosign
+// prints it, that names the signed image
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			Digest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// Verify checks the signature of an image in its registry and returns the
+// digest reference (repository@sha256:...) that verified. cosign resolves
+// tags, so pull the returned reference: the tag may move in between
+func (v *Verifier) Verify(ctx context.Context, ref string) (string, error) {
+	repository, digest := splitDigest(ref)
+	if digest != "" {
+		v.mu.Lock()
+		verified := v.verified[repository+"@"+digest]
+		v.mu.Unlock()
+		if verified {
+			return repository + "@" + digest, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	args := append([]string{"verify", "--output", "json"}, v.args...)
+	cmd := exec.CommandContext(ctx, v.cosign, append(args, ref)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := lastLine(stderr.String()); message != "" {
+			return "", fmt.Errorf("signature of %s did not verify: %s", ref, message)
+		}
+		return "", fmt.Errorf("signature of %s did not verify: %w", ref, err)
+	}
+
+	var payloads []cosignPayload
+	if err := json.Unmarshal(output, &payloads); err != nil || len(payloads) == 0 {
+		return "", fmt.Errorf("signature of %s did not verify: unexpected cosign output", ref)
+	}
+	signed := payloads[0].Critical.Image.Digest
+	for _, payload := range payloads {
+		if payload.Critical.Image.Digest != signed {
+			return "", fmt.Errorf("signatures of %s are for different digests", ref)
+		}
+	}
+	if !digestPattern.MatchString(signed) || (digest != "" && signed != digest) {
+		return "", fmt.Errorf("signature of %s is for another digest (%s)", ref, signed)
+	}
+
+	verified := repository + "@" + signed
+	v.mu.Lock()
+	v.verified[verified] = true
+	v.mu.Unlock()
+	return verified, nil
+}
+
+// verifyLocal checks a local image through the digests it was pulled by.
+// Images without any, such as local builds, are rejected: nothing ties them
+// to a signature
+func (v *Verifier) verifyLocal(ctx context.Context, name string, repoDigests []string) error {
+	if v == nil {
+		return nil
+	}
+	if len(repoDigests) == 0 {
+		return fmt.Errorf("image %s was not pulled from a registry, so its signature can't be verified", name)
+	}
+	var err error
+	for _, ref := range repoDigests {
+		if _, err = v.Verify(ctx, ref); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// lastLine returns the last non-empty line of cosign's messages, which
+// states why verification failed
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// containerSpec is a runner container's configuration, shared by
+// executions and background processes
+type containerSpec struct {
+	config     *container.Config
+	hostConfig *container.HostConfig
+	binds      []string
+	restricted bool   // On the egress network
+	services   string // Service network to join after creation, if any
+}
+
+// containerSpec builds the configuration of a runner container with the
+// sandbox mounted at /data and the runner's limits and hardening. The
+// context carries the per-execution options. Callers set the labels
+func (e *Executor) containerSpec(ctx context.Context, runner RunnerInfo, sandboxDir, user string, entrypoint []string, networkEnabled bool, environment map[string]string) (containerSpec, error) {
+	limits := e.Limits(runner)
+	hardening := e.currentHardening()
+	if user == "" {
+		user = defaultUser
+	}
+
+	// Convert environment map to Docker format (KEY=value)
+	envVars := make([]string, 0, len(environment)+1)
+	for key, value := range environment {
+		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+	}
+	if _, ok := environment["HOME"]; !ok && (user != defaultUser || hardening.ReadonlyRootfs) {
+		// UIDs without a passwd entry get HOME=/, which they can't write to,
+		// and a read-only root filesystem leaves only /tmp writable
+		envVars = append(envVars, "HOME=/tmp")
+	}
+	codeBinds := e.codeBinds(ctx)
+	if len(codeBinds) > 0 {
+		envVars = append(envVars, "SANDBOX_CODE_FILE="+CodeFile)
+	}
+	restricted := restrictedNetwork(ctx) && e.egress != nil
+	services := serviceNetwork(ctx)
+	networkDisabled := !networkEnabled && !restricted && services == ""
+	if restricted {
+		for _, key := range proxyEnv {
+			if _, ok := environment[key]; !ok {
+				envVars = append(envVars, key+"="+e.egress.ProxyURL)
+			}
+		}
+	}
+
+	containerConfig := &container.Config{
+		Image:           runner.Image,
+		Entrypoint:      entrypoint,
+		WorkingDir:      "/data",
+		OpenStdin:       true,
+		StdinOnce:       true,
+		AttachStdin:     true,
+		AttachStdout:    true,
+		AttachStderr:    true,
+		NetworkDisabled: networkDisabled, // Network disabled by default for security
+		User:            user,            // Run as non-root user (must match chown in sandbox manager)
+		Env:             envVars,         // Environment variables
+	}
+
+	// Bind mount the sandbox directory to /data in the container, and the
+	// package caches next to it if the caller asked for them
+	binds := append([]string{e.backend.bind(sandboxDir, "/data", false)}, e.packagesBinds(ctx)...)
+	binds = append(binds, codeBinds...)
+	binds = append(binds, e.buildBinds(ctx)...)
+	hostConfig := &container.HostConfig{
+		Binds: binds,
+		Resources: container.Resources{
+			Memory:   limits.MemoryBytes,
+			NanoCPUs: limits.NanoCPUs,
+		},
+		ShmSize: runner.ShmSize, // 0 keeps Docker's default (64MB); browsers need more
+		CapAdd:  e.permittedCaps(runner),
+	}
+	if restricted {
+		hostConfig.NetworkMode = container.NetworkMode(e.egress.Network)
+	} else if services != "" && !networkEnabled {
+		// The service network is internal, so this adds no outside access
+		hostConfig.NetworkMode = container.NetworkMode(services)
+		services = ""
+	}
+	if err := hardening.apply(hostConfig, runner); err != nil {
+		return containerSpec{}, err
+	}
+	if err := e.applyGPU(ctx, hostConfig, runner); err != nil {
+		return containerSpec{}, err
+	}
+	if preview, ok := previewFromContext(ctx); ok {
+		preview.apply(containerConfig, hostConfig)
+	}
+	e.backend.applyHostConfig(hostConfig, networkDisabled)
+
+	return containerSpec{
+		config:     containerConfig,
+		hostConfig: hostConfig,
+		binds:      binds,
+		restricted: restricted,
+		services:   services,
+	}, nil
+}
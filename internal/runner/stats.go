@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// ResourceUsage is what an execution consumed, sampled from the Docker stats
+// API while its container ran. Executions shorter than the first sample
+// have none
+type ResourceUsage struct {
+	CPUTime         time.Duration // User plus system time across all cores
+	PeakMemoryBytes uint64        // Highest sampled usage, excluding reclaimable page cache
+	NetworkRxBytes  uint64        // Zero unless the container had a network
+	NetworkTxBytes  uint64
+}
+
+// statsSampler streams a container's stats in the background
+type statsSampler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	usage   ResourceUsage
+	samples int
+}
+
+// sampleStats starts streaming stats for a running container. Call stop
+// before the container is removed
+func sampleStats(cli *client.Client, containerID string) *statsSampler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &statsSampler{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		resp, err := cli.ContainerStats(ctx, containerID, true)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to read stats for container %s: %v", containerID[:12], err)
+			}
+			return
+		}
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var stats container.StatsResponse
+			if err := decoder.Decode(&stats); err != nil {
+				if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+					log.Printf("Failed to decode stats for container %s: %v", containerID[:12], err)
+				}
+				return
+			}
+			s.record(stats)
+		}
+	}()
+	return s
+}
+
+// record folds one stats sample into the usage. Stats of a container that
+// has already exited are all zero and are skipped
+func (s *statsSampler) record(stats container.StatsResponse) {
+	if stats.Read.IsZero() || stats.CPUStats.CPUUsage.TotalUsage == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples++
+	s.usage.CPUTime = time.Duration(stats.CPUStats.CPUUsage.TotalUsage)
+	if memory := memoryUsage(stats.MemoryStats); memory > s.usage.PeakMemoryBytes {
+		s.usage.PeakMemoryBytes = memory
+	}
+	var rx, tx uint64
+	for _, network := range stats.Networks {
+		rx += network.RxBytes
+		tx += network.TxBytes
+	}
+	s.usage.NetworkRxBytes, s.usage.NetworkTxBytes = rx, tx
+}
+
+// stop ends the stream and returns the usage, or nil without samples
+func (s *statsSampler) stop() *ResourceUsage {
+	s.cancel()
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.samples == 0 {
+		return nil
+	}
+	usage := s.usage
+	return &usage
+}
+
+// memoryUsage subtracts inactive page cache like `docker stats` does, so
+// reading a large file doesn't count as memory use. cgroup v1 also keeps
+// its own peak
+func memoryUsage(stats container.MemoryStats) uint64 {
+	usage := stats.Usage
+	if stats.MaxUsage > usage {
+		usage = stats.MaxUsage
+	}
+	inactive, ok := stats.Stats["total_inactive_file"] // cgroup v1
+	if !ok {
+		inactive = stats.Stats["inactive_file"] // cgroup v2
+	}
+	if inactive < usage {
+		usage -= inactive
+	}
+	return usage
+}
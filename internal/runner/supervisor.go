@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrBackendUnavailable is returned while the Docker daemon can't be reached
+var ErrBackendUnavailable = errors.New("execution backend unavailable")
+
+// Reconnect backoff bounds
+const (
+	reconnectMinBackoff = time.Second
+	reconnectMaxBackoff = 30 * time.Second
+)
+
+// Available reports whether the primary Docker daemon answered the last
+// health check
+func (e *Executor) Available() bool {
+	return !e.unavailable.Load()
+}
+
+// Supervise pings the primary Docker daemon every interval until ctx is
+// done. When a ping fails, executions are rejected with
+// ErrBackendUnavailable while it reconnects with backoff; once the daemon
+// answers again, onReconnect restores what a daemon restart loses, such as
+// containers and networks
+func (e *Executor) Supervise(ctx context.Context, interval time.Duration, onReconnect func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := e.ping(ctx)
+		if err == nil {
+			continue
+		}
+
+		e.unavailable.Store(true)
+		log.Printf("Docker daemon unavailable, rejecting executions until it is back: %v", err)
+		if !e.reconnect(ctx) {
+			return
+		}
+		e.unavailable.Store(false)
+		log.Printf("Docker daemon available again")
+		if onReconnect != nil {
+			onReconnect(ctx)
+		}
+	}
+}
+
+// reconnect retries with exponential backoff until the daemon answers,
+// returning false if ctx is done first
+func (e *Executor) reconnect(ctx context.Context) bool {
+	backoff := reconnectMinBackoff
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+
+		// Connections to the old daemon are dead; dial afresh
+		e.cli.Close()
+		err := e.ping(ctx)
+		if err == nil {
+			// A restarted daemon may have been upgraded
+			e.cli.NegotiateAPIVersion(ctx)
+			return true
+		}
+		backoff = min(backoff*2, reconnectMaxBackoff)
+		log.Printf("Docker reconnect attempt %d failed, retrying in %s: %v", attempt, backoff, err)
+	}
+}
+
+// ping checks the daemon answers within a few seconds
+func (e *Executor) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := e.cli.Ping(ctx)
+	return err
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// refreshDebounce coalesces bursts of image events (a pull emits several)
+const refreshDebounce = 2 * time.Second
+
+// Watch keeps the registry current until ctx is cancelled. It subscribes to
+// Docker image events and also refreshes every interval as a fallback for
+// missed events. onChange is called after a refresh that changed the runners
+func (r *Registry) Watch(ctx context.Context, interval time.Duration, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// A stopped timer fires the debounced refresh once events go quiet
+	debounce := time.NewTimer(refreshDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	msgs, errs := r.subscribe(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-msgs:
+			log.Printf("Image event %s for %s, refreshing runners", msg.Action, msg.Actor.ID)
+			debounce.Reset(refreshDebounce)
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Docker event stream closed: %v (resubscribing on next refresh)", err)
+			msgs, errs = nil, nil
+		case <-debounce.C:
+			r.refresh(ctx, onChange)
+		case <-ticker.C:
+			if msgs == nil {
+				msgs, errs = r.subscribe(ctx)
+			}
+			r.refresh(ctx, onChange)
+		}
+	}
+}
+
+// subscribe opens a Docker event stream filtered to image changes
+func (r *Registry) subscribe(ctx context.Context) (<-chan events.Message, <-chan error) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("type", string(events.ImageEventType))
+	for _, action := range []events.Action{
+		events.ActionPull, events.ActionTag, events.ActionUnTag,
+		events.ActionDelete, events.ActionLoad, events.ActionImport,
+	} {
+		filterArgs.Add("event", string(action))
+	}
+	return r.cli.Events(ctx, events.ListOptions{Filters: filterArgs})
+}
+
+// refresh rebuilds the registry, logging failures rather than giving up
+func (r *Registry) refresh(ctx context.Context, onChange func()) {
+	changed, err := r.Refresh(ctx)
+	if err != nil {
+		log.Printf("Failed to refresh runners: %v", err)
+		return
+	}
+	if !changed {
+		return
+	}
+	log.Printf("Runners changed, now serving: %v", r.ListLanguages())
+	if onChange != nil {
+		onChange()
+	}
+}
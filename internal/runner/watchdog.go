@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Labels marking containers for the watchdog. Managed containers with a
+// deadline are removed once it has passed
+const (
+	executionLabel = "sandbox.execution"
+	deadlineLabel  = "sandbox.execution.deadline" // Unix seconds
+	languageLabel  = "sandbox.execution.language"
+)
+
+// watchdogRemoveTimeout bounds each forced removal
+const watchdogRemoveTimeout = 30 * time.Second
+
+// executionLabels tags an execution container with the time it must be gone by
+func executionLabels(runner RunnerInfo, sandboxDir string, deadline time.Time) map[string]string {
+	labels := managedLabels(sandboxDir)
+	labels[executionLabel] = "true"
+	labels[deadlineLabel] = strconv.FormatInt(deadline.Unix(), 10)
+	labels[languageLabel] = runner.Language
+	return labels
+}
+
+// Watchdog force-removes managed containers still present grace after
+// their deadline, until ctx is done. Execute enforces timeouts itself, but a
+// stalled Docker API call can leave its container running; the watchdog
+// works from container labels, so it also catches containers left behind
+// by a crashed server
+func (e *Executor) Watchdog(ctx context.Context, interval, grace time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, host := range e.pool.hosts {
+				e.reapOverdue(ctx, host, grace)
+			}
+		}
+	}
+}
+
+// reapOverdue removes a host's containers past their deadline plus grace
+func (e *Executor) reapOverdue(ctx context.Context, host *Host, grace time.Duration) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", managedLabel+"=true")
+	filterArgs.Add("label", deadlineLabel)
+
+	containers, err := host.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: filterArgs})
+	if err != nil {
+		log.Printf("Watchdog: failed to list containers on %s: %v", host.name, err)
+		return
+	}
+
+	now := time.Now()
+	for _, c := range containers {
+		unix, err := strconv.ParseInt(c.Labels[deadlineLabel], 10, 64)
+		if err != nil || now.Before(time.Unix(unix, 0).Add(grace)) {
+			continue
+		}
+
+		removeCtx, cancel := context.WithTimeout(ctx, watchdogRemoveTimeout)
+		err = host.cli.ContainerRemove(removeCtx, c.ID, container.RemoveOptions{Force: true})
+		cancel()
+		if err != nil {
+			log.Printf("Watchdog: failed to remove overdue container %s: %v", shortID(c.ID), err)
+			continue
+		}
+		log.Printf("Watchdog: removed container %s (%s) %s past its deadline", shortID(c.ID), c.Image, now.Sub(time.Unix(unix, 0)).Round(time.Second))
+		if c.Labels[executionLabel] == "true" {
+			e.metrics.WatchdogKill(c.Labels[languageLabel])
+		}
+	}
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
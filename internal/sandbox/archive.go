@@ -0,0 +1,268 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxArchiveEntries bounds how many files and directories one archive may create
+const maxArchiveEntries = 10000
+
+// ErrUnsupportedArchive is returned for file names without a known archive extension
+var ErrUnsupportedArchive = errors.New("unsupported archive format (use .zip, .tar, .tar.gz or .tgz)")
+
+// IsArchive reports whether filename has an extension ExtractArchive handles
+func IsArchive(filename string) bool {
+	return archiveFormat(filename) != ""
+}
+
+func archiveFormat(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// extractor writes archive entries into a sandbox. All paths go through an
+// os.Root, so neither ".." entries nor symlinks left in the sandbox by user
+// code can make the server write outside it
+type extractor struct {
+	root     *os.Root
+	uid, gid int
+	dirMode  os.FileMode
+	maxBytes int64
+	fileMax  int64 // Largest single file, 0 for no limit
+
+	written int64
+	entries int
+	files   []string // Extracted files, relative to the sandbox
+	created []string // Files and directories to remove if extraction fails
+}
+
+// ExtractArchive unpacks a zip or tar(.gz) archive into a conversation's
+// sandbox, keeping its directory structure, and returns the extracted file
+// paths. Only regular files and directories are extracted; links and
+// devices are skipped. If the archive is invalid or exceeds the size or entry
+// limits, or the sandbox's Limits, files extracted so far are removed
+func (m *Manager) ExtractArchive(conversationID, filename string, data []byte) ([]string, error) {
+	format := archiveFormat(filename)
+	if format == "" {
+		return nil, ErrUnsupportedArchive
+	}
+	if _, err := m.EnsureSandboxDir(conversationID); err != nil {
+		return nil, err
+	}
+
+	root, err := os.OpenRoot(m.GetSandboxDir(conversationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sandbox: %w", err)
+	}
+	defer root.Close()
+
+	uid, gid := m.Owner(conversationID)
+	x := &extractor{root: root, uid: uid, gid: gid, dirMode: m.dirMode(), maxBytes: m.extractMaxBytes, fileMax: m.limits.MaxFileBytes}
+
+	switch format {
+	case "zip":
+		err = x.extractZip(data)
+	case "tar.gz":
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			err = x.extractTar(gz)
+		}
+	case "tar":
+		err = x.extractTar(bytes.NewReader(data))
+	}
+	if err == nil {
+		err = m.checkSandboxUsage(conversationID)
+	}
+	if err != nil {
+		x.rollback()
+		return nil, err
+	}
+	return x.files, nil
+}
+
+func (x *extractor) extractZip(data []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := x.mkdir(f.Name); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("invalid zip entry %s: %w", f.Name, err)
+			}
+			err = x.writeFile(f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (x *extractor) extractTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := x.mkdir(hdr.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := x.writeFile(hdr.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// entryPath validates an archive entry name and returns it as a local path
+func (x *extractor) entryPath(name string) (string, error) {
+	x.entries++
+	if x.entries > maxArchiveEntries {
+		return "", fmt.Errorf("archive has more than %d entries", maxArchiveEntries)
+	}
+	clean := filepath.Clean(filepath.FromSlash(strings.TrimSuffix(name, "/")))
+	if !filepath.IsLocal(clean) {
+		return "", fmt.Errorf("unsafe path: %q", name)
+	}
+	return clean, nil
+}
+
+func (x *extractor) mkdir(name string) error {
+	dir, err := x.entryPath(name)
+	if err != nil {
+		return err
+	}
+	return x.mkdirAll(dir)
+}
+
+// mkdirAll creates dir and its parents owned by the sandbox owner
+func (x *extractor) mkdirAll(dir string) error {
+	if dir == "." {
+		return nil
+	}
+	if info, err := x.root.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("cannot create directory %s: a file with that name exists", dir)
+		}
+		return nil
+	}
+	if err := x.mkdirAll(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := x.root.Mkdir(dir, x.dirMode); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	x.created = append(x.created, dir)
+	x.root.Chown(dir, x.uid, x.gid)
+	return nil
+}
+
+func (x *extractor) writeFile(name string, r io.Reader) error {
+	file, err := x.entryPath(name)
+	if err != nil {
+		return err
+	}
+	if err := x.mkdirAll(filepath.Dir(file)); err != nil {
+		return err
+	}
+
+	f, err := x.root.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", file, err)
+	}
+	x.created = append(x.created, file)
+
+	// Count actual decompressed bytes; sizes in headers can't be trusted
+	remaining := x.maxBytes - x.written
+	limit := remaining
+	if x.fileMax > 0 && x.fileMax < limit {
+		limit = x.fileMax
+	}
+	n, err := io.Copy(f, io.LimitReader(r, limit+1))
+	f.Close()
+	x.written += n
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", file, err)
+	}
+	if x.fileMax > 0 && n > x.fileMax {
+		// n stops one byte past the limit; the full size isn't known
+		return &LimitError{Limit: LimitFileSize, Max: x.fileMax, Actual: n, Filename: filepath.ToSlash(file)}
+	}
+	if n > remaining {
+		return fmt.Errorf("files expand to more than %d bytes", x.maxBytes)
+	}
+
+	x.root.Chown(file, x.uid, x.gid)
+	x.files = append(x.files, filepath.ToSlash(file))
+	return nil
+}
+
+// rollback removes everything the extraction created, deepest first
+func (x *extractor) rollback() {
+	for i := len(x.created) - 1; i >= 0; i-- {
+		x.root.Remove(x.created[i])
+	}
+}
+
+// WriteFiles writes files (relative path to content) into a conversation's
+// sandbox, creating their directories, with the same path checks and size
+// limit as ExtractArchive, within the sandbox's Limits. It returns the
+// written paths. If any file can't be written, none are kept
+func (m *Manager) WriteFiles(conversationID string, files map[string]string) ([]string, error) {
+	if _, err := m.EnsureSandboxDir(conversationID); err != nil {
+		return nil, err
+	}
+
+	root, err := os.OpenRoot(m.GetSandboxDir(conversationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sandbox: %w", err)
+	}
+	defer root.Close()
+
+	uid, gid := m.Owner(conversationID)
+	x := &extractor{root: root, uid: uid, gid: gid, dirMode: m.dirMode(), maxBytes: m.extractMaxBytes, fileMax: m.limits.MaxFileBytes}
+	for name, content := range files {
+		if err := x.writeFile(name, strings.NewReader(content)); err != nil {
+			x.rollback()
+			return nil, err
+		}
+	}
+	if err := m.checkSandboxUsage(conversationID); err != nil {
+		x.rollback()
+		return nil, err
+	}
+	return x.files, nil
+}
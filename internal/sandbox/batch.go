@@ -0,0 +1,128 @@
+package sandbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MaxBatchFiles is the most files one WriteBatch call may write
+const MaxBatchFiles = 100
+
+// BatchFile is one file for WriteBatch
+type BatchFile struct {
+	Name string // Relative path; directories are created as needed
+	Data []byte
+}
+
+// WriteBatch writes files into a conversation's sandbox all at once and
+// returns their paths. Paths and the total size (at most the archive
+// extraction limit) are checked before anything is written, and every file
+// is staged next to its target before any is moved into place, so a file that
+// can't be written, or would break the sandbox's Limits, leaves the sandbox
+// as it was
+func (m *Manager) WriteBatch(conversationID string, files []BatchFile) ([]string, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to write")
+	}
+	if len(files) > MaxBatchFiles {
+		return nil, fmt.Errorf("at most %d files can be written at once", MaxBatchFiles)
+	}
+	if _, err := m.EnsureSandboxDir(conversationID); err != nil {
+		return nil, err
+	}
+
+	root, err := os.OpenRoot(m.GetSandboxDir(conversationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sandbox: %w", err)
+	}
+	defer root.Close()
+
+	uid, gid := m.Owner(conversationID)
+	x := &extractor{root: root, uid: uid, gid: gid, dirMode: m.dirMode(), maxBytes: m.extractMaxBytes}
+
+	names := make([]string, len(files))
+	sizes := make(map[string]int64, len(files))
+	seen := make(map[string]bool, len(files))
+	var total int64
+	for i, f := range files {
+		name, err := x.entryPath(f.Name)
+		if err != nil || name == "." {
+			return nil, fmt.Errorf("invalid filename: %q", f.Name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate filename: %q", f.Name)
+		}
+		seen[name] = true
+		if info, err := root.Lstat(name); err == nil && info.IsDir() {
+			return nil, fmt.Errorf("cannot write %s: a directory with that name exists", name)
+		}
+		total += int64(len(f.Data))
+		if total > x.maxBytes {
+			return nil, fmt.Errorf("files total more than %d bytes", x.maxBytes)
+		}
+		names[i] = name
+		sizes[name] = int64(len(f.Data))
+	}
+	if err := m.checkLimits(conversationID, sizes); err != nil {
+		return nil, err
+	}
+
+	staged := make([]string, 0, len(files))
+	cleanup := func() {
+		for _, tmp := range staged {
+			root.Remove(tmp)
+		}
+		x.rollback()
+	}
+	for i, f := range files {
+		if err := x.mkdirAll(filepath.Dir(names[i])); err != nil {
+			cleanup()
+			return nil, err
+		}
+		tmp, err := x.stage(filepath.Dir(names[i]), f.Data)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to write %s: %w", names[i], err)
+		}
+		staged = append(staged, tmp)
+	}
+
+	written := make([]string, 0, len(files))
+	for i, tmp := range staged {
+		if err := root.Rename(tmp, names[i]); err != nil {
+			for _, rest := range staged[i:] {
+				root.Remove(rest)
+			}
+			return nil, fmt.Errorf("failed to write %s: %w", names[i], err)
+		}
+		written = append(written, filepath.ToSlash(names[i]))
+	}
+	return written, nil
+}
+
+// stage writes data to a new hidden file in dir, owned by the sandbox owner,
+// and returns its path
+func (x *extractor) stage(dir string, data []byte) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	tmp := filepath.Join(dir, ".upload-"+hex.EncodeToString(suffix))
+	f, err := x.root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		x.root.Remove(tmp)
+		return "", err
+	}
+	x.root.Chown(tmp, x.uid, x.gid)
+	return tmp, nil
+}
@@ -0,0 +1,59 @@
+package sandbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// downloadCacheDirName is the directory under the sandbox root holding the
+// package download caches, one per tenant
+const downloadCacheDirName = ".cache"
+
+// downloadCacheTools are the per-package-manager cache subdirectories
+var downloadCacheTools = []string{"pip", "npm", "bun"}
+
+// SetDownloadCache enables package download caches shared by the installs of
+// each tenant's conversations
+func (m *Manager) SetDownloadCache(enabled bool) {
+	m.downloadCache = enabled
+}
+
+// EnsureDownloadCache creates the package download cache of the tenant that
+// owns a conversation and returns its Docker host path, or "" when download
+// caches are disabled. Install scripts can write anything into the cache, so
+// each tenant gets its own: one token's installs can't poison the packages
+// another's install. Conversations without a tenant share one. Directories
+// are world-writable with the sticky bit so every conversation UID can add
+// entries but none can remove another's
+func (m *Manager) EnsureDownloadCache(conversationID string) (string, error) {
+	if !m.downloadCache {
+		return "", nil
+	}
+	name := m.tenantCacheName(m.Tenant(m.hashConversationID(conversationID)))
+	root := filepath.Join(m.sandboxRoot, downloadCacheDirName)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create download cache: %w", err)
+	}
+	for _, dir := range append([]string{""}, downloadCacheTools...) {
+		path := filepath.Join(root, name, dir)
+		if err := os.MkdirAll(path, 0o777); err != nil {
+			return "", fmt.Errorf("failed to create download cache: %w", err)
+		}
+		if err := os.Chmod(path, 0o777|os.ModeSticky); err != nil {
+			return "", fmt.Errorf("failed to chmod download cache: %w", err)
+		}
+	}
+	return filepath.Join(m.sandboxHostPath, downloadCacheDirName, name), nil
+}
+
+// tenantCacheName names a tenant's download cache directory without putting
+// the token name on disk
+func (m *Manager) tenantCacheName(tenant string) string {
+	h := sha256.New()
+	h.Write([]byte("download-cache\x00" + tenant))
+	h.Write([]byte(m.secret))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
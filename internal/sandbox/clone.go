@@ -0,0 +1,66 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrDirectoryExists is returned when a cloned repository's directory is
+// already taken
+var ErrDirectoryExists = errors.New("directory already exists")
+
+// CloneStagingDir creates an empty directory next to a conversation's sandbox
+// to clone into, on the same filesystem so AdoptDirectory can move the clone
+// in. The caller removes it when done
+func (m *Manager) CloneStagingDir(conversationID string) (string, error) {
+	hashedDir, err := m.EnsureSandboxDir(conversationID)
+	if err != nil {
+		return "", err
+	}
+	// Dot-prefixed, so sandbox listings and GC skip it
+	dir, err := os.MkdirTemp(m.sandboxRoot, ".clone-"+hashedDir+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	return dir, nil
+}
+
+// ValidateDirectoryName checks that name can hold a cloned repository: a
+// single path element that isn't hidden
+func ValidateDirectoryName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || !filepath.IsLocal(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid directory name: %q", name)
+	}
+	return nil
+}
+
+// AdoptDirectory moves src, a directory built under CloneStagingDir, into a
+// conversation's sandbox as name and hands it to the conversation's owner.
+// Returns a *LimitError if its files would break the sandbox's Limits
+func (m *Manager) AdoptDirectory(conversationID, src, name string) error {
+	if err := ValidateDirectoryName(name); err != nil {
+		return err
+	}
+	dest := filepath.Join(m.GetSandboxDir(conversationID), name)
+	if _, err := os.Lstat(dest); err == nil {
+		return ErrDirectoryExists
+	}
+	if err := m.checkTree(conversationID, src); err != nil {
+		return err
+	}
+
+	uid, gid := m.Owner(conversationID)
+	if err := chownRecursive(src, uid, gid); err != nil {
+		if m.isolateUIDs {
+			return fmt.Errorf("failed to chown %s to %d:%d: %w", name, uid, gid, err)
+		}
+		fmt.Printf("Warning: failed to chown %s to %d:%d: %v\n", src, uid, gid, err)
+	}
+	if err := os.Rename(src, dest); err != nil {
+		return fmt.Errorf("failed to move %s into sandbox: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package sandbox
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Limits bounds the files clients may put in a sandbox with uploads,
+// downloads and clones. Files written by running code don't count against
+// an upload until the next one. Zero fields are unlimited
+type Limits struct {
+	MaxFileBytes  int64 // Largest single file
+	MaxTotalBytes int64 // Most the sandbox's files may total
+	MaxFiles      int   // Most files the sandbox may hold
+}
+
+// Limit names in LimitError
+const (
+	LimitFileSize    = "file_size"
+	LimitSandboxSize = "sandbox_size"
+	LimitFileCount   = "file_count"
+)
+
+// LimitError reports files that would break one of the sandbox's Limits
+type LimitError struct {
+	Limit    string // LimitFileSize, LimitSandboxSize or LimitFileCount
+	Max      int64
+	Actual   int64  // The file's size (at least), or the sandbox's size or file count with the upload
+	Filename string // The file over LimitFileSize
+}
+
+func (e *LimitError) Error() string {
+	switch e.Limit {
+	case LimitFileSize:
+		return fmt.Sprintf("%s is larger than the %d byte file size limit", e.Filename, e.Max)
+	case LimitSandboxSize:
+		return fmt.Sprintf("sandbox files would total %d bytes, over the %d byte limit", e.Actual, e.Max)
+	default:
+		return fmt.Sprintf("sandbox would hold %d files, over the %d file limit", e.Actual, e.Max)
+	}
+}
+
+// SetLimits sets the bounds on uploaded files
+func (m *Manager) SetLimits(limits Limits) {
+	m.limits = limits
+}
+
+// Limits returns the bounds on uploaded files
+func (m *Manager) Limits() Limits {
+	return m.limits
+}
+
+// CheckFileSize returns a *LimitError if one file (sandbox-relative name, size
+// in bytes) is larger than MaxFileBytes
+func (m *Manager) CheckFileSize(name string, size int64) error {
+	if m.limits.MaxFileBytes > 0 && size > m.limits.MaxFileBytes {
+		return &LimitError{Limit: LimitFileSize, Max: m.limits.MaxFileBytes, Actual: size, Filename: filepath.ToSlash(name)}
+	}
+	return nil
+}
+
+// checkLimits returns a *LimitError if writing files (sandbox-relative path
+// to size) would break the limits. Files replacing existing ones only count
+// the difference
+func (m *Manager) checkLimits(conversationID string, files map[string]int64) error {
+	for name, size := range files {
+		if err := m.CheckFileSize(name, size); err != nil {
+			return err
+		}
+	}
+	if m.limits.MaxTotalBytes <= 0 && m.limits.MaxFiles <= 0 {
+		return nil
+	}
+
+	sandboxDir := m.GetSandboxDir(conversationID)
+	total, count, err := m.filesUsage(sandboxDir)
+	if err != nil {
+		return err
+	}
+	for name, size := range files {
+		total += size
+		count++
+		if info, err := os.Lstat(filepath.Join(sandboxDir, name)); err == nil && info.Mode().IsRegular() {
+			total -= info.Size()
+			count--
+		}
+	}
+	return m.checkUsage(total, count)
+}
+
+// checkTree returns a *LimitError if adding the files under dir, outside the
+// sandbox, to a conversation's sandbox would break the limits
+func (m *Manager) checkTree(conversationID, dir string) error {
+	var added int64
+	var addedCount int
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if err := m.CheckFileSize(rel, info.Size()); err != nil {
+			return err
+		}
+		added += info.Size()
+		addedCount++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if m.limits.MaxTotalBytes <= 0 && m.limits.MaxFiles <= 0 {
+		return nil
+	}
+	total, count, err := m.filesUsage(m.GetSandboxDir(conversationID))
+	if err != nil {
+		return err
+	}
+	return m.checkUsage(total+added, count+addedCount)
+}
+
+// checkSandboxUsage returns a *LimitError if the files already in a
+// conversation's sandbox break the total size or count limits
+func (m *Manager) checkSandboxUsage(conversationID string) error {
+	if m.limits.MaxTotalBytes <= 0 && m.limits.MaxFiles <= 0 {
+		return nil
+	}
+	total, count, err := m.filesUsage(m.GetSandboxDir(conversationID))
+	if err != nil {
+		return err
+	}
+	return m.checkUsage(total, count)
+}
+
+func (m *Manager) checkUsage(total int64, count int) error {
+	if m.limits.MaxTotalBytes > 0 && total > m.limits.MaxTotalBytes {
+		return &LimitError{Limit: LimitSandboxSize, Max: m.limits.MaxTotalBytes, Actual: total}
+	}
+	if m.limits.MaxFiles > 0 && count > m.limits.MaxFiles {
+		return &LimitError{Limit: LimitFileCount, Max: int64(m.limits.MaxFiles), Actual: int64(count)}
+	}
+	return nil
+}
+
+// filesUsage totals the size and number of regular files under dir
+func (m *Manager) filesUsage(dir string) (int64, int, error) {
+	var total int64
+	var count int
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		total += info.Size()
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to measure sandbox: %w", err)
+	}
+	return total, count, nil
+}
@@ -0,0 +1,106 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Link revocation metadata. Share and download links carry the link epoch
+// they were made in; revoking bumps the epoch, which invalidates them all.
+// Single-use links are marked used by a file named after their ID
+const (
+	linkEpochFile = "link_epoch"
+	usedLinksDir  = "used_links"
+)
+
+// LinkEpoch returns the link epoch of the sandbox in hashedDir. It starts at 0
+func (m *Manager) LinkEpoch(hashedDir string) int64 {
+	if !validHashedDir(hashedDir) {
+		return 0
+	}
+	data, err := os.ReadFile(filepath.Join(m.sandboxRoot, metadataDirName, hashedDir, linkEpochFile))
+	if err != nil {
+		return 0
+	}
+	epoch, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return epoch
+}
+
+// ConversationLinkEpoch returns a conversation's link epoch
+func (m *Manager) ConversationLinkEpoch(conversationID string) int64 {
+	return m.LinkEpoch(m.hashConversationID(conversationID))
+}
+
+// RevokeLinks invalidates every share and download link made for a
+// conversation so far and returns the new link epoch
+func (m *Manager) RevokeLinks(conversationID string) (int64, error) {
+	m.linksMu.Lock()
+	defer m.linksMu.Unlock()
+
+	dir := m.GetMetadataDir(conversationID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return 0, fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+	epoch := m.ConversationLinkEpoch(conversationID) + 1
+	path := filepath.Join(dir, linkEpochFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(epoch, 10)), 0o600); err != nil {
+		return 0, fmt.Errorf("failed to write link epoch: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, fmt.Errorf("failed to write link epoch: %w", err)
+	}
+	// Links from earlier epochs are refused anyway
+	os.RemoveAll(filepath.Join(dir, usedLinksDir))
+	return epoch, nil
+}
+
+// ClaimLink marks the single-use link id of the sandbox in hashedDir as used.
+// It reports false if the link was used before. expires is when the link
+// stops working; records of expired links are dropped along the way
+func (m *Manager) ClaimLink(hashedDir, id string, expires time.Time) (bool, error) {
+	if !validHashedDir(hashedDir) || id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return false, fmt.Errorf("invalid link")
+	}
+	dir := filepath.Join(m.sandboxRoot, metadataDirName, hashedDir, usedLinksDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return false, fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+	m.pruneUsedLinks(dir)
+
+	// O_EXCL makes exactly one of two racing downloads win
+	path := filepath.Join(dir, id)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record link use: %w", err)
+	}
+	f.Close()
+	// The record's modification time is when it can be forgotten
+	if err := os.Chtimes(path, time.Time{}, expires); err != nil {
+		return false, fmt.Errorf("failed to record link use: %w", err)
+	}
+	return true, nil
+}
+
+// pruneUsedLinks removes the records of single-use links that have expired
+func (m *Manager) pruneUsedLinks(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && info.ModTime().Before(now) {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package sandbox
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxListing is the most files ListTree returns
+const MaxListing = 5000
+
+// FileEntry describes one file in a sandbox listing
+type FileEntry struct {
+	Name     string    // Slash-separated path relative to the sandbox
+	Size     int64     // Bytes
+	Modified time.Time // Last modification
+}
+
+// ListTree lists the regular files in a sandbox identified by its hashed
+// directory, including those in subdirectories, sorted by name within each
+// directory. Hidden files and directories (such as .git) are skipped, like
+// ls does. At most MaxListing files are returned; truncated reports whether
+// there were more
+func (m *Manager) ListTree(hashedDir string) (files []FileEntry, truncated bool, err error) {
+	if !validHashedDir(hashedDir) {
+		return nil, false, fmt.Errorf("invalid sandbox directory: %q", hashedDir)
+	}
+	sandboxDir := filepath.Join(m.sandboxRoot, hashedDir)
+
+	files = []FileEntry{}
+	err = filepath.WalkDir(sandboxDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if path == sandboxDir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(files) == MaxListing {
+			truncated = true
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		rel, _ := filepath.Rel(sandboxDir, path)
+		files = append(files, FileEntry{Name: filepath.ToSlash(rel), Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list sandbox: %w", err)
+	}
+	return files, truncated, nil
+}
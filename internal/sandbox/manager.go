@@ -2,27 +2,105 @@ package sandbox
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
 	"syscall"
+
+	"github.com/jsc/mcp-code-sandbox/internal/storage"
+)
+
+// metadataDirName is the directory under the sandbox root holding metadata
+const metadataDirName = ".metadata"
+
+// Sandbox ownership: the shared runner user, or with UID isolation a UID
+// derived from the conversation in a high range no host account uses
+const (
+	defaultUID    = 1000
+	isolatedBase  = 100000
+	isolatedRange = 1 << 30
 )
 
 // Manager handles sandbox filesystem operations
 type Manager struct {
-	sandboxRoot     string // Root directory for filesystem operations (server's view)
-	sandboxHostPath string // Root directory on Docker host for bind mounts (may be same as sandboxRoot)
-	secret          string // Secret for hashing conversation IDs
+	sandboxRoot     string     // Root directory for filesystem operations (server's view)
+	sandboxHostPath string     // Root directory on Docker host for bind mounts (may be same as sandboxRoot)
+	secret          string     // Secret for hashing conversation IDs
+	isolateUIDs     bool       // Give each conversation its own UID and a 0700 directory
+	minFreeBytes    int64      // Free space required before executions (0 disables the check)
+	extractMaxBytes int64      // Most bytes one uploaded archive may expand to
+	limits          Limits     // Bounds on uploaded files (SetLimits)
+	downloadCache   bool       // Give installs per-tenant download caches (SetDownloadCache)
+	linksMu         sync.Mutex // Serializes link epoch updates
+
+	remote     storage.Backend // Durable home of sandbox files, nil for local disk only
+	syncStates sync.Map        // hashedDir -> *syncState
+	digests    sync.Map        // Local path -> fileDigest
+}
+
+// InsufficientSpaceError reports that the sandbox filesystem is too full to
+// safely run code or accept files
+type InsufficientSpaceError struct {
+	FreeBytes     int64
+	RequiredBytes int64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient disk space on sandbox filesystem: %d bytes free, %d required", e.FreeBytes, e.RequiredBytes)
 }
 
 // NewManager creates a new sandbox manager
-func NewManager(sandboxRoot, sandboxHostPath, secret string) *Manager {
+func NewManager(sandboxRoot, sandboxHostPath, secret string, isolateUIDs bool, minFreeBytes, extractMaxBytes int64) *Manager {
 	return &Manager{
 		sandboxRoot:     sandboxRoot,
 		sandboxHostPath: sandboxHostPath,
 		secret:          secret,
+		isolateUIDs:     isolateUIDs,
+		minFreeBytes:    minFreeBytes,
+		extractMaxBytes: extractMaxBytes,
+	}
+}
+
+// CheckFreeSpace returns an *InsufficientSpaceError if the sandbox filesystem
+// has less free space than the configured minimum
+func (m *Manager) CheckFreeSpace() error {
+	if m.minFreeBytes <= 0 {
+		return nil
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(m.sandboxRoot, &stat); err != nil {
+		return fmt.Errorf("failed to check free space: %w", err)
+	}
+
+	// Bavail is what unprivileged writers (the runner user) can use
+	free := int64(stat.Bavail) * int64(stat.Bsize)
+	if free < m.minFreeBytes {
+		return &InsufficientSpaceError{FreeBytes: free, RequiredBytes: m.minFreeBytes}
+	}
+	return nil
+}
+
+// Owner returns the UID and GID that own a conversation's sandbox and run
+// its containers. Without isolation every conversation shares 1000:1000
+func (m *Manager) Owner(conversationID string) (int, int) {
+	if !m.isolateUIDs {
+		return defaultUID, defaultUID
 	}
+	h := sha256.Sum256([]byte("uid:" + m.hashConversationID(conversationID)))
+	id := isolatedBase + int(binary.BigEndian.Uint32(h[:4])%isolatedRange)
+	return id, id
+}
+
+// User returns the container user ("uid:gid") for a conversation
+func (m *Manager) User(conversationID string) string {
+	uid, gid := m.Owner(conversationID)
+	return fmt.Sprintf("%d:%d", uid, gid)
 }
 
 // hashConversationID creates a filesystem-safe hash of conversationID + secret
@@ -37,7 +115,7 @@ func (m *Manager) hashConversationID(conversationID string) string {
 }
 
 // EnsureSandboxDir ensures the sandbox directory exists for a conversation
-// Creates the directory and sets ownership to 1000:1000 for runner containers
+// Creates the directory and sets ownership to the conversation's owner (see Owner)
 // Returns the hashed directory name (not full path)
 func (m *Manager) EnsureSandboxDir(conversationID string) (string, error) {
 	if conversationID == "" {
@@ -48,29 +126,64 @@ func (m *Manager) EnsureSandboxDir(conversationID string) (string, error) {
 	hashedDir := m.hashConversationID(conversationID)
 	sandboxDir := filepath.Join(m.sandboxRoot, hashedDir)
 
-	// Create directory with 0777 permissions
-	if err := os.MkdirAll(sandboxDir, 0o777); err != nil {
+	mode := m.dirMode()
+
+	// Create directory
+	if err := os.MkdirAll(sandboxDir, mode); err != nil {
 		return "", fmt.Errorf("failed to create sandbox directory: %w", err)
 	}
 
-	// Change ownership to 1000:1000 (sandbox user in containers)
-	if err := os.Chown(sandboxDir, 1000, 1000); err != nil {
+	uid, gid := m.Owner(conversationID)
+	if m.isolateUIDs {
+		// Files written by the server (uploads, extracted archives) must be
+		// readable by the conversation's UID, and nothing else may read them
+		if err := chownRecursive(sandboxDir, uid, gid); err != nil {
+			return "", fmt.Errorf("failed to chown sandbox directory to %d:%d: %w", uid, gid, err)
+		}
+	} else if err := os.Chown(sandboxDir, uid, gid); err != nil {
 		// Log warning but don't fail - this might not work on all systems (e.g., Docker Desktop for Mac)
 		// The directory will still be usable, just with different ownership
-		fmt.Printf("Warning: failed to chown %s to 1000:1000: %v\n", sandboxDir, err)
+		fmt.Printf("Warning: failed to chown %s to %d:%d: %v\n", sandboxDir, uid, gid, err)
 	}
 
 	// Try to set permissions via chmod as well
-	if err := os.Chmod(sandboxDir, 0o777); err != nil {
-		fmt.Printf("Warning: failed to chmod %s to 0777: %v\n", sandboxDir, err)
+	if err := os.Chmod(sandboxDir, mode); err != nil {
+		fmt.Printf("Warning: failed to chmod %s to %o: %v\n", sandboxDir, mode, err)
 	}
 
 	return hashedDir, nil
 }
 
+// dirMode is the permission for sandbox directories. Shared-UID sandboxes
+// stay world-writable so the runner user can always write; isolated ones are
+// private to their owner
+func (m *Manager) dirMode() os.FileMode {
+	if m.isolateUIDs {
+		return 0o700
+	}
+	return 0o777
+}
+
 // ListFiles lists all files in a conversation's sandbox directory
 func (m *Manager) ListFiles(conversationID string) ([]string, error) {
-	hashedDir := m.hashConversationID(conversationID)
+	return m.ListHashedDir(m.hashConversationID(conversationID))
+}
+
+// HasFiles reports whether a conversation's sandbox has any files or
+// directories
+func (m *Manager) HasFiles(conversationID string) (bool, error) {
+	entries, err := os.ReadDir(m.GetSandboxDir(conversationID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read sandbox directory: %w", err)
+	}
+	return len(entries) > 0, nil
+}
+
+// ListHashedDir lists the files in a sandbox identified by its hashed directory
+func (m *Manager) ListHashedDir(hashedDir string) ([]string, error) {
 	sandboxDir := filepath.Join(m.sandboxRoot, hashedDir)
 
 	// Check if directory exists
@@ -100,6 +213,39 @@ func (m *Manager) GetFilePath(hashedDir, filename string) string {
 	return filepath.Join(m.sandboxRoot, hashedDir, filename)
 }
 
+// OpenFile opens a regular file in the sandbox in hashedDir for serving
+func (m *Manager) OpenFile(hashedDir, filename string) (*os.File, os.FileInfo, error) {
+	if !validHashedDir(hashedDir) {
+		return nil, nil, fmt.Errorf("invalid sandbox directory: %q", hashedDir)
+	}
+	return openRegular(filepath.Join(m.sandboxRoot, hashedDir), filename)
+}
+
+// openRegular opens the regular file at name, a relative path, in dir.
+// Sandboxed code controls the directory, so it opens through a root, where
+// symlinks can't lead out of it, and without blocking on FIFOs
+func openRegular(dir, name string) (*os.File, os.FileInfo, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer root.Close()
+	f, err := root.OpenFile(name, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, nil, fmt.Errorf("not a regular file: %s", name)
+	}
+	return f, info, nil
+}
+
 // GetSandboxDir returns the absolute path to a conversation's sandbox directory
 // This path is used for filesystem operations by the server
 func (m *Manager) GetSandboxDir(conversationID string) string {
@@ -118,51 +264,115 @@ func (m *Manager) GetSandboxHostPath(conversationID string) string {
 func (m *Manager) DeleteSandbox(conversationID string) error {
 	hashedDir := m.hashConversationID(conversationID)
 	sandboxDir := filepath.Join(m.sandboxRoot, hashedDir)
+	if err := os.RemoveAll(m.GetMetadataDir(conversationID)); err != nil {
+		return err
+	}
+	if err := m.ClearPackages(conversationID); err != nil {
+		return err
+	}
+	if err := m.deleteRemote(hashedDir); err != nil {
+		return err
+	}
+	m.forgetSync(hashedDir)
 	return os.RemoveAll(sandboxDir)
 }
 
+// GetMetadataDir returns the directory holding server-side metadata for a
+// conversation. It lives outside the sandbox directory so it is never
+// mounted into runner containers or served via /files.
+func (m *Manager) GetMetadataDir(conversationID string) string {
+	hashedDir := m.hashConversationID(conversationID)
+	return filepath.Join(m.sandboxRoot, metadataDirName, hashedDir)
+}
+
 // GetSandboxRoot returns the root directory for all sandboxes
 func (m *Manager) GetSandboxRoot() string {
 	return m.sandboxRoot
 }
 
 // WriteFile writes content to a file in a conversation's sandbox
-// Creates the sandbox directory if it doesn't exist
+// Creates the sandbox directory if it doesn't exist. The filename must be a
+// local path; the write goes through the sandbox root, so neither ".." nor
+// symlinks the code planted can reach outside it. Returns a *LimitError,
+// writing nothing, if the file would break the sandbox's Limits
 func (m *Manager) WriteFile(conversationID, filename string, content []byte) error {
-	hashedDir := m.hashConversationID(conversationID)
-	sandboxDir := filepath.Join(m.sandboxRoot, hashedDir)
-
-	// Ensure sandbox directory exists
-	if err := os.MkdirAll(sandboxDir, 0o777); err != nil {
-		return fmt.Errorf("failed to create sandbox directory: %w", err)
+	if !filepath.IsLocal(filename) {
+		return fmt.Errorf("invalid filename: %q", filename)
+	}
+	filename = filepath.Clean(filename)
+	if _, err := m.EnsureSandboxDir(conversationID); err != nil {
+		return err
+	}
+	if err := m.checkLimits(conversationID, map[string]int64{filename: int64(len(content))}); err != nil {
+		return err
 	}
 
-	// Change ownership to 1000:1000
-	if err := os.Chown(sandboxDir, 1000, 1000); err != nil {
-		fmt.Printf("Warning: failed to chown %s to 1000:1000: %v\n", sandboxDir, err)
+	root, err := os.OpenRoot(m.GetSandboxDir(conversationID))
+	if err != nil {
+		return fmt.Errorf("failed to open sandbox: %w", err)
 	}
+	defer root.Close()
 
 	// Write file
-	filePath := filepath.Join(sandboxDir, filename)
-	if err := os.WriteFile(filePath, content, 0o666); err != nil {
+	if err := root.WriteFile(filename, content, 0o666); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
-	// Change file ownership to 1000:1000
-	if err := os.Chown(filePath, 1000, 1000); err != nil {
-		fmt.Printf("Warning: failed to chown %s to 1000:1000: %v\n", filePath, err)
+	// Change file ownership to the conversation's owner
+	uid, gid := m.Owner(conversationID)
+	if err := root.Chown(filename, uid, gid); err != nil {
+		fmt.Printf("Warning: failed to chown %s to %d:%d: %v\n", filename, uid, gid, err)
 	}
 
 	return nil
 }
 
-// chownRecursive changes ownership of a directory and all its contents
+// ReadFile reads a file from a conversation's sandbox
+// The filename must be a plain name; path separators and ".." are rejected
+func (m *Manager) ReadFile(conversationID, filename string) ([]byte, error) {
+	if filename == "" || filename != filepath.Base(filename) || filename == ".." || filename == "." {
+		return nil, fmt.Errorf("invalid filename: %q", filename)
+	}
+
+	f, info, err := openRegular(m.GetSandboxDir(conversationID), filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := m.CheckFileSize(filename, info.Size()); err != nil {
+		return nil, err
+	}
+
+	// The file may grow while it's read
+	return io.ReadAll(io.LimitReader(f, info.Size()))
+}
+
+// ReadPath reads a regular file at a relative path in a conversation's
+// sandbox, such as a report a tool's command wrote. Symlinks can't lead out
+// of the sandbox
+func (m *Manager) ReadPath(conversationID, name string) ([]byte, error) {
+	f, info, err := openRegular(m.GetSandboxDir(conversationID), filepath.FromSlash(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, info.Size()))
+}
+
+// chownRecursive changes ownership of a directory and all its contents.
+// Sandboxed code controls the tree, so it walks through a root and changes
+// symlinks themselves, never what they point to
 func chownRecursive(path string, uid, gid int) error {
-	return filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+	root, err := os.OpenRoot(path)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return fs.WalkDir(root.FS(), ".", func(name string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		return os.Chown(name, uid, gid)
+		return root.Lchown(name, uid, gid)
 	})
 }
 
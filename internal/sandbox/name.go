@@ -0,0 +1,63 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// displayNameFile is the metadata file holding a conversation's display name
+const displayNameFile = "name"
+
+// MaxDisplayNameLength is the longest display name accepted, in characters
+const MaxDisplayNameLength = 100
+
+// SetDisplayName attaches a human-friendly name to a conversation so it can
+// be recognized in admin listings and share pages. An empty name removes it
+func (m *Manager) SetDisplayName(conversationID, name string) error {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > MaxDisplayNameLength {
+		return fmt.Errorf("name must be at most %d characters", MaxDisplayNameLength)
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return fmt.Errorf("name must not contain control characters")
+	}
+
+	dir := m.GetMetadataDir(conversationID)
+	path := filepath.Join(dir, displayNameFile)
+	if name == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove display name: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(name), 0o600); err != nil {
+		return fmt.Errorf("failed to write display name: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// DisplayName returns the display name of the sandbox in hashedDir, or ""
+func (m *Manager) DisplayName(hashedDir string) string {
+	if !validHashedDir(hashedDir) {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(m.sandboxRoot, metadataDirName, hashedDir, displayNameFile))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// ConversationDisplayName returns a conversation's display name, or ""
+func (m *Manager) ConversationDisplayName(conversationID string) string {
+	return m.DisplayName(m.hashConversationID(conversationID))
+}
@@ -0,0 +1,76 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// packagesDirName is the directory under the sandbox root holding each
+// conversation's installed packages, mounted into runners next to /data
+const packagesDirName = ".packages"
+
+// GetPackagesDir returns the server-side path of a conversation's package cache
+func (m *Manager) GetPackagesDir(conversationID string) string {
+	return filepath.Join(m.sandboxRoot, packagesDirName, m.hashConversationID(conversationID))
+}
+
+// GetPackagesHostPath returns the Docker host path of a conversation's package
+// cache for bind mounting
+func (m *Manager) GetPackagesHostPath(conversationID string) string {
+	return filepath.Join(m.sandboxHostPath, packagesDirName, m.hashConversationID(conversationID))
+}
+
+// HasPackages reports whether a conversation has a package cache
+func (m *Manager) HasPackages(conversationID string) bool {
+	info, err := os.Stat(m.GetPackagesDir(conversationID))
+	return err == nil && info.IsDir()
+}
+
+// EnsurePackagesDir creates a conversation's package cache, owned like its
+// sandbox so the package manager can write to it
+func (m *Manager) EnsurePackagesDir(conversationID string) error {
+	dir := m.GetPackagesDir(conversationID)
+	if err := os.MkdirAll(dir, m.dirMode()); err != nil {
+		return fmt.Errorf("failed to create package cache: %w", err)
+	}
+	uid, gid := m.Owner(conversationID)
+	if err := os.Chown(dir, uid, gid); err != nil && m.isolateUIDs {
+		return fmt.Errorf("failed to chown package cache to %d:%d: %w", uid, gid, err)
+	}
+	return os.Chmod(dir, m.dirMode())
+}
+
+// PackagesSize returns the total size of a conversation's package cache
+func (m *Manager) PackagesSize(conversationID string) (int64, error) {
+	var usage Usage
+	if err := addUsage(&usage, m.GetPackagesDir(conversationID)); err != nil {
+		return 0, err
+	}
+	return usage.SizeBytes, nil
+}
+
+// ClearPackages removes a conversation's package cache
+func (m *Manager) ClearPackages(conversationID string) error {
+	return os.RemoveAll(m.GetPackagesDir(conversationID))
+}
+
+// requirementsMarker names the file recording the digest of the
+// requirements last installed for a language. It lives in the package cache
+// so clearing the cache forgets it
+func (m *Manager) requirementsMarker(conversationID, language string) string {
+	return filepath.Join(m.GetPackagesDir(conversationID), ".requirements-"+filepath.Base(language))
+}
+
+// RequirementsInstalled reports whether the requirements with digest were
+// the last ones installed into the conversation's package cache
+func (m *Manager) RequirementsInstalled(conversationID, language, digest string) bool {
+	data, err := os.ReadFile(m.requirementsMarker(conversationID, language))
+	return err == nil && string(data) == digest
+}
+
+// MarkRequirementsInstalled records digest as the requirements installed for
+// language
+func (m *Manager) MarkRequirementsInstalled(conversationID, language, digest string) error {
+	return os.WriteFile(m.requirementsMarker(conversationID, language), []byte(digest), 0644)
+}
@@ -0,0 +1,444 @@
+package sandbox
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jsc/mcp-code-sandbox/internal/storage"
+)
+
+// With object storage configured, a sandbox's files and metadata live under
+// "<hashedDir>/files/" and "<hashedDir>/metadata/" in the store. Stage copies
+// them into the local sandbox root before a conversation is used and Sync
+// copies changes back afterwards, so any server instance can serve any
+// conversation. Objects are compared by their MD5 (the ETag of single-part
+// uploads), which needs no agreement between the instances' clocks
+
+// remoteTrees are the local directories mirrored to object storage, by key
+// segment. Metadata stays private to the server rather than going to the
+// sandbox's owner
+var remoteTrees = []struct {
+	segment string
+	private bool
+	dir     func(m *Manager, hashedDir string) string
+}{
+	{"files", false, func(m *Manager, hashedDir string) string { return filepath.Join(m.sandboxRoot, hashedDir) }},
+	{"metadata", true, func(m *Manager, hashedDir string) string {
+		return filepath.Join(m.sandboxRoot, metadataDirName, hashedDir)
+	}},
+}
+
+// syncState is what one sandbox looked like when it was last in sync with
+// object storage: MD5s by key. A local file missing from the store is only
+// deleted, and an object missing locally only removed, if it is unchanged
+// since then; anything else is new on one side and is kept
+type syncState struct {
+	mu     sync.Mutex
+	synced map[string]string
+}
+
+// fileDigest caches a local file's MD5 by size and modification time
+type fileDigest struct {
+	size    int64
+	modTime time.Time
+	md5     string
+}
+
+// SetStorage makes object storage the durable home of sandbox files; nil
+// keeps them on local disk only
+func (m *Manager) SetStorage(backend storage.Backend) {
+	m.remote = backend
+}
+
+// RemoteEnabled reports whether sandbox files are kept in object storage
+func (m *Manager) RemoteEnabled() bool {
+	return m.remote != nil
+}
+
+// Stage brings a conversation's sandbox up to date with object storage
+func (m *Manager) Stage(ctx context.Context, conversationID string) error {
+	if m.remote == nil || conversationID == "" {
+		return nil
+	}
+	hashedDir, err := m.EnsureSandboxDir(conversationID)
+	if err != nil {
+		return err
+	}
+	return m.StageHashedDir(ctx, hashedDir)
+}
+
+// StageHashedDir brings the sandbox in hashedDir up to date with object
+// storage, downloading new and changed files and removing deleted ones
+func (m *Manager) StageHashedDir(ctx context.Context, hashedDir string) error {
+	if m.remote == nil {
+		return nil
+	}
+	if !validHashedDir(hashedDir) {
+		return fmt.Errorf("invalid sandbox directory: %q", hashedDir)
+	}
+	state := m.syncState(hashedDir)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	objects, err := m.remote.List(ctx, hashedDir+"/")
+	if err != nil {
+		return fmt.Errorf("failed to list stored sandbox files: %w", err)
+	}
+	remote := make(map[string]storage.Object, len(objects))
+	for _, obj := range objects {
+		remote[strings.TrimPrefix(obj.Key, hashedDir+"/")] = obj
+	}
+	for _, tree := range remoteTrees {
+		root := tree.dir(m, hashedDir)
+		local, err := m.localDigests(root, tree.segment)
+		if err != nil {
+			return err
+		}
+		owner := m.ownerOfHashedDir(hashedDir)
+		if tree.private {
+			owner = nil
+		}
+		for rel, obj := range remote {
+			if !strings.HasPrefix(rel, tree.segment+"/") || local[rel] == obj.ETag {
+				continue
+			}
+			if err := m.download(ctx, obj.Key, root, strings.TrimPrefix(rel, tree.segment+"/"), owner); err != nil {
+				return err
+			}
+		}
+		for rel, sum := range local {
+			if _, ok := remote[rel]; ok || state.synced[rel] != sum {
+				continue
+			}
+			// Deleted by another instance since this one last synced
+			if r, err := os.OpenRoot(root); err == nil {
+				r.Remove(filepath.FromSlash(strings.TrimPrefix(rel, tree.segment+"/")))
+				r.Close()
+			}
+			delete(state.synced, rel)
+		}
+	}
+	for rel, obj := range remote {
+		state.synced[rel] = obj.ETag
+	}
+	return nil
+}
+
+// Sync copies changes to a conversation's sandbox to object storage
+func (m *Manager) Sync(ctx context.Context, conversationID string) error {
+	if m.remote == nil || conversationID == "" {
+		return nil
+	}
+	hashedDir := m.hashConversationID(conversationID)
+	state := m.syncState(hashedDir)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	objects, err := m.remote.List(ctx, hashedDir+"/")
+	if err != nil {
+		return fmt.Errorf("failed to list stored sandbox files: %w", err)
+	}
+	remote := make(map[string]storage.Object, len(objects))
+	for _, obj := range objects {
+		remote[strings.TrimPrefix(obj.Key, hashedDir+"/")] = obj
+	}
+
+	for _, tree := range remoteTrees {
+		root := tree.dir(m, hashedDir)
+		local, err := m.localDigests(root, tree.segment)
+		if err != nil {
+			return err
+		}
+		for rel, sum := range local {
+			if obj, ok := remote[rel]; ok && obj.ETag == sum {
+				continue
+			}
+			source := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(rel, tree.segment+"/")))
+			if err := m.upload(ctx, hashedDir+"/"+rel, source, sum); err != nil {
+				return err
+			}
+			state.synced[rel] = sum
+		}
+		for rel, obj := range remote {
+			if !strings.HasPrefix(rel, tree.segment+"/") {
+				continue
+			}
+			if _, ok := local[rel]; ok || state.synced[rel] != obj.ETag {
+				continue
+			}
+			// Deleted here since this instance last synced
+			if err := m.remote.Delete(ctx, obj.Key); err != nil {
+				return fmt.Errorf("failed to delete stored file: %w", err)
+			}
+			delete(state.synced, rel)
+		}
+	}
+	return nil
+}
+
+// FetchFile downloads one file of the sandbox in hashedDir from object
+// storage unless it is already present. It returns an error satisfying
+// os.IsNotExist for files that aren't stored either
+func (m *Manager) FetchFile(ctx context.Context, hashedDir, name string) error {
+	if m.remote == nil {
+		return nil
+	}
+	rel := path.Clean("/" + filepath.ToSlash(name))[1:]
+	if !validHashedDir(hashedDir) || rel == "" || rel != filepath.ToSlash(name) {
+		return fs.ErrNotExist
+	}
+	root := filepath.Join(m.sandboxRoot, hashedDir)
+	if r, err := os.OpenRoot(root); err == nil {
+		_, err = r.Lstat(filepath.FromSlash(rel))
+		r.Close()
+		if err == nil {
+			return nil
+		}
+	}
+
+	state := m.syncState(hashedDir)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	err := m.download(ctx, hashedDir+"/files/"+rel, root, rel, m.ownerOfHashedDir(hashedDir))
+	if errors.Is(err, storage.ErrNotFound) {
+		return fs.ErrNotExist
+	}
+	return err
+}
+
+// fetchMetadata copies one of a conversation's metadata files from object
+// storage unless it is already present, for checks that come before the
+// conversation is staged. A file that isn't stored is not an error
+func (m *Manager) fetchMetadata(ctx context.Context, conversationID, name string) error {
+	if m.remote == nil || conversationID == "" {
+		return nil
+	}
+	hashedDir := m.hashConversationID(conversationID)
+	dir := filepath.Join(m.sandboxRoot, metadataDirName, hashedDir)
+	if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+		return nil
+	}
+
+	state := m.syncState(hashedDir)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	err := m.download(ctx, hashedDir+"/metadata/"+name, dir, name, nil)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// deleteRemote removes everything stored for the sandbox in hashedDir
+func (m *Manager) deleteRemote(hashedDir string) error {
+	if m.remote == nil {
+		return nil
+	}
+	ctx := context.Background()
+	state := m.syncState(hashedDir)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	objects, err := m.remote.List(ctx, hashedDir+"/")
+	if err != nil {
+		return fmt.Errorf("failed to list stored sandbox files: %w", err)
+	}
+	for _, obj := range objects {
+		if err := m.remote.Delete(ctx, obj.Key); err != nil {
+			return fmt.Errorf("failed to delete stored file: %w", err)
+		}
+	}
+	state.synced = map[string]string{}
+	return nil
+}
+
+// forgetSync drops what is known about a sandbox whose local copy is removed
+func (m *Manager) forgetSync(hashedDir string) {
+	if m.remote == nil {
+		return
+	}
+	if state, ok := m.syncStates.Load(hashedDir); ok {
+		state := state.(*syncState)
+		state.mu.Lock()
+		state.synced = map[string]string{}
+		state.mu.Unlock()
+	}
+	for _, tree := range remoteTrees {
+		prefix := tree.dir(m, hashedDir) + string(filepath.Separator)
+		m.digests.Range(func(key, _ interface{}) bool {
+			if strings.HasPrefix(key.(string), prefix) {
+				m.digests.Delete(key)
+			}
+			return true
+		})
+	}
+}
+
+func (m *Manager) syncState(hashedDir string) *syncState {
+	state, _ := m.syncStates.LoadOrStore(hashedDir, &syncState{synced: map[string]string{}})
+	return state.(*syncState)
+}
+
+// localDigests returns the MD5s of the regular files under root, keyed by
+// segment and slash-separated path. Symlinks and other special files are
+// not stored
+func (m *Manager) localDigests(root, segment string) (map[string]string, error) {
+	digests := map[string]string{}
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		sum, err := m.digest(name, info)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, name)
+		digests[segment+"/"+filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	return digests, nil
+}
+
+// digest returns a file's MD5, reusing the last one while the file's size
+// and modification time are unchanged
+func (m *Manager) digest(name string, info fs.FileInfo) (string, error) {
+	if cached, ok := m.digests.Load(name); ok {
+		c := cached.(fileDigest)
+		if c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+			return c.md5, nil
+		}
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	m.digests.Store(name, fileDigest{size: info.Size(), modTime: info.ModTime(), md5: sum})
+	return sum, nil
+}
+
+// download writes an object to rel, a slash-separated path under root,
+// creating directories. Sandboxed code controls the tree, so everything goes
+// through a root, where symlinks can't lead out of it. With an owner
+// everything goes to the sandbox's owner; without one it is private to the
+// server
+func (m *Manager) download(ctx context.Context, key, root, rel string, owner *[2]int) error {
+	dirMode, fileMode := m.dirMode(), os.FileMode(0o666)
+	if owner == nil {
+		dirMode, fileMode = 0o700, 0o600
+	}
+	if err := os.MkdirAll(root, dirMode); err != nil {
+		return fmt.Errorf("failed to create %s: %w", root, err)
+	}
+	r, err := os.OpenRoot(root)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", root, err)
+	}
+	defer r.Close()
+
+	target := filepath.FromSlash(rel)
+	dir := filepath.Dir(target)
+	if err := r.MkdirAll(dir, dirMode); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if owner != nil {
+		for d := dir; d != "."; d = filepath.Dir(d) {
+			r.Lchown(d, owner[0], owner[1])
+		}
+	}
+
+	// Write next to the target and rename, so runs never see partial files
+	tmpName := filepath.Join(dir, ".sync-"+rand.Text())
+	tmp, err := r.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to stage %s: %w", key, err)
+	}
+	defer r.Remove(tmpName)
+	err = m.remote.Get(ctx, key, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	if err := r.Chmod(tmpName, fileMode); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", key, err)
+	}
+	if owner != nil {
+		if err := r.Lchown(tmpName, owner[0], owner[1]); err != nil && m.isolateUIDs {
+			return fmt.Errorf("failed to chown %s to %d:%d: %w", target, owner[0], owner[1], err)
+		}
+	}
+	if err := r.Rename(tmpName, target); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", key, err)
+	}
+	return nil
+}
+
+// upload stores the file source under key
+func (m *Manager) upload(ctx context.Context, key, source, sum string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // Removed since the scan
+		}
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	contentMD5, _ := hex.DecodeString(sum)
+	if err := m.remote.Put(ctx, key, f, info.Size(), contentMD5); err != nil {
+		// The file may have changed since it was hashed; the next sync retries
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return nil
+}
+
+// ownerOfHashedDir returns the owner of an existing sandbox directory, or
+// the shared runner user
+func (m *Manager) ownerOfHashedDir(hashedDir string) *[2]int {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(filepath.Join(m.sandboxRoot, hashedDir), &stat); err == nil {
+		return &[2]int{int(stat.Uid), int(stat.Gid)}
+	}
+	return &[2]int{defaultUID, defaultUID}
+}
+
+// validHashedDir reports whether hashedDir can name a sandbox directory
+func validHashedDir(hashedDir string) bool {
+	return hashedDir != "" && hashedDir == filepath.Base(hashedDir) && !strings.HasPrefix(hashedDir, ".")
+}
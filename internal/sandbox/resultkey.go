@@ -0,0 +1,63 @@
+package sandbox
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jsc/mcp-code-sandbox/internal/sealed"
+)
+
+// resultKeyFile is the metadata file holding a conversation's result key
+const resultKeyFile = "result_key"
+
+// SetResultKey stores the client's public key for sealing a conversation's
+// outputs and downloads. An empty key turns sealing off
+func (m *Manager) SetResultKey(conversationID, encoded string) error {
+	dir := m.GetMetadataDir(conversationID)
+	path := filepath.Join(dir, resultKeyFile)
+	if strings.TrimSpace(encoded) == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove result key: %w", err)
+		}
+		return nil
+	}
+
+	key, err := sealed.ParsePublicKey(encoded)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(base64.StdEncoding.EncodeToString(key.Bytes())), 0o600); err != nil {
+		return fmt.Errorf("failed to write result key: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// ResultKey returns the result key of the sandbox in hashedDir, or nil if its
+// outputs are not sealed
+func (m *Manager) ResultKey(hashedDir string) *ecdh.PublicKey {
+	if !validHashedDir(hashedDir) {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(m.sandboxRoot, metadataDirName, hashedDir, resultKeyFile))
+	if err != nil {
+		return nil
+	}
+	key, err := sealed.ParsePublicKey(string(data))
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+// ConversationResultKey returns a conversation's result key, or nil
+func (m *Manager) ConversationResultKey(conversationID string) *ecdh.PublicKey {
+	return m.ResultKey(m.hashConversationID(conversationID))
+}
@@ -0,0 +1,294 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// snapshotsDirName is the metadata subdirectory holding a conversation's
+// snapshots: <id>.tar.gz with the files and <id>.json describing them
+const snapshotsDirName = "snapshots"
+
+// MaxSnapshots is how many snapshots a conversation keeps; taking another
+// drops the oldest
+const MaxSnapshots = 10
+
+// MaxSnapshotLabelLength is the longest snapshot label accepted, in characters
+const MaxSnapshotLabelLength = 100
+
+// ErrSnapshotNotFound is returned for snapshot IDs the conversation doesn't have
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Snapshot describes a saved copy of a sandbox's files
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Files     int       `json:"files"`
+	SizeBytes int64     `json:"sizeBytes"` // Uncompressed size of the files
+}
+
+// CreateSnapshot saves the files and directories in a conversation's sandbox
+// as a tar.gz under a new snapshot ID. Symlinks and special files are
+// skipped. The files may total at most the archive extraction limit, so any
+// snapshot can be restored
+func (m *Manager) CreateSnapshot(conversationID, label string) (*Snapshot, error) {
+	label = strings.TrimSpace(label)
+	if utf8.RuneCountInString(label) > MaxSnapshotLabelLength {
+		return nil, fmt.Errorf("label must be at most %d characters", MaxSnapshotLabelLength)
+	}
+	if strings.ContainsFunc(label, unicode.IsControl) {
+		return nil, fmt.Errorf("label must not contain control characters")
+	}
+	if _, err := m.EnsureSandboxDir(conversationID); err != nil {
+		return nil, err
+	}
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate snapshot ID: %w", err)
+	}
+	snapshot := &Snapshot{ID: hex.EncodeToString(idBytes), Label: label, CreatedAt: time.Now().UTC()}
+
+	dir := filepath.Join(m.GetMetadataDir(conversationID), snapshotsDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	err = m.writeSnapshot(tmp, m.GetSandboxDir(conversationID), snapshot)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Join(dir, snapshot.ID)
+	if err := os.WriteFile(base+".json.tmp", info, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), base+".tar.gz"); err != nil {
+		os.Remove(base + ".json.tmp")
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(base+".json.tmp", base+".json"); err != nil {
+		os.Remove(base + ".tar.gz")
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	// Keep the newest MaxSnapshots
+	snapshots, err := m.ListSnapshots(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	for len(snapshots) > MaxSnapshots {
+		m.removeSnapshot(dir, snapshots[0].ID)
+		snapshots = snapshots[1:]
+	}
+	return snapshot, nil
+}
+
+// writeSnapshot archives sandboxDir into w, counting files and bytes into
+// snapshot
+func (m *Manager) writeSnapshot(w io.Writer, sandboxDir string, snapshot *Snapshot) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(sandboxDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == sandboxDir || !(d.IsDir() || d.Type().IsRegular()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		rel, _ := filepath.Rel(sandboxDir, path)
+		hdr := &tar.Header{
+			Name:    filepath.ToSlash(rel),
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: info.ModTime(),
+		}
+		if d.IsDir() {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			return tw.WriteHeader(hdr)
+		}
+
+		snapshot.Files++
+		snapshot.SizeBytes += info.Size()
+		if snapshot.SizeBytes > m.extractMaxBytes {
+			return fmt.Errorf("sandbox files exceed the %d byte snapshot limit", m.extractMaxBytes)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = info.Size()
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		// A file growing while it is copied would corrupt the archive
+		_, err = io.Copy(tw, io.LimitReader(f, hdr.Size))
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	return nil
+}
+
+// ListSnapshots returns a conversation's snapshots, oldest first
+func (m *Manager) ListSnapshots(conversationID string) ([]Snapshot, error) {
+	dir := filepath.Join(m.GetMetadataDir(conversationID), snapshotsDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Snapshot{}, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !validSnapshotID(id) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var snapshot Snapshot
+		if json.Unmarshal(data, &snapshot) != nil || snapshot.ID != id {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// RestoreSnapshot replaces the files in a conversation's sandbox with those
+// saved in a snapshot and returns its description. The snapshot is
+// unpacked next to the sandbox first, so a failure leaves the sandbox as it
+// was. File permissions are reset as for uploaded archives
+func (m *Manager) RestoreSnapshot(conversationID, id string) (*Snapshot, error) {
+	if !validSnapshotID(id) {
+		return nil, ErrSnapshotNotFound
+	}
+	dir := filepath.Join(m.GetMetadataDir(conversationID), snapshotsDirName)
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	archive, err := os.Open(filepath.Join(dir, id+".tar.gz"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	defer archive.Close()
+
+	hashedDir, err := m.EnsureSandboxDir(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	sandboxDir := m.GetSandboxDir(conversationID)
+
+	// Dot-prefixed, so sandbox listings and GC skip it
+	staging, err := os.MkdirTemp(m.sandboxRoot, ".restore-"+hashedDir+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage snapshot: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	root, err := os.OpenRoot(staging)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage snapshot: %w", err)
+	}
+	defer root.Close()
+
+	uid, gid := m.Owner(conversationID)
+	x := &extractor{root: root, uid: uid, gid: gid, dirMode: m.dirMode(), maxBytes: m.extractMaxBytes}
+	gz, err := gzip.NewReader(archive)
+	if err == nil {
+		err = x.extractTar(gz)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack snapshot: %w", err)
+	}
+
+	entries, err := os.ReadDir(sandboxDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sandbox directory: %w", err)
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(sandboxDir, entry.Name())); err != nil {
+			return nil, fmt.Errorf("failed to clear sandbox: %w", err)
+		}
+	}
+	staged, err := os.ReadDir(staging)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged snapshot: %w", err)
+	}
+	for _, entry := range staged {
+		if err := os.Rename(filepath.Join(staging, entry.Name()), filepath.Join(sandboxDir, entry.Name())); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", entry.Name(), err)
+		}
+	}
+	return &snapshot, nil
+}
+
+// removeSnapshot deletes a snapshot's archive and description
+func (m *Manager) removeSnapshot(dir, id string) {
+	os.Remove(filepath.Join(dir, id+".json"))
+	os.Remove(filepath.Join(dir, id+".tar.gz"))
+}
+
+// validSnapshotID reports whether id has the form CreateSnapshot generates
+func validSnapshotID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && id == strings.ToLower(id)
+}
@@ -0,0 +1,37 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stagingDirName is the directory under the sandbox root holding files
+// mounted into single executions, such as the code being run
+const stagingDirName = ".staging"
+
+// staleStagingAge is how old a staged file must be to count as left over
+// from a crash; run-once may share the directory with a running server
+const staleStagingAge = 24 * time.Hour
+
+// EnsureStaging creates the staging directory and returns it from the
+// server's and the Docker host's point of view, removing stale leftovers.
+// Files are bind mounted individually, so only the server needs access to
+// the directory itself
+func (m *Manager) EnsureStaging() (string, string, error) {
+	dir := filepath.Join(m.sandboxRoot, stagingDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read staging directory: %w", err)
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > staleStagingAge {
+			os.RemoveAll(filepath.Join(dir, entry.Name()))
+		}
+	}
+	return dir, filepath.Join(m.sandboxHostPath, stagingDirName), nil
+}
@@ -0,0 +1,61 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// tenantFile is the metadata file naming the token that created a conversation
+const tenantFile = "tenant"
+
+// ClaimTenant binds a conversation to tenant (a token name) if nothing has
+// claimed it yet, and reports the tenant it belongs to. With object storage
+// the stored claim is fetched first, so the check can come before staging
+func (m *Manager) ClaimTenant(ctx context.Context, conversationID, tenant string) (string, error) {
+	if err := m.fetchMetadata(ctx, conversationID, tenantFile); err != nil {
+		return "", fmt.Errorf("failed to fetch tenant: %w", err)
+	}
+	dir := m.GetMetadataDir(conversationID)
+	path := filepath.Join(dir, tenantFile)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	// O_EXCL makes the first claim win when two tokens race
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err == nil {
+		_, err = f.WriteString(tenant)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("failed to record tenant: %w", err)
+		}
+		return tenant, nil
+	}
+	if !errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("failed to record tenant: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tenant: %w", err)
+	}
+	return string(data), nil
+}
+
+// Tenant returns the token name the sandbox in hashedDir belongs to, or ""
+func (m *Manager) Tenant(hashedDir string) string {
+	if !validHashedDir(hashedDir) {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(m.sandboxRoot, metadataDirName, hashedDir, tenantFile))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
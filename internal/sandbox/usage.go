@@ -0,0 +1,112 @@
+package sandbox
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Usage describes one sandbox on disk
+type Usage struct {
+	HashedDir    string    `json:"hashedDir"`
+	DisplayName  string    `json:"displayName,omitempty"` // Set with set_conversation_name
+	SizeBytes    int64     `json:"sizeBytes"`
+	LastModified time.Time `json:"lastModified"` // Newest file or metadata change
+
+	Tenant string `json:"tenant,omitempty"` // Token that created it
+}
+
+// ListSandboxes reports the size and last activity of every sandbox,
+// including its server-side metadata and package cache
+func (m *Manager) ListSandboxes() ([]Usage, error) {
+	entries, err := os.ReadDir(m.sandboxRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sandbox root: %w", err)
+	}
+
+	var sandboxes []Usage
+	for _, entry := range entries {
+		// Skip .metadata and anything else that isn't a hashed directory
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		usage := Usage{HashedDir: entry.Name(), DisplayName: m.DisplayName(entry.Name()), Tenant: m.Tenant(entry.Name())}
+		for _, dir := range []string{
+			filepath.Join(m.sandboxRoot, entry.Name()),
+			filepath.Join(m.sandboxRoot, metadataDirName, entry.Name()),
+			filepath.Join(m.sandboxRoot, packagesDirName, entry.Name()),
+		} {
+			if err := addUsage(&usage, dir); err != nil {
+				return nil, err
+			}
+		}
+		sandboxes = append(sandboxes, usage)
+	}
+	return sandboxes, nil
+}
+
+// addUsage accumulates the size and newest modification time under dir
+func addUsage(usage *Usage, dir string) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		if info.Mode().IsRegular() {
+			usage.SizeBytes += info.Size()
+		}
+		if info.ModTime().After(usage.LastModified) {
+			usage.LastModified = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return nil
+}
+
+// DeleteHashedDir removes a sandbox identified by its hashed directory, along
+// with its metadata and package cache, and its copy in object storage
+func (m *Manager) DeleteHashedDir(hashedDir string) error {
+	if !validHashedDir(hashedDir) {
+		return fmt.Errorf("invalid sandbox directory: %q", hashedDir)
+	}
+	if err := m.deleteRemote(hashedDir); err != nil {
+		return err
+	}
+	return m.EvictHashedDir(hashedDir)
+}
+
+// EvictHashedDir removes the local copy of a sandbox, along with its
+// metadata and package cache, but not its copy in object storage
+func (m *Manager) EvictHashedDir(hashedDir string) error {
+	if !validHashedDir(hashedDir) {
+		return fmt.Errorf("invalid sandbox directory: %q", hashedDir)
+	}
+	for _, dir := range []string{metadataDirName, packagesDirName} {
+		if err := os.RemoveAll(filepath.Join(m.sandboxRoot, dir, hashedDir)); err != nil {
+			return err
+		}
+	}
+	m.forgetSync(hashedDir)
+	return os.RemoveAll(filepath.Join(m.sandboxRoot, hashedDir))
+}
+
+// HashedDirExists reports whether the sandbox in hashedDir exists
+func (m *Manager) HashedDirExists(hashedDir string) bool {
+	if !validHashedDir(hashedDir) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(m.sandboxRoot, hashedDir))
+	return err == nil && info.IsDir()
+}
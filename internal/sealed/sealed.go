@@ -0,0 +1,115 @@
+package sealed
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// info binds derived keys to this format
+const info = "mcp-code-sandbox sealed result v1"
+
+// Overhead is how many bytes Seal adds: the ephemeral public key, the GCM
+// nonce and the GCM tag
+const Overhead = 32 + 12 + 16
+
+// ParsePublicKey decodes a base64 (standard or URL, padded or not) X25519
+// public key
+func ParsePublicKey(encoded string) (*ecdh.PublicKey, error) {
+	encoded = strings.TrimRight(strings.TrimSpace(encoded), "=")
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		if raw, err = base64.RawURLEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("public key is not base64")
+		}
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("public key must be a 32-byte X25519 key")
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext so only the holder of the private key for
+// recipient can read it: an ephemeral X25519 key agreement, HKDF-SHA256 and
+// AES-256-GCM. The result is ephemeral public key || nonce || ciphertext
+func Seal(recipient *ecdh.PublicKey, plaintext []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+
+	ephemeralPub := ephemeral.PublicKey().Bytes()
+	salt := append(append([]byte{}, ephemeralPub...), recipient.Bytes()...)
+	key, err := hkdf.Key(sha256.New, shared, salt, info, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(plaintext)+Overhead)
+	out = append(out, ephemeralPub...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, nil), nil
+}
+
+// SealString seals plaintext and returns it base64 encoded
+func SealString(recipient *ecdh.PublicKey, plaintext []byte) (string, error) {
+	sealed, err := Seal(recipient, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a sealed payload with the recipient's private key. The
+// server never holds private keys; this exists for clients written in Go
+func Open(private *ecdh.PrivateKey, sealed []byte) ([]byte, error) {
+	if len(sealed) < Overhead {
+		return nil, fmt.Errorf("sealed payload too short")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(sealed[:32])
+	if err != nil {
+		return nil, err
+	}
+	shared, err := private.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, sealed[:32]...), private.PublicKey().Bytes()...)
+	key, err := hkdf.Key(sha256.New, shared, salt, info, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := sealed[32 : 32+aead.NonceSize()]
+	return aead.Open(nil, nonce, sealed[32+aead.NonceSize():], nil)
+}
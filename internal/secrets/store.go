@@ -0,0 +1,208 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// namePattern restricts secret names to what callers can type safely
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Secret is a named value the operator registered. Exactly one of Env,
+// File and Vault says where the value comes from
+type Secret struct {
+	Name     string   `yaml:"name"`
+	Variable string   `yaml:"variable"` // Container variable; defaults to Name
+	Env      string   `yaml:"env"`      // Server environment variable
+	File     string   `yaml:"file"`     // File holding the value, e.g. a Docker secret
+	Vault    string   `yaml:"vault"`    // Vault path and field, "secret/data/openai#api_key"
+	Tokens   []string `yaml:"tokens"`   // Token names that may use it; empty for all
+}
+
+// secretsFile is the YAML format of SECRETS_FILE
+type secretsFile struct {
+	Secrets []Secret `yaml:"secrets"`
+}
+
+// Store resolves the secrets registered in SECRETS_FILE. Values are read
+// when a run asks for them, so rotating a file or Vault entry needs no restart
+type Store struct {
+	secrets    map[string]Secret
+	vaultAddr  string
+	vaultToken string
+	client     *http.Client
+}
+
+// Load reads the secrets registered in path. An empty path yields an empty
+// store. vaultAddr and vaultToken are only needed for Vault secrets
+func Load(path, vaultAddr, vaultToken string) (*Store, error) {
+	s := &Store{
+		secrets:    make(map[string]Secret),
+		vaultAddr:  strings.TrimSuffix(vaultAddr, "/"),
+		vaultToken: vaultToken,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	var file secretsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: %w", err)
+	}
+
+	for i, secret := range file.Secrets {
+		if !namePattern.MatchString(secret.Name) {
+			return nil, fmt.Errorf("secret %d: name %q must be letters, digits and underscores", i+1, secret.Name)
+		}
+		if _, ok := s.secrets[secret.Name]; ok {
+			return nil, fmt.Errorf("duplicate secret %q", secret.Name)
+		}
+		sources := 0
+		for _, source := range []string{secret.Env, secret.File, secret.Vault} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return nil, fmt.Errorf("secret %q: set exactly one of env, file and vault", secret.Name)
+		}
+		if secret.Vault != "" {
+			if s.vaultAddr == "" || s.vaultToken == "" {
+				return nil, fmt.Errorf("secret %q: VAULT_ADDR and VAULT_TOKEN are required for vault secrets", secret.Name)
+			}
+			if path, field, ok := strings.Cut(secret.Vault, "#"); !ok || path == "" || field == "" {
+				return nil, fmt.Errorf("secret %q: vault must be \"path#field\"", secret.Name)
+			}
+		}
+		if secret.Variable == "" {
+			secret.Variable = secret.Name
+		}
+		if secret.Variable == "FILE_BASE_URL" || secret.Variable == "PREVIEW_URL" {
+			return nil, fmt.Errorf("secret %q: %s is reserved", secret.Name, secret.Variable)
+		}
+		s.secrets[secret.Name] = secret
+	}
+	return s, nil
+}
+
+// Len returns the number of registered secrets
+func (s *Store) Len() int {
+	return len(s.secrets)
+}
+
+// Names returns the secrets token may use, sorted. An empty token may use all
+func (s *Store) Names(token string) []string {
+	var names []string
+	for name, secret := range s.secrets {
+		if secret.allows(token) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the container variables for the named secrets. An empty
+// token may use every secret. Errors name the secret but never its value
+func (s *Store) Resolve(ctx context.Context, names []string, token string) (map[string]string, error) {
+	env := make(map[string]string, len(names))
+	for _, name := range names {
+		secret, ok := s.secrets[name]
+		if !ok || !secret.allows(token) {
+			return nil, fmt.Errorf("unknown secret %q", name)
+		}
+		value, err := s.value(ctx, secret)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q: %w", name, err)
+		}
+		env[secret.Variable] = value
+	}
+	return env, nil
+}
+
+// allows reports whether token may use the secret
+func (secret Secret) allows(token string) bool {
+	return token == "" || len(secret.Tokens) == 0 || slices.Contains(secret.Tokens, token)
+}
+
+// value reads a secret from its backend
+func (s *Store) value(ctx context.Context, secret Secret) (string, error) {
+	switch {
+	case secret.Env != "":
+		value, ok := os.LookupEnv(secret.Env)
+		if !ok {
+			return "", fmt.Errorf("server variable %s is not set", secret.Env)
+		}
+		return value, nil
+	case secret.File != "":
+		data, err := os.ReadFile(secret.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		// Secret files usually end with a newline the value doesn't include
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return s.vaultValue(ctx, secret.Vault)
+	}
+}
+
+// vaultValue reads field from a Vault KV secret, given as "path#field".
+// Both KV v1 and v2 (whose paths contain "data/") responses are understood
+func (s *Store) vaultValue(ctx context.Context, ref string) (string, error) {
+	path, field, _ := strings.Cut(ref, "#")
+	endpoint := s.vaultAddr + "/v1/" + (&url.URL{Path: strings.TrimPrefix(path, "/")}).EscapedPath()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", s.vaultToken)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse vault response: %w", err)
+	}
+	fields := body.Data
+	if nested, ok := body.Data["data"]; ok {
+		// KV v2 wraps the fields in data.data, next to data.metadata
+		var v2 map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &v2); err == nil {
+			fields = v2
+		}
+	}
+	raw, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret has no field %q", field)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("vault field %q is not a string", field)
+	}
+	return value, nil
+}
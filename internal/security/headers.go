@@ -0,0 +1,92 @@
+package security
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Policy is a set of security headers applied to a class of responses
+type Policy struct {
+	ContentSecurityPolicy string
+	FrameOptions          string
+}
+
+// UIPolicy applies to the web interface. Scripts and styles are inline or
+// loaded from cdnjs; the page itself may not be framed.
+var UIPolicy = Policy{
+	ContentSecurityPolicy: "default-src 'self'; " +
+		"script-src 'self' 'unsafe-inline' https://cdnjs.cloudflare.com; " +
+		"style-src 'self' 'unsafe-inline' https://cdnjs.cloudflare.com; " +
+		"img-src 'self' data: https:; " +
+		"connect-src 'self'; " +
+		"frame-src 'self'; " +
+		"frame-ancestors 'none'; " +
+		"base-uri 'none'; form-action 'self'",
+	FrameOptions: "DENY",
+}
+
+// FilePolicy applies to user-generated files. The CSP sandbox directive gives
+// HTML files an opaque origin with scripts disabled, so a malicious report
+// cannot act on the server's origin. Files may be framed by the UI for previews.
+var FilePolicy = Policy{
+	ContentSecurityPolicy: "default-src 'none'; " +
+		"img-src 'self' data:; " +
+		"media-src 'self'; " +
+		"style-src 'unsafe-inline'; " +
+		"frame-ancestors 'self'; " +
+		"sandbox",
+	FrameOptions: "SAMEORIGIN",
+}
+
+// ReportPolicy applies to HTML files when interactive reports are enabled,
+// such as plotly charts and folium maps. Inline scripts and those from
+// sources run, but like previews the sandbox directive gives the report an
+// opaque origin, and with connect-src and form-action closed it can only
+// reach sources through the scripts, styles, fonts and images it loads
+func ReportPolicy(sources []string) Policy {
+	allowed := strings.Join(sources, " ")
+	return Policy{
+		ContentSecurityPolicy: "default-src 'none'; " +
+			"script-src 'unsafe-inline' 'unsafe-eval' blob: " + allowed + "; " +
+			"style-src 'unsafe-inline' " + allowed + "; " +
+			"img-src 'self' data: blob: " + allowed + "; " +
+			"font-src data: " + allowed + "; " +
+			"media-src 'self'; " +
+			"worker-src blob:; " +
+			"connect-src 'none'; form-action 'none'; base-uri 'none'; " +
+			"frame-ancestors 'self'; " +
+			"sandbox allow-scripts allow-popups allow-downloads",
+		FrameOptions: "SAMEORIGIN",
+	}
+}
+
+// PreviewPolicy applies to proxied web app previews. Scripts run, but the
+// sandbox directive without allow-same-origin gives pages an opaque origin,
+// so sandbox code can't act on the server's origin or other previews
+var PreviewPolicy = Policy{
+	ContentSecurityPolicy: "frame-ancestors 'self'; " +
+		"sandbox allow-scripts allow-forms allow-popups allow-modals allow-downloads",
+	FrameOptions: "SAMEORIGIN",
+}
+
+// APIPolicy applies to JSON endpoints, which should never render as a document
+var APIPolicy = Policy{
+	ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+	FrameOptions:          "DENY",
+}
+
+// Headers creates a middleware that sets the policy's headers on every response
+func Headers(policy Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Content-Security-Policy", policy.ContentSecurityPolicy)
+			h.Set("X-Frame-Options", policy.FrameOptions)
+			h.Set("X-Content-Type-Options", "nosniff")
+			// File URLs are bearer capabilities; never leak them via Referer
+			h.Set("Referrer-Policy", "no-referrer")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
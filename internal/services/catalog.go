@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Definition describes a helper service a conversation can start
+type Definition struct {
+	Name  string
+	Image string
+	Port  int
+
+	// Each receives the service's generated password
+	Env         func(password string) []string
+	Cmd         func(password string) []string
+	HealthCheck func(password string) []string
+	Connection  func(host, password string) map[string]string
+}
+
+// builtin are the services SANDBOX_SERVICES may enable
+var builtin = map[string]Definition{
+	"postgres": {
+		Name:  "postgres",
+		Image: "docker.io/library/postgres:16-alpine",
+		Port:  5432,
+		Env: func(password string) []string {
+			return []string{"POSTGRES_USER=sandbox", "POSTGRES_PASSWORD=" + password, "POSTGRES_DB=sandbox"}
+		},
+		HealthCheck: func(string) []string {
+			return []string{"CMD", "pg_isready", "-U", "sandbox", "-d", "sandbox"}
+		},
+		Connection: func(host, password string) map[string]string {
+			return map[string]string{
+				"DATABASE_URL": fmt.Sprintf("postgres://sandbox:%s@%s:5432/sandbox?sslmode=disable", password, host),
+				"PGHOST":       host,
+				"PGPORT":       "5432",
+				"PGUSER":       "sandbox",
+				"PGPASSWORD":   password,
+				"PGDATABASE":   "sandbox",
+			}
+		},
+	},
+	"redis": {
+		Name:  "redis",
+		Image: "docker.io/library/redis:7-alpine",
+		Port:  6379,
+		Cmd: func(password string) []string {
+			return []string{"redis-server", "--requirepass", password, "--save", "", "--appendonly", "no"}
+		},
+		HealthCheck: func(password string) []string {
+			return []string{"CMD", "redis-cli", "--no-auth-warning", "-a", password, "ping"}
+		},
+		Connection: func(host, password string) map[string]string {
+			return map[string]string{
+				"REDIS_URL":      fmt.Sprintf("redis://:%s@%s:6379/0", password, host),
+				"REDIS_HOST":     host,
+				"REDIS_PORT":     "6379",
+				"REDIS_PASSWORD": password,
+			}
+		},
+	},
+}
+
+// Builtin lists the names of the built-in services
+func Builtin() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+const (
+	// Labels on service containers and networks
+	conversationLabel = "sandbox.service.conversation" // Hashed sandbox directory
+	serviceLabel      = "sandbox.service"              // Service name
+	passwordLabel     = "sandbox.service.password"
+
+	memoryLimit = 256 * 1024 * 1024 // 256MB per service
+	cpuLimit    = 500000000         // 0.5 CPU per service
+	pidsLimit   = 256
+
+	// startTimeout bounds pulling, starting and waiting for a service to be healthy
+	startTimeout = 2 * time.Minute
+)
+
+// Service is a running (or stopped) helper service
+type Service struct {
+	Name        string            `json:"name"`
+	Image       string            `json:"image"`
+	Host        string            `json:"host"`
+	Port        int               `json:"port"`
+	Status      string            `json:"status"`
+	Environment map[string]string `json:"environment,omitempty"` // Connection variables injected into run_code
+}
+
+// Manager starts helper services on per-conversation internal networks.
+// A nil Manager has no services enabled
+type Manager struct {
+	cli     *client.Client
+	enabled map[string]Definition
+	mu      sync.Mutex // Serializes starts and stops
+}
+
+// NewManager creates a service manager allowing the named built-in services.
+// It returns nil when names is empty
+func NewManager(cli *client.Client, names []string) (*Manager, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	enabled := make(map[string]Definition, len(names))
+	for _, name := range names {
+		def, ok := builtin[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown service %q (available: %v)", name, Builtin())
+		}
+		enabled[name] = def
+	}
+	return &Manager{cli: cli, enabled: enabled}, nil
+}
+
+// Enabled reports whether any services can be started
+func (m *Manager) Enabled() bool {
+	return m != nil && len(m.enabled) > 0
+}
+
+// Available lists the services that can be started
+func (m *Manager) Available() []string {
+	if m == nil {
+		return nil
+	}
+	names := make([]string, 0, len(m.enabled))
+	for name := range m.enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NetworkName is the internal network shared by a sandbox's services and runners
+func NetworkName(hashedDir string) string {
+	return "sandbox-svc-" + hashedDir
+}
+
+// containerName is the name of a sandbox's service container
+func containerName(hashedDir, service string) string {
+	return "sandbox-svc-" + hashedDir + "-" + service
+}
+
+// Start starts a service for the sandbox in hashedDir, or returns it if it
+// is already running, once its health check passes
+func (m *Manager) Start(ctx context.Context, hashedDir, name string) (Service, error) {
+	def, ok := m.definition(name)
+	if !ok {
+		return Service{}, fmt.Errorf("service %q is not available (available: %v)", name, m.Available())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	if err := m.ensureNetwork(ctx, hashedDir); err != nil {
+		return Service{}, err
+	}
+
+	id, password, err := m.ensureContainer(ctx, hashedDir, def)
+	if err != nil {
+		return Service{}, err
+	}
+	if err := m.waitHealthy(ctx, id); err != nil {
+		return Service{}, fmt.Errorf("%s did not become healthy: %w", name, err)
+	}
+
+	log.Printf("Service %s running for sandbox %s", name, hashedDir)
+	return Service{
+		Name:        name,
+		Image:       def.Image,
+		Host:        name,
+		Port:        def.Port,
+		Status:      "running",
+		Environment: def.Connection(name, password),
+	}, nil
+}
+
+// Stop removes a sandbox's service, and its network once no services remain
+func (m *Manager) Stop(ctx context.Context, hashedDir, name string) error {
+	if _, ok := m.definition(name); !ok {
+		return fmt.Errorf("service %q is not available (available: %v)", name, m.Available())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.cli.ContainerRemove(ctx, containerName(hashedDir, name), container.RemoveOptions{Force: true, RemoveVolumes: true})
+	if err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to remove %s: %w", name, err)
+	}
+
+	remaining, err := m.containers(ctx, hashedDir)
+	if err != nil {
+		return err
+	}
+	if len(remaining) == 0 {
+		m.removeNetwork(ctx, hashedDir)
+	}
+	log.Printf("Service %s stopped for sandbox %s", name, hashedDir)
+	return nil
+}
+
+// List returns a sandbox's services, with connection variables for the
+// running ones
+func (m *Manager) List(ctx context.Context, hashedDir string) ([]Service, error) {
+	if !m.Enabled() {
+		return nil, nil
+	}
+	found, err := m.containers(ctx, hashedDir)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]Service, 0, len(found))
+	for _, c := range found {
+		name := c.Labels[serviceLabel]
+		def, ok := builtin[name]
+		if !ok {
+			continue
+		}
+		service := Service{
+			Name:   name,
+			Image:  def.Image,
+			Host:   name,
+			Port:   def.Port,
+			Status: c.State,
+		}
+		if c.State == "running" {
+			service.Environment = def.Connection(name, c.Labels[passwordLabel])
+		}
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+// Environment returns the network a sandbox's runners should join and the
+// connection variables of its running services. The network is "" when
+// no services are running
+func (m *Manager) Environment(ctx context.Context, hashedDir string) (string, map[string]string, error) {
+	services, err := m.List(ctx, hashedDir)
+	if err != nil {
+		return "", nil, err
+	}
+	env := make(map[string]string)
+	for _, service := range services {
+		for key, value := range service.Environment {
+			env[key] = value
+		}
+	}
+	if len(env) == 0 {
+		return "", nil, nil
+	}
+	return NetworkName(hashedDir), env, nil
+}
+
+// Teardown removes all of a sandbox's services and its network
+func (m *Manager) Teardown(ctx context.Context, hashedDir string) error {
+	if !m.Enabled() {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found, err := m.containers(ctx, hashedDir)
+	if err != nil {
+		return err
+	}
+	for _, c := range found {
+		if err := m.cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true, RemoveVolumes: true}); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to remove service %s: %w", c.Labels[serviceLabel], err)
+		}
+	}
+	m.removeNetwork(ctx, hashedDir)
+	if len(found) > 0 {
+		log.Printf("Removed %d service(s) for sandbox %s", len(found), hashedDir)
+	}
+	return nil
+}
+
+// RemoveOrphans tears down services whose sandbox no longer exists, e.g.
+// when a sandbox was deleted while the server was down
+func (m *Manager) RemoveOrphans(ctx context.Context, exists func(hashedDir string) bool) {
+	if m == nil {
+		return
+	}
+	found, err := m.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", conversationLabel)),
+	})
+	if err != nil {
+		log.Printf("Failed to list service containers: %v", err)
+		return
+	}
+	seen := make(map[string]bool)
+	for _, c := range found {
+		hashedDir := c.Labels[conversationLabel]
+		if seen[hashedDir] || exists(hashedDir) {
+			continue
+		}
+		seen[hashedDir] = true
+		if err := m.Teardown(ctx, hashedDir); err != nil {
+			log.Printf("Failed to remove orphaned services for %s: %v", hashedDir, err)
+		}
+	}
+}
+
+// definition returns an enabled service's definition
+func (m *Manager) definition(name string) (Definition, bool) {
+	if m == nil {
+		return Definition{}, false
+	}
+	def, ok := m.enabled[name]
+	return def, ok
+}
+
+// containers lists a sandbox's service containers, running or not
+func (m *Manager) containers(ctx context.Context, hashedDir string) ([]container.Summary, error) {
+	found, err := m.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", conversationLabel+"="+hashedDir)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	return found, nil
+}
+
+// ensureNetwork creates a sandbox's internal service network if needed
+func (m *Manager) ensureNetwork(ctx context.Context, hashedDir string) error {
+	name := NetworkName(hashedDir)
+	if _, err := m.cli.NetworkInspect(ctx, name, network.InspectOptions{}); err == nil {
+		return nil
+	} else if !errdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to inspect network %s: %w", name, err)
+	}
+	_, err := m.cli.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver:   "bridge",
+		Internal: true, // Services and runners reach each other, nothing else
+		Labels:   map[string]string{conversationLabel: hashedDir},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create network %s: %w", name, err)
+	}
+	return nil
+}
+
+// removeNetwork removes a sandbox's service network, logging failures
+func (m *Manager) removeNetwork(ctx context.Context, hashedDir string) {
+	if err := m.cli.NetworkRemove(ctx, NetworkName(hashedDir)); err != nil && !errdefs.IsNotFound(err) {
+		log.Printf("Failed to remove network %s: %v", NetworkName(hashedDir), err)
+	}
+}
+
+// ensureContainer starts the service container, creating it if needed, and
+// returns its ID and password
+func (m *Manager) ensureContainer(ctx context.Context, hashedDir string, def Definition) (string, string, error) {
+	name := containerName(hashedDir, def.Name)
+
+	existing, err := m.cli.ContainerInspect(ctx, name)
+	switch {
+	case err == nil:
+		if existing.State == nil || !existing.State.Running {
+			if err := m.cli.ContainerStart(ctx, existing.ID, container.StartOptions{}); err != nil {
+				return "", "", fmt.Errorf("failed to restart %s: %w", def.Name, err)
+			}
+		}
+		return existing.ID, existing.Config.Labels[passwordLabel], nil
+	case !errdefs.IsNotFound(err):
+		return "", "", fmt.Errorf("failed to inspect %s: %w", def.Name, err)
+	}
+
+	if err := m.ensureImage(ctx, def.Image); err != nil {
+		return "", "", err
+	}
+
+	password, err := generatePassword()
+	if err != nil {
+		return "", "", err
+	}
+	config := &container.Config{
+		Image: def.Image,
+		Labels: map[string]string{
+			conversationLabel: hashedDir,
+			serviceLabel:      def.Name,
+			passwordLabel:     password,
+		},
+		Healthcheck: &container.HealthConfig{
+			Test:     def.HealthCheck(password),
+			Interval: time.Second,
+			Timeout:  3 * time.Second,
+			Retries:  60,
+		},
+	}
+	if def.Env != nil {
+		config.Env = def.Env(password)
+	}
+	if def.Cmd != nil {
+		config.Cmd = def.Cmd(password)
+	}
+	pids := int64(pidsLimit)
+	hostConfig := &container.HostConfig{
+		NetworkMode: container.NetworkMode(NetworkName(hashedDir)),
+		Resources: container.Resources{
+			Memory:    memoryLimit,
+			NanoCPUs:  cpuLimit,
+			PidsLimit: &pids,
+		},
+		SecurityOpt: []string{"no-new-privileges"},
+	}
+	networking := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			NetworkName(hashedDir): {Aliases: []string{def.Name}},
+		},
+	}
+
+	resp, err := m.cli.ContainerCreate(ctx, config, hostConfig, networking, nil, name)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create %s: %w", def.Name, err)
+	}
+	if err := m.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		m.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+		return "", "", fmt.Errorf("failed to start %s: %w", def.Name, err)
+	}
+	return resp.ID, password, nil
+}
+
+// waitHealthy polls a container until its health check passes
+func (m *Manager) waitHealthy(ctx context.Context, id string) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		info, err := m.cli.ContainerInspect(ctx, id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.State == nil || !info.State.Running:
+			return fmt.Errorf("container exited")
+		case info.State.Health == nil || info.State.Health.Status == container.Healthy:
+			return nil
+		case info.State.Health.Status == container.Unhealthy:
+			return fmt.Errorf("health check failed")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ensureImage pulls an image unless it is present locally
+func (m *Manager) ensureImage(ctx context.Context, imageName string) error {
+	if _, err := m.cli.ImageInspect(ctx, imageName); err == nil {
+		return nil
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	}
+	log.Printf("Pulling service image %s", imageName)
+	reader, err := m.cli.ImagePull(ctx, imageName, image.PullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull %s: %w", imageName, err)
+	}
+	defer reader.Close()
+	if err := jsonmessage.DisplayJSONMessagesStream(reader, io.Discard, 0, false, nil); err != nil {
+		return fmt.Errorf("failed to pull %s: %w", imageName, err)
+	}
+	return nil
+}
+
+// generatePassword returns a random password for a new service
+func generatePassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,163 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HeaderName is the HTTP header carrying the session ID (MCP Streamable HTTP)
+const HeaderName = "Mcp-Session-Id"
+
+// Session holds per-client state established by initialize
+type Session struct {
+	ID             string
+	ConversationID string // Default conversation for tool calls that omit one
+	CreatedAt      time.Time
+	lastSeen       time.Time
+
+	initialized     atomic.Bool  // The client sent notifications/initialized
+	protocolVersion atomic.Value // string; negotiated by initialize
+}
+
+// SetProtocolVersion records the MCP protocol version negotiated by initialize
+func (s *Session) SetProtocolVersion(version string) {
+	s.protocolVersion.Store(version)
+}
+
+// ProtocolVersion returns the negotiated MCP protocol version, or "" before
+// initialize completes
+func (s *Session) ProtocolVersion() string {
+	version, _ := s.protocolVersion.Load().(string)
+	return version
+}
+
+// MarkInitialized records that the client finished the initialize handshake
+func (s *Session) MarkInitialized() {
+	s.initialized.Store(true)
+}
+
+// Initialized reports whether the client finished the initialize handshake
+func (s *Session) Initialized() bool {
+	return s.initialized.Load()
+}
+
+// Store manages active sessions in memory
+type Store struct {
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	idleTTL     time.Duration
+	maxSessions int // 0 for no limit
+}
+
+// NewStore creates a session store; sessions idle longer than idleTTL expire.
+// At most maxSessions (0 for no limit) are kept: every initialize creates
+// one, so without a limit clients could grow the store without bound
+func NewStore(idleTTL time.Duration, maxSessions int) *Store {
+	if idleTTL == 0 {
+		idleTTL = 24 * time.Hour
+	}
+	return &Store{
+		sessions:    make(map[string]*Session),
+		idleTTL:     idleTTL,
+		maxSessions: maxSessions,
+	}
+}
+
+// Create starts a new session with a random ID. The session's default
+// conversation ID is the session ID itself. When the store is full, the
+// session idle longest is evicted; its client gets 404 and re-initializes
+func (s *Store) Create() *Session {
+	id := newID()
+	now := time.Now()
+	sess := &Session{
+		ID:             id,
+		ConversationID: id,
+		CreatedAt:      now,
+		lastSeen:       now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(now)
+	if s.maxSessions > 0 && len(s.sessions) >= s.maxSessions {
+		s.evictIdlestLocked()
+	}
+	s.sessions[id] = sess
+	return sess
+}
+
+// Get returns an active session and refreshes its idle timer
+func (s *Store) Get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if now.Sub(sess.lastSeen) > s.idleTTL {
+		delete(s.sessions, id)
+		return nil, false
+	}
+	sess.lastSeen = now
+	return sess, true
+}
+
+// Delete terminates a session, reporting whether it existed
+func (s *Store) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.sessions[id]
+	delete(s.sessions, id)
+	return ok
+}
+
+// sweepLocked removes expired sessions; caller must hold s.mu
+func (s *Store) sweepLocked(now time.Time) {
+	for id, sess := range s.sessions {
+		if now.Sub(sess.lastSeen) > s.idleTTL {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// evictIdlestLocked removes the session idle longest; caller must hold s.mu
+func (s *Store) evictIdlestLocked() {
+	var idlest *Session
+	for _, sess := range s.sessions {
+		if idlest == nil || sess.lastSeen.Before(idlest.lastSeen) {
+			idlest = sess
+		}
+	}
+	if idlest != nil {
+		log.Printf("[HTTP] Session limit (%d) reached, evicting idle session %s", s.maxSessions, idlest.ID)
+		delete(s.sessions, idlest.ID)
+	}
+}
+
+// newID returns a cryptographically random session ID (visible ASCII per spec)
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type contextKey struct{}
+
+// WithSession returns a context carrying the session
+func WithSession(ctx context.Context, sess *Session) context.Context {
+	return context.WithValue(ctx, contextKey{}, sess)
+}
+
+// FromContext returns the session carried by ctx, if any
+func FromContext(ctx context.Context) (*Session, bool) {
+	sess, ok := ctx.Value(contextKey{}).(*Session)
+	return sess, ok
+}
@@ -0,0 +1,32 @@
+// Package storage keeps sandbox files in object storage, so server instances
+// can share conversations instead of each owning its sandbox root
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned for objects that don't exist
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored object
+type Object struct {
+	Key  string
+	Size int64
+	// ETag is the hex MD5 of the content for objects written with Put
+	ETag string
+}
+
+// Backend is an object store holding sandbox files by key
+type Backend interface {
+	// List returns every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// Get copies an object's content to w
+	Get(ctx context.Context, key string, w io.Writer) error
+	// Put stores size bytes from r under key; contentMD5 is their MD5
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentMD5 []byte) error
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
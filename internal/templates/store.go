@@ -0,0 +1,160 @@
+package templates
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestName is the optional per-template settings file
+const manifestName = "template.yaml"
+
+// filesDir holds a template's seed files
+const filesDir = "files"
+
+// archiveNames are the accepted names of a template's seed archive, which is
+// unpacked into the sandbox as an alternative (or addition) to files/
+var archiveNames = []string{"files.zip", "files.tar", "files.tar.gz", "files.tgz"}
+
+// Template is a named starting point for a conversation's sandbox
+type Template struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Files       []string          `json:"files"`             // Slash-separated paths under files/
+	Archive     string            `json:"archive,omitempty"` // Seed archive in the template directory
+	Environment map[string]string `json:"-"`                 // Presets may be secrets; never listed
+	dir         string
+}
+
+// manifest is the on-disk format of template.yaml
+type manifest struct {
+	Description string            `yaml:"description"`
+	Environment map[string]string `yaml:"environment"`
+}
+
+// Store holds the templates found in a directory, one subdirectory each:
+//
+//	<dir>/<name>/template.yaml   optional description and environment presets
+//	<dir>/<name>/files/...       seed files and directories copied into the sandbox
+//	<dir>/<name>/files.tar.gz    seed archive unpacked into the sandbox (or
+//	                             files.zip, files.tar, files.tgz)
+type Store struct {
+	templates map[string]*Template
+}
+
+// Load reads all templates in dir. An empty dir yields an empty store
+func Load(dir string) (*Store, error) {
+	s := &Store{templates: make(map[string]*Template)}
+	if dir == "" {
+		return s, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		t, err := load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", entry.Name(), err)
+		}
+		s.templates[t.Name] = t
+	}
+	return s, nil
+}
+
+// load reads one template directory
+func load(dir string) (*Template, error) {
+	t := &Template{Name: filepath.Base(dir), dir: dir, Files: []string{}}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		var m manifest
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", manifestName, err)
+		}
+		t.Description = m.Description
+		t.Environment = m.Environment
+	}
+
+	root := filepath.Join(dir, filesDir)
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if !d.Type().IsRegular() {
+			log.Printf("Template %s: skipping %s (not a regular file)", t.Name, rel)
+			return nil
+		}
+		t.Files = append(t.Files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range archiveNames {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if t.Archive != "" {
+			return nil, fmt.Errorf("both %s and %s exist", t.Archive, name)
+		}
+		t.Archive = name
+	}
+
+	if len(t.Files) == 0 && t.Archive == "" && len(t.Environment) == 0 {
+		return nil, fmt.Errorf("no %s/, seed archive or environment presets", filesDir)
+	}
+	return t, nil
+}
+
+// Get returns a template by name
+func (s *Store) Get(name string) (*Template, bool) {
+	t, ok := s.templates[name]
+	return t, ok
+}
+
+// List returns all templates sorted by name
+func (s *Store) List() []*Template {
+	list := make([]*Template, 0, len(s.templates))
+	for _, t := range s.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// ReadFile reads one of the template's seed files; contents are read on
+// demand so edits take effect without a restart
+func (t *Template) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(t.dir, filesDir, filepath.FromSlash(name)))
+}
+
+// ReadArchive reads the template's seed archive, on demand like ReadFile
+func (t *Template) ReadArchive() ([]byte, error) {
+	if t.Archive == "" {
+		return nil, fmt.Errorf("template %s has no seed archive", t.Name)
+	}
+	return os.ReadFile(filepath.Join(t.dir, t.Archive))
+}
@@ -0,0 +1,144 @@
+package testreport
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Report summarizes a test run
+type Report struct {
+	Passed   int
+	Failed   int // Assertions that failed
+	Errors   int // Tests or files that couldn't run, e.g. import errors
+	Skipped  int
+	Failures []Failure // Failed and errored tests, in report order
+}
+
+// Total is the number of tests in the report
+func (r *Report) Total() int {
+	return r.Passed + r.Failed + r.Errors + r.Skipped
+}
+
+// Failure describes a failed or errored test
+type Failure struct {
+	Name    string // Test ID, e.g. tests.test_parse::test_empty
+	File    string // Test file relative to /data, when the framework says
+	Message string // One-line summary, e.g. the assertion
+	Details string // Traceback or diff
+	Error   bool   // The test errored rather than failed
+}
+
+type junitSuite struct {
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *struct{}     `xml:"skipped"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit reads a JUnit XML report, as written by pytest --junitxml and
+// bun test --reporter=junit. The root may be <testsuites> or <testsuite>
+func ParseJUnit(data []byte) (*Report, error) {
+	var root junitSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JUnit report: %w", err)
+	}
+	report := &Report{}
+	report.addSuite(root)
+	return report, nil
+}
+
+func (r *Report) addSuite(suite junitSuite) {
+	for _, c := range suite.Cases {
+		name := c.Name
+		if c.ClassName != "" {
+			name = c.ClassName + "::" + c.Name
+		}
+		switch {
+		case c.Failure != nil:
+			r.Failed++
+			r.Failures = append(r.Failures, Failure{Name: name, File: relative(c.File), Message: c.Failure.Message, Details: strings.TrimSpace(c.Failure.Text)})
+		case c.Error != nil:
+			r.Errors++
+			r.Failures = append(r.Failures, Failure{Name: name, File: relative(c.File), Message: c.Error.Message, Details: strings.TrimSpace(c.Error.Text), Error: true})
+		case c.Skipped != nil:
+			r.Skipped++
+		default:
+			r.Passed++
+		}
+	}
+	for _, s := range suite.Suites {
+		r.addSuite(s)
+	}
+}
+
+type jestReport struct {
+	TestResults []struct {
+		Name             string `json:"name"`
+		Message          string `json:"message"`
+		AssertionResults []struct {
+			FullName        string   `json:"fullName"`
+			Status          string   `json:"status"`
+			FailureMessages []string `json:"failureMessages"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+// ParseJest reads the report jest --json writes. Test files that failed to
+// run at all count as one error each
+func ParseJest(data []byte) (*Report, error) {
+	var jest jestReport
+	if err := json.Unmarshal(data, &jest); err != nil {
+		return nil, fmt.Errorf("invalid jest report: %w", err)
+	}
+	report := &Report{}
+	for _, file := range jest.TestResults {
+		name := relative(file.Name)
+		if len(file.AssertionResults) == 0 && file.Message != "" {
+			report.Errors++
+			report.Failures = append(report.Failures, Failure{Name: name, File: name, Message: firstLine(file.Message), Details: file.Message, Error: true})
+			continue
+		}
+		for _, test := range file.AssertionResults {
+			switch test.Status {
+			case "passed":
+				report.Passed++
+			case "failed":
+				details := strings.Join(test.FailureMessages, "\n\n")
+				report.Failed++
+				report.Failures = append(report.Failures, Failure{Name: test.FullName, File: name, Message: firstLine(details), Details: details})
+			default: // pending, skipped, todo, disabled
+				report.Skipped++
+			}
+		}
+	}
+	return report, nil
+}
+
+// relative strips the sandbox mount point from paths in reports
+func relative(path string) string {
+	return strings.TrimPrefix(path, "/data/")
+}
+
+// firstLine returns the first non-blank line of text
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
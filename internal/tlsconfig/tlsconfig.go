@@ -0,0 +1,117 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// FromFiles serves the certificate in certFile and keyFile, reloading them
+// when certFile changes so a renewed certificate needs no restart
+func FromFiles(certFile, keyFile string) (*tls.Config, error) {
+	r := &reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.getCertificate,
+	}, nil
+}
+
+// reloader holds the current key pair loaded from disk
+type reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// load reads the key pair from disk
+func (r *reloader) load() error {
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = info.ModTime()
+	return nil
+}
+
+// getCertificate returns the key pair, checking the certificate file for
+// changes at most every 10 seconds. A pair that fails to load is logged and
+// the previous one kept
+func (r *reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	due := time.Since(r.checked) > 10*time.Second
+	if due {
+		r.checked = time.Now()
+	}
+	modTime := r.modTime
+	r.mu.Unlock()
+
+	if due {
+		if info, err := os.Stat(r.certFile); err == nil && !info.ModTime().Equal(modTime) {
+			if err := r.load(); err != nil {
+				log.Printf("Failed to reload TLS certificate, keeping the previous one: %v", err)
+			} else {
+				log.Printf("Reloaded TLS certificate from %s", r.certFile)
+			}
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Autocert obtains and renews certificates for host from Let's Encrypt,
+// caching them in cacheDir. Challenges are answered over TLS-ALPN on the
+// HTTPS port, and over HTTP-01 by the manager's HTTPHandler when a plain
+// HTTP listener is run
+func Autocert(host, email, cacheDir string) (*tls.Config, *autocert.Manager, error) {
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate cache: %w", err)
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(host),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      email,
+	}
+	config := manager.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+	return config, manager, nil
+}
+
+// Redirect sends plain HTTP requests to the same URL over HTTPS on
+// httpsAddr's port
+func Redirect(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
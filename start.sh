@@ -51,7 +51,7 @@ for var in "${REQUIRED_VARS[@]}"; do
 done
 
 # Set defaults for optional variables
-export MCP_HTTP_ADDR=${MCP_HTTP_ADDR:-:8080}
+export MCP_HTTP_ADDR=${MCP_HTTP_ADDR:-127.0.0.1:8080}
 export SANDBOX_HOST_PATH=${SANDBOX_HOST_PATH:-$SANDBOX_ROOT}
 
 # Display configuration